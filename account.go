@@ -60,7 +60,7 @@ func NewUserPage(app *App, r *http.Request, u *User, title string, flashes []str
 	up.Flashes = flashes
 	up.Path = r.URL.Path
 	up.IsAdmin = u.IsAdmin()
-	up.CanInvite = canUserInvite(app.cfg, up.IsAdmin)
+	up.CanInvite = canUserInvite(app.Config(), up.IsAdmin)
 	return up
 }
 
@@ -122,12 +122,26 @@ func signup(app *App, w http.ResponseWriter, r *http.Request) (*AuthUser, error)
 func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWriter, r *http.Request) (*AuthUser, error) {
 	reqJSON := IsJSON(r)
 
+	switch app.Config().App.Registration() {
+	case config.RegistrationClosed:
+		return nil, ErrSignupClosed
+	case config.RegistrationInvite:
+		if signup.InviteCode == "" {
+			return nil, ErrInviteRequired
+		}
+	}
+	if signup.InviteCode != "" {
+		if err := checkInvite(app, signup.InviteCode); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate required params (alias)
 	if signup.Alias == "" {
 		return nil, impart.HTTPError{http.StatusBadRequest, "A username is required."}
 	}
-	if signup.Pass == "" {
-		return nil, impart.HTTPError{http.StatusBadRequest, "A password is required."}
+	if err := app.Config().App.ValidatePassword(signup.Pass); err != nil {
+		return nil, impart.HTTPError{http.StatusBadRequest, err.Error()}
 	}
 	var desiredUsername string
 	if signup.Normalize {
@@ -138,7 +152,7 @@ func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWr
 		desiredUsername = signup.Alias
 		signup.Alias = getSlug(signup.Alias, "")
 	}
-	if !author.IsValidUsername(app.cfg, signup.Alias) {
+	if !author.IsValidUsername(app.Config(), signup.Alias) {
 		// Ensure the username is syntactically correct.
 		return nil, impart.HTTPError{http.StatusPreconditionFailed, "Username is reserved or isn't valid. It must be at least 3 characters long, and can only include letters, numbers, and hyphens."}
 	}
@@ -146,7 +160,7 @@ func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWr
 	// Handle empty optional params
 	// TODO: remove this var
 	createdWithPass := true
-	hashedPass, err := auth.HashPass([]byte(signup.Pass))
+	hashedPass, err := hashPassword(app.Config(), []byte(signup.Pass))
 	if err != nil {
 		return nil, impart.HTTPError{http.StatusInternalServerError, "Could not create password hash."}
 	}
@@ -159,6 +173,9 @@ func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWr
 		Email:      zero.NewString("", signup.Email != ""),
 		Created:    time.Now().Truncate(time.Second).UTC(),
 	}
+	if app.Config().App.RequireApproval {
+		u.Status = UserPending
+	}
 	if signup.Email != "" {
 		encEmail, err := data.Encrypt(app.keys.EmailKey, signup.Email)
 		if err != nil {
@@ -169,7 +186,7 @@ func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWr
 	}
 
 	// Create actual user
-	if err := app.db.CreateUser(app.cfg, u, desiredUsername); err != nil {
+	if err := app.db.CreateUser(app.Config(), u, desiredUsername); err != nil {
 		return nil, err
 	}
 
@@ -208,14 +225,17 @@ func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWr
 	}
 
 	var token string
-	if reqJSON && !signup.Web {
-		token, err = app.db.GetAccessToken(u.ID)
+	if u.IsPending() {
+		// Pending users can't log in yet, so they don't get a token or session
+		log.Info("Signup: %s is awaiting approval", u.Username)
+	} else if reqJSON && !signup.Web {
+		token, err = issueAccessToken(app, u.ID)
 		if err != nil {
 			return nil, impart.HTTPError{http.StatusInternalServerError, "Could not create access token. Try re-authenticating."}
 		}
 		resUser.AccessToken = token
 	} else {
-		session, err := app.sessionStore.Get(r, cookieName)
+		session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 		if err != nil {
 			// The cookie should still save, even if there's an error.
 			// Source: https://github.com/gorilla/sessions/issues/16#issuecomment-143642144
@@ -236,7 +256,7 @@ func signupWithRegistration(app *App, signup userRegistration, w http.ResponseWr
 }
 
 func viewLogout(app *App, w http.ResponseWriter, r *http.Request) error {
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err != nil {
 		return ErrInternalCookieSession
 	}
@@ -290,6 +310,56 @@ func handleAPILogout(app *App, w http.ResponseWriter, r *http.Request) error {
 	return impart.HTTPError{Status: http.StatusNoContent}
 }
 
+// issueAccessToken creates a new API access token for the given user, which
+// expires after the configured api_token_ttl, if any.
+func issueAccessToken(app *App, userID int64) (string, error) {
+	ttl := app.Config().App.APITokenDuration()
+	if ttl <= 0 {
+		return app.db.GetAccessToken(userID)
+	}
+	secs := int(ttl / time.Second)
+	if ttl%time.Second != 0 {
+		secs++
+	}
+	return app.db.GetTemporaryAccessToken(userID, secs)
+}
+
+// handleAPIRotateToken replaces the access token the request was made with
+// by a new one, so clients can keep working without logging in again before
+// their token expires. The old token stops working right away.
+func handleAPIRotateToken(app *App, w http.ResponseWriter, r *http.Request) error {
+	accessToken := r.Header.Get("Authorization")
+	if accessToken == "" {
+		return ErrNoAccessToken
+	}
+	t := auth.GetToken(accessToken)
+	if len(t) == 0 {
+		return ErrNoAccessToken
+	}
+	userID := app.db.GetUserID(accessToken)
+	if userID == -1 {
+		return ErrBadAccessToken
+	}
+	if err := app.db.DeleteToken(t); err != nil {
+		if err, ok := err.(impart.HTTPError); !ok || err.Status != http.StatusNotFound {
+			log.Error("Rotate token: Unable to delete old token: %v", err)
+			return ErrInternalGeneral
+		}
+		// One-time tokens are already deleted once they're used
+	}
+
+	u, err := app.db.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	token, err := issueAccessToken(app, userID)
+	if err != nil {
+		log.Error("Rotate token: Unable to create access token: %v", err)
+		return impart.HTTPError{http.StatusInternalServerError, "Could not create access token. Try re-authenticating."}
+	}
+	return impart.WriteSuccess(w, getVerboseAuthUser(app, token, u, false), http.StatusOK)
+}
+
 func viewLogin(app *App, w http.ResponseWriter, r *http.Request) error {
 	var earlyError string
 	oneTimeToken := r.FormValue("with")
@@ -302,7 +372,7 @@ func viewLogin(app *App, w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err != nil {
 		// Ignore this
 		log.Error("Unable to get session; ignoring: %v", err)
@@ -314,12 +384,16 @@ func viewLogin(app *App, w http.ResponseWriter, r *http.Request) error {
 		Message       template.HTML
 		Flashes       []template.HTML
 		LoginUsername string
+		OAuthEnabled  bool
+		OAuthProvider string
 	}{
 		pageForReq(app, r),
 		r.FormValue("to"),
 		template.HTML(""),
 		[]template.HTML{},
 		getTempInfo(app, "login-user", r, w),
+		app.Config().OAuth.Enabled(),
+		app.Config().OAuth.Name(),
 	}
 
 	if earlyError != "" {
@@ -345,7 +419,7 @@ func webLogin(app *App, w http.ResponseWriter, r *http.Request) error {
 		username := r.FormValue("alias")
 		// Login request was unsuccessful; save the error in the session and redirect them
 		if err, ok := err.(impart.HTTPError); ok {
-			session, _ := app.sessionStore.Get(r, cookieName)
+			session, _ := app.sessionStore.Get(r, app.Config().Session.Name())
 			if session != nil {
 				session.AddFlash(err.Message)
 				session.Save(r, w)
@@ -383,7 +457,7 @@ func login(app *App, w http.ResponseWriter, r *http.Request) error {
 
 	redirectTo := r.FormValue("to")
 	if redirectTo == "" {
-		if app.cfg.App.SingleUser {
+		if app.Config().App.SingleUser {
 			redirectTo = "/me/new"
 		} else {
 			redirectTo = "/"
@@ -455,7 +529,7 @@ func login(app *App, w http.ResponseWriter, r *http.Request) error {
 
 		// Prevent excessive login attempts on the same account
 		// Skip this check in dev environment
-		if !app.cfg.Server.Dev {
+		if !app.Config().Server.Dev {
 			now := time.Now()
 			attemptExp, att := loginAttemptUsers.LoadOrStore(signin.Alias, now.Add(loginAttemptExpiration))
 			if att {
@@ -496,6 +570,10 @@ func login(app *App, w http.ResponseWriter, r *http.Request) error {
 			return impart.HTTPError{http.StatusUnauthorized, "Incorrect password."}
 		}
 	}
+	if err = checkLoginAllowed(u); err != nil {
+		log.Info("Login: %s isn't allowed to log in: %v", u.Username, err)
+		return err
+	}
 
 	if reqJSON && !signin.Web {
 		var token string
@@ -503,10 +581,10 @@ func login(app *App, w http.ResponseWriter, r *http.Request) error {
 			// Get last created token when User-Agent is empty
 			token = app.db.FetchLastAccessToken(u.ID)
 			if token == "" {
-				token, err = app.db.GetAccessToken(u.ID)
+				token, err = issueAccessToken(app, u.ID)
 			}
 		} else {
-			token, err = app.db.GetAccessToken(u.ID)
+			token, err = issueAccessToken(app, u.ID)
 		}
 		if err != nil {
 			log.Error("Login: Unable to create access token: %v", err)
@@ -516,7 +594,7 @@ func login(app *App, w http.ResponseWriter, r *http.Request) error {
 		return impart.WriteSuccess(w, resUser, http.StatusOK)
 	}
 
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err != nil {
 		// The cookie should still save, even if there's an error.
 		log.Error("Login: Session: %v; ignoring", err)
@@ -540,6 +618,15 @@ func login(app *App, w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// checkLoginAllowed returns an error if the given authenticated user still
+// can't log in.
+func checkLoginAllowed(u *User) error {
+	if u.IsPending() {
+		return ErrUserPending
+	}
+	return nil
+}
+
 func getVerboseAuthUser(app *App, token string, u *User, verbose bool) *AuthUser {
 	resUser := &AuthUser{
 		AccessToken: token,
@@ -552,7 +639,7 @@ func getVerboseAuthUser(app *App, token string, u *User, verbose bool) *AuthUser
 		if err != nil {
 			log.Error("Login: Unable to get user posts: %v", err)
 		}
-		colls, err := app.db.GetCollections(u, app.cfg.App.Host)
+		colls, err := app.db.GetCollections(u, app.Config().App.Host)
 		if err != nil {
 			log.Error("Login: Unable to get user collections: %v", err)
 		}
@@ -600,7 +687,7 @@ func viewExportPosts(app *App, w http.ResponseWriter, r *http.Request) ([]byte,
 		}
 	} else {
 		// Use user cookie
-		session, err := app.sessionStore.Get(r, cookieName)
+		session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 		if err != nil {
 			// The cookie should still save, even if there's an error.
 			log.Error("Session: %v; ignoring", err)
@@ -625,7 +712,7 @@ func viewExportPosts(app *App, w http.ResponseWriter, r *http.Request) ([]byte,
 
 	// Export as CSV
 	if strings.HasSuffix(r.URL.Path, ".csv") {
-		data = exportPostsCSV(app.cfg.App.Host, u, posts)
+		data = exportPostsCSV(app.Config().App.Host, u, posts)
 		return data, filename, err
 	}
 	if strings.HasSuffix(r.URL.Path, ".zip") {
@@ -722,7 +809,7 @@ func viewMyCollectionsAPI(app *App, u *User, w http.ResponseWriter, r *http.Requ
 		return ErrBadRequestedType
 	}
 
-	p, err := app.db.GetCollections(u, app.cfg.App.Host)
+	p, err := app.db.GetCollections(u, app.Config().App.Host)
 	if err != nil {
 		return err
 	}
@@ -745,7 +832,7 @@ func viewArticles(app *App, u *User, w http.ResponseWriter, r *http.Request) err
 		log.Error("unable to fetch flashes: %v", err)
 	}
 
-	c, err := app.db.GetPublishableCollections(u, app.cfg.App.Host)
+	c, err := app.db.GetPublishableCollections(u, app.Config().App.Host)
 	if err != nil {
 		log.Error("unable to fetch collections: %v", err)
 	}
@@ -774,7 +861,7 @@ func viewArticles(app *App, u *User, w http.ResponseWriter, r *http.Request) err
 }
 
 func viewCollections(app *App, u *User, w http.ResponseWriter, r *http.Request) error {
-	c, err := app.db.GetCollections(u, app.cfg.App.Host)
+	c, err := app.db.GetCollections(u, app.Config().App.Host)
 	if err != nil {
 		log.Error("unable to fetch collections: %v", err)
 		return fmt.Errorf("No collections")
@@ -802,7 +889,7 @@ func viewCollections(app *App, u *User, w http.ResponseWriter, r *http.Request)
 		UserPage:         NewUserPage(app, r, u, u.Username+"'s Blogs", f),
 		Collections:      c,
 		UsedCollections:  int(uc),
-		NewBlogsDisabled: !app.cfg.App.CanCreateBlogs(uc),
+		NewBlogsDisabled: !app.Config().App.CanCreateBlogs(uc),
 		Suspended:        suspended,
 	}
 	d.UserPage.SetMessaging(u)
@@ -942,6 +1029,9 @@ func updatePassphrase(app *App, w http.ResponseWriter, r *http.Request) error {
 	if newPass == "" {
 		return impart.HTTPError{http.StatusBadRequest, "Provide a new password."}
 	}
+	if err := app.Config().App.ValidatePassword(newPass); err != nil {
+		return impart.HTTPError{http.StatusBadRequest, err.Error()}
+	}
 
 	userID, sudo := app.db.GetUserIDPrivilege(accessToken)
 	if userID == -1 {
@@ -955,7 +1045,7 @@ func updatePassphrase(app *App, w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Hash the new password
-	hashedPass, err := auth.HashPass([]byte(newPass))
+	hashedPass, err := hashPassword(app.Config(), []byte(newPass))
 	if err != nil {
 		return impart.HTTPError{http.StatusInternalServerError, "Could not create password hash."}
 	}
@@ -1015,7 +1105,7 @@ func viewStats(app *App, u *User, w http.ResponseWriter, r *http.Request) error
 		TopPosts:   topPosts,
 		Suspended:  suspended,
 	}
-	if app.cfg.App.Federation {
+	if app.Config().App.Federation {
 		folls, err := app.db.GetAPFollowers(c)
 		if err != nil {
 			return err
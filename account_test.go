@@ -0,0 +1,174 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/writeas/impart"
+	"github.com/writeas/writefreely/config"
+)
+
+// tokenNow is the time that newTokenDB expires access tokens as of.
+var tokenNow = time.Now
+
+var tokenTTLReg = regexp.MustCompile(`INTERVAL (\d+) second`)
+
+// newTokenDB returns a fake database that keeps access tokens for user 1,
+// expiring them like the accesstokens table does, as of tokenNow.
+func newTokenDB() *fakeDB {
+	var mu sync.Mutex
+	expires := map[string]time.Time{}
+	return &fakeDB{
+		Exec: func(query string, args []driver.Value) (driver.Result, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case strings.HasPrefix(query, "INSERT INTO accesstokens"):
+				var exp time.Time
+				if m := tokenTTLReg.FindStringSubmatch(query); m != nil {
+					secs, _ := strconv.Atoi(m[1])
+					exp = tokenNow().Add(time.Duration(secs) * time.Second)
+				}
+				expires[tokenKey(args[0])] = exp
+				return driver.RowsAffected(1), nil
+			case strings.HasPrefix(query, "DELETE FROM accesstokens"):
+				if _, ok := expires[tokenKey(args[0])]; !ok {
+					return driver.RowsAffected(0), nil
+				}
+				delete(expires, tokenKey(args[0]))
+				return driver.RowsAffected(1), nil
+			}
+			return nil, errors.New("not supported")
+		},
+		Query: func(query string, args []driver.Value) (driver.Rows, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case strings.HasPrefix(query, "SELECT user_id, sudo, one_time FROM accesstokens"):
+				exp, ok := expires[tokenKey(args[0])]
+				if !ok || (!exp.IsZero() && !exp.After(tokenNow())) {
+					return &fakeRows{cols: []string{"user_id", "sudo", "one_time"}}, nil
+				}
+				return &fakeRows{cols: []string{"user_id", "sudo", "one_time"}, vals: []driver.Value{int64(1), false, false}}, nil
+			case strings.HasPrefix(query, "SELECT username, password, email, created, status FROM users"):
+				return &fakeRows{cols: []string{"username", "password", "email", "created", "status"}, vals: []driver.Value{"matt", []byte{}, nil, time.Now(), int64(0)}}, nil
+			}
+			return nil, errors.New("not supported")
+		},
+	}
+}
+
+func tokenKey(v driver.Value) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v.(string)
+}
+
+func TestAPITokenTTL(t *testing.T) {
+	cfg := config.New()
+	cfg.App.APITokenTTL = "1h"
+	app := newTestApp(cfg)
+	app.db = &datastore{DB: newTokenDB().open(t), driverName: "wffake"}
+	defer func() { tokenNow = time.Now }()
+
+	authed := func(token string) error {
+		r := httptest.NewRequest("GET", "/api/me", nil)
+		r.Header.Set("Authorization", token)
+		_, err := apiAuth(app, r)
+		return err
+	}
+
+	token, err := issueAccessToken(app, 1)
+	if err != nil {
+		t.Fatalf("issueAccessToken failed: %v", err)
+	}
+	if err = authed(token); err != nil {
+		t.Errorf("Valid token was rejected: %v", err)
+	}
+
+	// An hour later, the token has expired
+	start := time.Now()
+	tokenNow = func() time.Time { return start.Add(time.Hour + time.Second) }
+	err = authed(token)
+	if herr, ok := err.(impart.HTTPError); !ok || herr.Status != http.StatusUnauthorized {
+		t.Errorf("Expired token returned %v; expected a 401", err)
+	}
+
+	// Tokens don't expire without a TTL
+	cfg.App.APITokenTTL = ""
+	tokenNow = func() time.Time { return start }
+	forever, err := issueAccessToken(app, 1)
+	if err != nil {
+		t.Fatalf("issueAccessToken failed: %v", err)
+	}
+	tokenNow = func() time.Time { return start.AddDate(10, 0, 0) }
+	if err = authed(forever); err != nil {
+		t.Errorf("Token without a TTL was rejected: %v", err)
+	}
+}
+
+func TestRotateAPIToken(t *testing.T) {
+	cfg := config.New()
+	cfg.App.APITokenTTL = "24h"
+	app := newTestApp(cfg)
+	app.db = &datastore{DB: newTokenDB().open(t), driverName: "wffake"}
+
+	old, err := issueAccessToken(app, 1)
+	if err != nil {
+		t.Fatalf("issueAccessToken failed: %v", err)
+	}
+	r := httptest.NewRequest("POST", "/api/auth/me/rotate", nil)
+	r.Header.Set("Authorization", old)
+	w := httptest.NewRecorder()
+	if err = handleAPIRotateToken(app, w, r); err != nil {
+		t.Fatalf("Rotating a valid token failed: %v", err)
+	}
+	var res struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Unable to decode response: %v", err)
+	}
+	if res.Data.AccessToken == "" || res.Data.AccessToken == old {
+		t.Fatalf("Rotated token = %q; expected a new token", res.Data.AccessToken)
+	}
+
+	r = httptest.NewRequest("GET", "/api/me", nil)
+	r.Header.Set("Authorization", res.Data.AccessToken)
+	if _, err = apiAuth(app, r); err != nil {
+		t.Errorf("New token was rejected: %v", err)
+	}
+	r.Header.Set("Authorization", old)
+	if _, err = apiAuth(app, r); err != ErrBadAccessToken {
+		t.Errorf("Old token returned %v; expected %v", err, ErrBadAccessToken)
+	}
+
+	// The old token can't be rotated again
+	r = httptest.NewRequest("POST", "/api/auth/me/rotate", nil)
+	r.Header.Set("Authorization", old)
+	if err = handleAPIRotateToken(app, httptest.NewRecorder(), r); err != ErrBadAccessToken {
+		t.Errorf("Rotating the old token returned %v; expected %v", err, ErrBadAccessToken)
+	}
+}
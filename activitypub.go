@@ -21,7 +21,9 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -72,7 +74,7 @@ func handleFetchCollectionActivities(app *App, w http.ResponseWriter, r *http.Re
 	// Get base Collection data
 	var c *Collection
 	var err error
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(alias)
@@ -88,7 +90,7 @@ func handleFetchCollectionActivities(app *App, w http.ResponseWriter, r *http.Re
 	if suspended {
 		return ErrCollectionNotFound
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	p := c.PersonObject()
 
@@ -105,7 +107,7 @@ func handleFetchCollectionOutbox(app *App, w http.ResponseWriter, r *http.Reques
 	// Get base Collection data
 	var c *Collection
 	var err error
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(alias)
@@ -121,9 +123,9 @@ func handleFetchCollectionOutbox(app *App, w http.ResponseWriter, r *http.Reques
 	if suspended {
 		return ErrCollectionNotFound
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		if alias != c.Alias {
 			return ErrCollectionNotFound
 		}
@@ -145,10 +147,10 @@ func handleFetchCollectionOutbox(app *App, w http.ResponseWriter, r *http.Reques
 	ocp := activitystreams.NewOrderedCollectionPage(accountRoot, "outbox", res.TotalPosts, p)
 	ocp.OrderedItems = []interface{}{}
 
-	posts, err := app.db.GetPosts(app.cfg, c, p, false, true, false)
+	posts, err := app.db.GetPosts(app.Config(), c, p, false, true, false)
 	for _, pp := range *posts {
 		pp.Collection = res
-		o := pp.ActivityObject(app.cfg)
+		o := pp.ActivityObject(app.Config())
 		a := activitystreams.NewCreateActivity(o)
 		ocp.OrderedItems = append(ocp.OrderedItems, *a)
 	}
@@ -166,7 +168,7 @@ func handleFetchCollectionFollowers(app *App, w http.ResponseWriter, r *http.Req
 	// Get base Collection data
 	var c *Collection
 	var err error
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(alias)
@@ -182,7 +184,7 @@ func handleFetchCollectionFollowers(app *App, w http.ResponseWriter, r *http.Req
 	if suspended {
 		return ErrCollectionNotFound
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	accountRoot := c.FederatedAccount()
 
@@ -220,7 +222,7 @@ func handleFetchCollectionFollowing(app *App, w http.ResponseWriter, r *http.Req
 	// Get base Collection data
 	var c *Collection
 	var err error
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(alias)
@@ -236,7 +238,7 @@ func handleFetchCollectionFollowing(app *App, w http.ResponseWriter, r *http.Req
 	if suspended {
 		return ErrCollectionNotFound
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	accountRoot := c.FederatedAccount()
 
@@ -254,14 +256,196 @@ func handleFetchCollectionFollowing(app *App, w http.ResponseWriter, r *http.Req
 	return impart.RenderActivityJSON(w, ocp, http.StatusOK)
 }
 
+// activityActor returns the ID of the actor that sent the given activity, or
+// an empty string if it doesn't name one.
+func activityActor(m map[string]interface{}) string {
+	switch a := m["actor"].(type) {
+	case string:
+		return a
+	case map[string]interface{}:
+		id, _ := a["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// activityActorHost returns the host of the actor that sent the given
+// activity, or an empty string if it can't be determined.
+func activityActorHost(m map[string]interface{}) string {
+	u, err := url.Parse(activityActor(m))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// signatureParamReg matches the algorithm parameter of an HTTP Signature.
+var signatureParamReg = regexp.MustCompile(`(?:^|,)\s*algorithm\s*=\s*"([^"]*)"`)
+
+// signatureAlg returns the algorithm the request's HTTP Signature says it
+// was made with, from either the Signature or Authorization header, or an
+// empty string if there's no signature. A signature that doesn't name one
+// is hs2019, which leaves the algorithm up to the key.
+func signatureAlg(r *http.Request) string {
+	sig := r.Header.Get("Signature")
+	if sig == "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Signature ") {
+			return ""
+		}
+		sig = strings.TrimPrefix(auth, "Signature ")
+	}
+	if m := signatureParamReg.FindStringSubmatch(sig); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return "hs2019"
+}
+
+// maxSignatureAge is how far a signed request's Date can be from now before
+// it's rejected, so captured requests can't be replayed later.
+const maxSignatureAge = 5 * time.Minute
+
+// verifyActivitySignature checks the request's HTTP Signature, and the digest
+// of its body, against the public key of the actor it names. It returns the
+// ID of the actor that owns the key.
+func verifyActivitySignature(app *App, r *http.Request, body []byte) (string, error) {
+	alg := signatureAlg(r)
+	if alg == "" {
+		return "", ErrSignatureMissing
+	}
+	if !app.Config().App.SignatureAlgAllowed(alg) {
+		log.Info("Rejecting activity signed with %s", alg)
+		return "", ErrSignatureAlgNotAllowed
+	}
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		log.Info("Rejecting activity without a valid Date")
+		return "", ErrSignatureInvalid
+	}
+	if age := time.Since(date); age > maxSignatureAge || age < -maxSignatureAge {
+		log.Info("Rejecting activity dated %s", r.Header.Get("Date"))
+		return "", ErrSignatureInvalid
+	}
+
+	var owner string
+	getKey := httpsig.KeyGetterFunc(func(id string) interface{} {
+		key, keyOwner, err := fetchActorKey(app, id)
+		if err != nil {
+			log.Error("Unable to get key %s: %v", id, err)
+			return nil
+		}
+		owner = keyOwner
+		return key
+	})
+	var v *httpsig.Verifier
+	header := "Signature"
+	if r.Header.Get(header) != "" {
+		v = httpsig.NewSigHeaderVerifier(getKey)
+	} else {
+		header = "Authorization"
+		v = httpsig.NewVerifier(getKey)
+	}
+	v.SetRequiredHeaders([]string{"(request-target)", "host", "date", "digest"})
+
+	vr := r
+	if alg == "hs2019" {
+		// hs2019 leaves the algorithm up to the key, and actor keys are
+		// RSA, so check it the same way as rsa-sha256.
+		sig := r.Header.Get(header)
+		if signatureParamReg.MatchString(strings.TrimPrefix(sig, "Signature ")) {
+			sig = strings.Replace(sig, `algorithm="hs2019"`, `algorithm="rsa-sha256"`, 1)
+		} else {
+			sig += `,algorithm="rsa-sha256"`
+		}
+		vr = r.WithContext(r.Context())
+		vr.Header = r.Header.Clone()
+		vr.Header.Set(header, sig)
+	}
+	if err := v.Verify(vr); err != nil {
+		log.Info("Rejecting activity with a bad signature: %v", err)
+		return "", ErrSignatureInvalid
+	}
+
+	h := sha256.Sum256(body)
+	if r.Header.Get("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(h[:]) {
+		log.Info("Rejecting activity with a bad digest")
+		return "", ErrSignatureInvalid
+	}
+	return owner, nil
+}
+
+// fetchActorKey fetches the actor document that the given key ID belongs to,
+// and returns the public key along with the ID of its owner.
+func fetchActorKey(app *App, keyID string) (interface{}, string, error) {
+	actorIRI := keyID
+	if i := strings.Index(actorIRI, "#"); i != -1 {
+		actorIRI = actorIRI[:i]
+	}
+	actorResp, err := app.remote.Get(actorIRI, func(iri string) ([]byte, error) {
+		return resolveIRI(app.federationClient(), app.Config().App.Host, iri)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	actor := &activitystreams.Person{}
+	if err := unmarshalActor(actorResp, actor); err != nil {
+		return nil, "", err
+	}
+	if actor.PublicKey.ID != keyID {
+		return nil, "", fmt.Errorf("actor %s doesn't have that key", actorIRI)
+	}
+	key, err := activitypub.DecodePublicKey([]byte(actor.PublicKey.PublicKeyPEM))
+	if err != nil {
+		return nil, "", err
+	}
+	return key, actor.ID, nil
+}
+
 func handleFetchCollectionInbox(app *App, w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Server", serverSoftware)
 
+	if debugging {
+		dump, err := httputil.DumpRequest(r, true)
+		if err != nil {
+			log.Error("Can't dump: %v", err)
+		} else {
+			log.Info("Rec'd! %q", dump)
+		}
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return err
+	}
+	if host := activityActorHost(m); !app.Config().App.InstanceAllowed(host) {
+		log.Info("Rejecting activity from instance %s", host)
+		return ErrInstanceNotAllowed
+	}
+	signer, err := verifyActivitySignature(app, r, body)
+	if err == ErrSignatureMissing && app.Config().App.AcceptUnsignedActivities {
+		log.Info("Accepting unsigned activity from %s", activityActor(m))
+	} else if err != nil {
+		return err
+	} else if signer != activityActor(m) {
+		log.Info("Rejecting activity from %s signed by %s", activityActor(m), signer)
+		return ErrSignatureInvalid
+	}
+
+	if t, _ := m["type"].(string); app.Config().App.ActivityIgnored(t) {
+		if debugging {
+			log.Info("Ignoring %s activity", t)
+		}
+		return nil
+	}
+
 	vars := mux.Vars(r)
 	alias := vars["alias"]
 	var c *Collection
-	var err error
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(alias)
@@ -278,21 +462,7 @@ func handleFetchCollectionInbox(app *App, w http.ResponseWriter, r *http.Request
 	if suspended {
 		return ErrCollectionNotFound
 	}
-	c.hostName = app.cfg.App.Host
-
-	if debugging {
-		dump, err := httputil.DumpRequest(r, true)
-		if err != nil {
-			log.Error("Can't dump: %v", err)
-		} else {
-			log.Info("Rec'd! %q", dump)
-		}
-	}
-
-	var m map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		return err
-	}
+	c.hostName = app.Config().App.Host
 
 	a := streams.NewAccept()
 	p := c.PersonObject()
@@ -394,7 +564,7 @@ func handleFetchCollectionInbox(app *App, w http.ResponseWriter, r *http.Request
 			log.Error("No to! %v", err)
 			return
 		}
-		err = makeActivityPost(app.cfg.App.Host, p, fullActor.Inbox, am)
+		err = makeActivityPost(app.federationClient(), app.Config().App.Host, p, fullActor.Inbox, am)
 		if err != nil {
 			log.Error("Unable to make activity POST: %v", err)
 			return
@@ -468,7 +638,14 @@ func handleFetchCollectionInbox(app *App, w http.ResponseWriter, r *http.Request
 	return nil
 }
 
-func makeActivityPost(hostName string, p *activitystreams.Person, url string, m interface{}) error {
+// federationClient returns the HTTP client for requests to other
+// instances, which gives up on them after the configured
+// federation_timeout.
+func (app *App) federationClient() *http.Client {
+	return &http.Client{Timeout: app.Config().App.FederationTimeoutDuration()}
+}
+
+func makeActivityPost(c *http.Client, hostName string, p *activitystreams.Person, url string, m interface{}) error {
 	log.Info("POST %s", url)
 	b, err := json.Marshal(m)
 	if err != nil {
@@ -502,7 +679,7 @@ func makeActivityPost(hostName string, p *activitystreams.Person, url string, m
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(r)
+	resp, err := c.Do(r)
 	if err != nil {
 		return err
 	}
@@ -522,7 +699,7 @@ func makeActivityPost(hostName string, p *activitystreams.Person, url string, m
 	return nil
 }
 
-func resolveIRI(hostName, url string) ([]byte, error) {
+func resolveIRI(c *http.Client, hostName, url string) ([]byte, error) {
 	log.Info("GET %s", url)
 
 	r, _ := http.NewRequest("GET", url, nil)
@@ -538,7 +715,7 @@ func resolveIRI(hostName, url string) ([]byte, error) {
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(r)
+	resp, err := c.Do(r)
 	if err != nil {
 		return nil, err
 	}
@@ -559,12 +736,15 @@ func resolveIRI(hostName, url string) ([]byte, error) {
 }
 
 func deleteFederatedPost(app *App, p *PublicPost, collID int64) error {
+	if app.db.CollectionHasAttribute(collID, collAttrNoFederation) {
+		return nil
+	}
 	if debugging {
 		log.Info("Deleting federated post!")
 	}
-	p.Collection.hostName = app.cfg.App.Host
+	p.Collection.hostName = app.Config().App.Host
 	actor := p.Collection.PersonObject(collID)
-	na := p.ActivityObject(app.cfg)
+	na := p.ActivityObject(app.Config())
 
 	// Add followers
 	p.Collection.ID = collID
@@ -587,21 +767,29 @@ func deleteFederatedPost(app *App, p *PublicPost, collID int64) error {
 		}
 	}
 
+	activities := map[string]*activitystreams.Activity{}
 	for si, instFolls := range inboxes {
-		na.CC = []string{}
+		o := *na
+		o.CC = []string{}
 		for _, f := range instFolls {
-			na.CC = append(na.CC, f)
+			o.CC = append(o.CC, f)
 		}
+		activities[si] = activitystreams.NewDeleteActivity(&o)
+	}
 
-		err = makeActivityPost(app.cfg.App.Host, actor, si, activitystreams.NewDeleteActivity(na))
+	app.deliveries.deliver(inboxKeys(activities), func(si string) {
+		err := makeActivityPost(app.federationClient(), app.Config().App.Host, actor, si, activities[si])
 		if err != nil {
 			log.Error("Couldn't delete post! %v", err)
 		}
-	}
+	})
 	return nil
 }
 
 func federatePost(app *App, p *PublicPost, collID int64, isUpdate bool) error {
+	if app.db.CollectionHasAttribute(collID, collAttrNoFederation) {
+		return nil
+	}
 	if debugging {
 		if isUpdate {
 			log.Info("Federating updated post!")
@@ -610,7 +798,7 @@ func federatePost(app *App, p *PublicPost, collID int64, isUpdate bool) error {
 		}
 	}
 	actor := p.Collection.PersonObject(collID)
-	na := p.ActivityObject(app.cfg)
+	na := p.ActivityObject(app.Config())
 
 	// Add followers
 	p.Collection.ID = collID
@@ -634,27 +822,42 @@ func federatePost(app *App, p *PublicPost, collID int64, isUpdate bool) error {
 		}
 	}
 
+	activities := map[string]*activitystreams.Activity{}
 	for si, instFolls := range inboxes {
-		na.CC = []string{}
+		o := *na
+		o.CC = []string{}
 		for _, f := range instFolls {
-			na.CC = append(na.CC, f)
+			o.CC = append(o.CC, f)
 		}
 		var activity *activitystreams.Activity
 		if isUpdate {
-			activity = activitystreams.NewUpdateActivity(na)
+			activity = activitystreams.NewUpdateActivity(&o)
 		} else {
-			activity = activitystreams.NewCreateActivity(na)
-			activity.To = na.To
-			activity.CC = na.CC
+			activity = activitystreams.NewCreateActivity(&o)
+			activity.To = o.To
+			activity.CC = o.CC
 		}
-		err = makeActivityPost(app.cfg.App.Host, actor, si, activity)
+		activities[si] = activity
+	}
+
+	app.deliveries.deliver(inboxKeys(activities), func(si string) {
+		err := makeActivityPost(app.federationClient(), app.Config().App.Host, actor, si, activities[si])
 		if err != nil {
 			log.Error("Couldn't post! %v", err)
 		}
-	}
+	})
 	return nil
 }
 
+// inboxKeys returns the inboxes the given activities are addressed to.
+func inboxKeys(activities map[string]*activitystreams.Activity) []string {
+	inboxes := make([]string, 0, len(activities))
+	for si := range activities {
+		inboxes = append(inboxes, si)
+	}
+	return inboxes
+}
+
 func getRemoteUser(app *App, actorID string) (*RemoteUser, error) {
 	u := RemoteUser{ActorID: actorID}
 	err := app.db.QueryRow("SELECT id, inbox, shared_inbox FROM remoteusers WHERE actor_id = ?", actorID).Scan(&u.ID, &u.Inbox, &u.SharedInbox)
@@ -678,7 +881,9 @@ func getActor(app *App, actorIRI string) (*activitystreams.Person, *RemoteUser,
 			if iErr.Status == http.StatusNotFound {
 				// Fetch remote actor
 				log.Info("Not found; fetching actor %s remotely", actorIRI)
-				actorResp, err := resolveIRI(app.cfg.App.Host, actorIRI)
+				actorResp, err := app.remote.Get(actorIRI, func(iri string) ([]byte, error) {
+					return resolveIRI(app.federationClient(), app.Config().App.Host, iri)
+				})
 				if err != nil {
 					log.Error("Unable to get actor! %v", err)
 					return nil, nil, impart.HTTPError{http.StatusInternalServerError, "Couldn't fetch actor."}
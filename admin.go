@@ -21,7 +21,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/writeas/impart"
-	"github.com/writeas/web-core/auth"
 	"github.com/writeas/web-core/log"
 	"github.com/writeas/web-core/passgen"
 	"github.com/writeas/writefreely/appstats"
@@ -110,7 +109,7 @@ func handleViewAdminDash(app *App, u *User, w http.ResponseWriter, r *http.Reque
 	}{
 		UserPage:  NewUserPage(app, r, u, "Admin", nil),
 		SysStatus: sysStatus,
-		Config:    app.cfg.App,
+		Config:    app.Config().App,
 
 		Message:       r.FormValue("m"),
 		ConfigMessage: r.FormValue("cm"),
@@ -132,7 +131,7 @@ func handleViewAdminUsers(app *App, u *User, w http.ResponseWriter, r *http.Requ
 		TotalPages []int
 	}{
 		UserPage: NewUserPage(app, r, u, "Users", nil),
-		Config:   app.cfg.App,
+		Config:   app.Config().App,
 		Message:  r.FormValue("m"),
 	}
 
@@ -179,7 +178,7 @@ func handleViewAdminUser(app *App, u *User, w http.ResponseWriter, r *http.Reque
 		TotalPosts  int64
 		ClearEmail  string
 	}{
-		Config:  app.cfg.App,
+		Config:  app.Config().App,
 		Message: r.FormValue("m"),
 		Colls:   []inspectedCollection{},
 	}
@@ -207,7 +206,7 @@ func handleViewAdminUser(app *App, u *User, w http.ResponseWriter, r *http.Reque
 		p.LastPost = lp.Format("January 2, 2006, 3:04 PM")
 	}
 
-	colls, err := app.db.GetCollections(p.User, app.cfg.App.Host)
+	colls, err := app.db.GetCollections(p.User, app.Config().App.Host)
 	if err != nil {
 		return impart.HTTPError{http.StatusInternalServerError, fmt.Sprintf("Could not get user's collections: %v", err)}
 	}
@@ -216,7 +215,7 @@ func handleViewAdminUser(app *App, u *User, w http.ResponseWriter, r *http.Reque
 			CollectionObj: CollectionObj{Collection: c},
 		}
 
-		if app.cfg.App.Federation {
+		if app.Config().App.Federation {
 			folls, err := app.db.GetAPFollowers(&c)
 			if err == nil {
 				// TODO: handle error here (at least log it)
@@ -253,7 +252,7 @@ func handleAdminToggleUserStatus(app *App, u *User, w http.ResponseWriter, r *ht
 		log.Error("failed to get user: %v", err)
 		return impart.HTTPError{http.StatusInternalServerError, fmt.Sprintf("Could not get user from username: %v", err)}
 	}
-	if user.IsSilenced() {
+	if user.IsSilenced() || user.IsPending() {
 		err = app.db.SetUserStatus(user.ID, UserActive)
 	} else {
 		err = app.db.SetUserStatus(user.ID, UserSilenced)
@@ -274,7 +273,7 @@ func handleAdminResetUserPass(app *App, u *User, w http.ResponseWriter, r *http.
 
 	// Generate new random password since none supplied
 	pass := passgen.NewWordish()
-	hashedPass, err := auth.HashPass([]byte(pass))
+	hashedPass, err := hashPassword(app.Config(), []byte(pass))
 	if err != nil {
 		return impart.HTTPError{http.StatusInternalServerError, fmt.Sprintf("Could not create password hash: %v", err)}
 	}
@@ -306,7 +305,7 @@ func handleViewAdminPages(app *App, u *User, w http.ResponseWriter, r *http.Requ
 		Pages []*instanceContent
 	}{
 		UserPage: NewUserPage(app, r, u, "Pages", nil),
-		Config:   app.cfg.App,
+		Config:   app.Config().App,
 		Message:  r.FormValue("m"),
 	}
 
@@ -325,7 +324,7 @@ func handleViewAdminPages(app *App, u *User, w http.ResponseWriter, r *http.Requ
 		if c.ID == "about" {
 			hasAbout = true
 			if !c.Title.Valid {
-				p.Pages[i].Title = defaultAboutTitle(app.cfg)
+				p.Pages[i].Title = defaultAboutTitle(app.Config())
 			}
 		} else if c.ID == "privacy" {
 			hasPrivacy = true
@@ -337,8 +336,8 @@ func handleViewAdminPages(app *App, u *User, w http.ResponseWriter, r *http.Requ
 	if !hasAbout {
 		p.Pages = append(p.Pages, &instanceContent{
 			ID:      "about",
-			Title:   defaultAboutTitle(app.cfg),
-			Content: defaultAboutPage(app.cfg),
+			Title:   defaultAboutTitle(app.Config()),
+			Content: defaultAboutPage(app.Config()),
 			Updated: defaultPageUpdatedTime,
 		})
 	}
@@ -346,7 +345,7 @@ func handleViewAdminPages(app *App, u *User, w http.ResponseWriter, r *http.Requ
 		p.Pages = append(p.Pages, &instanceContent{
 			ID:      "privacy",
 			Title:   defaultPrivacyTitle(),
-			Content: defaultPrivacyPolicy(app.cfg),
+			Content: defaultPrivacyPolicy(app.Config()),
 			Updated: defaultPageUpdatedTime,
 		})
 	}
@@ -370,7 +369,7 @@ func handleViewAdminPage(app *App, u *User, w http.ResponseWriter, r *http.Reque
 		Banner  *instanceContent
 		Content *instanceContent
 	}{
-		Config:  app.cfg.App,
+		Config:  app.Config().App,
 		Message: r.FormValue("m"),
 	}
 
@@ -440,34 +439,55 @@ func handleAdminUpdateSite(app *App, u *User, w http.ResponseWriter, r *http.Req
 }
 
 func handleAdminUpdateConfig(apper Apper, u *User, w http.ResponseWriter, r *http.Request) error {
-	apper.App().cfg.App.SiteName = r.FormValue("site_name")
-	apper.App().cfg.App.SiteDesc = r.FormValue("site_desc")
-	apper.App().cfg.App.Landing = r.FormValue("landing")
-	apper.App().cfg.App.OpenRegistration = r.FormValue("open_registration") == "on"
-	mul, err := strconv.Atoi(r.FormValue("min_username_len"))
-	if err == nil {
-		apper.App().cfg.App.MinUsernameLen = mul
-	}
-	mb, err := strconv.Atoi(r.FormValue("max_blogs"))
-	if err == nil {
-		apper.App().cfg.App.MaxBlogs = mb
+	// The same edits are made to the running config and to the one on disk,
+	// so values that came from the environment aren't written to the file.
+	edit := func(cfg *config.Config) {
+		cfg.App.SiteName = r.FormValue("site_name")
+		cfg.App.SiteDesc = r.FormValue("site_desc")
+		cfg.App.Landing = r.FormValue("landing")
+		mul, err := strconv.Atoi(r.FormValue("min_username_len"))
+		if err == nil {
+			cfg.App.MinUsernameLen = mul
+		}
+		mb, err := strconv.Atoi(r.FormValue("max_blogs"))
+		if err == nil {
+			cfg.App.MaxBlogs = mb
+		}
+		cfg.App.Federation = r.FormValue("federation") == "on"
+		cfg.App.FederateNewBlogs = r.FormValue("federate_new_blogs") == "on"
+		switch ps := r.FormValue("public_stats"); ps {
+		case config.StatsNone, config.StatsBasic, config.StatsFull:
+			cfg.App.PublicStats = ps
+		}
+		cfg.App.Private = r.FormValue("private") == "on"
+		cfg.App.LocalTimeline = r.FormValue("local_timeline") == "on"
+		cfg.App.LocalTimelinePublic = r.FormValue("local_timeline_public") == "on"
+		cfg.App.UserInvites = r.FormValue("user_invites")
+		if cfg.App.UserInvites == "none" {
+			cfg.App.UserInvites = ""
+		}
+		cfg.App.DefaultVisibility = r.FormValue("default_visibility")
+		cfg.App.RequireApproval = r.FormValue("require_approval") == "on"
+		if r.FormValue("open_registration") == "on" {
+			cfg.App.SetRegistrationMode(config.RegistrationOpen)
+		} else if cfg.App.UserInvites != "" {
+			cfg.App.SetRegistrationMode(config.RegistrationInvite)
+		} else {
+			cfg.App.SetRegistrationMode(config.RegistrationClosed)
+		}
 	}
-	apper.App().cfg.App.Federation = r.FormValue("federation") == "on"
-	apper.App().cfg.App.PublicStats = r.FormValue("public_stats") == "on"
-	apper.App().cfg.App.Private = r.FormValue("private") == "on"
-	apper.App().cfg.App.LocalTimeline = r.FormValue("local_timeline") == "on"
-	if apper.App().cfg.App.LocalTimeline && apper.App().timeline == nil {
+	cfg := apper.App().updateConfig(edit)
+	if cfg.App.LocalTimeline && apper.App().timeline == nil {
 		log.Info("Initializing local timeline...")
 		initLocalTimeline(apper.App())
 	}
-	apper.App().cfg.App.UserInvites = r.FormValue("user_invites")
-	if apper.App().cfg.App.UserInvites == "none" {
-		apper.App().cfg.App.UserInvites = ""
-	}
-	apper.App().cfg.App.DefaultVisibility = r.FormValue("default_visibility")
 
 	m := "?cm=Configuration+saved."
-	err = apper.SaveConfig(apper.App().cfg)
+	fileCfg, err := config.LoadFile(apper.App().cfgFile)
+	if err == nil {
+		edit(fileCfg)
+		err = apper.SaveConfig(fileCfg)
+	}
 	if err != nil {
 		m = "?cm=" + err.Error()
 	}
@@ -513,7 +533,7 @@ func updateAppStats() {
 }
 
 func adminResetPassword(app *App, u *User, newPass string) error {
-	hashedPass, err := auth.HashPass([]byte(newPass))
+	hashedPass, err := hashPassword(app.Config(), []byte(newPass))
 	if err != nil {
 		return impart.HTTPError{http.StatusInternalServerError, fmt.Sprintf("Could not create password hash: %v", err)}
 	}
@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/writeas/writefreely/config"
+)
+
+func TestAdminUpdateConfigKeepsEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfadmin")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "config.ini")
+
+	cfg := config.New()
+	cfg.App.SiteName = "Old Name"
+	cfg.Server.Port = 8080
+	cfg.Database.Password = "c0rrect-h0rse"
+	if err = config.Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	for k, v := range map[string]string{
+		"WF_SERVER_PORT":   "9090",
+		"WF_APP_SITE_DESC": "From the environment",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	app := &App{cfgFile: fname}
+	if err = app.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	form := url.Values{"site_name": {"New Name"}, "site_desc": {"Edited"}}
+	r := httptest.NewRequest("POST", "/admin/update/config", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handleAdminUpdateConfig(app, nil, httptest.NewRecorder(), r)
+
+	if app.Config().App.SiteName != "New Name" {
+		t.Errorf("Running site name = %q; expected New Name", app.Config().App.SiteName)
+	}
+	saved, err := config.LoadFile(fname)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if saved.App.SiteName != "New Name" || saved.App.SiteDesc != "Edited" {
+		t.Errorf("Saved site = %q, %q; expected the edited New Name, Edited", saved.App.SiteName, saved.App.SiteDesc)
+	}
+	if saved.Server.Port != 8080 {
+		t.Errorf("Saved server port = %d; expected 8080 from the file, not the environment", saved.Server.Port)
+	}
+}
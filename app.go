@@ -11,18 +11,25 @@
 package writefreely
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -32,7 +39,6 @@ import (
 	"github.com/manifoldco/promptui"
 	"github.com/writeas/go-strip-markdown"
 	"github.com/writeas/impart"
-	"github.com/writeas/web-core/auth"
 	"github.com/writeas/web-core/converter"
 	"github.com/writeas/web-core/log"
 	"github.com/writeas/writefreely/author"
@@ -67,13 +73,29 @@ type App struct {
 	router       *mux.Router
 	shttp        *http.ServeMux
 	db           *datastore
-	cfg          *config.Config
 	cfgFile      string
 	keys         *key.Keychain
-	sessionStore *sessions.CookieStore
+	sessionStore sessions.Store
 	formDecoder  *schema.Decoder
 
-	timeline *localTimeline
+	timeline   *localTimeline
+	remote     *remoteCache
+	rateLimits *rateLimits
+	deliveries *deliveryPool
+
+	customCSS    []byte
+	customCSSMod time.Time
+
+	// cfg holds the running *config.Config. Once the app is serving, it's
+	// only ever replaced, not changed, so requests can read it without
+	// locking.
+	cfg atomic.Value
+
+	// cfgMu serializes replacing cfg, and guards loadedCfg, which is the
+	// configuration as it was read from cfgFile, before the app filled in
+	// any defaults of its own.
+	cfgMu     sync.Mutex
+	loadedCfg *config.Config
 }
 
 // DB returns the App's datastore
@@ -88,12 +110,42 @@ func (app *App) Router() *mux.Router {
 
 // Config returns the App's current configuration.
 func (app *App) Config() *config.Config {
-	return app.cfg
+	cfg, _ := app.cfg.Load().(*config.Config)
+	return cfg
 }
 
 // SetConfig updates the App's Config to the given value.
 func (app *App) SetConfig(cfg *config.Config) {
-	app.cfg = cfg
+	app.cfg.Store(cfg)
+}
+
+// updateConfig replaces the running configuration, and the one it was
+// loaded as, with copies that fn has been applied to. Requests that are
+// already reading the old configuration keep seeing it unchanged.
+func (app *App) updateConfig(fn func(cfg *config.Config)) *config.Config {
+	app.cfgMu.Lock()
+	defer app.cfgMu.Unlock()
+	cfg := *app.Config()
+	fn(&cfg)
+	app.SetConfig(&cfg)
+	if app.loadedCfg != nil {
+		loaded := *app.loadedCfg
+		fn(&loaded)
+		app.loadedCfg = &loaded
+	}
+	return &cfg
+}
+
+// fillConfig replaces the running configuration with a copy that fn has
+// filled in the app's own defaults and fallbacks on. Unlike updateConfig, it
+// leaves the configuration as loaded alone, so these aren't reported as
+// changes on reload or written back to the file.
+func (app *App) fillConfig(fn func(cfg *config.Config)) {
+	app.cfgMu.Lock()
+	defer app.cfgMu.Unlock()
+	cfg := *app.Config()
+	fn(&cfg)
+	app.SetConfig(&cfg)
 }
 
 // SetKeys updates the App's Keychain to the given value.
@@ -132,19 +184,39 @@ func (app *App) App() *App {
 // LoadConfig loads and parses a config file.
 func (app *App) LoadConfig() error {
 	log.Info("Loading %s configuration...", app.cfgFile)
-	cfg, err := config.Load(app.cfgFile)
+	cfg, err := config.LoadWithEnv(app.cfgFile)
 	if err != nil {
 		log.Error("Unable to load configuration: %v", err)
 		os.Exit(1)
 		return err
 	}
-	app.cfg = cfg
+	cfg.Server.Dev = debugging
+	err = cfg.Validate()
+	if err != nil {
+		log.Error("%s", err)
+		os.Exit(1)
+		return err
+	}
+	for _, w := range cfg.Warnings() {
+		log.Error("[WARNING] %s.", w)
+	}
+	err = initLogging(cfg.Log)
+	if err != nil {
+		log.Error("Unable to set up logging: %v", err)
+		os.Exit(1)
+		return err
+	}
+	loaded := *cfg
+	app.cfgMu.Lock()
+	app.loadedCfg = &loaded
+	app.cfgMu.Unlock()
+	app.SetConfig(cfg)
 	return nil
 }
 
 // SaveConfig saves the given Config to disk -- namely, to the App's cfgFile.
 func (app *App) SaveConfig(c *config.Config) error {
-	return config.Save(c, app.cfgFile)
+	return config.SaveFile(c, app.cfgFile)
 }
 
 // LoadKeys reads all needed keys from disk into the App. In order to use the
@@ -165,7 +237,9 @@ func (app *App) LoadKeys() error {
 	if debugging {
 		log.Info("  %s", cookieAuthKeyPath)
 	}
-	app.keys.CookieAuthKey, err = ioutil.ReadFile(cookieAuthKeyPath)
+	// Cookie keys only sign and encrypt sessions, so they're generated if
+	// missing instead of requiring --gen-keys
+	app.keys.CookieAuthKey, err = loadOrGenerateKey(cookieAuthKeyPath)
 	if err != nil {
 		return err
 	}
@@ -173,7 +247,7 @@ func (app *App) LoadKeys() error {
 	if debugging {
 		log.Info("  %s", cookieKeyPath)
 	}
-	app.keys.CookieKey, err = ioutil.ReadFile(cookieKeyPath)
+	app.keys.CookieKey, err = loadOrGenerateKey(cookieKeyPath)
 	if err != nil {
 		return err
 	}
@@ -188,7 +262,7 @@ func (app *App) ReqLog(r *http.Request, status int, timeSince time.Duration) str
 // handleViewHome shows page at root path. It checks the configuration and
 // authentication state to show the correct page.
 func handleViewHome(app *App, w http.ResponseWriter, r *http.Request) error {
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		// Render blog index
 		return handleViewCollection(app, w, r)
 	}
@@ -199,10 +273,10 @@ func handleViewHome(app *App, w http.ResponseWriter, r *http.Request) error {
 		// Show correct page based on user auth status and configured landing path
 		u := getUserSession(app, r)
 
-		if app.cfg.App.Chorus {
+		if app.Config().App.Chorus {
 			// This instance is focused on reading, so show Reader on home route if not
 			// private or a private-instance user is logged in.
-			if !app.cfg.App.Private || u != nil {
+			if !app.Config().App.Private || u != nil {
 				return viewLocalTimeline(app, w, r)
 			}
 		}
@@ -212,7 +286,7 @@ func handleViewHome(app *App, w http.ResponseWriter, r *http.Request) error {
 			return handleViewPad(app, w, r)
 		}
 
-		if land := app.cfg.App.LandingPath(); land != "/" {
+		if land := app.Config().App.LandingPath(); land != "/" {
 			return impart.HTTPError{http.StatusFound, land}
 		}
 	}
@@ -240,17 +314,17 @@ func handleViewLanding(app *App, w http.ResponseWriter, r *http.Request) error {
 		log.Error("unable to get landing banner: %v", err)
 		return impart.HTTPError{http.StatusInternalServerError, fmt.Sprintf("Could not get banner: %v", err)}
 	}
-	p.Banner = template.HTML(applyMarkdown([]byte(banner.Content), "", app.cfg))
+	p.Banner = template.HTML(applyMarkdown([]byte(banner.Content), "", app.Config()))
 
 	content, err := getLandingBody(app)
 	if err != nil {
 		log.Error("unable to get landing content: %v", err)
 		return impart.HTTPError{http.StatusInternalServerError, fmt.Sprintf("Could not get content: %v", err)}
 	}
-	p.Content = template.HTML(applyMarkdown([]byte(content.Content), "", app.cfg))
+	p.Content = template.HTML(applyMarkdown([]byte(content.Content), "", app.Config()))
 
 	// Get error messages
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err != nil {
 		// Ignore this
 		log.Error("Unable to get session in handleViewHome; ignoring: %v", err)
@@ -284,9 +358,11 @@ func handleTemplatedPage(app *App, w http.ResponseWriter, r *http.Request, t *te
 			c, err = getAboutPage(app)
 
 			// Fetch stats
-			p.AboutStats = &InstanceStats{}
-			p.AboutStats.NumPosts, _ = app.db.GetTotalPosts()
-			p.AboutStats.NumBlogs, _ = app.db.GetTotalCollections()
+			if app.Config().App.ShowsStats(config.StatsBasic) {
+				p.AboutStats = &InstanceStats{}
+				p.AboutStats.NumPosts, _ = app.db.GetTotalPosts()
+				p.AboutStats.NumBlogs, _ = app.db.GetTotalCollections()
+			}
 		} else {
 			c, err = getPrivacyPage(app)
 		}
@@ -295,7 +371,7 @@ func handleTemplatedPage(app *App, w http.ResponseWriter, r *http.Request, t *te
 			return err
 		}
 		p.ContentTitle = c.Title.String
-		p.Content = template.HTML(applyMarkdown([]byte(c.Content), "", app.cfg))
+		p.Content = template.HTML(applyMarkdown([]byte(c.Content), "", app.Config()))
 		p.PlainContent = shortPostDescription(stripmd.Strip(c.Content))
 		if !c.Updated.IsZero() {
 			p.Updated = c.Updated.Format("January 2, 2006")
@@ -312,7 +388,7 @@ func handleTemplatedPage(app *App, w http.ResponseWriter, r *http.Request, t *te
 
 func pageForReq(app *App, r *http.Request) page.StaticPage {
 	p := page.StaticPage{
-		AppCfg:  app.cfg.App,
+		AppCfg:  app.Config().App,
 		Path:    r.URL.Path,
 		Version: "v" + softwareVer,
 	}
@@ -334,10 +410,10 @@ func pageForReq(app *App, r *http.Request) page.StaticPage {
 		if u != nil {
 			p.Username = u.Username
 			p.IsAdmin = u != nil && u.IsAdmin()
-			p.CanInvite = canUserInvite(app.cfg, p.IsAdmin)
+			p.CanInvite = canUserInvite(app.Config(), p.IsAdmin)
 		}
 	}
-	p.CanViewReader = !app.cfg.App.Private || u != nil
+	p.CanViewReader = (!app.Config().App.Private || u != nil) && (app.Config().App.LocalTimelinePublic || p.IsAdmin)
 
 	return p
 }
@@ -350,9 +426,18 @@ func Initialize(apper Apper, debug bool) (*App, error) {
 	debugging = debug
 
 	apper.LoadConfig()
+	err := apper.App().Config().Server.CheckDataDirWritable()
+	if err != nil {
+		return nil, fmt.Errorf("data dir: %s", err)
+	}
+	checkTheme(apper.App())
+	loadCustomCSS(apper.App())
+	checkBranding(apper.App())
+	apper.App().remote = newRemoteCache(apper.App().Config().App.FederationCacheDuration())
+	apper.App().deliveries = newDeliveryPool(apper.App().Config().App.MaxFederationConcurrency)
 
 	// Load templates
-	err := InitTemplates(apper.App().Config())
+	err = InitTemplates(apper.App().Config())
 	if err != nil {
 		return nil, fmt.Errorf("load templates: %s", err)
 	}
@@ -373,7 +458,7 @@ func Initialize(apper Apper, debug bool) (*App, error) {
 	}
 
 	// Handle local timeline, if enabled
-	if apper.App().cfg.App.LocalTimeline {
+	if apper.App().Config().App.LocalTimeline {
 		log.Info("Initializing local timeline...")
 		initLocalTimeline(apper.App())
 	}
@@ -384,84 +469,215 @@ func Initialize(apper Apper, debug bool) (*App, error) {
 func Serve(app *App, r *mux.Router) {
 	log.Info("Going to serve...")
 
-	isSingleUser = app.cfg.App.SingleUser
-	app.cfg.Server.Dev = debugging
+	isSingleUser = app.Config().App.SingleUser
+	app.fillConfig(func(cfg *config.Config) {
+		cfg.Server.Dev = debugging
+	})
+
+	// Set up web application servers
+	bindAddrs := app.Config().Server.BindAddrs()
+	var servers []*http.Server
+	var listen func(s *http.Server) error
+	if app.Config().IsSecureStandalone() {
+		if app.Config().Server.Autocert {
+			m := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				Cache:      autocert.DirCache(app.Config().Server.AutoCertCacheDir()),
+				HostPolicy: autocert.HostWhitelist(app.Config().Server.AutoCertHosts...),
+			}
+			log.Info("Using autocert on hosts %s", strings.Join(app.Config().Server.AutoCertHosts, ", "))
+			for _, a := range bindAddrs {
+				go serveHTTPSRedirects(net.JoinHostPort(a, "80"), autocertHTTPHandler(m, app.Config()))
+			}
+
+			for _, a := range hostPorts(bindAddrs, "443") {
+				log.Info("Serving on https://%s", a)
+				s := newHTTPServer(app.Config().Server, a, r)
+				s.TLSConfig = &tls.Config{
+					GetCertificate: m.GetCertificate,
+				}
+				s.SetKeepAlivesEnabled(false)
+				servers = append(servers, s)
+			}
+			listen = func(s *http.Server) error {
+				return s.ListenAndServeTLS("", "")
+			}
+		} else {
+			if app.Config().Server.RedirectHTTP {
+				for _, a := range bindAddrs {
+					go serveHTTPSRedirects(net.JoinHostPort(a, "80"), httpsRedirectHandler(app.Config().App.Host))
+				}
+			}
+
+			for _, a := range hostPorts(bindAddrs, "443") {
+				log.Info("Serving on https://%s", a)
+				servers = append(servers, newHTTPServer(app.Config().Server, a, r))
+			}
+			log.Info("Using manual certificates")
+			listen = func(s *http.Server) error {
+				return s.ListenAndServeTLS(app.Config().Server.TLSCertPath, app.Config().Server.TLSKeyPath)
+			}
+		}
+	} else {
+		for _, a := range hostPorts(bindAddrs, strconv.Itoa(app.Config().Server.Port)) {
+			log.Info("Serving on http://%s", a)
+			servers = append(servers, newHTTPServer(app.Config().Server, a, r))
+		}
+		listen = func(s *http.Server) error {
+			return s.ListenAndServe()
+		}
+	}
+	log.Info("---")
 
 	// Handle shutdown
+	stopScheduler := make(chan struct{})
+	if s := newPostScheduler(app); s != nil {
+		log.Info("Publishing scheduled posts every %s", s.interval)
+		go s.run(stopScheduler)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			app.reloadConfig()
+		}
+	}()
+
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		log.Info("Shutting down...")
+		close(stopScheduler)
+		shutdownServers(servers, app.Config().Server.ShutdownTimeoutDuration())
 		shutdown(app)
 		log.Info("Done.")
 		os.Exit(0)
 	}()
 
-	// Start web application server
-	var bindAddress = app.cfg.Server.Bind
-	if bindAddress == "" {
-		bindAddress = "localhost"
+	// Start web application servers
+	err := serveAll(servers, listen)
+	if err == http.ErrServerClosed {
+		// Wait for the shutdown handler to finish and exit
+		select {}
 	}
-	var err error
-	if app.cfg.IsSecureStandalone() {
-		if app.cfg.Server.Autocert {
-			m := &autocert.Manager{
-				Prompt: autocert.AcceptTOS,
-				Cache:  autocert.DirCache(app.cfg.Server.TLSCertPath),
-			}
-			host, err := url.Parse(app.cfg.App.Host)
-			if err != nil {
-				log.Error("[WARNING] Unable to parse configured host! %s", err)
-				log.Error(`[WARNING] ALL hosts are allowed, which can open you to an attack where
-clients connect to a server by IP address and pretend to be asking for an
-incorrect host name, and cause you to reach the CA's rate limit for certificate
-requests. We recommend supplying a valid host name.`)
-				log.Info("Using autocert on ANY host")
-			} else {
-				log.Info("Using autocert on host %s", host.Host)
-				m.HostPolicy = autocert.HostWhitelist(host.Host)
-			}
-			s := &http.Server{
-				Addr:    ":https",
-				Handler: r,
-				TLSConfig: &tls.Config{
-					GetCertificate: m.GetCertificate,
-				},
-			}
-			s.SetKeepAlivesEnabled(false)
+	if err != nil {
+		log.Error("Unable to start: %v", err)
+		os.Exit(1)
+	}
+}
+
+// reloadConfig reads the configuration file again, applying the settings
+// that can change without a restart, and warning about the rest.
+func (app *App) reloadConfig() {
+	log.Info("Reloading %s configuration...", app.cfgFile)
+	next, err := config.Reload(app.cfgFile, app.Config().Server.Dev)
+	if err != nil {
+		log.Error("Unable to reload configuration: %v", err)
+		return
+	}
+	if err = initLogging(next.Log); err != nil {
+		log.Error("Unable to reload configuration: %v", err)
+		return
+	}
+	for _, w := range next.Warnings() {
+		log.Error("[WARNING] %s.", w)
+	}
+	app.updateConfig(func(cfg *config.Config) {
+		cfg.ApplyReloadable(next)
+	})
+	app.cfgMu.Lock()
+	restart := app.loadedCfg.RestartKeys(next)
+	app.cfgMu.Unlock()
+	if app.rateLimits != nil {
+		app.rateLimits.update(app.Config().RateLimit)
+	}
+	if len(restart) > 0 {
+		log.Error("[WARNING] Restart to apply these changed settings: %s", strings.Join(restart, ", "))
+	}
+	log.Info("Reloaded configuration.")
+}
 
-			go func() {
-				log.Info("Serving redirects on http://%s:80", bindAddress)
-				err = http.ListenAndServe(":80", m.HTTPHandler(nil))
-				log.Error("Unable to start redirect server: %v", err)
-			}()
+// hostPorts joins each of the given hosts with port.
+func hostPorts(hosts []string, port string) []string {
+	addrs := make([]string, len(hosts))
+	for i, h := range hosts {
+		addrs[i] = net.JoinHostPort(h, port)
+	}
+	return addrs
+}
 
-			log.Info("Serving on https://%s:443", bindAddress)
-			log.Info("---")
-			err = s.ListenAndServeTLS("", "")
-		} else {
-			go func() {
-				log.Info("Serving redirects on http://%s:80", bindAddress)
-				err = http.ListenAndServe(fmt.Sprintf("%s:80", bindAddress), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					http.Redirect(w, r, app.cfg.App.Host, http.StatusMovedPermanently)
-				}))
-				log.Error("Unable to start redirect server: %v", err)
-			}()
-
-			log.Info("Serving on https://%s:443", bindAddress)
-			log.Info("Using manual certificates")
-			log.Info("---")
-			err = http.ListenAndServeTLS(fmt.Sprintf("%s:443", bindAddress), app.cfg.Server.TLSCertPath, app.cfg.Server.TLSKeyPath, r)
+// serveAll calls listen for each of the given servers in its own goroutine,
+// and returns the first error encountered.
+func serveAll(servers []*http.Server, listen func(s *http.Server) error) error {
+	errc := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *http.Server) {
+			errc <- listen(s)
+		}(s)
+	}
+	return <-errc
+}
+
+// shutdownServers gracefully shuts down the given servers, waiting up to
+// timeout for in-flight requests to finish before closing any remaining
+// connections. A zero timeout closes the servers immediately.
+func shutdownServers(servers []*http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, s := range servers {
+		if timeout > 0 {
+			err := s.Shutdown(ctx)
+			if err == nil {
+				continue
+			}
+			log.Error("Unable to shut down %s gracefully: %v", s.Addr, err)
 		}
-	} else {
-		log.Info("Serving on http://%s:%d\n", bindAddress, app.cfg.Server.Port)
-		log.Info("---")
-		err = http.ListenAndServe(fmt.Sprintf("%s:%d", bindAddress, app.cfg.Server.Port), r)
+		s.Close()
 	}
-	if err != nil {
-		log.Error("Unable to start: %v", err)
-		os.Exit(1)
+}
+
+// serveHTTPSRedirects listens for plain HTTP requests on the given address,
+// handling them with h.
+func serveHTTPSRedirects(addr string, h http.Handler) {
+	log.Info("Serving redirects on http://%s", addr)
+	err := http.ListenAndServe(addr, h)
+	log.Error("Unable to start redirect server: %v", err)
+}
+
+// autocertHTTPHandler returns the handler for plain HTTP requests while
+// autocert is on. It always answers the ACME challenges that certificates
+// are issued with, since they need port 80. Other requests get the
+// permanent redirect when redirect_http is set, and autocert's own
+// redirect otherwise.
+func autocertHTTPHandler(m *autocert.Manager, cfg *config.Config) http.Handler {
+	if cfg.Server.RedirectHTTP {
+		return m.HTTPHandler(httpsRedirectHandler(cfg.App.Host))
+	}
+	return m.HTTPHandler(nil)
+}
+
+// httpsRedirectHandler returns a handler that permanently redirects every
+// request to the same path and query on the HTTPS version of the given host.
+func httpsRedirectHandler(host string) http.Handler {
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// newHTTPServer returns an http.Server for the given address and handler that
+// uses the configured timeouts.
+func newHTTPServer(cfg config.ServerCfg, addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      h,
+		ReadTimeout:  cfg.ReadTimeoutDuration(),
+		WriteTimeout: cfg.WriteTimeoutDuration(),
+		IdleTimeout:  cfg.IdleTimeoutDuration(),
 	}
 }
 
@@ -481,24 +697,33 @@ func (app *App) InitDecoder() {
 // tests the connection.
 func ConnectToDatabase(app *App) error {
 	// Check database configuration
-	if app.cfg.Database.Type == driverMySQL && (app.cfg.Database.User == "" || app.cfg.Database.Password == "") {
-		return fmt.Errorf("Database user or password not set.")
-	}
-	if app.cfg.Database.Host == "" {
-		app.cfg.Database.Host = "localhost"
-	}
-	if app.cfg.Database.Database == "" {
-		app.cfg.Database.Database = "writefreely"
+	if app.Config().Database.DSN == "" {
+		if app.Config().Database.Type == driverMySQL && (app.Config().Database.User == "" || app.Config().Database.Password == "") {
+			return fmt.Errorf("Database user or password not set.")
+		}
+		app.fillConfig(func(cfg *config.Config) {
+			if cfg.Database.Host == "" && cfg.Database.Socket == "" {
+				cfg.Database.Host = "localhost"
+			}
+			if cfg.Database.Database == "" {
+				cfg.Database.Database = "writefreely"
+			}
+		})
 	}
 
 	// TODO: check err
 	connectToDatabase(app)
 
 	// Test database connection
-	err := app.db.Ping()
+	err := pingDatabase(app.db.DB, app.Config().Database)
 	if err != nil {
 		return fmt.Errorf("Database ping failed: %s", err)
 	}
+	for i, replica := range app.db.replicas {
+		if err = pingDatabase(replica, app.Config().Database); err != nil {
+			return fmt.Errorf("Read replica %d ping failed: %s", i+1, err)
+		}
+	}
 
 	return nil
 }
@@ -525,13 +750,23 @@ func CreateConfig(app *App) error {
 	log.Info("Creating configuration...")
 	c := config.New()
 	log.Info("Saving configuration %s...", app.cfgFile)
-	err := config.Save(c, app.cfgFile)
+	err := config.SaveFile(c, app.cfgFile)
 	if err != nil {
-		return fmt.Errorf("Unable to save configuration: %v", err)
+		return fmt.Errorf("Unable to save configuration: %w", err)
 	}
 	return nil
 }
 
+// DumpEffectiveConfig prints the configuration the app would run with,
+// defaults and environment overrides included, with secrets redacted.
+func DumpEffectiveConfig(app *App) error {
+	cfg, err := config.LoadWithEnv(app.cfgFile)
+	if err != nil {
+		return fmt.Errorf("Unable to load configuration: %w", err)
+	}
+	return cfg.DumpEffective(os.Stdout)
+}
+
 // DoConfig runs the interactive configuration process.
 func DoConfig(app *App, configSections string) {
 	if configSections == "" {
@@ -548,9 +783,12 @@ func DoConfig(app *App, configSections string) {
 	d, err := config.Configure(app.cfgFile, configSections)
 	if err != nil {
 		log.Error("Unable to configure: %v", err)
+		if errors.Is(err, fs.ErrPermission) {
+			log.Error("Run the installer as a user that can write to %s, or pass a different file with -c.", app.cfgFile)
+		}
 		os.Exit(1)
 	}
-	app.cfg = d.Config
+	app.SetConfig(d.Config)
 	connectToDatabase(app)
 	defer shutdown(app)
 
@@ -573,7 +811,7 @@ func DoConfig(app *App, configSections string) {
 
 		// Create blog
 		log.Info("Creating user %s...\n", u.Username)
-		err = app.db.CreateUser(app.cfg, u, app.cfg.App.SiteName)
+		err = app.db.CreateUser(app.Config(), u, app.Config().App.SiteName)
 		if err != nil {
 			log.Error("Unable to create user: %s", err)
 			os.Exit(1)
@@ -589,7 +827,7 @@ func GenerateKeyFiles(app *App) error {
 	app.LoadConfig()
 
 	// Create keys dir if it doesn't exist yet
-	fullKeysDir := filepath.Join(app.cfg.Server.KeysParentDir, keysDir)
+	fullKeysDir := filepath.Join(app.Config().Server.KeysDir(), keysDir)
 	if _, err := os.Stat(fullKeysDir); os.IsNotExist(err) {
 		err = os.Mkdir(fullKeysDir, 0700)
 		if err != nil {
@@ -682,38 +920,101 @@ func ResetPassword(apper Apper, username string) error {
 }
 
 func connectToDatabase(app *App) {
-	log.Info("Connecting to %s database...", app.cfg.Database.Type)
+	log.Info("Connecting to %s database...", app.Config().Database.Type)
+	if debugging {
+		if dsn, err := app.Config().Database.RedactedDSN(); err == nil {
+			log.Info("Using DSN %s", dsn)
+		}
+	}
 
 	var db *sql.DB
 	var err error
-	if app.cfg.Database.Type == driverMySQL {
-		db, err = sql.Open(app.cfg.Database.Type, fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&loc=%s", app.cfg.Database.User, app.cfg.Database.Password, app.cfg.Database.Host, app.cfg.Database.Port, app.cfg.Database.Database, url.QueryEscape(time.Local.String())))
-		db.SetMaxOpenConns(50)
-	} else if app.cfg.Database.Type == driverSQLite {
+	if app.Config().Database.Type == driverMySQL {
+		if app.Config().Database.WAL || app.Config().Database.BusyTimeout > 0 {
+			log.Error("[WARNING] Ignoring SQLite-only wal and busy_timeout settings for %s database.", app.Config().Database.Type)
+		}
+		db, err = sql.Open(app.Config().Database.Type, dataSourceName(app.Config().Database))
+		setConnPool(db, app.Config().Database)
+	} else if app.Config().Database.Type == driverSQLite {
 		if !SQLiteEnabled {
-			log.Error("Invalid database type '%s'. Binary wasn't compiled with SQLite3 support.", app.cfg.Database.Type)
+			log.Error("Invalid database type '%s'. Binary wasn't compiled with SQLite3 support.", app.Config().Database.Type)
 			os.Exit(1)
 		}
-		if app.cfg.Database.FileName == "" {
+		if app.Config().Database.FileName == "" && app.Config().Database.DSN == "" {
 			log.Error("SQLite database filename value in config.ini is empty.")
 			os.Exit(1)
 		}
-		db, err = sql.Open("sqlite3_with_regex", app.cfg.Database.FileName+"?parseTime=true&cached=shared")
+		dbCfg := app.Config().Database
+		dbCfg.FileName = app.Config().SQLiteFileName()
+		db, err = sql.Open("sqlite3_with_regex", dataSourceName(dbCfg))
 		db.SetMaxOpenConns(1)
 	} else {
-		log.Error("Invalid database type '%s'. Only 'mysql' and 'sqlite3' are supported right now.", app.cfg.Database.Type)
+		log.Error("Invalid database type '%s'. Only 'mysql' and 'sqlite3' are supported right now.", app.Config().Database.Type)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Error("%s", err)
 		os.Exit(1)
 	}
+	app.db = &datastore{DB: db, driverName: app.Config().Database.Type, queryTimeout: app.Config().Database.QueryTimeoutDuration()}
+
+	dsns, err := app.Config().Database.ReplicaDSNs()
 	if err != nil {
 		log.Error("%s", err)
 		os.Exit(1)
 	}
-	app.db = &datastore{db, app.cfg.Database.Type}
+	for _, dsn := range dsns {
+		replica, err := sql.Open(app.Config().Database.Type, dsn)
+		if err != nil {
+			log.Error("Unable to open read replica: %s", err)
+			os.Exit(1)
+		}
+		setConnPool(replica, app.Config().Database)
+		app.db.replicas = append(app.db.replicas, replica)
+	}
+	if len(dsns) > 0 {
+		log.Info("Reading from %d replica(s)", len(dsns))
+	}
+}
+
+// dataSourceName returns the driver-specific connection string for the
+// given database configuration, or an empty string if its type isn't
+// supported.
+func dataSourceName(cfg config.DatabaseCfg) string {
+	dsn, _ := cfg.GetDSN()
+	return dsn
+}
+
+// setConnPool applies the configured connection pool limits to the given
+// database handle.
+func setConnPool(db *sql.DB, cfg config.DatabaseCfg) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetimeDuration())
+}
+
+// pingDatabase checks the connection to the given database, retrying with
+// backoff as configured in case it's still starting up.
+func pingDatabase(db *sql.DB, cfg config.DatabaseCfg) error {
+	delay := cfg.ConnectRetryDelayDuration()
+	err := db.Ping()
+	for i := 0; err != nil && i < cfg.ConnectRetries; i++ {
+		log.Info("Database isn't ready: %s. Retrying in %s...", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+		err = db.Ping()
+	}
+	return err
 }
 
 func shutdown(app *App) {
 	log.Info("Closing database connection...")
 	app.db.Close()
+	for _, replica := range app.db.replicas {
+		replica.Close()
+	}
 }
 
 // CreateUser creates a new admin or normal user from the given credentials.
@@ -747,12 +1048,12 @@ func CreateUser(apper Apper, username, password string, isAdmin bool) error {
 		usernameDesc += " (originally: " + desiredUsername + ")"
 	}
 
-	if !author.IsValidUsername(apper.App().cfg, username) {
-		return fmt.Errorf("Username %s is invalid, reserved, or shorter than configured minimum length (%d characters).", usernameDesc, apper.App().cfg.App.MinUsernameLen)
+	if !author.IsValidUsername(apper.App().Config(), username) {
+		return fmt.Errorf("Username %s is invalid, reserved, or shorter than configured minimum length (%d characters).", usernameDesc, apper.App().Config().App.MinUsernameLen)
 	}
 
 	// Hash the password
-	hashedPass, err := auth.HashPass([]byte(password))
+	hashedPass, err := hashPassword(apper.App().Config(), []byte(password))
 	if err != nil {
 		return fmt.Errorf("Unable to hash password: %v", err)
 	}
@@ -778,7 +1079,7 @@ func CreateUser(apper Apper, username, password string, isAdmin bool) error {
 
 func adminInitDatabase(app *App) error {
 	schemaFileName := "schema.sql"
-	if app.cfg.Database.Type == driverSQLite {
+	if app.Config().Database.Type == driverSQLite {
 		schemaFileName = "sqlite.sql"
 	}
 
@@ -0,0 +1,310 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/writeas/writefreely/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newTestApp returns an App running with the given configuration.
+func newTestApp(cfg *config.Config) *App {
+	app := &App{}
+	app.SetConfig(cfg)
+	return app
+}
+
+var dsnTLSTestTable = []struct {
+	Type     string
+	TLS      string
+	Expected string
+}{
+	{driverMySQL, "", ""},
+	{driverMySQL, "disable", "&tls=false"},
+	{driverMySQL, "require", "&tls=skip-verify"},
+	{driverMySQL, "verify-full", "&tls=true"},
+}
+
+func TestDataSourceNameTLS(t *testing.T) {
+	for _, tc := range dsnTLSTestTable {
+		dsn := dataSourceName(config.DatabaseCfg{
+			Type:     tc.Type,
+			User:     "wf",
+			Password: "pass",
+			Host:     "localhost",
+			Port:     1234,
+			Database: "writefreely",
+			TLS:      tc.TLS,
+		})
+		if tc.Expected == "" {
+			if strings.Contains(dsn, "tls=") {
+				t.Errorf("%s/%q: DSN %s has unexpected tls param", tc.Type, tc.TLS, dsn)
+			}
+			continue
+		}
+		if !strings.Contains(dsn, tc.Expected) {
+			t.Errorf("%s/%q: DSN %s doesn't contain %s", tc.Type, tc.TLS, dsn, tc.Expected)
+		}
+	}
+}
+
+func TestDataSourceNameSocket(t *testing.T) {
+	cfg := config.DatabaseCfg{
+		Type:     driverMySQL,
+		User:     "wf",
+		Password: "pass",
+		Host:     "db.example.com",
+		Port:     3306,
+		Database: "writefreely",
+	}
+	if dsn := dataSourceName(cfg); !strings.HasPrefix(dsn, "wf:pass@tcp(db.example.com:3306)/writefreely?") {
+		t.Errorf("MySQL TCP DSN = %s", dsn)
+	}
+	cfg.Host = ""
+	cfg.Socket = "/var/run/mysqld/mysqld.sock"
+	if dsn := dataSourceName(cfg); !strings.HasPrefix(dsn, "wf:pass@unix(/var/run/mysqld/mysqld.sock)/writefreely?") {
+		t.Errorf("MySQL socket DSN = %s", dsn)
+	}
+
+}
+
+func TestDataSourceNameSQLite(t *testing.T) {
+	cfg := config.New()
+	cfg.UseSQLite(true)
+	if dsn := dataSourceName(cfg.Database); dsn != "writefreely.db?parseTime=true&cached=shared&_journal_mode=WAL&_busy_timeout=5000" {
+		t.Errorf("SQLite DSN = %s", dsn)
+	}
+
+	cfg.Database.WAL = false
+	cfg.Database.BusyTimeout = 0
+	if dsn := dataSourceName(cfg.Database); dsn != "writefreely.db?parseTime=true&cached=shared" {
+		t.Errorf("Untuned SQLite DSN = %s", dsn)
+	}
+}
+
+func TestDataSourceNameDSN(t *testing.T) {
+	dsn := "wf:pass@tcp(cluster.example.com:3306)/writefreely?parseTime=true&interpolateParams=true"
+	cfg := config.DatabaseCfg{
+		Type:     driverMySQL,
+		User:     "ignored",
+		Password: "ignored",
+		Host:     "localhost",
+		Port:     3306,
+		Database: "ignored",
+		DSN:      dsn,
+	}
+	if res := dataSourceName(cfg); res != dsn {
+		t.Errorf("DSN = %s; expected the configured %s", res, dsn)
+	}
+	cfg.Type = driverSQLite
+	cfg.DSN = "file:/data/wf.db?parseTime=true&_journal_mode=WAL"
+	if res := dataSourceName(cfg); res != cfg.DSN {
+		t.Errorf("SQLite DSN = %s; expected the configured %s", res, cfg.DSN)
+	}
+}
+
+var httpsRedirectTestTable = []struct {
+	Host     string
+	Path     string
+	Expected string
+}{
+	{"https://example.com", "/", "https://example.com/"},
+	{"https://example.com", "/read?p=2", "https://example.com/read?p=2"},
+	{"http://example.com", "/matt/my-post", "https://example.com/matt/my-post"},
+	{"https://blog.example.com", "/api/me?a=1&b=2", "https://blog.example.com/api/me?a=1&b=2"},
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	for _, tc := range httpsRedirectTestTable {
+		w := httptest.NewRecorder()
+		httpsRedirectHandler(tc.Host).ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+tc.Path, nil))
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s: status = %d; expected %d", tc.Host, tc.Path, w.Code, http.StatusMovedPermanently)
+		}
+		if loc := w.Header().Get("Location"); loc != tc.Expected {
+			t.Errorf("%s%s: Location = %s; expected %s", tc.Host, tc.Path, loc, tc.Expected)
+		}
+	}
+}
+
+func TestAutocertHTTPHandler(t *testing.T) {
+	m := &autocert.Manager{Prompt: autocert.AcceptTOS}
+	for _, redirect := range []bool{false, true} {
+		cfg := config.New()
+		cfg.App.Host = "https://example.com"
+		cfg.Server.RedirectHTTP = redirect
+		h := autocertHTTPHandler(m, cfg)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/.well-known/acme-challenge/token", nil))
+		if w.Code == http.StatusFound || w.Code == http.StatusMovedPermanently {
+			t.Errorf("redirect_http %t: ACME challenge was redirected to %s", redirect, w.Header().Get("Location"))
+		}
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/matt", nil))
+		if loc := w.Header().Get("Location"); loc != "https://example.com/matt" {
+			t.Errorf("redirect_http %t: Location = %q; expected https://example.com/matt", redirect, loc)
+		}
+		if redirect && w.Code != http.StatusMovedPermanently {
+			t.Errorf("redirect_http %t: status = %d; expected %d", redirect, w.Code, http.StatusMovedPermanently)
+		}
+	}
+}
+
+func TestHostPorts(t *testing.T) {
+	addrs := hostPorts(config.ServerCfg{Bind: "localhost, [::1]"}.BindAddrs(), "8080")
+	if strings.Join(addrs, " ") != "localhost:8080 [::1]:8080" {
+		t.Errorf("hostPorts() = %q; expected localhost:8080 and [::1]:8080", addrs)
+	}
+}
+
+func TestShutdownServersImmediately(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	go s.Serve(l)
+	go http.Get("http://" + l.Addr().String())
+	<-started
+
+	// A graceful shutdown would wait on the blocked request forever
+	done := make(chan struct{})
+	go func() {
+		shutdownServers([]*http.Server{s}, 0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("shutdownServers with zero timeout waited for in-flight request")
+	}
+}
+
+// flakyConnector fails to connect the given number of times before it
+// succeeds, like a database that's still starting up.
+type flakyConnector struct {
+	failures int
+	attempts int
+}
+
+func (c *flakyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.attempts++
+	if c.attempts <= c.failures {
+		return nil, errors.New("connection refused")
+	}
+	return fakeConn{&fakeDB{}}, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver { return fakeDriver{} }
+
+func TestPingDatabase(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Failures int
+		Retries  int
+		OK       bool
+	}{
+		{"Ready", 0, 0, true},
+		{"Not ready", 1, 0, false},
+		{"Ready after retries", 3, 3, true},
+		{"Never ready", 4, 3, false},
+	} {
+		c := &flakyConnector{failures: tc.Failures}
+		db := sql.OpenDB(c)
+		start := time.Now()
+		err := pingDatabase(db, config.DatabaseCfg{ConnectRetries: tc.Retries, ConnectRetryDelay: "1ms"})
+		elapsed := time.Since(start)
+		db.Close()
+		if (err == nil) != tc.OK {
+			t.Errorf("%s: pingDatabase = %v; expected success: %t", tc.Name, err, tc.OK)
+		}
+		expected := tc.Failures + 1
+		if tc.Failures > tc.Retries {
+			expected = tc.Retries + 1
+		}
+		if c.attempts != expected {
+			t.Errorf("%s: connected %d times; expected %d", tc.Name, c.attempts, expected)
+		}
+		if tc.Retries == 3 && elapsed < 7*time.Millisecond {
+			t.Errorf("%s: retried within %s; expected the delay to double each time", tc.Name, elapsed)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfreload")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "config.ini")
+	logFile := filepath.Join(dir, "writefreely.log")
+
+	cfg := config.New()
+	cfg.App.SiteName = "Old Name"
+	cfg.Log.File = logFile
+	cfg.Database.Password = "c0rrect-h0rse"
+	if err = config.Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	defer initLogging(config.New().Log)
+	app := &App{cfgFile: fname}
+	if err = app.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	// Startup fills in defaults that aren't in the file
+	running := app.Config()
+	running.Database.Host = "localhost"
+
+	cfg.App.SiteName = "New Name"
+	if err = config.Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	app.reloadConfig()
+
+	if running.App.SiteName != "Old Name" {
+		t.Errorf("Reloading changed the running config in place, to site name %q", running.App.SiteName)
+	}
+	if app.Config().App.SiteName != "New Name" {
+		t.Errorf("Site name = %q; expected the reloaded New Name", app.Config().App.SiteName)
+	}
+	if app.Config().Database.Host != "localhost" {
+		t.Errorf("Database host = %q; expected the default filled in at startup", app.Config().Database.Host)
+	}
+	logged, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Unable to read log: %v", err)
+	}
+	if strings.Contains(string(logged), "Restart to apply") {
+		t.Errorf("Reloading an unchanged database section asked for a restart:\n%s", logged)
+	}
+}
@@ -10,6 +10,11 @@
 
 package writefreely
 
+import (
+	"github.com/writeas/writefreely/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
 // AuthenticateUser ensures a user with the given accessToken is valid. Call
 // it before any operations that require authentication or optionally associate
 // data with a user account.
@@ -26,3 +31,14 @@ func AuthenticateUser(db writestore, accessToken string) (int64, error) {
 
 	return userID, nil
 }
+
+// hashPassword hashes the given password with the configured bcrypt cost,
+// clearing the plaintext from memory afterward.
+func hashPassword(cfg *config.Config, pass []byte) ([]byte, error) {
+	defer func() {
+		for i := range pass {
+			pass[i] = 0
+		}
+	}()
+	return bcrypt.GenerateFromPassword(pass, cfg.App.PasswordCost())
+}
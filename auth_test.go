@@ -0,0 +1,45 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"testing"
+
+	"github.com/writeas/writefreely/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	cfg := config.New()
+	for _, c := range []struct {
+		Cost, Expected int
+	}{
+		{0, config.DefaultBcryptCost},
+		{bcrypt.MinCost, bcrypt.MinCost},
+		{5, 5},
+	} {
+		cfg.App.BcryptCost = c.Cost
+		pass := []byte("correct horse")
+		hash, err := hashPassword(cfg, pass)
+		if err != nil {
+			t.Fatalf("hashPassword failed: %v", err)
+		}
+		if cost, _ := bcrypt.Cost(hash); cost != c.Expected {
+			t.Errorf("Cost for bcrypt_cost %d = %d; expected %d", c.Cost, cost, c.Expected)
+		}
+		if bcrypt.CompareHashAndPassword(hash, []byte("correct horse")) != nil {
+			t.Error("Hash doesn't match the password")
+		}
+		if string(pass) == "correct horse" {
+			t.Error("Plaintext password wasn't cleared")
+		}
+	}
+}
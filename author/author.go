@@ -65,6 +65,7 @@ var reservedUsernames = map[string]bool{
 	"metadata":         true,
 	"new":              true,
 	"news":             true,
+	"oauth":            true,
 	"post":             true,
 	"posts":            true,
 	"privacy":          true,
@@ -121,6 +122,9 @@ func IsValidUsername(cfg *config.Config, username string) bool {
 	if _, reserved := reservedUsernames[username]; reserved {
 		return false
 	}
+	if cfg.App.IsUsernameReserved(username) {
+		return false
+	}
 
 	// TODO: use correct regexp function here
 	return len(validUsernameReg.FindStringSubmatch(username)) > 0
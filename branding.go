@@ -0,0 +1,53 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+// checkBranding makes sure the configured favicon and logo exist, falling
+// back to the defaults when they don't, instead of linking broken images.
+func checkBranding(app *App) {
+	app.fillConfig(func(cfg *config.Config) {
+		for _, f := range []struct {
+			key  string
+			path *string
+		}{
+			{"favicon_path", &cfg.App.FaviconPath},
+			{"logo_path", &cfg.App.LogoPath},
+		} {
+			if *f.path == "" {
+				continue
+			}
+			fi, err := os.Stat(*f.path)
+			if err == nil && fi.IsDir() {
+				err = fmt.Errorf("%s is a directory", *f.path)
+			}
+			if err != nil {
+				log.Error("[WARNING] %s '%s': %v. Using the default instead.", f.key, *f.path, err)
+				*f.path = ""
+			}
+		}
+	})
+}
+
+// brandFileServer serves the given favicon or logo file.
+func brandFileServer(fname string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, fname)
+	})
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/writeas/writefreely/config"
+	"github.com/writeas/writefreely/page"
+)
+
+func TestBranding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfbranding")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	favicon := filepath.Join(dir, "icon.PNG")
+	logo := filepath.Join(dir, "logo.svg")
+	for _, f := range []string{favicon, logo} {
+		if err = ioutil.WriteFile(f, []byte(filepath.Base(f)), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", f, err)
+		}
+	}
+
+	initPage("", filepath.Join(pagesDir, "404.tmpl"), "404.tmpl")
+	for _, tc := range []struct {
+		Name          string
+		Favicon, Logo string
+		Expected      []string
+		Unexpected    []string
+	}{
+		{"Defaults", "", "", []string{`href="http://localhost:8080/favicon.ico"`}, []string{"/brand/"}},
+		{"Configured", favicon, logo, []string{`href="http://localhost:8080/brand/favicon.png"`, `src="http://localhost:8080/brand/logo.svg"`}, []string{"/favicon.ico"}},
+		{"Missing", filepath.Join(dir, "missing.ico"), dir, []string{`href="http://localhost:8080/favicon.ico"`}, []string{"/brand/"}},
+	} {
+		app := newTestApp(config.New())
+		app.Config().App.Host = "http://localhost:8080"
+		app.Config().App.FaviconPath = tc.Favicon
+		app.Config().App.LogoPath = tc.Logo
+		prev := app.Config()
+		checkBranding(app)
+		// Requests may still be reading the previous config
+		if prev.App.FaviconPath != tc.Favicon || prev.App.LogoPath != tc.Logo {
+			t.Errorf("%s: checkBranding changed the running config in place", tc.Name)
+		}
+
+		var buf bytes.Buffer
+		if err = renderPage(&buf, "404.tmpl", page.StaticPage{AppCfg: app.Config().App}); err != nil {
+			t.Fatalf("%s: unable to render page: %v", tc.Name, err)
+		}
+		out := buf.String()
+		for _, s := range tc.Expected {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: page doesn't include %s", tc.Name, s)
+			}
+		}
+		for _, s := range tc.Unexpected {
+			if strings.Contains(out, s) {
+				t.Errorf("%s: page includes %s", tc.Name, s)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	brandFileServer(logo).ServeHTTP(w, httptest.NewRequest("GET", "/brand/logo.svg", nil))
+	if w.Body.String() != "logo.svg" || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("Served logo %q as %s", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/writeas/writefreely/config"
+)
+
+// canonicalHostMiddleware redirects requests that don't use the host in the
+// app's Host to the same path and query there. Health, readiness, and
+// metrics checks are exempt, since they're often made to the bind IP.
+func canonicalHostMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	canonical, _ := url.Parse(cfg.App.Host)
+	exempt := map[string]bool{}
+	if !cfg.Server.HealthDisabled {
+		exempt[cfg.Server.HealthEndpoint()] = true
+		exempt[cfg.Server.ReadyEndpoint()] = true
+	}
+	if cfg.Server.MetricsEnabled {
+		exempt[cfg.Server.MetricsEndpoint()] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if canonical == nil || canonical.Host == "" || exempt[r.URL.Path] || sameHost(r.Host, canonical.Host) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			to := canonical.Scheme + "://" + canonical.Host + r.URL.RequestURI()
+			http.Redirect(w, r, to, http.StatusMovedPermanently)
+		})
+	}
+}
+
+// sameHost returns whether the two hosts, with optional ports, are the same,
+// ignoring case and any trailing dot.
+func sameHost(a, b string) bool {
+	norm := func(h string) string {
+		return strings.TrimSuffix(strings.ToLower(h), ".")
+	}
+	return norm(a) == norm(b)
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestCanonicalHostMiddleware(t *testing.T) {
+	cfg := config.New()
+	cfg.App.Host = "https://blog.example.com"
+	cfg.Server.EnforceCanonicalHost = true
+
+	r := mux.NewRouter()
+	r.Use(canonicalHostMiddleware(cfg))
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+	r.HandleFunc(cfg.Server.HealthEndpoint(), ok)
+	r.HandleFunc(cfg.Server.ReadyEndpoint(), ok)
+	r.PathPrefix("/").HandlerFunc(ok)
+
+	for _, tc := range []struct {
+		Name, Host, Target string
+		Status             int
+		Location           string
+	}{
+		{"Canonical host", "blog.example.com", "/matt/?page=2", http.StatusOK, ""},
+		{"Canonical host in another case", "Blog.Example.com", "/", http.StatusOK, ""},
+		{"Other host", "www.example.com", "/matt/hello?lang=en", http.StatusMovedPermanently, "https://blog.example.com/matt/hello?lang=en"},
+		{"Bind IP", "10.0.0.5:8080", "/", http.StatusMovedPermanently, "https://blog.example.com/"},
+		{"Health check at the bind IP", "10.0.0.5:8080", "/healthz", http.StatusOK, ""},
+		{"Readiness check at the bind IP", "10.0.0.5:8080", "/readyz", http.StatusOK, ""},
+	} {
+		req := httptest.NewRequest("GET", tc.Target, nil)
+		req.Host = tc.Host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.Status {
+			t.Errorf("%s: status = %d; expected %d", tc.Name, w.Code, tc.Status)
+		}
+		if loc := w.Header().Get("Location"); loc != tc.Location {
+			t.Errorf("%s: Location = %q; expected %q", tc.Name, loc, tc.Location)
+		}
+	}
+}
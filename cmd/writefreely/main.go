@@ -16,6 +16,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/writeas/web-core/log"
 	"github.com/writeas/writefreely"
+	"github.com/writeas/writefreely/config"
 	"os"
 	"strings"
 )
@@ -23,7 +24,7 @@ import (
 func main() {
 	// General options usable with other commands
 	debugPtr := flag.Bool("debug", false, "Enables debug logging.")
-	configFile := flag.String("c", "config.ini", "The configuration file to use")
+	configFile := flag.String("c", config.DefaultFileName(), "The configuration file to use, or "+config.StdinFileName+" to read it from standard input. It can also be set with "+config.ConfigFileEnv+", or chosen for the environment in "+config.EnvironmentEnv)
 
 	// Setup actions
 	createConfig := flag.Bool("create-config", false, "Creates a basic configuration and exits")
@@ -34,6 +35,7 @@ func main() {
 	genKeys := flag.Bool("gen-keys", false, "Generate encryption and authentication keys")
 	createSchema := flag.Bool("init-db", false, "Initialize app database")
 	migrate := flag.Bool("migrate", false, "Migrate the database")
+	dumpConfig := flag.Bool("effective-config", false, "Print the configuration in use, including defaults, with secrets redacted")
 
 	// Admin actions
 	createAdmin := flag.String("create-admin", "", "Create an admin with the given username:password")
@@ -54,6 +56,13 @@ func main() {
 			os.Exit(1)
 		}
 		os.Exit(0)
+	} else if *dumpConfig {
+		err := writefreely.DumpEffectiveConfig(app)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
 	} else if *doConfig {
 		writefreely.DoConfig(app, *configSections)
 		os.Exit(0)
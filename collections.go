@@ -83,7 +83,11 @@ type (
 		Privacy   int    `schema:"privacy" json:"privacy"`
 		Pass      string `schema:"password" json:"password"`
 		MathJax   bool   `schema:"mathjax" json:"mathjax"`
-		Handle    string `schema:"handle" json:"handle"`
+
+		// Federation, when given, turns federation on or off for the
+		// collection.
+		Federation *bool  `schema:"federation" json:"federation"`
+		Handle     string `schema:"handle" json:"handle"`
 
 		// Actual collection values updated in the DB
 		Alias       *string         `schema:"alias" json:"alias"`
@@ -338,6 +342,26 @@ func (c *Collection) RenderMathJax() bool {
 	return c.db.CollectionHasAttribute(c.ID, "render_mathjax")
 }
 
+// collAttrNoFederation is the collection attribute that keeps a blog from
+// federating, when the instance does.
+const collAttrNoFederation = "disable_federation"
+
+// Federates returns whether the collection takes part in federation, when
+// the instance has it enabled.
+func (c *Collection) Federates() bool {
+	return !c.db.CollectionHasAttribute(c.ID, collAttrNoFederation)
+}
+
+// newCollectionAttributes returns the attributes that new collections start
+// with, based on the instance's defaults.
+func newCollectionAttributes(cfg *config.Config) []string {
+	var attrs []string
+	if !cfg.App.FederateNewBlogs {
+		attrs = append(attrs, collAttrNoFederation)
+	}
+	return attrs
+}
+
 func newCollection(app *App, w http.ResponseWriter, r *http.Request) error {
 	reqJSON := IsJSON(r)
 	alias := r.FormValue("alias")
@@ -378,6 +402,9 @@ func newCollection(app *App, w http.ResponseWriter, r *http.Request) error {
 	if missingParams != "" {
 		return impart.HTTPError{http.StatusBadRequest, fmt.Sprintf("Parameter(s) %srequired.", missingParams)}
 	}
+	if err := checkCollectionLength(app, &c.Title, nil); err != nil {
+		return err
+	}
 
 	var userID int64
 	var err error
@@ -406,11 +433,20 @@ func newCollection(app *App, w http.ResponseWriter, r *http.Request) error {
 		return ErrUserSuspended
 	}
 
-	if !author.IsValidUsername(app.cfg, c.Alias) {
+	if !author.IsValidUsername(app.Config(), c.Alias) {
 		return impart.HTTPError{http.StatusPreconditionFailed, "Collection alias isn't valid."}
 	}
 
-	coll, err := app.db.CreateCollection(app.cfg, c.Alias, c.Title, userID)
+	collCount, err := app.db.GetUserCollectionCount(userID)
+	if err != nil {
+		log.Error("new collection: %v", err)
+		return ErrInternalGeneral
+	}
+	if ok, err := app.Config().App.CanCreateBlog(int(collCount)); !ok {
+		return impart.HTTPError{http.StatusForbidden, err.Error()}
+	}
+
+	coll, err := app.db.CreateCollection(app.Config(), c.Alias, c.Title, userID)
 	if err != nil {
 		// TODO: handle this
 		return err
@@ -461,7 +497,7 @@ func fetchCollection(app *App, w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	// Redirect users who aren't requesting JSON
 	reqJSON := IsJSON(r)
@@ -505,7 +541,7 @@ func fetchCollectionPosts(app *App, w http.ResponseWriter, r *http.Request) erro
 	if err != nil {
 		return err
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	// Check permissions
 	userID, err := apiCheckCollectionPermissions(app, r, c)
@@ -523,7 +559,7 @@ func fetchCollectionPosts(app *App, w http.ResponseWriter, r *http.Request) erro
 		}
 	}
 
-	posts, err := app.db.GetPosts(app.cfg, c, page, isCollOwner, false, false)
+	posts, err := app.db.GetPosts(app.Config(), c, page, isCollOwner, false, false)
 	if err != nil {
 		return err
 	}
@@ -600,7 +636,7 @@ func processCollectionPermissions(app *App, cr *collectionReq, u *User, w http.R
 	// Display collection if this is a collection
 	var c *Collection
 	var err error
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(cr.alias)
@@ -633,7 +669,7 @@ func processCollectionPermissions(app *App, cr *collectionReq, u *User, w http.R
 		}
 		return nil, err
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	// Update CollectionRequest to reflect owner status
 	cr.isCollOwner = u != nil && u.ID == c.OwnerID
@@ -746,7 +782,7 @@ func handleViewCollection(app *App, w http.ResponseWriter, r *http.Request) erro
 	if c == nil || err != nil {
 		return err
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	suspended, err := app.db.IsUserSuspended(c.OwnerID)
 	if err != nil {
@@ -768,13 +804,13 @@ func handleViewCollection(app *App, w http.ResponseWriter, r *http.Request) erro
 	coll.TotalPages = int(math.Ceil(float64(coll.TotalPosts) / float64(coll.Format.PostsPerPage())))
 	if coll.TotalPages > 0 && page > coll.TotalPages {
 		redirURL := fmt.Sprintf("/page/%d", coll.TotalPages)
-		if !app.cfg.App.SingleUser {
+		if !app.Config().App.SingleUser {
 			redirURL = fmt.Sprintf("/%s%s%s", cr.prefix, coll.Alias, redirURL)
 		}
 		return impart.HTTPError{http.StatusFound, redirURL}
 	}
 
-	coll.Posts, _ = app.db.GetPosts(app.cfg, c, page, cr.isCollOwner, false, false)
+	coll.Posts, _ = app.db.GetPosts(app.Config(), c, page, cr.isCollOwner, false, false)
 
 	// Serve collection
 	displayPage := CollectionPage{
@@ -784,7 +820,7 @@ func handleViewCollection(app *App, w http.ResponseWriter, r *http.Request) erro
 		IsWelcome:         r.FormValue("greeting") != "",
 	}
 	displayPage.IsAdmin = u != nil && u.IsAdmin()
-	displayPage.CanInvite = canUserInvite(app.cfg, displayPage.IsAdmin)
+	displayPage.CanInvite = canUserInvite(app.Config(), displayPage.IsAdmin)
 	var owner *User
 	if u != nil {
 		displayPage.Username = u.Username
@@ -794,7 +830,7 @@ func handleViewCollection(app *App, w http.ResponseWriter, r *http.Request) erro
 			owner = u
 			displayPage.CanPin = true
 
-			pubColls, err := app.db.GetPublishableCollections(owner, app.cfg.App.Host)
+			pubColls, err := app.db.GetPublishableCollections(owner, app.Config().App.Host)
 			if err != nil {
 				log.Error("unable to fetch collections: %v", err)
 			}
@@ -822,7 +858,7 @@ func handleViewCollection(app *App, w http.ResponseWriter, r *http.Request) erro
 	displayPage.PinnedPosts, _ = app.db.GetPinnedPosts(coll.CollectionObj, isOwner)
 
 	collTmpl := "collection"
-	if app.cfg.App.Chorus {
+	if app.Config().App.Chorus {
 		collTmpl = "chorus-collection"
 	}
 	err = templates[collTmpl].ExecuteTemplate(w, "collection", displayPage)
@@ -874,7 +910,7 @@ func handleViewCollectionTag(app *App, w http.ResponseWriter, r *http.Request) e
 
 	coll := newDisplayCollection(c, cr, page)
 
-	coll.Posts, _ = app.db.GetPostsTagged(app.cfg, c, tag, page, cr.isCollOwner)
+	coll.Posts, _ = app.db.GetPostsTagged(app.Config(), c, tag, page, cr.isCollOwner)
 	if coll.Posts != nil && len(*coll.Posts) == 0 {
 		return ErrCollectionPageNotFound
 	}
@@ -900,7 +936,7 @@ func handleViewCollectionTag(app *App, w http.ResponseWriter, r *http.Request) e
 			owner = u
 			displayPage.CanPin = true
 
-			pubColls, err := app.db.GetPublishableCollections(owner, app.cfg.App.Host)
+			pubColls, err := app.db.GetPublishableCollections(owner, app.Config().App.Host)
 			if err != nil {
 				log.Error("unable to fetch collections: %v", err)
 			}
@@ -946,12 +982,25 @@ func handleCollectionPostRedirect(app *App, w http.ResponseWriter, r *http.Reque
 
 	// Normalize the URL, redirecting user to consistent post URL
 	loc := fmt.Sprintf("/%s", slug)
-	if !app.cfg.App.SingleUser {
+	if !app.Config().App.SingleUser {
 		loc = fmt.Sprintf("/%s/%s", cr.alias, slug)
 	}
 	return impart.HTTPError{http.StatusFound, loc}
 }
 
+// checkCollectionLength returns an error if the given blog title or
+// description is longer than the instance allows. Either can be nil when it
+// isn't being set.
+func checkCollectionLength(app *App, title, desc *string) error {
+	if title != nil && app.Config().App.BlogTitleTooLong(*title) {
+		return impart.HTTPError{http.StatusBadRequest, fmt.Sprintf("Blog title is too long. The maximum length is %d characters.", app.Config().App.MaxBlogTitleLen)}
+	}
+	if desc != nil && app.Config().App.BlogDescTooLong(*desc) {
+		return impart.HTTPError{http.StatusBadRequest, fmt.Sprintf("Blog description is too long. The maximum length is %d characters.", app.Config().App.MaxBlogDescLen)}
+	}
+	return nil
+}
+
 func existingCollection(app *App, w http.ResponseWriter, r *http.Request) error {
 	reqJSON := IsJSON(r)
 	vars := mux.Vars(r)
@@ -1018,7 +1067,10 @@ func existingCollection(app *App, w http.ResponseWriter, r *http.Request) error
 		}
 	}
 
-	err = app.db.UpdateCollection(&c, collAlias)
+	err = checkCollectionLength(app, c.Title, c.Description)
+	if err == nil {
+		err = app.db.UpdateCollection(app, &c, collAlias)
+	}
 	if err != nil {
 		if err, ok := err.(impart.HTTPError); ok {
 			if reqJSON {
@@ -1116,7 +1168,7 @@ func handleWebCollectionUnlock(app *App, w http.ResponseWriter, r *http.Request)
 	}
 
 	next := "/" + readReq.Next
-	if !app.cfg.App.SingleUser {
+	if !app.Config().App.SingleUser {
 		next = "/" + readReq.Alias + next
 	}
 	return impart.HTTPError{http.StatusFound, next}
@@ -0,0 +1,75 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/writeas/impart"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestNewCollectionAttributes(t *testing.T) {
+	for _, tc := range []struct {
+		Federate bool
+		Expected []string
+	}{
+		{true, nil},
+		{false, []string{collAttrNoFederation}},
+	} {
+		cfg := config.New()
+		cfg.App.FederateNewBlogs = tc.Federate
+		if attrs := newCollectionAttributes(cfg); !reflect.DeepEqual(attrs, tc.Expected) {
+			t.Errorf("FederateNewBlogs %t: attributes = %v; expected %v", tc.Federate, attrs, tc.Expected)
+		}
+	}
+}
+
+func TestCollectionLength(t *testing.T) {
+	app := newTestApp(config.New())
+	app.Config().App.MaxBlogTitleLen = 4
+	app.Config().App.MaxBlogDescLen = 8
+	str := func(s string) *string { return &s }
+
+	for _, tc := range []struct {
+		Name        string
+		Title, Desc *string
+		Error       string
+	}{
+		{"At the limits", str("Café"), str("Ünïcödé!"), ""},
+		{"Title over", str("Cafés"), nil, "Blog title is too long. The maximum length is 4 characters."},
+		{"Description over", nil, str("Ünïcödé!!"), "Blog description is too long. The maximum length is 8 characters."},
+		{"Neither set", nil, nil, ""},
+	} {
+		err := checkCollectionLength(app, tc.Title, tc.Desc)
+		if tc.Error == "" {
+			if err != nil {
+				t.Errorf("%s: error %v; expected none", tc.Name, err)
+			}
+			continue
+		}
+		if herr, ok := err.(impart.HTTPError); !ok || herr.Status != http.StatusBadRequest || herr.Message != tc.Error {
+			t.Errorf("%s: error %v; expected a 400 saying %q", tc.Name, err, tc.Error)
+		}
+	}
+
+	// New blogs are checked before anything else is done with them
+	r := httptest.NewRequest("POST", "/api/collections", strings.NewReader(`{"alias":"cafe","title":"Café au lait"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "00000000-0000-0000-0000-000000000000")
+	if err := newCollection(app, httptest.NewRecorder(), r); err == nil || !strings.Contains(err.Error(), "Blog title is too long") {
+		t.Errorf("Creating a blog with a long title returned %v", err)
+	}
+}
@@ -12,14 +12,38 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"github.com/writeas/web-core/log"
 	"gopkg.in/ini.v1"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 )
 
+// includeSection is the INI section that lists other configuration files
+// to load first.
+const includeSection = "include"
+
+// ErrConfigNotFound is returned when loading a configuration file that
+// doesn't exist, as opposed to one that can't be parsed.
+var ErrConfigNotFound = errors.New("Configuration file not found")
+
+// stdin is where Load reads the configuration from for StdinFileName.
+var stdin io.Reader = os.Stdin
+
 const (
 	// FileName is the default configuration file name
 	FileName = "config.ini"
 
+	// StdinFileName is the configuration file name that's read from
+	// standard input, for configurations piped in at deploy time. Since
+	// it's only there once, it can't be reloaded or saved.
+	StdinFileName = "-"
+
 	UserNormal UserType = "user"
 	UserAdmin           = "admin"
 )
@@ -29,109 +53,636 @@ type (
 
 	// ServerCfg holds values that affect how the HTTP server runs
 	ServerCfg struct {
-		HiddenHost string `ini:"hidden_host"`
-		Port       int    `ini:"port"`
-		Bind       string `ini:"bind"`
+		HiddenHost string `ini:"hidden_host" json:"hidden_host" yaml:"hidden_host"`
+		Port       int    `ini:"port" json:"port" yaml:"port"`
+		Bind       string `ini:"bind" json:"bind" yaml:"bind"`
+
+		TLSCertPath string `ini:"tls_cert_path" json:"tls_cert_path" yaml:"tls_cert_path"`
+		TLSKeyPath  string `ini:"tls_key_path" json:"tls_key_path" yaml:"tls_key_path"`
+
+		// Autocert obtains certificates from Let's Encrypt for the
+		// AutoCertHosts, caching them in AutoCertDir, instead of using the
+		// TLS cert and key paths.
+		Autocert      bool     `ini:"autocert" json:"autocert" yaml:"autocert"`
+		AutoCertDir   string   `ini:"autocert_dir" json:"autocert_dir" yaml:"autocert_dir"`
+		AutoCertHosts []string `ini:"autocert_hosts" delim:"," json:"autocert_hosts" yaml:"autocert_hosts,omitempty"`
+
+		// TrustedProxies are the CIDRs or IP addresses of reverse proxies
+		// whose X-Forwarded-For and X-Real-IP headers are honored.
+		TrustedProxies []string `ini:"trusted_proxies" delim:"," json:"trusted_proxies" yaml:"trusted_proxies,omitempty"`
+
+		// UseProxyHeaders builds URLs from the scheme and host in the
+		// X-Forwarded-Proto and X-Forwarded-Host headers of requests from
+		// TrustedProxies.
+		UseProxyHeaders bool `ini:"use_proxy_headers" json:"use_proxy_headers" yaml:"use_proxy_headers"`
+
+		// RedirectHTTP starts a listener on port 80 that redirects all
+		// requests to HTTPS when running as a secure standalone server.
+		// With Autocert, port 80 is always served, to answer ACME
+		// challenges.
+		RedirectHTTP bool `ini:"redirect_http" json:"redirect_http" yaml:"redirect_http"`
+
+		// EnforceCanonicalHost permanently redirects requests for any host
+		// other than the one in the app's Host to that host. Health and
+		// readiness checks are still served at any host, like the bind IP.
+		EnforceCanonicalHost bool `ini:"enforce_canonical_host" json:"enforce_canonical_host" yaml:"enforce_canonical_host"`
+
+		TemplatesParentDir string `ini:"templates_parent_dir" json:"templates_parent_dir" yaml:"templates_parent_dir"`
+		StaticParentDir    string `ini:"static_parent_dir" json:"static_parent_dir" yaml:"static_parent_dir"`
+		PagesParentDir     string `ini:"pages_parent_dir" json:"pages_parent_dir" yaml:"pages_parent_dir"`
+		KeysParentDir      string `ini:"keys_parent_dir" json:"keys_parent_dir" yaml:"keys_parent_dir"`
+
+		// DataDir is the directory that relative paths to the data the app
+		// writes, like the SQLite database and the keys, are resolved
+		// against. Empty means the working directory.
+		DataDir string `ini:"data_dir" json:"data_dir" yaml:"data_dir"`
+
+		// HTTP server timeouts, as durations like "10s". Empty means no
+		// timeout.
+		ReadTimeout  string `ini:"read_timeout" json:"read_timeout" yaml:"read_timeout"`
+		WriteTimeout string `ini:"write_timeout" json:"write_timeout" yaml:"write_timeout"`
+		IdleTimeout  string `ini:"idle_timeout" json:"idle_timeout" yaml:"idle_timeout"`
+
+		// ShutdownTimeout is how long to wait for in-flight requests to
+		// finish when shutting down.
+		ShutdownTimeout string `ini:"shutdown_timeout" json:"shutdown_timeout" yaml:"shutdown_timeout"`
 
-		TLSCertPath string `ini:"tls_cert_path"`
-		TLSKeyPath  string `ini:"tls_key_path"`
-		Autocert    bool   `ini:"autocert"`
+		// MetricsEnabled serves Prometheus metrics at MetricsPath, which is
+		// /metrics when empty. When MetricsToken is set, scrapers must send
+		// it as a bearer token.
+		MetricsEnabled bool   `ini:"metrics_enabled" json:"metrics_enabled" yaml:"metrics_enabled"`
+		MetricsPath    string `ini:"metrics_path" json:"metrics_path" yaml:"metrics_path"`
+		MetricsToken   string `ini:"metrics_token" json:"metrics_token" yaml:"metrics_token"`
 
-		TemplatesParentDir string `ini:"templates_parent_dir"`
-		StaticParentDir    string `ini:"static_parent_dir"`
-		PagesParentDir     string `ini:"pages_parent_dir"`
-		KeysParentDir      string `ini:"keys_parent_dir"`
+		// HealthPath and ReadyPath serve liveness and readiness checks for
+		// orchestrators, at /healthz and /readyz when empty. The readiness
+		// check fails when the database is unreachable. HealthDisabled
+		// turns both off.
+		HealthPath     string `ini:"health_path" json:"health_path" yaml:"health_path"`
+		ReadyPath      string `ini:"ready_path" json:"ready_path" yaml:"ready_path"`
+		HealthDisabled bool   `ini:"health_disabled" json:"health_disabled" yaml:"health_disabled"`
 
-		Dev bool `ini:"-"`
+		Dev bool `ini:"-" json:"-" yaml:"-"`
 	}
 
 	// DatabaseCfg holds values that determine how the application connects to a datastore
 	DatabaseCfg struct {
-		Type     string `ini:"type"`
-		FileName string `ini:"filename"`
-		User     string `ini:"username"`
-		Password string `ini:"password"`
-		Database string `ini:"database"`
-		Host     string `ini:"host"`
-		Port     int    `ini:"port"`
+		Type     string `ini:"type" json:"type" yaml:"type"`
+		FileName string `ini:"filename" json:"filename" yaml:"filename"`
+		User     string `ini:"username" json:"username" yaml:"username"`
+		Password string `ini:"password" json:"password" yaml:"password"`
+
+		// PasswordFile is the path to a file containing the database
+		// password, for use instead of Password.
+		PasswordFile string `ini:"password_file" json:"password_file" yaml:"password_file"`
+
+		Database string `ini:"database" json:"database" yaml:"database"`
+		Host     string `ini:"host" json:"host" yaml:"host"`
+		Port     int    `ini:"port" json:"port" yaml:"port"`
+
+		// DSN is a driver-specific connection string that's used verbatim
+		// instead of building one from Host, Port, Socket, User, Password,
+		// Database, and TLS, which are ignored. MySQL DSNs must include
+		// parseTime=true.
+		DSN string `ini:"dsn" json:"dsn" yaml:"dsn"`
+
+		// Socket is the path to a Unix domain socket to connect through
+		// instead of Host and Port.
+		Socket string `ini:"socket" json:"socket" yaml:"socket"`
+
+		// TLS sets how connections to MySQL are encrypted: "disable",
+		// "require", "verify-ca", or "verify-full". Empty means the driver
+		// default.
+		TLS string `ini:"tls" json:"tls" yaml:"tls"`
+
+		// ReadReplicas are MySQL replicas that some reads,
+		// like blog post listings, are spread across. Each is a host:port
+		// that's connected to with the other database settings, or a DSN.
+		ReadReplicas []string `ini:"read_replicas" delim:"," json:"read_replicas" yaml:"read_replicas,omitempty"`
+
+		// Connection pool. Zero MaxOpenConns means unlimited, zero
+		// MaxIdleConns keeps the database/sql default, and an empty
+		// ConnMaxLifetime (a duration like "5m") reuses connections forever.
+		// SQLite databases always use a single connection.
+		MaxOpenConns    int    `ini:"max_open_conns" json:"max_open_conns" yaml:"max_open_conns"`
+		MaxIdleConns    int    `ini:"max_idle_conns" json:"max_idle_conns" yaml:"max_idle_conns"`
+		ConnMaxLifetime string `ini:"conn_max_lifetime" json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+
+		// QueryTimeout is how long a query can run, like 10s, before it's
+		// cancelled. Zero or empty lets queries run indefinitely.
+		QueryTimeout string `ini:"query_timeout" json:"query_timeout" yaml:"query_timeout"`
+
+		// ConnectRetries is how many more times to try connecting at
+		// startup when the database isn't ready yet. The first retry waits
+		// ConnectRetryDelay, like 1s, and each one after waits twice as
+		// long as the last.
+		ConnectRetries    int    `ini:"connect_retries" json:"connect_retries" yaml:"connect_retries"`
+		ConnectRetryDelay string `ini:"connect_retry_delay" json:"connect_retry_delay" yaml:"connect_retry_delay"`
+
+		// SQLite tuning: WAL enables write-ahead logging, and BusyTimeout is
+		// how long, in milliseconds, to wait on a locked database.
+		WAL         bool `ini:"wal" json:"wal" yaml:"wal"`
+		BusyTimeout int  `ini:"busy_timeout" json:"busy_timeout" yaml:"busy_timeout"`
 	}
 
 	// AppCfg holds values that affect how the application functions
 	AppCfg struct {
-		SiteName string `ini:"site_name"`
-		SiteDesc string `ini:"site_description"`
-		Host     string `ini:"host"`
+		SiteName string `ini:"site_name" json:"site_name" yaml:"site_name"`
+		SiteDesc string `ini:"site_description" json:"site_description" yaml:"site_description"`
+		Host     string `ini:"host" json:"host" yaml:"host"`
 
 		// Site appearance
-		Theme      string `ini:"theme"`
-		Editor     string `ini:"editor"`
-		JSDisabled bool   `ini:"disable_js"`
-		WebFonts   bool   `ini:"webfonts"`
-		Landing    string `ini:"landing"`
-		SimpleNav  bool   `ini:"simple_nav"`
-		WFModesty  bool   `ini:"wf_modesty"`
+		Theme      string `ini:"theme" json:"theme" yaml:"theme"`
+		Editor     string `ini:"editor" json:"editor" yaml:"editor"`
+		JSDisabled bool   `ini:"disable_js" json:"disable_js" yaml:"disable_js"`
+		WebFonts   bool   `ini:"webfonts" json:"webfonts" yaml:"webfonts"`
+		Landing    string `ini:"landing" json:"landing" yaml:"landing"`
+		SimpleNav  bool   `ini:"simple_nav" json:"simple_nav" yaml:"simple_nav"`
+		WFModesty  bool   `ini:"wf_modesty" json:"wf_modesty" yaml:"wf_modesty"`
+
+		// WebFontSource is where pages load their fonts from: "local" serves
+		// the bundled fonts, "cdn" loads them from Google Fonts, and "none"
+		// uses the visitor's own fonts. It replaces WebFonts, which configs
+		// without a webfont_source fall back to.
+		WebFontSource string `ini:"webfont_source" json:"webfont_source" yaml:"webfont_source"`
+
+		// DefaultBlogTheme is the theme blogs are shown with, apart from the
+		// site's own pages, which use Theme. Empty means Theme.
+		DefaultBlogTheme string `ini:"default_blog_theme" json:"default_blog_theme" yaml:"default_blog_theme"`
+
+		// MarkdownExtensions are the Markdown features, like tables or
+		// footnotes, that posts and pages are rendered with. Raw HTML is
+		// one of them, and is still sanitized when it's enabled.
+		MarkdownExtensions []string `ini:"markdown_extensions" delim:"," json:"markdown_extensions" yaml:"markdown_extensions,omitempty"`
+
+		// Maintenance responds to everyone but admins with a 503 and the
+		// MaintenanceMessage. Requests with the MaintenanceToken in the
+		// X-Maintenance-Token header are let through.
+		Maintenance        bool   `ini:"maintenance" json:"maintenance" yaml:"maintenance"`
+		MaintenanceMessage string `ini:"maintenance_message" json:"maintenance_message" yaml:"maintenance_message"`
+		MaintenanceToken   string `ini:"maintenance_token" json:"maintenance_token" yaml:"maintenance_token"`
+
+		// Lang is the language of the instance's interface, like en. Empty
+		// means en. Unsupported languages fall back to en when loading.
+		Lang string `ini:"language" json:"language" yaml:"language"`
+
+		// ThemesDir is a directory of theme stylesheets, like
+		// {theme}.css, that are used before the built-in ones. Relative
+		// paths are resolved from the working directory.
+		ThemesDir string `ini:"themes_dir" json:"themes_dir" yaml:"themes_dir"`
+
+		// CustomCSSPath is a stylesheet that's linked after the theme on
+		// every page, for small branding changes. It's read once at
+		// startup. Empty means no custom CSS.
+		CustomCSSPath string `ini:"custom_css_path" json:"custom_css_path" yaml:"custom_css_path"`
+
+		// FaviconPath and LogoPath are image files used instead of the
+		// default favicon and the site name in the page header. Missing
+		// files fall back to the defaults at startup.
+		FaviconPath string `ini:"favicon_path" json:"favicon_path" yaml:"favicon_path"`
+		LogoPath    string `ini:"logo_path" json:"logo_path" yaml:"logo_path"`
+
+		// DisallowCrawlers makes the generated robots.txt ask all crawlers
+		// to stay away. RobotsTxtPath, when set, is served as robots.txt
+		// instead.
+		DisallowCrawlers bool   `ini:"disallow_crawlers" json:"disallow_crawlers" yaml:"disallow_crawlers"`
+		RobotsTxtPath    string `ini:"robots_txt_path" json:"robots_txt_path" yaml:"robots_txt_path"`
+
+		// CSP is sent as the Content-Security-Policy header on HTML pages.
+		// Empty means no header is sent.
+		CSP string `ini:"csp" json:"csp" yaml:"csp"`
 
 		// Site functionality
-		Chorus        bool `ini:"chorus"`
-		DisableDrafts bool `ini:"disable_drafts"`
+		Chorus        bool `ini:"chorus" json:"chorus" yaml:"chorus"`
+		DisableDrafts bool `ini:"disable_drafts" json:"disable_drafts" yaml:"disable_drafts"`
+
+		// MaxPostLength limits the number of characters in a post. Zero, the
+		// default, means unlimited.
+		MaxPostLength int `ini:"max_post_length" json:"max_post_length" yaml:"max_post_length"`
+
+		// BlockedWords are words or phrases that new posts are checked for,
+		// ignoring case and matching whole words only. BlockedWordAction
+		// is "reject", the default, to refuse the post, or "flag" to
+		// publish it and log a warning for admins.
+		BlockedWords      []string `ini:"blocked_words" delim:"," json:"blocked_words" yaml:"blocked_words,omitempty"`
+		BlockedWordAction string   `ini:"blocked_word_action" json:"blocked_word_action" yaml:"blocked_word_action"`
+
+		// MaxAPIBodyBytes limits the size of request bodies sent to the
+		// API. Zero means unlimited. Multipart bodies, like uploads, are
+		// limited by the storage max_upload_bytes instead, unless it's zero.
+		MaxAPIBodyBytes int64 `ini:"max_api_body_bytes" json:"max_api_body_bytes" yaml:"max_api_body_bytes"`
 
 		// Users
-		SingleUser       bool `ini:"single_user"`
-		OpenRegistration bool `ini:"open_registration"`
-		MinUsernameLen   int  `ini:"min_username_len"`
-		MaxBlogs         int  `ini:"max_blogs"`
+		SingleUser       bool `ini:"single_user" json:"single_user" yaml:"single_user"`
+		OpenRegistration bool `ini:"open_registration" json:"open_registration" yaml:"open_registration"`
+		MinUsernameLen   int  `ini:"min_username_len" json:"min_username_len" yaml:"min_username_len"`
+		MaxBlogs         int  `ini:"max_blogs" json:"max_blogs" yaml:"max_blogs"`
+
+		// MaxDrafts limits how many posts each user can keep outside of a
+		// blog. Zero means unlimited.
+		MaxDrafts int `ini:"max_drafts" json:"max_drafts" yaml:"max_drafts"`
+
+		// MaxBlogTitleLen and MaxBlogDescLen limit the number of characters
+		// in blog titles and descriptions. Zero means unlimited.
+		MaxBlogTitleLen int `ini:"max_blog_title_len" json:"max_blog_title_len" yaml:"max_blog_title_len"`
+		MaxBlogDescLen  int `ini:"max_blog_desc_len" json:"max_blog_desc_len" yaml:"max_blog_desc_len"`
+
+		// MinPasswordLen is the fewest characters a password can have when
+		// signing up or changing it. Zero means any non-empty password.
+		MinPasswordLen int `ini:"min_password_len" json:"min_password_len" yaml:"min_password_len"`
+
+		// RequireStrongPassword also requires upper and lowercase letters,
+		// and a number or symbol.
+		RequireStrongPassword bool `ini:"require_strong_password" json:"require_strong_password" yaml:"require_strong_password"`
+
+		// BcryptCost is the work factor for hashing passwords, from 4 to 31.
+		// It defaults to 12. Existing hashes keep their cost until the
+		// password is changed.
+		BcryptCost int `ini:"bcrypt_cost" json:"bcrypt_cost" yaml:"bcrypt_cost"`
+
+		// RegistrationMode is open, closed, or invite, where signing up
+		// requires a valid invite code. It replaces OpenRegistration, which
+		// is kept in sync when the config is loaded.
+		RegistrationMode string `ini:"registration_mode" json:"registration_mode" yaml:"registration_mode"`
+
+		// RequireApproval creates new users in a pending state, so they
+		// can't log in until an admin approves them.
+		RequireApproval bool `ini:"require_approval" json:"require_approval" yaml:"require_approval"`
+
+		// ReservedUsernames can't be registered as usernames or blog
+		// aliases, in addition to the application's built-in list.
+		ReservedUsernames []string `ini:"reserved_usernames" delim:"," json:"reserved_usernames" yaml:"reserved_usernames,omitempty"`
 
 		// Federation
-		Federation  bool `ini:"federation"`
-		PublicStats bool `ini:"public_stats"`
+		Federation bool `ini:"federation" json:"federation" yaml:"federation"`
+
+		// FederateNewBlogs is whether new blogs start out federated, when
+		// Federation is enabled. Owners can change it per blog.
+		FederateNewBlogs bool `ini:"federate_new_blogs" json:"federate_new_blogs" yaml:"federate_new_blogs"`
+
+		// PublicStats is how much usage data the about page and NodeInfo
+		// report: none, basic for post and blog totals, or full to also
+		// include active users.
+		PublicStats string `ini:"public_stats" json:"public_stats" yaml:"public_stats"`
+
+		// AllowedInstances, when set, are the only instances that can send
+		// activities to this one. Otherwise, any instance not in
+		// BlockedInstances can. A leading dot, as in .example.com, also
+		// matches subdomains.
+		AllowedInstances []string `ini:"allowed_instances" delim:"," json:"allowed_instances" yaml:"allowed_instances,omitempty"`
+		BlockedInstances []string `ini:"blocked_instances" delim:"," json:"blocked_instances" yaml:"blocked_instances,omitempty"`
+
+		// AllowedSignatureAlgs are the HTTP Signature algorithms, like
+		// rsa-sha256, that activities sent to an inbox can be signed with.
+		// Empty allows the defaults, rsa-sha256 and hs2019.
+		AllowedSignatureAlgs []string `ini:"allowed_signature_algs" delim:"," json:"allowed_signature_algs" yaml:"allowed_signature_algs,omitempty"`
+
+		// AcceptUnsignedActivities lets activities without an HTTP Signature
+		// into inboxes, for instances that don't sign their deliveries yet.
+		// Anyone can send these as any actor. Signed activities are still
+		// verified.
+		AcceptUnsignedActivities bool `ini:"accept_unsigned_activities" json:"accept_unsigned_activities" yaml:"accept_unsigned_activities"`
+
+		// NodeInfoEnabled serves the NodeInfo endpoints that federation
+		// discovery tools read the instance's software and usage from. The
+		// usage shown is limited by PublicStats.
+		NodeInfoEnabled bool `ini:"nodeinfo" json:"nodeinfo" yaml:"nodeinfo"`
+
+		// AdminEmail and ContactURL tell visitors and other instances who
+		// runs this one. When set, they're shown on the about page and in
+		// the NodeInfo metadata.
+		AdminEmail string `ini:"admin_email" json:"admin_email" yaml:"admin_email"`
+		ContactURL string `ini:"contact_url" json:"contact_url" yaml:"contact_url"`
+
+		// IgnoredActivities are the ActivityPub activity types, like Like or
+		// Announce, that inboxes acknowledge without processing. Empty
+		// processes everything.
+		IgnoredActivities []string `ini:"ignored_activities" delim:"," json:"ignored_activities" yaml:"ignored_activities,omitempty"`
+
+		// FederationCacheTTL is how long remote actors are kept in memory
+		// after being fetched, like 1h. Zero or empty disables the cache.
+		FederationCacheTTL string `ini:"federation_cache_ttl" json:"federation_cache_ttl" yaml:"federation_cache_ttl"`
+
+		// FederationTimeout is how long to wait for another instance to
+		// respond to a request, like 30s, which is the default when empty.
+		// Zero waits indefinitely.
+		FederationTimeout string `ini:"federation_timeout" json:"federation_timeout" yaml:"federation_timeout"`
+
+		// MaxFederationConcurrency is how many activities can be delivered
+		// to other instances at once, across all posts. Zero delivers to
+		// each post's followers one inbox at a time, without a limit on
+		// how many posts are delivered at once.
+		MaxFederationConcurrency int `ini:"max_federation_concurrency" json:"max_federation_concurrency" yaml:"max_federation_concurrency"`
+
+		// ScheduledPublishInterval is how often, as a duration like 1m, to
+		// look for scheduled posts that have come due, so they can be sent
+		// to followers. Empty or zero turns this off.
+		ScheduledPublishInterval string `ini:"scheduled_publish_interval" json:"scheduled_publish_interval" yaml:"scheduled_publish_interval"`
 
 		// Access
-		Private bool `ini:"private"`
+		Private bool `ini:"private" json:"private" yaml:"private"`
+
+		// NormalizeURLs permanently redirects blog and post URLs with
+		// uppercase letters, or posts with a trailing slash, to their
+		// canonical lowercase form.
+		NormalizeURLs bool `ini:"normalize_urls" json:"normalize_urls" yaml:"normalize_urls"`
+
+		// AllowAnonymous lets visitors without an account publish posts
+		// outside of any blog. Each post comes with a token that can be
+		// used to edit or delete it, or to claim it after signing up.
+		// Turning it off also stops posts from being edited with a token.
+		AllowAnonymous bool `ini:"allow_anonymous" json:"allow_anonymous" yaml:"allow_anonymous"`
+
+		// APITokenTTL is how long, as a duration like 720h, access tokens
+		// issued to API clients stay valid. Empty or zero means they don't
+		// expire.
+		APITokenTTL string `ini:"api_token_ttl" json:"api_token_ttl" yaml:"api_token_ttl"`
+
+		// CORSOrigins are the origins, like https://admin.example.com,
+		// allowed to call the API from a browser. "*" allows any origin,
+		// but can't be combined with CORSCredentials, which lets those
+		// origins send cookies.
+		CORSOrigins     []string `ini:"cors_origins" delim:"," json:"cors_origins" yaml:"cors_origins,omitempty"`
+		CORSCredentials bool     `ini:"cors_credentials" json:"cors_credentials" yaml:"cors_credentials"`
 
 		// Additional functions
-		LocalTimeline bool   `ini:"local_timeline"`
-		UserInvites   string `ini:"user_invites"`
+		LocalTimeline bool   `ini:"local_timeline" json:"local_timeline" yaml:"local_timeline"`
+		UserInvites   string `ini:"user_invites" json:"user_invites" yaml:"user_invites"`
+
+		// LocalTimelinePublic shows the local timeline, which is built when
+		// LocalTimeline is on, to everyone. Otherwise, only admins can see
+		// it, to moderate what would be shown there.
+		LocalTimelinePublic bool `ini:"local_timeline_public" json:"local_timeline_public" yaml:"local_timeline_public"`
 
 		// Defaults
-		DefaultVisibility string `ini:"default_visibility"`
+		// DefaultVisibility is the visibility of new blogs: unlisted (the
+		// default when empty), public, or private
+		DefaultVisibility string `ini:"default_visibility" json:"default_visibility" yaml:"default_visibility"`
+	}
+
+	// EmailCfg holds values for sending email over SMTP, for things like
+	// password resets
+	EmailCfg struct {
+		SMTPHost     string `ini:"smtp_host" json:"smtp_host" yaml:"smtp_host"`
+		SMTPPort     int    `ini:"smtp_port" json:"smtp_port" yaml:"smtp_port"`
+		SMTPUser     string `ini:"smtp_user" json:"smtp_user" yaml:"smtp_user"`
+		SMTPPassword string `ini:"smtp_password" json:"smtp_password" yaml:"smtp_password"`
+		FromAddress  string `ini:"from_address" json:"from_address" yaml:"from_address"`
+		UseTLS       bool   `ini:"use_tls" json:"use_tls" yaml:"use_tls"`
+	}
+
+	// StorageCfg holds values that determine where uploaded media is stored
+	StorageCfg struct {
+		// Type is "local", the default when empty, or "s3"
+		Type string `ini:"type" json:"type" yaml:"type"`
+
+		S3Endpoint  string `ini:"s3_endpoint" json:"s3_endpoint" yaml:"s3_endpoint"`
+		S3Bucket    string `ini:"s3_bucket" json:"s3_bucket" yaml:"s3_bucket"`
+		S3Region    string `ini:"s3_region" json:"s3_region" yaml:"s3_region"`
+		S3AccessKey string `ini:"s3_access_key" json:"s3_access_key" yaml:"s3_access_key"`
+		S3SecretKey string `ini:"s3_secret_key" json:"s3_secret_key" yaml:"s3_secret_key"`
+
+		// BaseURL is the public URL that stored media is served from
+		BaseURL string `ini:"base_url" json:"base_url" yaml:"base_url"`
+
+		// MaxUploadBytes limits the size of multipart API request bodies,
+		// which is how files are uploaded. Zero leaves them to the app
+		// max_api_body_bytes.
+		MaxUploadBytes int64 `ini:"max_upload_bytes" json:"max_upload_bytes" yaml:"max_upload_bytes"`
+	}
+
+	// RateLimitCfg holds the number of requests a single client can make to
+	// certain routes. Zero means unlimited.
+	RateLimitCfg struct {
+		LoginPerMinute int `ini:"login_per_minute" json:"login_per_minute" yaml:"login_per_minute"`
+		SignupPerHour  int `ini:"signup_per_hour" json:"signup_per_hour" yaml:"signup_per_hour"`
+		APIPerMinute   int `ini:"api_per_minute" json:"api_per_minute" yaml:"api_per_minute"`
+		// AnonymousPostsPerHour limits posts made without an account, which
+		// have no owner for app max_drafts to count against.
+		AnonymousPostsPerHour int `ini:"anonymous_posts_per_hour" json:"anonymous_posts_per_hour" yaml:"anonymous_posts_per_hour"`
+	}
+
+	// LogCfg holds values that affect how the application logs
+	LogCfg struct {
+		// Level is debug, info, warn, or error
+		Level string `ini:"level" json:"level" yaml:"level"`
+		// Format is text or json
+		Format string `ini:"format" json:"format" yaml:"format"`
+		// File is the path to append logs to. Empty means the console.
+		File string `ini:"file" json:"file" yaml:"file"`
+		// ExcludePaths are request paths, like /favicon.ico, that aren't
+		// written to the request log. Paths ending in a slash, like /css/,
+		// exclude everything under them.
+		ExcludePaths []string `ini:"exclude_paths" delim:"," json:"exclude_paths" yaml:"exclude_paths,omitempty"`
+	}
+
+	// CacheCfg holds values for where sessions are stored. Running several
+	// app instances behind a load balancer needs a shared store like Redis.
+	CacheCfg struct {
+		// Type is memory (the default when empty) or redis
+		Type string `ini:"type" json:"type" yaml:"type"`
+
+		RedisHost     string `ini:"redis_host" json:"redis_host" yaml:"redis_host"`
+		RedisPort     int    `ini:"redis_port" json:"redis_port" yaml:"redis_port"`
+		RedisPassword string `ini:"redis_password" json:"redis_password" yaml:"redis_password"`
+		RedisDB       int    `ini:"redis_db" json:"redis_db" yaml:"redis_db"`
+	}
+
+	// OAuthCfg holds values for logging in through a generic OpenID Connect
+	// provider, like a company's single sign-on.
+	OAuthCfg struct {
+		// ProviderName is shown on the login button, like "Log in with Acme"
+		ProviderName string `ini:"provider_name" json:"provider_name" yaml:"provider_name"`
+
+		ClientID     string `ini:"client_id" json:"client_id" yaml:"client_id"`
+		ClientSecret string `ini:"client_secret" json:"client_secret" yaml:"client_secret"`
+
+		AuthURL     string `ini:"auth_url" json:"auth_url" yaml:"auth_url"`
+		TokenURL    string `ini:"token_url" json:"token_url" yaml:"token_url"`
+		UserInfoURL string `ini:"userinfo_url" json:"userinfo_url" yaml:"userinfo_url"`
+
+		// Scopes requested from the provider, which default to openid,
+		// profile, and email
+		Scopes []string `ini:"scopes" delim:"," json:"scopes" yaml:"scopes,omitempty"`
+
+		// CallbackURL is the URL the provider sends users back to, which
+		// defaults to the instance's /oauth/callback
+		CallbackURL string `ini:"callback_url" json:"callback_url" yaml:"callback_url"`
+	}
+
+	// SessionCfg holds values for the cookies that keep users logged in.
+	SessionCfg struct {
+		// CookieName defaults to wfu
+		CookieName string `ini:"cookie_name" json:"cookie_name" yaml:"cookie_name"`
+
+		// CookieSecure sends cookies over HTTPS only. It's always on when the
+		// host is https.
+		CookieSecure bool `ini:"cookie_secure" json:"cookie_secure" yaml:"cookie_secure"`
+
+		// CookieSameSite is lax, strict, or none. Empty leaves the attribute
+		// off, so browsers use their default.
+		CookieSameSite string `ini:"cookie_samesite" json:"cookie_samesite" yaml:"cookie_samesite"`
+
+		// CookieMaxAge is how long users stay logged in, like 720h. It
+		// defaults to 180 days.
+		CookieMaxAge string `ini:"cookie_max_age" json:"cookie_max_age" yaml:"cookie_max_age"`
+	}
+
+	// WebhookCfg holds values for notifying another service, like an
+	// internal system, when posts are published.
+	WebhookCfg struct {
+		// URL receives a POST with a JSON payload for each event
+		URL string `ini:"url" json:"url" yaml:"url"`
+
+		// Secret signs each payload with HMAC-SHA256, sent in the
+		// X-WriteFreely-Signature header
+		Secret string `ini:"secret" json:"secret" yaml:"secret"`
+
+		// Events are the events to send, like post.published. Empty sends
+		// all of them.
+		Events []string `ini:"events" delim:"," json:"events" yaml:"events,omitempty"`
+
+		// Retries is how many more times a delivery that fails is attempted
+		// before giving up. Zero tries once.
+		Retries int `ini:"retries" json:"retries" yaml:"retries"`
 	}
 
 	// Config holds the complete configuration for running a writefreely instance
 	Config struct {
-		Server   ServerCfg   `ini:"server"`
-		Database DatabaseCfg `ini:"database"`
-		App      AppCfg      `ini:"app"`
+		// Version is the layout version the configuration was written for
+		Version int `ini:"version" json:"version" yaml:"version"`
+
+		Server    ServerCfg    `ini:"server" json:"server" yaml:"server"`
+		Database  DatabaseCfg  `ini:"database" json:"database" yaml:"database"`
+		App       AppCfg       `ini:"app" json:"app" yaml:"app"`
+		Email     EmailCfg     `ini:"email" json:"email" yaml:"email"`
+		Storage   StorageCfg   `ini:"storage" json:"storage" yaml:"storage"`
+		RateLimit RateLimitCfg `ini:"rate_limit" json:"rate_limit" yaml:"rate_limit"`
+		Log       LogCfg       `ini:"log" json:"log" yaml:"log"`
+		Cache     CacheCfg     `ini:"cache" json:"cache" yaml:"cache"`
+		OAuth     OAuthCfg     `ini:"oauth" json:"oauth" yaml:"oauth"`
+		Session   SessionCfg   `ini:"session" json:"session" yaml:"session"`
+		Webhook   WebhookCfg   `ini:"webhook" json:"webhook" yaml:"webhook"`
 	}
 )
 
+// Defaults used by New, and by UseMySQL and UseSQLite for fresh databases.
+const (
+	DefaultPort           = 8080
+	DefaultBind           = "localhost"
+	DefaultHost           = "http://localhost:8080"
+	DefaultTheme          = "write"
+	DefaultMinUsernameLen = 3
+	DefaultMinPasswordLen = 8
+	DefaultMaxBlogs       = 1
+
+	DefaultMaxBlogTitleLen = 100
+	DefaultMaxBlogDescLen  = 500
+
+	DefaultMaxFederationConcurrency = 10
+
+	// DefaultLang is the language the interface is written in.
+	DefaultLang = "en"
+
+	DefaultMaxUploadBytes  = 10 << 20
+	DefaultMaxAPIBodyBytes = 1 << 20
+
+	DefaultMySQLPort      = 3306
+	DefaultSQLiteFileName = "writefreely.db"
+)
+
+// DefaultReservedUsernames can't be registered, since they'd clash with the
+// app's own routes. New gives each Config its own copy.
+var DefaultReservedUsernames = []string{
+	"a", "about", "admin", "api", "auth", "c", "claim", "collections",
+	"disperse", "export", "feed", "invite", "invites", "login", "logout",
+	"me", "new", "p", "page", "password", "posts", "privacy", "read",
+	"settings", "signup", "sitemap", "t", "tag", "tags",
+}
+
 // New creates a new Config with sane defaults
 func New() *Config {
+	return NewWithOptions()
+}
+
+// NewWithOptions returns a new default Config, like New, with the given
+// options applied in order.
+func NewWithOptions(opts ...Option) *Config {
 	c := &Config{
+		Version: CurrentVersion,
 		Server: ServerCfg{
-			Port: 8080,
-			Bind: "localhost", /* IPV6 support when not using localhost? */
+			Port:         DefaultPort,
+			Bind:         DefaultBind,
+			ReadTimeout:  "5s",
+			WriteTimeout: "10s",
+			IdleTimeout:  "120s",
+
+			ShutdownTimeout: "30s",
 		},
 		App: AppCfg{
-			Host:           "http://localhost:8080",
-			Theme:          "write",
-			WebFonts:       true,
-			SingleUser:     true,
-			MinUsernameLen: 3,
-			MaxBlogs:       1,
-			Federation:     true,
-			PublicStats:    true,
+			Host:              DefaultHost,
+			Theme:             DefaultTheme,
+			WebFontSource:     WebFontsLocal,
+			WebFonts:          true,
+			SingleUser:        true,
+			MinUsernameLen:    DefaultMinUsernameLen,
+			MinPasswordLen:    DefaultMinPasswordLen,
+			BcryptCost:        DefaultBcryptCost,
+			ReservedUsernames: append([]string(nil), DefaultReservedUsernames...),
+			MaxBlogs:          DefaultMaxBlogs,
+			MaxBlogTitleLen:   DefaultMaxBlogTitleLen,
+			MaxBlogDescLen:    DefaultMaxBlogDescLen,
+			RegistrationMode:  RegistrationClosed,
+			Federation:        true,
+			FederateNewBlogs:  true,
+			NodeInfoEnabled:   true,
+			PublicStats:       StatsFull,
+
+			LocalTimelinePublic: true,
+
+			FederationCacheTTL: "1h",
+			FederationTimeout:  "30s",
+
+			MaxFederationConcurrency: DefaultMaxFederationConcurrency,
+
+			ScheduledPublishInterval: "1m",
+		},
+		Database: DatabaseCfg{
+			MaxOpenConns: 50,
+			MaxIdleConns: 2,
+
+			ConnectRetries:    5,
+			ConnectRetryDelay: "1s",
 		},
 	}
+	c.Storage.MaxUploadBytes = DefaultMaxUploadBytes
+	c.App.MaxAPIBodyBytes = DefaultMaxAPIBodyBytes
+	c.App.MarkdownExtensions = append([]string(nil), DefaultMarkdownExtensions...)
+	c.RateLimit = RateLimitCfg{
+		LoginPerMinute:        10,
+		SignupPerHour:         10,
+		APIPerMinute:          120,
+		AnonymousPostsPerHour: 10,
+	}
+	c.Log = LogCfg{
+		Level:  "info",
+		Format: "text",
+	}
 	c.UseMySQL(true)
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
 // UseMySQL resets the Config's Database to use default values for a MySQL setup.
 func (cfg *Config) UseMySQL(fresh bool) {
 	cfg.Database.Type = "mysql"
+	cfg.Database.WAL = false
+	cfg.Database.BusyTimeout = 0
 	if fresh {
 		cfg.Database.Host = "localhost"
-		cfg.Database.Port = 3306
+		cfg.Database.Port = DefaultMySQLPort
 	}
 }
 
@@ -139,14 +690,19 @@ func (cfg *Config) UseMySQL(fresh bool) {
 func (cfg *Config) UseSQLite(fresh bool) {
 	cfg.Database.Type = "sqlite3"
 	if fresh {
-		cfg.Database.FileName = "writefreely.db"
+		cfg.Database.FileName = DefaultSQLiteFileName
+		cfg.Database.WAL = true
+		cfg.Database.BusyTimeout = 5000
 	}
 }
 
 // IsSecureStandalone returns whether or not the application is running as a
 // standalone server with TLS enabled.
 func (cfg *Config) IsSecureStandalone() bool {
-	return cfg.Server.Port == 443 && cfg.Server.TLSCertPath != "" && cfg.Server.TLSKeyPath != ""
+	if cfg.Server.Port != 443 {
+		return false
+	}
+	return cfg.Server.Autocert || (cfg.Server.TLSCertPath != "" && cfg.Server.TLSKeyPath != "")
 }
 
 func (ac *AppCfg) LandingPath() string {
@@ -157,34 +713,350 @@ func (ac *AppCfg) LandingPath() string {
 }
 
 // Load reads the given configuration file, then parses and returns it as a Config.
+// When fname is empty, the DefaultFileName is read, and logged. When it's
+// StdinFileName, the configuration is read from standard input.
+// It returns an error wrapping ErrConfigNotFound if the file doesn't exist.
 func Load(fname string) (*Config, error) {
 	if fname == "" {
-		fname = FileName
+		fname = DefaultFileName()
+		log.Info("Using configuration file %s", fname)
+	}
+	if fname == StdinFileName {
+		return LoadReader(stdin)
+	}
+	return LoadSource(FileSource(fname))
+}
+
+// openError wraps a failure to open the given configuration file, so missing
+// files can be told apart with errors.Is(err, ErrConfigNotFound).
+func openError(fname string, err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrConfigNotFound, fname)
+	}
+	return err
+}
+
+// LoadReader parses INI configuration data from the given io.Reader and
+// returns it as a Config. Configurations written for older versions are
+// migrated to the current layout. Included files are found relative to the
+// working directory.
+func LoadReader(r io.Reader) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
 	}
-	cfg, err := ini.Load(fname)
+	sources, err := includeSources("", b, map[string]bool{})
 	if err != nil {
 		return nil, err
 	}
+	return loadINI(sources)
+}
+
+// includeSources returns the data of every file included by the given INI
+// data, read from the file fname, followed by the data itself, so that each
+// source overrides the ones before it. Files are included with a
+// comma-separated path key in an [include] section, relative to the
+// including file. parents holds the absolute paths of the files currently
+// being read, to detect circular includes.
+func includeSources(fname string, data []byte, parents map[string]bool) ([]interface{}, error) {
+	f, err := ini.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse configuration: %w", err)
+	}
+	sec, err := f.GetSection(includeSection)
+	if err != nil || sec.Key("path").String() == "" {
+		return []interface{}{data}, nil
+	}
+
+	var sources []interface{}
+	for _, p := range strings.Split(sec.Key("path").String(), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(fname), p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		if parents[abs] {
+			return nil, fmt.Errorf("Unable to load configuration: %s includes itself", abs)
+		}
+		b, err := ioutil.ReadFile(abs)
+		if err != nil {
+			// Not wrapped, since a missing include doesn't mean the
+			// configuration itself is missing
+			return nil, fmt.Errorf("Unable to read included configuration: %v", err)
+		}
+		if isYAML(abs) {
+			if b, err = yamlToINI(b); err != nil {
+				return nil, err
+			}
+		}
+		parents[abs] = true
+		inc, err := includeSources(abs, b, parents)
+		delete(parents, abs)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, inc...)
+	}
+	return append(sources, data), nil
+}
 
-	// Parse INI file
+// loadINI parses the given INI sources, each overriding the ones before it,
+// and returns them as a Config.
+func loadINI(sources []interface{}) (*Config, error) {
+	cfg, err := ini.Load(sources[0], sources[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse configuration: %w", err)
+	}
+	cfg.DeleteSection(includeSection)
+	if unknown := unknownKeys(cfg); len(unknown) > 0 {
+		log.Error("[WARNING] Ignoring unknown configuration keys, which may be left over from an older version: %s", strings.Join(unknown, ", "))
+	}
+
+	// Parse INI data
 	uc := &Config{}
 	err = cfg.MapTo(uc)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Unable to parse configuration: %w", err)
 	}
+	cleanLists(reflect.ValueOf(uc).Elem())
+	migrateLoaded(uc)
 	return uc, nil
 }
 
-// Save writes the given Config to the given file.
+// cleanLists trims the spaces around each entry of every comma-separated
+// list, which the ini mapping already does, and drops the empty entries it
+// keeps, like those left by a trailing comma. It descends into nested
+// structs.
+func cleanLists(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				cleanLists(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String || v.Len() == 0 {
+			return
+		}
+		cleaned := reflect.Zero(v.Type())
+		for i := 0; i < v.Len(); i++ {
+			if s := strings.TrimSpace(v.Index(i).String()); s != "" {
+				cleaned = reflect.Append(cleaned, reflect.ValueOf(s).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(cleaned)
+	}
+}
+
+// Save writes the given Config to the given file. If the file already exists,
+// its comments and key order are kept, and any new keys are added to the end
+// of their section. Values that the file's included files already set are
+// left to them, unless the file sets them itself.
 func Save(uc *Config, fname string) error {
+	if fname == "" {
+		fname = DefaultFileName()
+	}
+	if fname == StdinFileName {
+		return fmt.Errorf("config: cannot save a configuration read from standard input")
+	}
+	if err := checkWritable(fname); err != nil {
+		return err
+	}
+
+	// Update an existing file in place, so its comments and key order are kept
 	cfg := ini.Empty()
+	var inherited map[string]string
+	if data, err := ioutil.ReadFile(fname); err == nil {
+		cfg, err = ini.Load(data)
+		if err != nil {
+			return err
+		}
+		if inherited, err = inheritedValues(fname, data); err != nil {
+			return err
+		}
+	}
+	local := iniKeys(cfg)
 	err := ini.ReflectFrom(cfg, uc)
 	if err != nil {
 		return err
 	}
+	for _, sec := range cfg.Sections() {
+		for _, k := range sec.Keys() {
+			name := sec.Name() + "." + k.Name()
+			if v, ok := inherited[name]; ok && !local[name] && v == k.String() {
+				sec.DeleteKey(k.Name())
+			}
+		}
+	}
+	return writeFileAtomic(fname, 0644, func(w io.Writer) error {
+		_, err := cfg.WriteTo(w)
+		return err
+	})
+}
+
+// inheritedValues returns the values set by the files that the INI data read
+// from fname includes, keyed by "section.key", in the form that ReflectFrom
+// writes them. It returns nil when nothing is included.
+func inheritedValues(fname string, data []byte) (map[string]string, error) {
+	abs, err := filepath.Abs(fname)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := includeSources(abs, data, map[string]bool{abs: true})
+	if err != nil || len(sources) < 2 {
+		return nil, err
+	}
+	included, err := ini.Load(sources[0], sources[1:len(sources)-1]...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse configuration: %w", err)
+	}
+	included.DeleteSection(includeSection)
+	ic := &Config{}
+	if err = included.MapTo(ic); err != nil {
+		return nil, fmt.Errorf("Unable to parse configuration: %w", err)
+	}
+	cleanLists(reflect.ValueOf(ic).Elem())
+	written := ini.Empty()
+	if err = ini.ReflectFrom(written, ic); err != nil {
+		return nil, err
+	}
+
+	vals := map[string]string{}
+	for name := range iniKeys(included) {
+		i := strings.LastIndex(name, ".")
+		vals[name] = written.Section(name[:i]).Key(name[i+1:]).String()
+	}
+	return vals, nil
+}
+
+// iniKeys returns the "section.key" name of every key in the given file.
+func iniKeys(f *ini.File) map[string]bool {
+	keys := map[string]bool{}
+	for _, sec := range f.Sections() {
+		for _, k := range sec.KeyStrings() {
+			keys[sec.Name()+"."+k] = true
+		}
+	}
+	return keys
+}
+
+// SaveString returns the given Config in the INI format that Save writes to
+// a new file, without writing anything to disk.
+func SaveString(uc *Config) (string, error) {
+	cfg := ini.Empty()
+	if err := ini.ReflectFrom(cfg, uc); err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if _, err := cfg.WriteTo(&buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
 
+// SaveBackup saves the given Config like SaveFile, after copying any
+// existing file at fname to fname + ".bak".
+func SaveBackup(uc *Config, fname string) error {
 	if fname == "" {
-		fname = FileName
+		fname = DefaultFileName()
+	}
+	if err := checkWritable(fname); err != nil {
+		return err
+	}
+	if err := backupFile(fname); err != nil {
+		return fmt.Errorf("Unable to back up configuration: %w", err)
+	}
+	return SaveFile(uc, fname)
+}
+
+// backupFile copies the file at fname to fname + ".bak", with the same
+// permissions, replacing any older backup. It does nothing if fname
+// doesn't exist.
+func backupFile(fname string) error {
+	fi, err := os.Stat(fname)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	bak := fname + ".bak"
+	err = writeFileAtomic(bak, fi.Mode().Perm(), func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return os.Chmod(bak, fi.Mode().Perm())
+}
+
+// checkWritable returns an error if fname can't be written, before any
+// work is done to write it. Errors wrap the underlying error, so permission
+// problems can be told apart with errors.Is(err, fs.ErrPermission).
+func checkWritable(fname string) error {
+	if target, err := filepath.EvalSymlinks(fname); err == nil {
+		fname = target
+	}
+	if _, err := os.Stat(fname); err == nil {
+		f, err := os.OpenFile(fname, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("config: cannot write %s: %w", fname, err)
+		}
+		f.Close()
+	}
+	// Saving writes a temporary file next to fname, then renames it
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp")
+	if err != nil {
+		return fmt.Errorf("config: cannot write %s: %w", fname, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// writeFileAtomic writes a file by calling write with a temporary file in
+// the same directory, then renaming it over fname, so fname is either left
+// as it was or fully written. An existing file keeps its permissions, and
+// new files get perm.
+func writeFileAtomic(fname string, perm os.FileMode, write func(io.Writer) error) error {
+	// Replace the target of a symlink, not the link itself
+	if target, err := filepath.EvalSymlinks(fname); err == nil {
+		fname = target
+	}
+	if fi, err := os.Stat(fname); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	// Remove the temporary file unless it's been renamed into place
+	defer os.Remove(tmpName)
+
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
 	}
-	return cfg.SaveTo(fname)
+	return os.Rename(tmpName, fname)
 }
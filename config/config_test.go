@@ -0,0 +1,634 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+// tempConfigPath returns a path to a config file in a new temporary
+// directory, along with a func that removes it.
+func tempConfigPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "wfconfig")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	return filepath.Join(dir, FileName), func() { os.RemoveAll(dir) }
+}
+
+func TestLoadReader(t *testing.T) {
+	cfg, err := LoadReader(strings.NewReader(`[server]
+port = 9000
+
+[database]
+type = sqlite3
+filename = test.db
+
+[app]
+site_name = Reader Blog
+single_user = true
+`))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Port = %d; expected 9000", cfg.Server.Port)
+	}
+	if cfg.Database.Type != "sqlite3" || cfg.Database.FileName != "test.db" {
+		t.Errorf("Database = %s %s; expected sqlite3 test.db", cfg.Database.Type, cfg.Database.FileName)
+	}
+	if cfg.App.SiteName != "Reader Blog" || !cfg.App.SingleUser {
+		t.Errorf("App = %+v; expected site name and single user set", cfg.App)
+	}
+
+	cfg, err = LoadReader(strings.NewReader("[server\nport = 9000"))
+	if err == nil {
+		t.Error("Expected error parsing malformed INI")
+	}
+	if cfg != nil {
+		t.Error("Expected nil Config on parse failure")
+	}
+}
+
+func TestLoadStdin(t *testing.T) {
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = strings.NewReader("[server]\nport = 9001\n\n[app]\nsite_name = Piped Blog\n")
+
+	cfg, err := Load(StdinFileName)
+	if err != nil {
+		t.Fatalf("Load(%s) failed: %v", StdinFileName, err)
+	}
+	if cfg.Server.Port != 9001 || cfg.App.SiteName != "Piped Blog" {
+		t.Errorf("Loaded port %d, site name %q; expected 9001, Piped Blog", cfg.Server.Port, cfg.App.SiteName)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d; expected stdin configs to be migrated to %d", cfg.Version, CurrentVersion)
+	}
+
+	stdin = strings.NewReader("[server\nport = 9001")
+	if _, err = Load(StdinFileName); err == nil {
+		t.Error("Loading malformed INI from stdin succeeded")
+	}
+	if _, err = Reload(StdinFileName, false); err == nil {
+		t.Error("Reloading from stdin succeeded")
+	}
+	if err = Save(New(), StdinFileName); err == nil {
+		t.Error("Saving to stdin succeeded")
+	}
+	if _, err = os.Stat(StdinFileName); !os.IsNotExist(err) {
+		os.Remove(StdinFileName)
+		t.Errorf("Saving to stdin wrote a file named %s", StdinFileName)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+
+	for _, name := range []string{fname, strings.TrimSuffix(fname, ".ini") + ".yaml"} {
+		_, err := LoadFile(name)
+		if !errors.Is(err, ErrConfigNotFound) {
+			t.Errorf("LoadFile(%s) on missing file = %v; expected ErrConfigNotFound", name, err)
+		}
+	}
+
+	for name, content := range map[string]string{
+		fname: "[server\nport = 9000",
+		strings.TrimSuffix(fname, ".ini") + ".yaml": "server: [port",
+	} {
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatalf("Unable to write config: %v", err)
+		}
+		_, err := LoadFile(name)
+		if err == nil {
+			t.Errorf("LoadFile(%s) on malformed file succeeded", name)
+			continue
+		}
+		if errors.Is(err, ErrConfigNotFound) {
+			t.Errorf("LoadFile(%s) on malformed file = %v; expected a parse error", name, err)
+		}
+		if !strings.Contains(err.Error(), "Unable to parse configuration") {
+			t.Errorf("LoadFile(%s) error = %v; expected it to mention parsing", name, err)
+		}
+	}
+}
+
+func TestAutocert(t *testing.T) {
+	cfg := New()
+	cfg.Server.Port = 443
+	if cfg.IsSecureStandalone() {
+		t.Error("IsSecureStandalone() = true without certs or autocert")
+	}
+	cfg.Server.Autocert = true
+	cfg.Server.AutoCertHosts = []string{"example.com", "www.example.com"}
+	if !cfg.IsSecureStandalone() {
+		t.Error("IsSecureStandalone() = false with autocert enabled")
+	}
+	if dir := cfg.Server.AutoCertCacheDir(); dir != "certs" {
+		t.Errorf("AutoCertCacheDir() = %s; expected certs", dir)
+	}
+	cfg.Server.TLSCertPath = "legacy"
+	if dir := cfg.Server.AutoCertCacheDir(); dir != "legacy" {
+		t.Errorf("AutoCertCacheDir() = %s; expected legacy", dir)
+	}
+	cfg.Server.AutoCertDir = "/var/lib/writefreely/certs"
+	if dir := cfg.Server.AutoCertCacheDir(); dir != cfg.Server.AutoCertDir {
+		t.Errorf("AutoCertCacheDir() = %s; expected %s", dir, cfg.Server.AutoCertDir)
+	}
+
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Server.AutoCertHosts) != 2 || loaded.Server.AutoCertHosts[1] != "www.example.com" {
+		t.Errorf("Loaded hosts = %v; expected %v", loaded.Server.AutoCertHosts, cfg.Server.AutoCertHosts)
+	}
+}
+
+func TestSavePreservesComments(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	err := ioutil.WriteFile(fname, []byte(`# Instance settings
+[server]
+# Behind nginx
+port = 8080
+bind = localhost
+
+[app]
+site_name = Old Name
+`), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+
+	cfg, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg.App.SiteName = "New Name"
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("Unable to read config: %v", err)
+	}
+	saved := string(b)
+	for _, s := range []string{"# Instance settings", "# Behind nginx", "site_name"} {
+		if !strings.Contains(saved, s) {
+			t.Errorf("Saved config is missing %q:\n%s", s, saved)
+		}
+	}
+	if strings.Index(saved, "port") > strings.Index(saved, "bind") {
+		t.Errorf("Saved config reordered port and bind:\n%s", saved)
+	}
+	if i := strings.Index(saved, "idle_timeout"); i < strings.Index(saved, "bind") || i > strings.Index(saved, "[database]") {
+		t.Errorf("New key idle_timeout wasn't added to the end of [server]:\n%s", saved)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.App.SiteName != "New Name" {
+		t.Errorf("SiteName = %s; expected New Name", loaded.App.SiteName)
+	}
+}
+
+func TestEmailRoundTrip(t *testing.T) {
+	cfg := New()
+	if cfg.Email.Enabled() {
+		t.Error("Email enabled by default")
+	}
+	cfg.Email = EmailCfg{
+		SMTPHost:     "smtp.example.com",
+		SMTPPort:     587,
+		SMTPUser:     "writefreely",
+		SMTPPassword: "s3cret",
+		FromAddress:  "noreply@example.com",
+		UseTLS:       true,
+	}
+	if !cfg.Email.Enabled() {
+		t.Error("Email disabled with host and from address set")
+	}
+
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Email != cfg.Email {
+		t.Errorf("Loaded email = %+v; expected %+v", loaded.Email, cfg.Email)
+	}
+	if (EmailCfg{SMTPHost: "smtp.example.com"}).Enabled() {
+		t.Error("Email enabled without a from address")
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	cfg := newValidConfig()
+	if cfg.Cache.IsRedis() {
+		t.Error("Redis enabled by default")
+	}
+	cfg.Cache = CacheCfg{
+		Type:          "redis",
+		RedisHost:     "redis.internal",
+		RedisPassword: "s3cret",
+		RedisDB:       2,
+	}
+	if !cfg.Cache.IsRedis() {
+		t.Error("IsRedis() = false with type redis")
+	}
+	if addr := cfg.Cache.RedisAddr(); addr != "redis.internal:6379" {
+		t.Errorf("RedisAddr() = %s; expected redis.internal:6379", addr)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Redis config failed validation: %v", err)
+	}
+
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Cache != cfg.Cache {
+		t.Errorf("Loaded cache = %+v; expected %+v", loaded.Cache, cfg.Cache)
+	}
+}
+
+func TestOAuthRoundTrip(t *testing.T) {
+	cfg := newValidConfig()
+	if cfg.OAuth.Enabled() {
+		t.Error("OAuth enabled by default")
+	}
+	cfg.OAuth = OAuthCfg{
+		ProviderName: "Example SSO",
+		ClientID:     "writefreely",
+		ClientSecret: "wJalrXUtnFEMI",
+		AuthURL:      "https://sso.example.com/authorize",
+		TokenURL:     "https://sso.example.com/token",
+		UserInfoURL:  "https://sso.example.com/userinfo",
+		Scopes:       []string{"openid", "profile"},
+	}
+	if !cfg.OAuth.Enabled() {
+		t.Error("OAuth disabled with client_id set")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("OAuth config failed validation: %v", err)
+	}
+	if cb := cfg.OAuth.Callback("https://blog.example.com/"); cb != "https://blog.example.com/oauth/callback" {
+		t.Errorf("Callback() = %s; expected https://blog.example.com/oauth/callback", cb)
+	}
+
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.OAuth, cfg.OAuth) {
+		t.Errorf("Loaded OAuth = %+v; expected %+v", loaded.OAuth, cfg.OAuth)
+	}
+	if name := (OAuthCfg{}).Name(); name != "SSO" {
+		t.Errorf("Default Name() = %s; expected SSO", name)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	cfg := New()
+	for _, d := range []struct {
+		Name          string
+		Val, Expected interface{}
+	}{
+		{"Server.Port", cfg.Server.Port, DefaultPort},
+		{"Server.Bind", cfg.Server.Bind, DefaultBind},
+		{"App.Host", cfg.App.Host, DefaultHost},
+		{"App.Theme", cfg.App.Theme, DefaultTheme},
+		{"App.MinUsernameLen", cfg.App.MinUsernameLen, DefaultMinUsernameLen},
+		{"App.MinPasswordLen", cfg.App.MinPasswordLen, DefaultMinPasswordLen},
+		{"App.MaxBlogs", cfg.App.MaxBlogs, DefaultMaxBlogs},
+		{"App.MaxBlogTitleLen", cfg.App.MaxBlogTitleLen, DefaultMaxBlogTitleLen},
+		{"App.MaxBlogDescLen", cfg.App.MaxBlogDescLen, DefaultMaxBlogDescLen},
+		{"App.BcryptCost", cfg.App.BcryptCost, DefaultBcryptCost},
+		{"App.MaxAPIBodyBytes", cfg.App.MaxAPIBodyBytes, int64(DefaultMaxAPIBodyBytes)},
+		{"Storage.MaxUploadBytes", cfg.Storage.MaxUploadBytes, int64(DefaultMaxUploadBytes)},
+		{"Database.Port", cfg.Database.Port, DefaultMySQLPort},
+		{"SupportedLangs", cfg.App.SupportedLangs()[0], DefaultLang},
+	} {
+		if !reflect.DeepEqual(d.Val, d.Expected) {
+			t.Errorf("%s = %v; expected %v", d.Name, d.Val, d.Expected)
+		}
+	}
+	if !reflect.DeepEqual(cfg.App.ReservedUsernames, DefaultReservedUsernames) {
+		t.Errorf("ReservedUsernames = %q; expected DefaultReservedUsernames", cfg.App.ReservedUsernames)
+	}
+
+	// Each Config gets its own list
+	cfg.App.ReservedUsernames[0] = "changed"
+	if DefaultReservedUsernames[0] == "changed" {
+		t.Error("Changing a Config's ReservedUsernames changed DefaultReservedUsernames")
+	}
+
+	cfg.UseMySQL(true)
+	if cfg.Database.Port != DefaultMySQLPort {
+		t.Errorf("MySQL port = %d; expected %d", cfg.Database.Port, DefaultMySQLPort)
+	}
+	cfg.UseSQLite(true)
+	if cfg.Database.FileName != DefaultSQLiteFileName {
+		t.Errorf("SQLite file name = %s; expected %s", cfg.Database.FileName, DefaultSQLiteFileName)
+	}
+}
+
+func TestListRoundTrip(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+
+	cfg := New()
+	cfg.App.ReservedUsernames = []string{" admin", "", "root ", "  ", "writefreely"}
+	cfg.Server.TrustedProxies = []string{"", ""}
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if names := loaded.App.ReservedUsernames; !reflect.DeepEqual(names, []string{"admin", "root", "writefreely"}) {
+		t.Errorf("ReservedUsernames = %q; expected admin, root, writefreely", names)
+	}
+	if p := loaded.Server.TrustedProxies; len(p) != 0 {
+		t.Errorf("TrustedProxies = %q; expected none", p)
+	}
+
+	// Hand-written lists are cleaned up the same way
+	loaded, err = LoadReader(strings.NewReader(fmt.Sprintf("version = %d\n[app]\nallowed_instances = example.com , , .friend.example,\n", CurrentVersion)))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if i := loaded.App.AllowedInstances; !reflect.DeepEqual(i, []string{"example.com", ".friend.example"}) {
+		t.Errorf("AllowedInstances = %q; expected example.com and .friend.example", i)
+	}
+}
+
+func TestSaveReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Permissions aren't enforced for root")
+	}
+	dir, err := ioutil.TempDir("", "wfconfig")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Chmod(dir, 0555); err != nil {
+		t.Fatalf("Unable to make dir read-only: %v", err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	fname := filepath.Join(dir, "config.ini")
+	for name, save := range map[string]func(*Config, string) error{
+		"Save":       Save,
+		"SaveBackup": SaveBackup,
+		"SaveYAML":   SaveYAML,
+	} {
+		err = save(New(), fname)
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s error = %v; expected a permission error", name, err)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "config: cannot write "+fname) {
+			t.Errorf("%s error %q doesn't name the file", name, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+		t.Errorf("Failed saves left %d files behind", len(files))
+	}
+}
+
+func TestSaveAtomic(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	original := []byte("[server]\nport = 8080\n")
+	if err := ioutil.WriteFile(fname, original, 0640); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+
+	writeErr := errors.New("disk full")
+	err := writeFileAtomic(fname, 0644, func(w io.Writer) error {
+		w.Write([]byte("[server]\npo"))
+		return writeErr
+	})
+	if err != writeErr {
+		t.Errorf("writeFileAtomic error = %v; expected %v", err, writeErr)
+	}
+	if b, _ := ioutil.ReadFile(fname); string(b) != string(original) {
+		t.Errorf("Original file changed after a failed write:\n%s", b)
+	}
+	if files, _ := ioutil.ReadDir(filepath.Dir(fname)); len(files) != 1 {
+		t.Errorf("Expected only the config file to remain; found %d files", len(files))
+	}
+
+	cfg := New()
+	cfg.Server.Port = 9000
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("Mode = %v; expected the original 0640", fi.Mode().Perm())
+	}
+	if loaded, err := Load(fname); err != nil || loaded.Server.Port != 9000 {
+		t.Errorf("Loaded port after Save = %v, %v; expected 9000", loaded, err)
+	}
+}
+
+func TestSaveBackup(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+
+	// Nothing to back up yet
+	if err := SaveBackup(New(), fname); err != nil {
+		t.Fatalf("SaveBackup failed: %v", err)
+	}
+	if _, err := os.Stat(fname + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup of a new file; got %v", err)
+	}
+
+	original, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("Unable to read config: %v", err)
+	}
+	cfg := New()
+	cfg.Server.Port = 9000
+	if err := SaveBackup(cfg, fname); err != nil {
+		t.Fatalf("SaveBackup failed: %v", err)
+	}
+	if b, err := ioutil.ReadFile(fname + ".bak"); err != nil || string(b) != string(original) {
+		t.Errorf("Backup = %q, %v; expected the pre-save content", b, err)
+	}
+	if loaded, err := Load(fname); err != nil || loaded.Server.Port != 9000 {
+		t.Errorf("Loaded port after SaveBackup = %v, %v; expected 9000", loaded, err)
+	}
+}
+
+func TestSaveString(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	cfg := New()
+	cfg.App.SiteName = "Test Blog"
+	cfg.App.ReservedUsernames = []string{"staff", "support"}
+
+	s, err := SaveString(cfg)
+	if err != nil {
+		t.Fatalf("SaveString failed: %v", err)
+	}
+	if _, err := os.Stat(fname); !os.IsNotExist(err) {
+		t.Fatalf("SaveString touched the disk: %v", err)
+	}
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("Unable to read saved config: %v", err)
+	}
+	if s != string(b) {
+		t.Errorf("SaveString output doesn't match the saved file:\n%s\n---\n%s", s, b)
+	}
+}
+
+func TestLoadInclude(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	dir := filepath.Dir(fname)
+	for name, content := range map[string]string{
+		"common.ini": "[server]\nport = 8081\nbind = 0.0.0.0\n\n[app]\nsite_name = Shared\n",
+		"base.ini":   "[include]\npath = common.ini\n\n[server]\nport = 8082\n\n[app]\nhost = https://base.example.com\n",
+		FileName:     "[include]\npath = base.ini\n\n[app]\nsite_name = Local\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, tc := range []struct {
+		Name, Got, Expected string
+	}{
+		{"Bind from common.ini", cfg.Server.Bind, "0.0.0.0"},
+		{"Host from base.ini", cfg.App.Host, "https://base.example.com"},
+		{"Site name from the local file", cfg.App.SiteName, "Local"},
+	} {
+		if tc.Got != tc.Expected {
+			t.Errorf("%s = %s; expected %s", tc.Name, tc.Got, tc.Expected)
+		}
+	}
+	if cfg.Server.Port != 8082 {
+		t.Errorf("Port = %d; expected base.ini to override common.ini", cfg.Server.Port)
+	}
+
+	// A cycle through base.ini back to common.ini
+	err = ioutil.WriteFile(filepath.Join(dir, "common.ini"), []byte("[include]\npath = base.ini\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write common.ini: %v", err)
+	}
+	if _, err = Load(fname); err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("Expected circular include error; got %v", err)
+	}
+
+	// A missing include isn't a missing config
+	err = ioutil.WriteFile(fname, []byte("[include]\npath = nonexistent.ini\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+	if _, err = Load(fname); err == nil || errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("Expected a non-ErrConfigNotFound error for a missing include; got %v", err)
+	}
+}
+
+func TestSaveInclude(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	dir := filepath.Dir(fname)
+	for name, content := range map[string]string{
+		"common.ini": "[server]\nport = 8081\nbind = 0.0.0.0\n\n[app]\nsite_name = Shared\nhost = https://common.example.com\n",
+		FileName:     "[include]\npath = common.ini\n\n[app]\nsite_name = Shared\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg.Server.Port = 9000
+	if err = Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	saved, err := ini.Load(fname)
+	if err != nil {
+		t.Fatalf("Unable to parse saved config: %v", err)
+	}
+	if saved.Section("server").HasKey("bind") || saved.Section("app").HasKey("host") {
+		t.Errorf("Saved config copied the included values:\n%s", saved.Section("server").KeysHash())
+	}
+	if saved.Section("server").Key("port").String() != "9000" {
+		t.Errorf("Saved port = %q; expected the changed value 9000", saved.Section("server").Key("port").String())
+	}
+	if !saved.Section("app").HasKey("site_name") {
+		t.Errorf("Saved config dropped site_name, which the local file set itself")
+	}
+	if saved.Section(includeSection).Key("path").String() != "common.ini" {
+		t.Errorf("Saved config lost its include")
+	}
+
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load after Save failed: %v", err)
+	}
+	if loaded.Server.Bind != "0.0.0.0" || loaded.Server.Port != 9000 || loaded.App.Host != "https://common.example.com" {
+		t.Errorf("Bind, port, host = %s, %d, %s; expected the included values and the changed port", loaded.Server.Bind, loaded.Server.Port, loaded.App.Host)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetDSN builds the driver-specific connection string for the database, or
+// returns the configured DSN as is. It returns an error for an unsupported
+// database type.
+func (dc DatabaseCfg) GetDSN() (string, error) {
+	if dc.DSN != "" {
+		return dc.DSN, nil
+	}
+	switch dc.Type {
+	case "mysql":
+		addr := fmt.Sprintf("tcp(%s:%d)", dc.Host, dc.Port)
+		if dc.Socket != "" {
+			addr = fmt.Sprintf("unix(%s)", dc.Socket)
+		}
+		dsn := fmt.Sprintf("%s:%s@%s/%s?charset=utf8mb4&parseTime=true&loc=%s", dc.User, dc.Password, addr, dc.Database, url.QueryEscape(time.Local.String()))
+		if tls := mySQLTLSParam(dc.TLS); tls != "" {
+			dsn += "&tls=" + tls
+		}
+		return dsn, nil
+	case "sqlite3":
+		dsn := dc.FileName + "?parseTime=true&cached=shared"
+		if dc.WAL {
+			dsn += "&_journal_mode=WAL"
+		}
+		if dc.BusyTimeout > 0 {
+			dsn += fmt.Sprintf("&_busy_timeout=%d", dc.BusyTimeout)
+		}
+		return dsn, nil
+	}
+	return "", fmt.Errorf("Unsupported database type '%s'", dc.Type)
+}
+
+// ReplicaDSNs returns the connection string for each of the ReadReplicas.
+func (dc DatabaseCfg) ReplicaDSNs() ([]string, error) {
+	var dsns []string
+	for _, r := range dc.ReadReplicas {
+		rc := replicaCfg(dc, strings.TrimSpace(r))
+		dsn, err := rc.GetDSN()
+		if err != nil {
+			return nil, err
+		}
+		dsns = append(dsns, dsn)
+	}
+	return dsns, nil
+}
+
+// replicaCfg returns the database configuration for connecting to the given
+// replica, which is either a DSN or a host with an optional port.
+func replicaCfg(dc DatabaseCfg, replica string) DatabaseCfg {
+	rc := dc
+	rc.ReadReplicas = nil
+	if strings.ContainsAny(replica, "/@=") {
+		rc.DSN = replica
+		return rc
+	}
+	rc.DSN = ""
+	rc.Socket = ""
+	rc.Host = replica
+	if host, port, err := net.SplitHostPort(replica); err == nil {
+		rc.Host = host
+		rc.Port, _ = strconv.Atoi(port)
+	}
+	return rc
+}
+
+// RedactedDSN returns the connection string from GetDSN with any password
+// replaced, so it can be logged or shared for debugging.
+func (dc DatabaseCfg) RedactedDSN() (string, error) {
+	dsn, err := dc.GetDSN()
+	if err != nil {
+		return "", err
+	}
+
+	switch dc.Type {
+	case "mysql":
+		// Like the driver, take the password to end at the last @ before the
+		// database name
+		end := strings.LastIndex(dsn, "/")
+		if end < 0 {
+			end = len(dsn)
+		}
+		at := strings.LastIndex(dsn[:end], "@")
+		if colon := strings.Index(dsn[:at+1], ":"); at > 0 && colon >= 0 {
+			dsn = dsn[:colon+1] + redacted + dsn[at:]
+		}
+	}
+	return dsn, nil
+}
+
+// mySQLTLSParam translates the configured database TLS mode into the MySQL
+// driver's `tls` parameter.
+func mySQLTLSParam(mode string) string {
+	switch mode {
+	case "disable":
+		return "false"
+	case "require":
+		return "skip-verify"
+	case "verify-ca", "verify-full":
+		return "true"
+	}
+	return ""
+}
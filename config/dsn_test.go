@@ -0,0 +1,105 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+var dsnTestTable = []struct {
+	Name     string
+	Cfg      DatabaseCfg
+	Expected string
+	Redacted string
+}{
+	{
+		"MySQL",
+		DatabaseCfg{Type: "mysql", User: "wf", Password: "pass", Host: "db.example.com", Port: 3306, Database: "writefreely", TLS: "require"},
+		"wf:pass@tcp(db.example.com:3306)/writefreely?charset=utf8mb4&parseTime=true&loc=",
+		"wf:***@tcp(db.example.com:3306)/writefreely?",
+	},
+	{
+		"SQLite",
+		DatabaseCfg{Type: "sqlite3", FileName: "writefreely.db", WAL: true},
+		"writefreely.db?parseTime=true&cached=shared&_journal_mode=WAL",
+		"writefreely.db?parseTime=true&cached=shared&_journal_mode=WAL",
+	},
+	{
+		"MySQL DSN",
+		DatabaseCfg{Type: "mysql", DSN: "wf:p@ss@tcp(db:3306)/writefreely?parseTime=true"},
+		"wf:p@ss@tcp(db:3306)/writefreely?parseTime=true",
+		"wf:***@tcp(db:3306)/writefreely?parseTime=true",
+	},
+}
+
+func TestGetDSN(t *testing.T) {
+	for _, tc := range dsnTestTable {
+		dsn, err := tc.Cfg.GetDSN()
+		if err != nil {
+			t.Errorf("%s: GetDSN failed: %v", tc.Name, err)
+			continue
+		}
+		if !strings.HasPrefix(dsn, tc.Expected) {
+			t.Errorf("%s: GetDSN = %s; expected %s", tc.Name, dsn, tc.Expected)
+		}
+		redacted, err := tc.Cfg.RedactedDSN()
+		if err != nil {
+			t.Errorf("%s: RedactedDSN failed: %v", tc.Name, err)
+			continue
+		}
+		if !strings.HasPrefix(redacted, tc.Redacted) {
+			t.Errorf("%s: RedactedDSN = %s; expected %s", tc.Name, redacted, tc.Redacted)
+		}
+	}
+
+	if _, err := (DatabaseCfg{Type: "oracle"}).GetDSN(); err == nil {
+		t.Error("Expected an error for an unsupported database type")
+	}
+}
+
+func TestReplicaDSNs(t *testing.T) {
+	dc := DatabaseCfg{
+		Type:     "mysql",
+		User:     "wf",
+		Password: "secret",
+		Database: "writefreely",
+		Host:     "db-primary",
+		Port:     3306,
+		ReadReplicas: []string{
+			"db-replica1",
+			" db-replica2:3307",
+			"ro:hunter2@tcp(db-replica3:3306)/writefreely?parseTime=true",
+		},
+	}
+	dsns, err := dc.ReplicaDSNs()
+	if err != nil {
+		t.Fatalf("ReplicaDSNs failed: %v", err)
+	}
+	for i, expected := range []string{
+		"wf:secret@tcp(db-replica1:3306)/writefreely?",
+		"wf:secret@tcp(db-replica2:3307)/writefreely?",
+		"ro:hunter2@tcp(db-replica3:3306)/writefreely?parseTime=true",
+	} {
+		if i >= len(dsns) || !strings.HasPrefix(dsns[i], expected) {
+			t.Errorf("Replica DSNs = %v; expected #%d to be %s", dsns, i+1, expected)
+		}
+	}
+
+	cfg := New()
+	cfg.Database = dc
+	if r := cfg.Redacted().Database.ReadReplicas; r[0] != "db-replica1" || strings.Contains(r[2], "hunter2") {
+		t.Errorf("Redacted replicas = %v", r)
+	}
+	if dc.ReadReplicas[2] != "ro:hunter2@tcp(db-replica3:3306)/writefreely?parseTime=true" {
+		t.Error("Redacted modified the original replicas")
+	}
+}
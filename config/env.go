@@ -0,0 +1,150 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prepended to every environment variable that can override a
+// configuration value.
+const EnvPrefix = "WF_"
+
+// ConfigFileEnv is the environment variable that sets the configuration
+// file used when none is given, like in containers that mount it somewhere
+// other than the working directory.
+const ConfigFileEnv = "WRITEFREELY_CONFIG"
+
+// EnvironmentEnv is the environment variable naming the environment the
+// application runs in, like "staging", so one checkout can keep a
+// configuration file for each.
+const EnvironmentEnv = "WF_ENV"
+
+// DefaultFileName returns the configuration file to use when none is given:
+// the path in ConfigFileEnv if it's set, or else config.<env>.ini for the
+// environment in EnvironmentEnv if that file exists, or FileName otherwise.
+func DefaultFileName() string {
+	if fname := os.Getenv(ConfigFileEnv); fname != "" {
+		return fname
+	}
+	if env := os.Getenv(EnvironmentEnv); env != "" {
+		fname := EnvFileName(env)
+		if _, err := os.Stat(fname); err == nil {
+			return fname
+		}
+	}
+	return FileName
+}
+
+// EnvFileName returns the configuration file name for the given
+// environment, like config.staging.ini for "staging".
+func EnvFileName(env string) string {
+	ext := filepath.Ext(FileName)
+	return strings.TrimSuffix(FileName, ext) + "." + env + ext
+}
+
+// LoadWithEnv reads the given configuration file like LoadFile, then overrides
+// its values with any matching environment variables and reads any secrets
+// stored in separate files. The result is meant for running the application,
+// and shouldn't be written back to disk with Save.
+func LoadWithEnv(fname string) (*Config, error) {
+	uc, err := LoadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	err = ApplyEnv(uc)
+	if err != nil {
+		return nil, err
+	}
+	err = uc.Database.readPasswordFile()
+	if err != nil {
+		return nil, err
+	}
+	return uc, nil
+}
+
+// ApplyEnv overrides values in the given Config with those found in the
+// environment. Variable names are derived from each field's section and ini
+// key, so `[database] password` is overridden by WF_DATABASE_PASSWORD.
+func ApplyEnv(uc *Config) error {
+	cv := reflect.ValueOf(uc).Elem()
+	ct := cv.Type()
+	for i := 0; i < ct.NumField(); i++ {
+		sec := ct.Field(i).Tag.Get("ini")
+		if sec == "" || sec == "-" || ct.Field(i).Type.Kind() != reflect.Struct {
+			continue
+		}
+
+		sv := cv.Field(i)
+		st := sv.Type()
+		for j := 0; j < st.NumField(); j++ {
+			key := strings.Split(st.Field(j).Tag.Get("ini"), ",")[0]
+			if key == "" || key == "-" {
+				continue
+			}
+
+			name := EnvName(sec, key)
+			val, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			err := setFromEnv(sv.Field(j), val)
+			if err != nil {
+				return fmt.Errorf("Invalid value for %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable name that overrides the given
+// section and key.
+func EnvName(section, key string) string {
+	return EnvPrefix + strings.ToUpper(section+"_"+key)
+}
+
+func setFromEnv(f reflect.Value, val string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		var vals []string
+		for _, v := range strings.Split(val, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				vals = append(vals, v)
+			}
+		}
+		f.Set(reflect.ValueOf(vals))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
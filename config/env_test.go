@@ -0,0 +1,192 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"WF_DATABASE_PASSWORD":     "s3cret",
+		"WF_SERVER_PORT":           "9090",
+		"WF_APP_SINGLE_USER":       "false",
+		"WF_SERVER_AUTOCERT_HOSTS": "example.com, www.example.com",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	cfg := New()
+	if err := ApplyEnv(cfg); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if cfg.Database.Password != "s3cret" {
+		t.Errorf("Database.Password = %s; expected s3cret", cfg.Database.Password)
+	}
+	if cfg.Server.Port != 9090 {
+		t.Errorf("Server.Port = %d; expected 9090", cfg.Server.Port)
+	}
+	if cfg.App.SingleUser {
+		t.Error("App.SingleUser = true; expected false")
+	}
+	if h := cfg.Server.AutoCertHosts; len(h) != 2 || h[0] != "example.com" || h[1] != "www.example.com" {
+		t.Errorf("Server.AutoCertHosts = %q; expected example.com and www.example.com", h)
+	}
+	if cfg.App.Theme != "write" {
+		t.Errorf("App.Theme = %s; expected unset variable to leave write", cfg.App.Theme)
+	}
+}
+
+func TestApplyEnvMalformed(t *testing.T) {
+	malformed := map[string]string{
+		"WF_SERVER_PORT":    "eighty",
+		"WF_APP_FEDERATION": "sometimes",
+		"WF_APP_MAX_BLOGS":  "1.5",
+		"WF_DATABASE_PORT":  "",
+		"WF_APP_PRIVATE":    "yes please",
+	}
+	for k, v := range malformed {
+		os.Setenv(k, v)
+		err := ApplyEnv(New())
+		if err == nil {
+			t.Errorf("%s=%q: expected error", k, v)
+		}
+		os.Unsetenv(k)
+	}
+}
+
+func TestLoadWithEnvPasswordFile(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	passFile := filepath.Join(filepath.Dir(fname), "db_password")
+	if err := ioutil.WriteFile(passFile, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("Unable to write password file: %v", err)
+	}
+
+	cfg := New()
+	cfg.Database.PasswordFile = passFile
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadWithEnv(fname)
+	if err != nil {
+		t.Fatalf("LoadWithEnv failed: %v", err)
+	}
+	if loaded.Database.Password != "hunter2" {
+		t.Errorf("Password = %q; expected hunter2", loaded.Database.Password)
+	}
+
+	cfg.Database.Password = "changeme"
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err = LoadWithEnv(fname); err == nil {
+		t.Error("Expected error with both password and password_file set")
+	}
+}
+
+func TestLoadDefaultFromEnv(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	cfg := New()
+	cfg.App.SiteName = "Mounted Blog"
+	if err := Save(cfg, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	os.Setenv(ConfigFileEnv, fname)
+	defer os.Unsetenv(ConfigFileEnv)
+	if f := DefaultFileName(); f != fname {
+		t.Errorf("DefaultFileName() = %s; expected %s", f, fname)
+	}
+	loaded, err := Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.App.SiteName != "Mounted Blog" {
+		t.Errorf("SiteName = %s; expected Load(\"\") to read %s", loaded.App.SiteName, fname)
+	}
+
+	// An explicit file name takes precedence
+	_, err = Load(filepath.Join(filepath.Dir(fname), "other.ini"))
+	if err == nil {
+		t.Error("Load with a missing explicit file read the environment's file instead")
+	}
+
+	os.Unsetenv(ConfigFileEnv)
+	if f := DefaultFileName(); f != FileName {
+		t.Errorf("DefaultFileName() = %s; expected %s", f, FileName)
+	}
+}
+
+func TestLoadForEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wf-config-env")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	for name, site := range map[string]string{
+		FileName:               "Production",
+		EnvFileName("staging"): "Staging",
+	} {
+		cfg := New()
+		cfg.App.SiteName = site
+		if err = Save(cfg, name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		Env, File, SiteName string
+	}{
+		{"", FileName, "Production"},
+		{"staging", "config.staging.ini", "Staging"},
+		// Environments without their own file fall back to the default
+		{"dev", FileName, "Production"},
+	} {
+		os.Setenv(EnvironmentEnv, tc.Env)
+		if f := DefaultFileName(); f != tc.File {
+			t.Errorf("%s=%q: DefaultFileName() = %s; expected %s", EnvironmentEnv, tc.Env, f, tc.File)
+		}
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("%s=%q: Load failed: %v", EnvironmentEnv, tc.Env, err)
+		}
+		if cfg.App.SiteName != tc.SiteName {
+			t.Errorf("%s=%q: SiteName = %s; expected %s", EnvironmentEnv, tc.Env, cfg.App.SiteName, tc.SiteName)
+		}
+	}
+
+	// An explicit file name takes precedence
+	os.Setenv(EnvironmentEnv, "staging")
+	defer os.Unsetenv(EnvironmentEnv)
+	cfg, err := Load(FileName)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.App.SiteName != "Production" {
+		t.Errorf("SiteName = %s; expected an explicit %s to be read", cfg.App.SiteName, FileName)
+	}
+}
@@ -11,7 +11,17 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // FriendlyHost returns the app's Host sans any schema
@@ -19,9 +29,836 @@ func (ac AppCfg) FriendlyHost() string {
 	return ac.Host[strings.Index(ac.Host, "://")+len("://"):]
 }
 
+// Registration modes for AppCfg.RegistrationMode.
+const (
+	RegistrationOpen   = "open"
+	RegistrationClosed = "closed"
+	RegistrationInvite = "invite"
+)
+
+// Levels for AppCfg.PublicStats, from least to most shown.
+const (
+	StatsNone  = "none"
+	StatsBasic = "basic"
+	StatsFull  = "full"
+)
+
+var statsLevels = []string{StatsNone, StatsBasic, StatsFull}
+
+// ShowsStats returns whether the instance's public stats include those at
+// the given level. An empty or unknown PublicStats shows none.
+func (ac AppCfg) ShowsStats(level string) bool {
+	return statsLevelIndex(ac.PublicStats) >= statsLevelIndex(level)
+}
+
+// ShowsLocalTimeline returns whether the local timeline is built and shown
+// to everyone.
+func (ac AppCfg) ShowsLocalTimeline() bool {
+	return ac.LocalTimeline && ac.LocalTimelinePublic
+}
+
+// statsLevelIndex returns the position of the given level in statsLevels,
+// treating an unknown level as none.
+func statsLevelIndex(level string) int {
+	for i, l := range statsLevels {
+		if l == level {
+			return i
+		}
+	}
+	return 0
+}
+
+// Registration returns the instance's registration mode. Configs without a
+// registration_mode fall back to the legacy open_registration setting.
+func (ac AppCfg) Registration() string {
+	if ac.RegistrationMode != "" {
+		return ac.RegistrationMode
+	}
+	return legacyRegistrationMode(ac.OpenRegistration, ac.UserInvites)
+}
+
+// SetRegistrationMode changes the registration mode and updates
+// OpenRegistration to match.
+func (ac *AppCfg) SetRegistrationMode(mode string) {
+	ac.RegistrationMode = mode
+	ac.OpenRegistration = mode == RegistrationOpen
+}
+
+// legacyRegistrationMode maps the old open_registration bool to a
+// registration mode. Closed instances that allowed invites kept accepting
+// invited users, so they map to invite mode.
+func legacyRegistrationMode(open bool, userInvites string) string {
+	if open {
+		return RegistrationOpen
+	}
+	if userInvites != "" {
+		return RegistrationInvite
+	}
+	return RegistrationClosed
+}
+
+// Sources for AppCfg.WebFontSource.
+const (
+	WebFontsCDN   = "cdn"
+	WebFontsLocal = "local"
+	WebFontsNone  = "none"
+)
+
+// FontSource returns where pages load their fonts from. Configs without a
+// webfont_source fall back to the legacy webfonts setting.
+func (ac AppCfg) FontSource() string {
+	if ac.WebFontSource != "" {
+		return ac.WebFontSource
+	}
+	return legacyWebFontSource(ac.WebFonts)
+}
+
+// legacyWebFontSource maps the old webfonts bool to a font source. Enabled
+// web fonts have always been served from this instance.
+func legacyWebFontSource(enabled bool) string {
+	if enabled {
+		return WebFontsLocal
+	}
+	return WebFontsNone
+}
+
+// ValidateTheme returns an error if the configured Theme isn't one of the
+// given installed themes.
+func (ac AppCfg) ValidateTheme(available []string) error {
+	return validateTheme("Theme", ac.Theme, available)
+}
+
+// ValidateBlogTheme returns an error if the configured DefaultBlogTheme
+// is set and isn't one of the given installed themes.
+func (ac AppCfg) ValidateBlogTheme(available []string) error {
+	if ac.DefaultBlogTheme == "" {
+		return nil
+	}
+	return validateTheme("Blog theme", ac.DefaultBlogTheme, available)
+}
+
+func validateTheme(name, theme string, available []string) error {
+	for _, t := range available {
+		if t == theme {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s '%s' isn't installed; available themes are %s", name, theme, strings.Join(available, ", "))
+}
+
+// BlogTheme returns the theme blogs are shown with, which is the site's
+// Theme unless a DefaultBlogTheme is set.
+func (ac AppCfg) BlogTheme() string {
+	if ac.DefaultBlogTheme == "" {
+		return ac.Theme
+	}
+	return ac.DefaultBlogTheme
+}
+
+// FaviconURL returns the path the instance's favicon is served at.
+func (ac AppCfg) FaviconURL() string {
+	if ac.FaviconPath == "" {
+		return "/favicon.ico"
+	}
+	return "/brand/favicon" + strings.ToLower(filepath.Ext(ac.FaviconPath))
+}
+
+// LogoURL returns the path the instance's logo is served at, or an empty
+// string if there isn't one.
+func (ac AppCfg) LogoURL() string {
+	if ac.LogoPath == "" {
+		return ""
+	}
+	return "/brand/logo" + strings.ToLower(filepath.Ext(ac.LogoPath))
+}
+
+// ThemesPath returns the absolute path of the configured ThemesDir, or an
+// empty string if there isn't one. It returns an error if the directory
+// doesn't exist.
+func (ac AppCfg) ThemesPath() (string, error) {
+	if ac.ThemesDir == "" {
+		return "", nil
+	}
+	dir, err := filepath.Abs(ac.ThemesDir)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("%s isn't a directory", dir)
+	}
+	return dir, nil
+}
+
+// SupportedLangs returns the languages the interface has translations for.
+func (ac AppCfg) SupportedLangs() []string {
+	return []string{DefaultLang}
+}
+
+// IsLangSupported returns whether the configured Lang has a translation.
+// An empty Lang means the default language.
+func (ac AppCfg) IsLangSupported() bool {
+	if ac.Lang == "" {
+		return true
+	}
+	for _, l := range ac.SupportedLangs() {
+		if l == ac.Lang {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSOrigin returns the value of the Access-Control-Allow-Origin header
+// for a request from the given origin, or an empty string if the origin
+// isn't allowed.
+func (ac AppCfg) CORSOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, o := range ac.CORSOrigins {
+		if o == "*" {
+			return "*"
+		}
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// IsUsernameReserved returns whether the given username is in the configured
+// ReservedUsernames, ignoring case.
+func (ac AppCfg) IsUsernameReserved(name string) bool {
+	for _, r := range ac.ReservedUsernames {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// PostTooLong returns whether the given post content has more characters
+// than MaxPostLength allows. Characters are counted as runes, so multibyte
+// content counts the same as ASCII.
+func (ac AppCfg) PostTooLong(content string) bool {
+	return ac.MaxPostLength > 0 && utf8.RuneCountInString(content) > ac.MaxPostLength
+}
+
+// BlogTitleTooLong returns whether the given blog title has more characters
+// than MaxBlogTitleLen allows.
+func (ac AppCfg) BlogTitleTooLong(title string) bool {
+	return ac.MaxBlogTitleLen > 0 && utf8.RuneCountInString(title) > ac.MaxBlogTitleLen
+}
+
+// BlogDescTooLong returns whether the given blog description has more
+// characters than MaxBlogDescLen allows.
+func (ac AppCfg) BlogDescTooLong(desc string) bool {
+	return ac.MaxBlogDescLen > 0 && utf8.RuneCountInString(desc) > ac.MaxBlogDescLen
+}
+
+// Actions for AppCfg.BlockedWordAction.
+const (
+	BlockedWordReject = "reject"
+	BlockedWordFlag   = "flag"
+)
+
+// ContainsBlockedWord returns whether the given content contains any of the
+// BlockedWords, and the first one found. Words match regardless of case,
+// but only whole: blocking "ass" doesn't block "class".
+func (ac AppCfg) ContainsBlockedWord(content string) (bool, string) {
+	if len(ac.BlockedWords) == 0 {
+		return false, ""
+	}
+	words := splitWords(content)
+	for _, b := range ac.BlockedWords {
+		phrase := splitWords(b)
+		if len(phrase) == 0 {
+			continue
+		}
+		for i := 0; i+len(phrase) <= len(words); i++ {
+			if wordsEqual(words[i:i+len(phrase)], phrase) {
+				return true, strings.TrimSpace(b)
+			}
+		}
+	}
+	return false, ""
+}
+
+// splitWords returns the lowercased words in s, which are runs of letters
+// and numbers.
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func wordsEqual(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// InstanceAllowed returns whether the instance at the given host can federate
+// with this one. If AllowedInstances is set, only those instances are
+// allowed; otherwise, all instances but those in BlockedInstances are.
+func (ac AppCfg) InstanceAllowed(host string) bool {
+	if len(ac.AllowedInstances) > 0 {
+		return matchesInstance(ac.AllowedInstances, host)
+	}
+	return !matchesInstance(ac.BlockedInstances, host)
+}
+
+// defaultSignatureAlgs are the HTTP Signature algorithms accepted when
+// AllowedSignatureAlgs is empty.
+var defaultSignatureAlgs = []string{"rsa-sha256", "hs2019"}
+
+// SignatureAlgs returns the HTTP Signature algorithms that inbound
+// activities can be signed with.
+func (ac AppCfg) SignatureAlgs() []string {
+	if len(ac.AllowedSignatureAlgs) == 0 {
+		return defaultSignatureAlgs
+	}
+	return ac.AllowedSignatureAlgs
+}
+
+// SignatureAlgAllowed returns whether inbound activities can be signed with
+// the given HTTP Signature algorithm.
+func (ac AppCfg) SignatureAlgAllowed(alg string) bool {
+	for _, a := range ac.SignatureAlgs() {
+		if strings.EqualFold(strings.TrimSpace(a), alg) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActivityIgnored returns whether inbound activities of the given type are
+// acknowledged without being processed.
+func (ac AppCfg) ActivityIgnored(t string) bool {
+	for _, a := range ac.IgnoredActivities {
+		if t != "" && strings.EqualFold(strings.TrimSpace(a), t) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesInstance returns whether the given host is in the list of
+// instances, where entries with a leading dot also match any subdomain.
+func matchesInstance(instances []string, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, i := range instances {
+		i = strings.TrimSuffix(strings.ToLower(i), ".")
+		if i == "" {
+			continue
+		}
+		if strings.HasPrefix(i, ".") {
+			if host == i[1:] || strings.HasSuffix(host, i) {
+				return true
+			}
+		} else if host == i {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultCSP returns a Content-Security-Policy that only loads scripts,
+// styles, and fonts from this instance. Inline scripts and styles are
+// allowed, since templates and custom blog CSS rely on them.
+func (ac AppCfg) DefaultCSP() string {
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'unsafe-inline'",
+		"style-src 'self' 'unsafe-inline'",
+		"font-src 'self'",
+		"img-src 'self' data: https:",
+		"media-src 'self' https:",
+		"frame-src https:",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'self'",
+	}, "; ")
+}
+
 func (ac AppCfg) CanCreateBlogs(currentlyUsed uint64) bool {
-	if ac.MaxBlogs <= 0 {
+	ok, _ := ac.CanCreateBlog(int(currentlyUsed))
+	return ok
+}
+
+// CanCreateBlog returns whether a user who already has currentCount blogs
+// can create another one, and if not, an error that explains the limit.
+// A MaxBlogs of zero or less means unlimited.
+func (ac AppCfg) CanCreateBlog(currentCount int) (bool, error) {
+	if ac.MaxBlogs <= 0 || currentCount < ac.MaxBlogs {
+		return true, nil
+	}
+	blogs := "blogs"
+	if ac.MaxBlogs == 1 {
+		blogs = "blog"
+	}
+	return false, fmt.Errorf("You've reached the maximum of %d %s per user on this instance.", ac.MaxBlogs, blogs)
+}
+
+// CanCreateDraft returns whether a user with the given number of drafts can
+// create another one, and an error explaining why not if they can't. A
+// MaxDrafts of zero or less means unlimited.
+func (ac AppCfg) CanCreateDraft(currentCount int) (bool, error) {
+	if ac.MaxDrafts <= 0 || currentCount < ac.MaxDrafts {
+		return true, nil
+	}
+	drafts := "drafts"
+	if ac.MaxDrafts == 1 {
+		drafts = "draft"
+	}
+	return false, fmt.Errorf("You've reached the maximum of %d %s per user on this instance. Publish or delete one to write another.", ac.MaxDrafts, drafts)
+}
+
+// readPasswordFile sets the database password to the contents of the
+// configured PasswordFile, if there is one.
+func (dc *DatabaseCfg) readPasswordFile() error {
+	if dc.PasswordFile == "" {
+		return nil
+	}
+	if dc.Password != "" {
+		return fmt.Errorf("Database password and password_file are both set; use only one.")
+	}
+	b, err := ioutil.ReadFile(dc.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("Unable to read database password_file: %v", err)
+	}
+	dc.Password = strings.TrimRight(string(b), " \t\r\n")
+	return nil
+}
+
+// ConnMaxLifetimeDuration returns the parsed ConnMaxLifetime, or zero if it
+// isn't set or is invalid.
+func (dc DatabaseCfg) ConnMaxLifetimeDuration() time.Duration {
+	d, _ := parseDuration(dc.ConnMaxLifetime)
+	return d
+}
+
+// QueryTimeoutDuration returns the parsed QueryTimeout, or zero if it isn't
+// set or is invalid.
+func (dc DatabaseCfg) QueryTimeoutDuration() time.Duration {
+	d, _ := parseDuration(dc.QueryTimeout)
+	return d
+}
+
+// defaultConnectRetryDelay is used when ConnectRetryDelay isn't set.
+const defaultConnectRetryDelay = time.Second
+
+// ConnectRetryDelayDuration returns the parsed ConnectRetryDelay, which
+// defaults to a second.
+func (dc DatabaseCfg) ConnectRetryDelayDuration() time.Duration {
+	if dc.ConnectRetryDelay == "" {
+		return defaultConnectRetryDelay
+	}
+	d, _ := parseDuration(dc.ConnectRetryDelay)
+	return d
+}
+
+// Enabled returns whether enough of the email configuration is set to send
+// email.
+func (ec EmailCfg) Enabled() bool {
+	return ec.SMTPHost != "" && ec.FromAddress != ""
+}
+
+// IsS3 returns whether uploaded media is stored in an S3-compatible bucket.
+func (sc StorageCfg) IsS3() bool {
+	return sc.Type == "s3"
+}
+
+// IsRedis returns whether sessions are stored in Redis.
+func (cc CacheCfg) IsRedis() bool {
+	return cc.Type == "redis"
+}
+
+// RedisAddr returns the host:port of the Redis server, using Redis' default
+// port when none is set.
+func (cc CacheCfg) RedisAddr() string {
+	port := cc.RedisPort
+	if port == 0 {
+		port = 6379
+	}
+	return net.JoinHostPort(cc.RedisHost, strconv.Itoa(port))
+}
+
+// MetricsEndpoint returns the path Prometheus metrics are served at.
+func (sc ServerCfg) MetricsEndpoint() string {
+	if sc.MetricsPath == "" {
+		return "/metrics"
+	}
+	return sc.MetricsPath
+}
+
+// HealthEndpoint returns the path of the liveness check.
+func (sc ServerCfg) HealthEndpoint() string {
+	if sc.HealthPath == "" {
+		return "/healthz"
+	}
+	return sc.HealthPath
+}
+
+// ReadyEndpoint returns the path of the readiness check.
+func (sc ServerCfg) ReadyEndpoint() string {
+	if sc.ReadyPath == "" {
+		return "/readyz"
+	}
+	return sc.ReadyPath
+}
+
+// BindAddrs returns each host in the comma-separated Bind value, with any
+// brackets around IPv6 addresses removed. It returns localhost when Bind is
+// empty.
+func (sc ServerCfg) BindAddrs() []string {
+	var addrs []string
+	for _, a := range strings.Split(sc.Bind, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		addrs = append(addrs, strings.TrimSuffix(strings.TrimPrefix(a, "["), "]"))
+	}
+	if len(addrs) == 0 {
+		return []string{"localhost"}
+	}
+	return addrs
+}
+
+// AutoCertCacheDir returns the directory where automatically obtained
+// certificates are stored.
+func (sc ServerCfg) AutoCertCacheDir() string {
+	if sc.AutoCertDir != "" {
+		return sc.AutoCertDir
+	}
+	if sc.TLSCertPath != "" {
+		// Older configurations stored the cache directory here
+		return sc.TLSCertPath
+	}
+	return "certs"
+}
+
+// DataPath returns the given path resolved against DataDir, unless it's
+// absolute or there isn't a DataDir.
+func (sc ServerCfg) DataPath(path string) string {
+	if sc.DataDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(sc.DataDir, path)
+}
+
+// KeysDir returns the parent directory of the keys directory.
+func (sc ServerCfg) KeysDir() string {
+	return sc.DataPath(sc.KeysParentDir)
+}
+
+// CheckDataDir returns an error if the configured DataDir doesn't exist or
+// isn't a directory.
+func (sc ServerCfg) CheckDataDir() error {
+	if sc.DataDir == "" {
+		return nil
+	}
+	fi, err := os.Stat(sc.DataDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", sc.DataDir)
+	}
+	return nil
+}
+
+// CheckDataDirWritable returns an error if files can't be created in the
+// configured DataDir. It creates and removes a temporary file to find out, so
+// it's checked once at startup rather than with the rest of the config.
+func (sc ServerCfg) CheckDataDirWritable() error {
+	if sc.DataDir == "" {
+		return nil
+	}
+	f, err := ioutil.TempFile(sc.DataDir, ".writefreely")
+	if err != nil {
+		return fmt.Errorf("%s isn't writable: %v", sc.DataDir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// SQLiteFileName returns the path of the SQLite database file, resolved
+// against the server's DataDir.
+func (cfg *Config) SQLiteFileName() string {
+	return cfg.Server.DataPath(cfg.Database.FileName)
+}
+
+// ReadTimeoutDuration returns the parsed ReadTimeout, or zero for no timeout.
+func (sc ServerCfg) ReadTimeoutDuration() time.Duration {
+	d, _ := parseDuration(sc.ReadTimeout)
+	return d
+}
+
+// WriteTimeoutDuration returns the parsed WriteTimeout, or zero for no
+// timeout.
+func (sc ServerCfg) WriteTimeoutDuration() time.Duration {
+	d, _ := parseDuration(sc.WriteTimeout)
+	return d
+}
+
+// IdleTimeoutDuration returns the parsed IdleTimeout, or zero for no timeout.
+func (sc ServerCfg) IdleTimeoutDuration() time.Duration {
+	d, _ := parseDuration(sc.IdleTimeout)
+	return d
+}
+
+// ShutdownTimeoutDuration returns the parsed ShutdownTimeout, or zero to shut
+// down immediately.
+func (sc ServerCfg) ShutdownTimeoutDuration() time.Duration {
+	d, _ := parseDuration(sc.ShutdownTimeout)
+	return d
+}
+
+// FederationCacheDuration returns the parsed FederationCacheTTL, or zero
+// when remote actors shouldn't be cached.
+func (ac AppCfg) FederationCacheDuration() time.Duration {
+	d, _ := parseDuration(ac.FederationCacheTTL)
+	return d
+}
+
+// ScheduledPublishDuration returns the parsed ScheduledPublishInterval, or
+// zero when scheduled posts aren't published in the background.
+func (ac AppCfg) ScheduledPublishDuration() time.Duration {
+	d, _ := parseDuration(ac.ScheduledPublishInterval)
+	return d
+}
+
+// APITokenDuration returns the parsed APITokenTTL, or zero when API access
+// tokens don't expire.
+func (ac AppCfg) APITokenDuration() time.Duration {
+	d, _ := parseDuration(ac.APITokenTTL)
+	return d
+}
+
+// defaultFederationTimeout is used when FederationTimeout isn't set.
+const defaultFederationTimeout = 30 * time.Second
+
+// FederationTimeoutDuration returns the parsed FederationTimeout, which
+// defaults to 30 seconds, or zero for no timeout.
+func (ac AppCfg) FederationTimeoutDuration() time.Duration {
+	if ac.FederationTimeout == "" {
+		return defaultFederationTimeout
+	}
+	d, _ := parseDuration(ac.FederationTimeout)
+	return d
+}
+
+// ValidatePassword returns an error explaining why the given password doesn't
+// meet the instance's requirements, if it doesn't.
+func (ac AppCfg) ValidatePassword(pw string) error {
+	if pw == "" {
+		return fmt.Errorf("A password is required.")
+	}
+	if n := utf8.RuneCountInString(pw); n < ac.MinPasswordLen {
+		return fmt.Errorf("Password must be at least %d characters.", ac.MinPasswordLen)
+	}
+	if !ac.RequireStrongPassword {
+		return nil
+	}
+	var upper, lower, other bool
+	for _, c := range pw {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		default:
+			other = true
+		}
+	}
+	if !upper || !lower || !other {
+		return fmt.Errorf("Password must include uppercase and lowercase letters, and a number or symbol.")
+	}
+	return nil
+}
+
+// DefaultBcryptCost is the password hashing cost used when BcryptCost isn't
+// set, which matches what passwords were always hashed with.
+const DefaultBcryptCost = 12
+
+// PasswordCost returns the bcrypt cost to hash new passwords with.
+func (ac AppCfg) PasswordCost() int {
+	if ac.BcryptCost == 0 {
+		return DefaultBcryptCost
+	}
+	return ac.BcryptCost
+}
+
+// defaultCookieName and defaultCookieMaxAge are used when the session
+// cookie's name and lifetime aren't set.
+const (
+	defaultCookieName   = "wfu"
+	defaultCookieMaxAge = 180 * 24 * time.Hour
+)
+
+// Name returns the name of the session cookie.
+func (sc SessionCfg) Name() string {
+	if sc.CookieName == "" {
+		return defaultCookieName
+	}
+	return sc.CookieName
+}
+
+// Secure returns whether session cookies should only be sent over HTTPS for
+// an instance at the given host.
+func (sc SessionCfg) Secure(host string) bool {
+	return sc.CookieSecure || strings.HasPrefix(host, "https://")
+}
+
+// SameSite returns the SameSite mode for session cookies.
+func (sc SessionCfg) SameSite() http.SameSite {
+	switch strings.ToLower(sc.CookieSameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
+
+// MaxAge returns the session cookie's lifetime in seconds.
+func (sc SessionCfg) MaxAge() int {
+	d, err := parseDuration(sc.CookieMaxAge)
+	if err != nil || d <= 0 {
+		d = defaultCookieMaxAge
+	}
+	return int(d / time.Second)
+}
+
+// defaultOAuthScopes are requested from an OAuth provider when no scopes are
+// configured.
+var defaultOAuthScopes = []string{"openid", "profile", "email"}
+
+// Enabled returns whether logging in through the OAuth provider is
+// configured.
+func (oc OAuthCfg) Enabled() bool {
+	return oc.ClientID != ""
+}
+
+// Name returns the provider name shown to users.
+func (oc OAuthCfg) Name() string {
+	if oc.ProviderName == "" {
+		return "SSO"
+	}
+	return oc.ProviderName
+}
+
+// ScopeList returns the scopes to request from the provider.
+func (oc OAuthCfg) ScopeList() []string {
+	if len(oc.Scopes) == 0 {
+		return defaultOAuthScopes
+	}
+	return oc.Scopes
+}
+
+// Callback returns the URL the provider sends users back to, given the
+// instance's host.
+func (oc OAuthCfg) Callback(host string) string {
+	if oc.CallbackURL == "" {
+		return strings.TrimSuffix(host, "/") + "/oauth/callback"
+	}
+	return oc.CallbackURL
+}
+
+// parseDuration parses the given duration string, treating an empty string
+// as zero.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Extensions for AppCfg.MarkdownExtensions.
+const (
+	MarkdownTables        = "tables"
+	MarkdownFencedCode    = "fenced_code"
+	MarkdownAutolink      = "autolink"
+	MarkdownStrikethrough = "strikethrough"
+	MarkdownSpaceHeaders  = "space_headers"
+	MarkdownHeaderIDs     = "header_ids"
+	MarkdownFootnotes     = "footnotes"
+	MarkdownRawHTML       = "raw_html"
+)
+
+var markdownExtensions = []string{MarkdownTables, MarkdownFencedCode, MarkdownAutolink, MarkdownStrikethrough, MarkdownSpaceHeaders, MarkdownHeaderIDs, MarkdownFootnotes, MarkdownRawHTML}
+
+// DefaultMarkdownExtensions are the extensions posts were always rendered
+// with before they could be configured.
+var DefaultMarkdownExtensions = []string{MarkdownTables, MarkdownFencedCode, MarkdownAutolink, MarkdownStrikethrough, MarkdownSpaceHeaders, MarkdownHeaderIDs, MarkdownRawHTML}
+
+func isMarkdownExtension(name string) bool {
+	for _, e := range markdownExtensions {
+		if strings.EqualFold(strings.TrimSpace(name), e) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkdownExtensionEnabled returns whether the given Markdown extension is
+// one of the MarkdownExtensions.
+func (ac AppCfg) MarkdownExtensionEnabled(name string) bool {
+	for _, e := range ac.MarkdownExtensions {
+		if strings.EqualFold(strings.TrimSpace(e), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Events for WebhookCfg.Events.
+const (
+	WebhookPostPublished = "post.published"
+	WebhookPostUpdated   = "post.updated"
+	WebhookPostDeleted   = "post.deleted"
+)
+
+var webhookEvents = []string{WebhookPostPublished, WebhookPostUpdated, WebhookPostDeleted}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if strings.EqualFold(strings.TrimSpace(event), e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled returns whether a webhook URL is configured.
+func (wc WebhookCfg) Enabled() bool {
+	return wc.URL != ""
+}
+
+// Sends returns whether the webhook is sent for the given event.
+func (wc WebhookCfg) Sends(event string) bool {
+	if !wc.Enabled() {
+		return false
+	}
+	if len(wc.Events) == 0 {
 		return true
 	}
-	return int(currentlyUsed) < ac.MaxBlogs
+	for _, e := range wc.Events {
+		if strings.EqualFold(strings.TrimSpace(e), event) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,547 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTimeouts(t *testing.T) {
+	sc := New().Server
+	if d := sc.ReadTimeoutDuration(); d != 5*time.Second {
+		t.Errorf("ReadTimeoutDuration = %s; expected 5s", d)
+	}
+	if d := sc.WriteTimeoutDuration(); d != 10*time.Second {
+		t.Errorf("WriteTimeoutDuration = %s; expected 10s", d)
+	}
+	if d := sc.IdleTimeoutDuration(); d != 2*time.Minute {
+		t.Errorf("IdleTimeoutDuration = %s; expected 2m", d)
+	}
+	if d := sc.ShutdownTimeoutDuration(); d != 30*time.Second {
+		t.Errorf("ShutdownTimeoutDuration = %s; expected 30s", d)
+	}
+
+	sc = ServerCfg{ReadTimeout: "", WriteTimeout: "0", IdleTimeout: "0s"}
+	if sc.ReadTimeoutDuration() != 0 || sc.WriteTimeoutDuration() != 0 || sc.IdleTimeoutDuration() != 0 || sc.ShutdownTimeoutDuration() != 0 {
+		t.Errorf("Expected empty and zero timeouts to mean no timeout; got %+v", sc)
+	}
+}
+
+func TestFederationTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		Timeout  string
+		Expected time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"5s", 5 * time.Second},
+		{"0", 0},
+	} {
+		ac := AppCfg{FederationTimeout: tc.Timeout}
+		if d := ac.FederationTimeoutDuration(); d != tc.Expected {
+			t.Errorf("FederationTimeoutDuration(%q) = %s; expected %s", tc.Timeout, d, tc.Expected)
+		}
+	}
+}
+
+var bindAddrsTestTable = []struct {
+	Bind     string
+	Expected []string
+}{
+	{"", []string{"localhost"}},
+	{"localhost", []string{"localhost"}},
+	{"127.0.0.1, [::1]", []string{"127.0.0.1", "::1"}},
+	{" [fe80::1] ,192.168.1.10,", []string{"fe80::1", "192.168.1.10"}},
+}
+
+func TestValidatePassword(t *testing.T) {
+	ac := New().App
+	for _, tc := range []struct {
+		Pass   string
+		Strong bool
+		Valid  bool
+	}{
+		{"", false, false},
+		{"hunter2", false, false},
+		{"hunter22", false, true},
+		{"pässwörd", false, true},
+		{"hunter22", true, false},
+		{"HUNTER22", true, false},
+		{"HunterTwo", true, false},
+		{"Hunter22", true, true},
+		{"Hunter two", true, true},
+	} {
+		ac.RequireStrongPassword = tc.Strong
+		if err := ac.ValidatePassword(tc.Pass); (err == nil) != tc.Valid {
+			t.Errorf("ValidatePassword(%q), strong %t: error %v; expected valid = %t", tc.Pass, tc.Strong, err, tc.Valid)
+		}
+	}
+
+	// Older configs have no minimum
+	if err := (AppCfg{}).ValidatePassword("a"); err != nil {
+		t.Errorf("ValidatePassword with no minimum failed: %v", err)
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	var sc SessionCfg
+	if sc.Name() != "wfu" || sc.MaxAge() != 180*86400 || sc.SameSite() != http.SameSiteDefaultMode {
+		t.Errorf("Default session cookie = %s, %d, %v", sc.Name(), sc.MaxAge(), sc.SameSite())
+	}
+	if sc.Secure("http://localhost:8080") || !sc.Secure("https://example.com") {
+		t.Error("Default Secure doesn't follow the host's scheme")
+	}
+
+	sc = SessionCfg{CookieName: "sid", CookieSecure: true, CookieSameSite: "Strict", CookieMaxAge: "720h"}
+	if sc.Name() != "sid" || sc.MaxAge() != 30*86400 || sc.SameSite() != http.SameSiteStrictMode {
+		t.Errorf("Session cookie = %s, %d, %v", sc.Name(), sc.MaxAge(), sc.SameSite())
+	}
+	if !sc.Secure("http://localhost:8080") {
+		t.Error("cookie_secure is ignored on an http host")
+	}
+}
+
+func TestBindAddrs(t *testing.T) {
+	for _, tc := range bindAddrsTestTable {
+		addrs := ServerCfg{Bind: tc.Bind}.BindAddrs()
+		if strings.Join(addrs, " ") != strings.Join(tc.Expected, " ") {
+			t.Errorf("BindAddrs(%q) = %q; expected %q", tc.Bind, addrs, tc.Expected)
+		}
+	}
+}
+
+func TestIsUsernameReserved(t *testing.T) {
+	ac := New().App
+	for _, name := range []string{"admin", "API", "About", "login", "tags"} {
+		if !ac.IsUsernameReserved(name) {
+			t.Errorf("IsUsernameReserved(%s) = false; expected default seed to reserve it", name)
+		}
+	}
+	if ac.IsUsernameReserved("matt") {
+		t.Error("IsUsernameReserved(matt) = true; expected false")
+	}
+
+	ac.ReservedUsernames = append(ac.ReservedUsernames, "WriteFreely")
+	if !ac.IsUsernameReserved("writefreely") {
+		t.Error("IsUsernameReserved(writefreely) = false; expected case-insensitive match")
+	}
+	if (AppCfg{}).IsUsernameReserved("admin") {
+		t.Error("IsUsernameReserved(admin) = true with no reserved usernames")
+	}
+}
+
+func TestPostTooLong(t *testing.T) {
+	ac := AppCfg{MaxPostLength: 5}
+	// Each of these is 5 runes, but more than 5 bytes
+	for _, c := range []string{"hello", "héllo", "日本語です", "🙂🙂🙂🙂🙂"} {
+		if ac.PostTooLong(c) {
+			t.Errorf("PostTooLong(%s) = true for %d bytes; expected runes to be counted", c, len(c))
+		}
+	}
+	if !ac.PostTooLong("日本語ですね") {
+		t.Error("PostTooLong(日本語ですね) = false for 6 runes; expected true")
+	}
+	if (AppCfg{}).PostTooLong(strings.Repeat("a", 100000)) {
+		t.Error("PostTooLong = true with no limit")
+	}
+}
+
+func TestBlogTooLong(t *testing.T) {
+	ac := AppCfg{MaxBlogTitleLen: 5, MaxBlogDescLen: 6}
+	for _, tc := range []struct {
+		Text        string
+		Title, Desc bool
+	}{
+		{"hello", false, false},
+		{"日本語です", false, false},
+		{"🙂🙂🙂🙂🙂", false, false},
+		{"日本語ですね", true, false},
+		{"hello!!", true, true},
+	} {
+		if ac.BlogTitleTooLong(tc.Text) != tc.Title || ac.BlogDescTooLong(tc.Text) != tc.Desc {
+			t.Errorf("%q (%d bytes): title too long %t, description %t; expected %t, %t", tc.Text, len(tc.Text), ac.BlogTitleTooLong(tc.Text), ac.BlogDescTooLong(tc.Text), tc.Title, tc.Desc)
+		}
+	}
+	if (AppCfg{}).BlogTitleTooLong(strings.Repeat("a", 10000)) || (AppCfg{}).BlogDescTooLong(strings.Repeat("a", 10000)) {
+		t.Error("Blog text is too long with no limit")
+	}
+}
+
+var instanceAllowedTestTable = []struct {
+	Name     string
+	Allowed  []string
+	Blocked  []string
+	Host     string
+	Expected bool
+}{
+	{"No lists", nil, nil, "example.com", true},
+	{"Blocked host", nil, []string{"spam.example"}, "spam.example", false},
+	{"Blocked host, other case", nil, []string{"spam.example"}, "SPAM.example", false},
+	{"Blocked host, trailing dot", nil, []string{"spam.example"}, "spam.example.", false},
+	{"Subdomain of exact block", nil, []string{"spam.example"}, "www.spam.example", true},
+	{"Unblocked host", nil, []string{"spam.example"}, "example.com", true},
+	{"Blocked subdomain", nil, []string{".spam.example"}, "www.spam.example", false},
+	{"Blocked domain via dot", nil, []string{".spam.example"}, "spam.example", false},
+	{"Suffix isn't a subdomain", nil, []string{".spam.example"}, "notspam.example", true},
+	{"Allowed host", []string{"friend.example"}, nil, "friend.example", true},
+	{"Not allowed host", []string{"friend.example"}, nil, "example.com", false},
+	{"Allowed subdomain", []string{".friend.example"}, nil, "blog.friend.example", true},
+	{"Allowlist takes precedence", []string{"friend.example"}, []string{"friend.example"}, "friend.example", true},
+	{"Allowlist ignores blocklist", []string{"friend.example"}, []string{"spam.example"}, "other.example", false},
+}
+
+func TestInstanceAllowed(t *testing.T) {
+	for _, tc := range instanceAllowedTestTable {
+		ac := AppCfg{AllowedInstances: tc.Allowed, BlockedInstances: tc.Blocked}
+		if allowed := ac.InstanceAllowed(tc.Host); allowed != tc.Expected {
+			t.Errorf("%s: InstanceAllowed(%s) = %t; expected %t", tc.Name, tc.Host, allowed, tc.Expected)
+		}
+	}
+}
+
+var canCreateBlogTestTable = []struct {
+	MaxBlogs, Count int
+	Expected        bool
+}{
+	{0, 0, true},
+	{0, 100, true},
+	{-1, 5, true},
+	{3, 2, true},
+	{3, 3, false},
+	{3, 5, false},
+	{1, 1, false},
+}
+
+func TestCanCreateBlog(t *testing.T) {
+	for _, tc := range canCreateBlogTestTable {
+		ac := AppCfg{MaxBlogs: tc.MaxBlogs}
+		ok, err := ac.CanCreateBlog(tc.Count)
+		if ok != tc.Expected {
+			t.Errorf("MaxBlogs %d, count %d: CanCreateBlog = %t; expected %t", tc.MaxBlogs, tc.Count, ok, tc.Expected)
+		}
+		if ok != (err == nil) {
+			t.Errorf("MaxBlogs %d, count %d: CanCreateBlog returned %t with error %v", tc.MaxBlogs, tc.Count, ok, err)
+		}
+		if ac.CanCreateBlogs(uint64(tc.Count)) != ok {
+			t.Errorf("MaxBlogs %d, count %d: CanCreateBlogs doesn't match CanCreateBlog", tc.MaxBlogs, tc.Count)
+		}
+	}
+
+	_, err := AppCfg{MaxBlogs: 3}.CanCreateBlog(3)
+	if err == nil || !strings.Contains(err.Error(), "maximum of 3 blogs") {
+		t.Errorf("At-limit error = %v; expected it to mention the limit", err)
+	}
+	_, err = AppCfg{MaxBlogs: 1}.CanCreateBlog(2)
+	if err == nil || !strings.Contains(err.Error(), "maximum of 1 blog ") {
+		t.Errorf("Over-limit error = %v; expected it to mention the limit", err)
+	}
+}
+
+func TestSignatureAlgAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		Allowed  []string
+		Alg      string
+		Expected bool
+	}{
+		{nil, "rsa-sha256", true},
+		{nil, "hs2019", true},
+		{nil, "rsa-sha1", false},
+		{[]string{"rsa-sha256"}, "RSA-SHA256", true},
+		{[]string{"rsa-sha256"}, "hs2019", false},
+		{[]string{"rsa-sha1", "rsa-sha256"}, "rsa-sha1", true},
+	} {
+		ac := AppCfg{AllowedSignatureAlgs: tc.Allowed}
+		if ok := ac.SignatureAlgAllowed(tc.Alg); ok != tc.Expected {
+			t.Errorf("Allowed %q: SignatureAlgAllowed(%s) = %t; expected %t", tc.Allowed, tc.Alg, ok, tc.Expected)
+		}
+	}
+}
+
+func TestCanCreateDraft(t *testing.T) {
+	for _, tc := range []struct {
+		MaxDrafts, Count int
+		Expected         bool
+	}{
+		{0, 0, true},
+		{0, 5000, true},
+		{5, 4, true},
+		{5, 5, false},
+		{5, 6, false},
+	} {
+		ok, err := AppCfg{MaxDrafts: tc.MaxDrafts}.CanCreateDraft(tc.Count)
+		if ok != tc.Expected || ok != (err == nil) {
+			t.Errorf("MaxDrafts %d, count %d: CanCreateDraft = %t, %v; expected %t", tc.MaxDrafts, tc.Count, ok, err, tc.Expected)
+		}
+	}
+	if _, err := (AppCfg{MaxDrafts: 5}).CanCreateDraft(5); err == nil || !strings.Contains(err.Error(), "maximum of 5 drafts") {
+		t.Errorf("At-limit error = %v; expected it to mention the limit", err)
+	}
+}
+
+func TestValidateTheme(t *testing.T) {
+	installed := []string{"write", "fonts"}
+	ac := New().App
+	if err := ac.ValidateTheme(installed); err != nil {
+		t.Errorf("Default theme failed validation: %v", err)
+	}
+	ac.Theme = "wirte"
+	err := ac.ValidateTheme(installed)
+	if err == nil {
+		t.Fatal("Bogus theme passed validation")
+	}
+	if !strings.Contains(err.Error(), "'wirte'") || !strings.Contains(err.Error(), "write, fonts") {
+		t.Errorf("Error = %v; expected it to name the theme and the installed ones", err)
+	}
+}
+
+func TestValidateBlogTheme(t *testing.T) {
+	installed := []string{"write", "dark"}
+	ac := New().App
+	if err := ac.ValidateBlogTheme(installed); err != nil {
+		t.Errorf("Unset blog theme failed validation: %v", err)
+	}
+	ac.DefaultBlogTheme = "dark"
+	if err := ac.ValidateBlogTheme(installed); err != nil {
+		t.Errorf("Installed blog theme failed validation: %v", err)
+	}
+	ac.DefaultBlogTheme = "drak"
+	err := ac.ValidateBlogTheme(installed)
+	if err == nil {
+		t.Fatal("Bogus blog theme passed validation")
+	}
+	if !strings.Contains(err.Error(), "Blog theme 'drak'") {
+		t.Errorf("Error = %v; expected it to name the blog theme", err)
+	}
+}
+
+func TestBlogTheme(t *testing.T) {
+	for _, tc := range []struct {
+		App      AppCfg
+		Expected string
+	}{
+		{AppCfg{Theme: "write"}, "write"},
+		{AppCfg{Theme: "dark"}, "dark"},
+		{AppCfg{Theme: "write", DefaultBlogTheme: "dark"}, "dark"},
+	} {
+		if theme := tc.App.BlogTheme(); theme != tc.Expected {
+			t.Errorf("Theme %q, blog theme %q: BlogTheme() = %s; expected %s", tc.App.Theme, tc.App.DefaultBlogTheme, theme, tc.Expected)
+		}
+	}
+}
+
+func TestThemesPath(t *testing.T) {
+	if dir, err := New().App.ThemesPath(); dir != "" || err != nil {
+		t.Errorf("ThemesPath() = %q, %v; expected no themes dir", dir, err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unable to get working dir: %v", err)
+	}
+	ac := AppCfg{ThemesDir: "testdata"}
+	dir, err := ac.ThemesPath()
+	if err != nil {
+		t.Fatalf("ThemesPath() failed: %v", err)
+	}
+	if expected := filepath.Join(wd, "testdata"); dir != expected {
+		t.Errorf("ThemesPath() = %s; expected %s", dir, expected)
+	}
+
+	tmp, err := ioutil.TempDir("", "wfthemes")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	ac.ThemesDir = tmp + "/../" + filepath.Base(tmp) + "/"
+	if dir, err = ac.ThemesPath(); err != nil || dir != tmp {
+		t.Errorf("ThemesPath() = %s, %v; expected cleaned %s", dir, err, tmp)
+	}
+
+	for _, d := range []string{filepath.Join(tmp, "missing"), filepath.Join("testdata", "config.ini")} {
+		ac.ThemesDir = d
+		if _, err = ac.ThemesPath(); err == nil {
+			t.Errorf("ThemesPath() with %s succeeded; expected an error", d)
+		}
+		cfg := New()
+		cfg.App.ThemesDir = d
+		if err = cfg.Validate(); err == nil || !strings.Contains(err.Error(), "themes_dir") {
+			t.Errorf("Validate() with themes_dir %s = %v; expected a themes_dir error", d, err)
+		}
+	}
+}
+
+func TestLang(t *testing.T) {
+	for _, tc := range []struct {
+		Lang      string
+		Supported bool
+	}{
+		{"", true},
+		{"en", true},
+		{"en_US", false},
+		{"klingon", false},
+	} {
+		if ok := (AppCfg{Lang: tc.Lang}).IsLangSupported(); ok != tc.Supported {
+			t.Errorf("IsLangSupported(%q) = %t; expected %t", tc.Lang, ok, tc.Supported)
+		}
+	}
+
+	cfg, err := LoadReader(strings.NewReader("[app]\nlanguage = en_US\n"))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if cfg.App.Lang != "en" {
+		t.Errorf("Lang = %s; expected unsupported en_US to fall back to en", cfg.App.Lang)
+	}
+}
+
+func TestActivityIgnored(t *testing.T) {
+	for _, tc := range []struct {
+		Ignored  []string
+		Type     string
+		Expected bool
+	}{
+		{nil, "Like", false},
+		{[]string{"Like", "Announce"}, "Announce", true},
+		{[]string{"Like"}, "like", true},
+		{[]string{"Like"}, "Follow", false},
+		{[]string{"Like"}, "", false},
+	} {
+		ac := AppCfg{IgnoredActivities: tc.Ignored}
+		if ok := ac.ActivityIgnored(tc.Type); ok != tc.Expected {
+			t.Errorf("Ignored %q: ActivityIgnored(%s) = %t; expected %t", tc.Ignored, tc.Type, ok, tc.Expected)
+		}
+	}
+}
+
+func TestWebhookSends(t *testing.T) {
+	for _, tc := range []struct {
+		Webhook  WebhookCfg
+		Event    string
+		Expected bool
+	}{
+		{WebhookCfg{}, WebhookPostPublished, false},
+		{WebhookCfg{Events: []string{WebhookPostPublished}}, WebhookPostPublished, false},
+		{WebhookCfg{URL: "https://hooks.example.com/wf"}, WebhookPostDeleted, true},
+		{WebhookCfg{URL: "https://hooks.example.com/wf", Events: []string{"Post.Published"}}, WebhookPostPublished, true},
+		{WebhookCfg{URL: "https://hooks.example.com/wf", Events: []string{WebhookPostPublished}}, WebhookPostUpdated, false},
+	} {
+		if ok := tc.Webhook.Sends(tc.Event); ok != tc.Expected {
+			t.Errorf("%+v: Sends(%s) = %t; expected %t", tc.Webhook, tc.Event, ok, tc.Expected)
+		}
+	}
+}
+
+func TestContainsBlockedWord(t *testing.T) {
+	ac := AppCfg{BlockedWords: []string{"ass", "Free Money", "café"}}
+	for _, tc := range []struct {
+		Content string
+		Found   bool
+		Word    string
+	}{
+		{"A donkey is an ass.", true, "ass"},
+		{"What an ASS!", true, "ass"},
+		{"First class passage", false, ""},
+		{"Get free money now", true, "Free Money"},
+		{"Get free\n\nMONEY now", true, "Free Money"},
+		{"It's free. Money is tight.", true, "Free Money"},
+		{"Freemoney", false, ""},
+		{"Meet me at the CAFÉ", true, "café"},
+		{"Cafés", false, ""},
+		{"", false, ""},
+	} {
+		found, word := ac.ContainsBlockedWord(tc.Content)
+		if found != tc.Found || word != tc.Word {
+			t.Errorf("%q: ContainsBlockedWord = %t, %q; expected %t, %q", tc.Content, found, word, tc.Found, tc.Word)
+		}
+	}
+	if found, _ := (AppCfg{}).ContainsBlockedWord("anything"); found {
+		t.Error("Content is blocked without any blocked words")
+	}
+}
+
+func TestSQLiteFileName(t *testing.T) {
+	for _, tc := range []struct {
+		DataDir, FileName, Expected string
+	}{
+		{"", "writefreely.db", "writefreely.db"},
+		{"/var/lib/writefreely", "writefreely.db", "/var/lib/writefreely/writefreely.db"},
+		{"/var/lib/writefreely", "db/writefreely.db", "/var/lib/writefreely/db/writefreely.db"},
+		{"/var/lib/writefreely", "/srv/writefreely.db", "/srv/writefreely.db"},
+		{"data", "writefreely.db", filepath.Join("data", "writefreely.db")},
+	} {
+		cfg := New()
+		cfg.Server.DataDir = tc.DataDir
+		cfg.Database.FileName = tc.FileName
+		if fname := cfg.SQLiteFileName(); fname != tc.Expected {
+			t.Errorf("Data dir %q, filename %q: SQLiteFileName() = %s; expected %s", tc.DataDir, tc.FileName, fname, tc.Expected)
+		}
+	}
+
+	sc := ServerCfg{DataDir: "/var/lib/writefreely"}
+	if dir := sc.KeysDir(); dir != "/var/lib/writefreely" {
+		t.Errorf("KeysDir() = %s; expected the data dir", dir)
+	}
+}
+
+func TestCheckDataDir(t *testing.T) {
+	if err := (ServerCfg{}).CheckDataDir(); err != nil {
+		t.Errorf("No data dir failed the check: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "wfdata")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = (ServerCfg{DataDir: dir}).CheckDataDir(); err != nil {
+		t.Errorf("Data dir failed the check: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+		t.Errorf("Checking the data dir created %s", files[0].Name())
+	}
+
+	if err = (ServerCfg{DataDir: filepath.Join(dir, "missing")}).CheckDataDir(); err == nil {
+		t.Error("Missing data dir passed the check")
+	}
+	fname := filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(fname, nil, 0644); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	if err = (ServerCfg{DataDir: fname}).CheckDataDir(); err == nil {
+		t.Error("File passed the data dir check")
+	}
+}
+
+func TestCheckDataDirWritable(t *testing.T) {
+	if err := (ServerCfg{}).CheckDataDirWritable(); err != nil {
+		t.Errorf("No data dir failed the check: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "wfdata")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = (ServerCfg{DataDir: dir}).CheckDataDirWritable(); err != nil {
+		t.Errorf("Writable data dir failed the check: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+		t.Errorf("Checking the data dir left %s behind", files[0].Name())
+	}
+	if err = (ServerCfg{DataDir: filepath.Join(dir, "missing")}).CheckDataDirWritable(); err == nil {
+		t.Error("Missing data dir passed the check")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// redacted replaces secret values in a Config meant for display.
+const redacted = "***"
+
+// LoadJSON reads a Config encoded as JSON, using the same keys as the INI
+// file. Like LoadReader, it migrates older configurations and cleans up
+// lists, but doesn't fill in defaults for missing values. JSON has no
+// [include] section, so nothing else is read.
+func LoadJSON(r io.Reader) (*Config, error) {
+	uc := &Config{}
+	err := json.NewDecoder(r).Decode(uc)
+	if err != nil {
+		return nil, err
+	}
+	cleanLists(reflect.ValueOf(uc).Elem())
+	migrateLoaded(uc)
+	return uc, nil
+}
+
+// Redacted returns a deep copy of the Config with passwords, tokens, and
+// private key paths replaced by "***", so it can be safely displayed,
+// shared in support requests, or marshaled for the admin dashboard.
+func (cfg *Config) Redacted() *Config {
+	rc := cfg.Merge(nil)
+	for _, s := range []*string{
+		&rc.Database.Password,
+		&rc.Database.PasswordFile,
+		&rc.Database.DSN,
+		&rc.Server.TLSKeyPath,
+		&rc.Server.MetricsToken,
+		&rc.App.MaintenanceToken,
+		&rc.Email.SMTPPassword,
+		&rc.Storage.S3SecretKey,
+		&rc.Cache.RedisPassword,
+		&rc.OAuth.ClientSecret,
+		&rc.Webhook.Secret,
+	} {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	for i, r := range rc.Database.ReadReplicas {
+		if replicaCfg(rc.Database, r).DSN == "" {
+			continue
+		}
+		rc.Database.ReadReplicas[i] = redacted
+		if dsn, err := (DatabaseCfg{Type: rc.Database.Type, DSN: r}).RedactedDSN(); err == nil {
+			rc.Database.ReadReplicas[i] = dsn
+		}
+	}
+	return rc
+}
+
+// RedactSecrets is the same as Redacted.
+func (cfg *Config) RedactSecrets() *Config {
+	return cfg.Redacted()
+}
+
+// String returns the redacted Config in the INI file format.
+func (cfg *Config) String() string {
+	s, _ := SaveString(cfg.Redacted())
+	return s
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	cfg := New()
+	cfg.Database.Password = "s3cret"
+	cfg.Server.AutoCertHosts = []string{"example.com", "www.example.com"}
+	cfg.App.SiteName = "JSON Blog"
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(b), `"site_name":"JSON Blog"`) {
+		t.Errorf("JSON doesn't use ini key names: %s", b)
+	}
+	loaded, err := LoadJSON(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, loaded) {
+		t.Errorf("Round trip changed Config:\n%+v\n%+v", cfg, loaded)
+	}
+
+	if _, err := LoadJSON(strings.NewReader(`{"server": {"port": "eighty"}}`)); err == nil {
+		t.Error("Expected error loading malformed JSON")
+	}
+	// Older layouts are migrated like they are from INI files
+	old, err := LoadJSON(strings.NewReader(`{"app": {"open_registration": true, "reserved_usernames": ["admin", " ", "root"]}}`))
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if old.Version != CurrentVersion || old.App.Registration() != RegistrationOpen {
+		t.Errorf("Version, registration = %d, %q; expected %d, %q", old.Version, old.App.Registration(), CurrentVersion, RegistrationOpen)
+	}
+	if !reflect.DeepEqual(old.App.ReservedUsernames, []string{"admin", "root"}) {
+		t.Errorf("Reserved usernames = %q; expected the list cleaned up", old.App.ReservedUsernames)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	cfg := New()
+	cfg.Database.Password = "s3cret"
+	cfg.Server.TLSKeyPath = "/etc/ssl/private/key.pem"
+
+	rc := cfg.RedactSecrets()
+	if rc.Database.Password != redacted || rc.Server.TLSKeyPath != redacted {
+		t.Errorf("Secrets weren't redacted: %s %s", rc.Database.Password, rc.Server.TLSKeyPath)
+	}
+	if rc.Database.PasswordFile != "" {
+		t.Errorf("Empty PasswordFile = %s; expected it to stay empty", rc.Database.PasswordFile)
+	}
+	if cfg.Database.Password != "s3cret" {
+		t.Error("RedactSecrets modified the original Config")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := New()
+	cfg.Database.Password = "s3cret"
+	cfg.Email.SMTPPassword = "smtp-s3cret"
+	cfg.Storage.S3SecretKey = "s3-s3cret"
+	cfg.App.ReservedUsernames = []string{"staff"}
+
+	rc := cfg.Redacted()
+	for name, v := range map[string]string{
+		"Database.Password":   rc.Database.Password,
+		"Email.SMTPPassword":  rc.Email.SMTPPassword,
+		"Storage.S3SecretKey": rc.Storage.S3SecretKey,
+	} {
+		if v != "***" {
+			t.Errorf("%s = %q; expected ***", name, v)
+		}
+	}
+	rc.App.ReservedUsernames[0] = "changed"
+	if cfg.Database.Password != "s3cret" || cfg.Email.SMTPPassword != "smtp-s3cret" || cfg.Storage.S3SecretKey != "s3-s3cret" {
+		t.Error("Redacted modified the original Config")
+	}
+	if cfg.App.ReservedUsernames[0] != "staff" {
+		t.Error("Redacted copy shares slices with the original Config")
+	}
+
+	s := cfg.String()
+	if strings.Contains(s, "s3cret") {
+		t.Errorf("String() includes a secret:\n%s", s)
+	}
+	if !strings.Contains(s, "password") || !strings.Contains(s, "***") {
+		t.Errorf("String() doesn't include the redacted password:\n%s", s)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// unknownKeys returns the sections and keys in the given INI file that
+// don't map to any Config field, like keys left over from older versions.
+func unknownKeys(f *ini.File) []string {
+	known := knownKeys(reflect.TypeOf(Config{}))
+	var unknown []string
+	for _, sec := range f.Sections() {
+		keys, ok := known[sec.Name()]
+		if !ok {
+			unknown = append(unknown, "["+sec.Name()+"]")
+			continue
+		}
+		for _, k := range sec.KeyStrings() {
+			if !keys[k] {
+				if sec.Name() == ini.DEFAULT_SECTION {
+					unknown = append(unknown, k)
+				} else {
+					unknown = append(unknown, sec.Name()+"."+k)
+				}
+			}
+		}
+	}
+	return unknown
+}
+
+// knownKeys maps each section name in the given Config type to the set of
+// keys it holds.
+func knownKeys(ct reflect.Type) map[string]map[string]bool {
+	known := map[string]map[string]bool{
+		ini.DEFAULT_SECTION: {},
+	}
+	for i := 0; i < ct.NumField(); i++ {
+		name := iniName(ct.Field(i))
+		if name == "" {
+			continue
+		}
+		if ct.Field(i).Type.Kind() != reflect.Struct {
+			known[ini.DEFAULT_SECTION][name] = true
+			continue
+		}
+
+		st := ct.Field(i).Type
+		keys := map[string]bool{}
+		for j := 0; j < st.NumField(); j++ {
+			if k := iniName(st.Field(j)); k != "" {
+				keys[k] = true
+			}
+		}
+		known[name] = keys
+	}
+	return known
+}
+
+// iniName returns the name the given field is stored under in INI files, or
+// an empty string if it isn't stored.
+func iniName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("ini"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	f, err := ini.Load([]byte(`version = 3
+theme = write
+
+[server]
+port = 8080
+
+[app]
+site_name = Blog
+foo = bar
+
+[plugins]
+enabled = true
+`))
+	if err != nil {
+		t.Fatalf("Unable to parse INI: %v", err)
+	}
+	expected := []string{"theme", "app.foo", "[plugins]"}
+	if unknown := unknownKeys(f); !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("unknownKeys() = %v; expected %v", unknown, expected)
+	}
+
+	for _, fixture := range []string{"config.ini", "config-v0.ini"} {
+		f, err = ini.Load(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatalf("Unable to load %s: %v", fixture, err)
+		}
+		if unknown := unknownKeys(f); len(unknown) > 0 {
+			t.Errorf("%s has unknown keys %v", fixture, unknown)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// LogLevel is the minimum severity of messages that get logged.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// ParseLogLevel returns the LogLevel with the given name: debug, info, warn,
+// or error. An empty name means info.
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "", "info":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error":
+		return LogLevelError, nil
+	}
+	return LogLevelInfo, fmt.Errorf("Unknown log level '%s'", s)
+}
+
+// Writer returns the file that logs should be written to, opening it for
+// appending, or os.Stderr if no file is configured.
+func (lc LogCfg) Writer() (io.Writer, error) {
+	if lc.File == "" {
+		return os.Stderr, nil
+	}
+	return os.OpenFile(lc.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// Excludes returns whether requests for the given path are left out of the
+// request log.
+func (lc LogCfg) Excludes(path string) bool {
+	for _, p := range lc.ExcludePaths {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
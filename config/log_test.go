@@ -0,0 +1,94 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var logLevelTestTable = []struct {
+	Name     string
+	Expected LogLevel
+	Valid    bool
+}{
+	{"", LogLevelInfo, true},
+	{"debug", LogLevelDebug, true},
+	{"INFO", LogLevelInfo, true},
+	{"warn", LogLevelWarn, true},
+	{"warning", LogLevelWarn, true},
+	{"error", LogLevelError, true},
+	{"verbose", LogLevelInfo, false},
+	{"3", LogLevelInfo, false},
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, tc := range logLevelTestTable {
+		l, err := ParseLogLevel(tc.Name)
+		if (err == nil) != tc.Valid {
+			t.Errorf("ParseLogLevel(%q) error = %v; expected valid = %t", tc.Name, err, tc.Valid)
+		}
+		if l != tc.Expected {
+			t.Errorf("ParseLogLevel(%q) = %d; expected %d", tc.Name, l, tc.Expected)
+		}
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	w, err := New().Log.Writer()
+	if err != nil {
+		t.Fatalf("Writer failed: %v", err)
+	}
+	if w != os.Stderr {
+		t.Errorf("Writer() = %v; expected os.Stderr by default", w)
+	}
+
+	dir, err := ioutil.TempDir("", "wflog")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "writefreely.log")
+	w, err = LogCfg{File: fname}.Writer()
+	if err != nil {
+		t.Fatalf("Writer failed: %v", err)
+	}
+	f, ok := w.(*os.File)
+	if !ok || f.Name() != fname {
+		t.Fatalf("Writer() = %v; expected %s", w, fname)
+	}
+	f.Close()
+
+	if _, err := (LogCfg{File: filepath.Join(dir, "missing", "writefreely.log")}).Writer(); err == nil {
+		t.Error("Expected error opening log file in nonexistent directory")
+	}
+}
+
+func TestLogExcludes(t *testing.T) {
+	lc := LogCfg{ExcludePaths: []string{"/favicon.ico", "/css/"}}
+	for path, expected := range map[string]bool{
+		"/favicon.ico":     true,
+		"/favicon.ico.bak": false,
+		"/css/write.css":   true,
+		"/css/":            true,
+		"/css":             false,
+		"/":                false,
+	} {
+		if excluded := lc.Excludes(path); excluded != expected {
+			t.Errorf("Excludes(%s) = %t; expected %t", path, excluded, expected)
+		}
+	}
+	if (LogCfg{}).Excludes("/") {
+		t.Error("Paths are excluded by default")
+	}
+}
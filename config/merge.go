@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"io"
+	"reflect"
+)
+
+// Merge returns a new Config with the values of cfg, overridden by every
+// non-zero field in other. Since zero values are never applied, an override
+// can't set a field to "", 0, or false.
+func (cfg *Config) Merge(other *Config) *Config {
+	merged := &Config{}
+	mergeValue(reflect.ValueOf(merged).Elem(), reflect.ValueOf(cfg).Elem())
+	if other != nil {
+		mergeValue(reflect.ValueOf(merged).Elem(), reflect.ValueOf(other).Elem())
+	}
+	return merged
+}
+
+// Effective returns a new Config with the values of cfg, and the defaults
+// from New for the rest, to show what the app is actually using. Booleans
+// can't be told apart from missing ones, and some zero values stand for
+// settings of their own, like no timeout or no limit, so those are kept.
+func (cfg *Config) Effective() *Config {
+	ec := New().Merge(cfg)
+	keepBools(reflect.ValueOf(ec).Elem(), reflect.ValueOf(cfg).Elem())
+
+	ec.Server.ReadTimeout = cfg.Server.ReadTimeout
+	ec.Server.WriteTimeout = cfg.Server.WriteTimeout
+	ec.Server.IdleTimeout = cfg.Server.IdleTimeout
+	ec.Server.ShutdownTimeout = cfg.Server.ShutdownTimeout
+	ec.Database.MaxOpenConns = cfg.Database.MaxOpenConns
+	ec.Database.ConnectRetries = cfg.Database.ConnectRetries
+	ec.App.MaxBlogs = cfg.App.MaxBlogs
+	ec.App.MinPasswordLen = cfg.App.MinPasswordLen
+	ec.App.MaxBlogTitleLen = cfg.App.MaxBlogTitleLen
+	ec.App.MaxBlogDescLen = cfg.App.MaxBlogDescLen
+	ec.App.PublicStats = cfg.App.PublicStats
+	ec.App.WebFontSource = cfg.App.WebFontSource
+	ec.App.FederationCacheTTL = cfg.App.FederationCacheTTL
+	ec.App.MaxFederationConcurrency = cfg.App.MaxFederationConcurrency
+	ec.App.ScheduledPublishInterval = cfg.App.ScheduledPublishInterval
+	ec.App.MaxAPIBodyBytes = cfg.App.MaxAPIBodyBytes
+	ec.Storage.MaxUploadBytes = cfg.Storage.MaxUploadBytes
+	ec.RateLimit = cfg.RateLimit
+	if (cfg.Database.Type != "" && cfg.Database.Type != "mysql") || cfg.Database.DSN != "" || cfg.Database.Socket != "" {
+		// The MySQL host and port don't apply, or aren't used to connect
+		ec.Database.Host = cfg.Database.Host
+		ec.Database.Port = cfg.Database.Port
+	}
+	return ec
+}
+
+// DumpEffective writes the redacted Effective Config to w in the INI file
+// format.
+func (cfg *Config) DumpEffective(w io.Writer) error {
+	s, err := SaveString(cfg.Effective().Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, s)
+	return err
+}
+
+// keepBools copies every bool field in src to dst, descending into nested
+// structs.
+func keepBools(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				keepBools(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Bool:
+		dst.Set(src)
+	}
+}
+
+// mergeValue copies each non-zero field in src to dst, descending into
+// nested structs.
+func mergeValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				mergeValue(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if src.Len() > 0 {
+			dst.Set(reflect.AppendSlice(reflect.MakeSlice(src.Type(), 0, src.Len()), src))
+		}
+	default:
+		if !reflect.DeepEqual(src.Interface(), reflect.Zero(src.Type()).Interface()) {
+			dst.Set(src)
+		}
+	}
+}
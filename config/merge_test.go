@@ -0,0 +1,145 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := New()
+	base.Server.Port = 8080
+	base.App.SiteName = "Base Blog"
+	base.Server.AutoCertHosts = []string{"example.com"}
+
+	override := &Config{}
+	override.App.SiteName = "Staging Blog"
+	override.App.SingleUser = true
+	override.Database.Host = "db.staging"
+	override.Database.MaxOpenConns = 10
+
+	merged := base.Merge(override)
+	if merged == base || merged == override {
+		t.Fatal("Merge returned one of its inputs; expected a new Config")
+	}
+	if merged.App.SiteName != "Staging Blog" {
+		t.Errorf("SiteName = %s; expected string override", merged.App.SiteName)
+	}
+	if merged.Server.Port != 8080 {
+		t.Errorf("Port = %d; expected zero override to keep base port", merged.Server.Port)
+	}
+	if merged.Database.MaxOpenConns != 10 {
+		t.Errorf("MaxOpenConns = %d; expected int override", merged.Database.MaxOpenConns)
+	}
+	if !merged.App.SingleUser {
+		t.Error("SingleUser = false; expected bool override")
+	}
+	if merged.Database.Host != "db.staging" || merged.Database.Type != base.Database.Type {
+		t.Errorf("Database = %s %s; expected nested override to keep other fields", merged.Database.Type, merged.Database.Host)
+	}
+	if merged.Database.Host == base.Database.Host || base.App.SiteName != "Base Blog" {
+		t.Error("Merge modified the base Config")
+	}
+
+	merged.Server.AutoCertHosts[0] = "changed.example.com"
+	if base.Server.AutoCertHosts[0] != "example.com" {
+		t.Error("Merged Config shares slices with the base Config")
+	}
+
+	if m := base.Merge(nil); m.App.SiteName != "Base Blog" || m.Server.Port != 8080 {
+		t.Errorf("Merge(nil) = %+v; expected a copy of the base", m)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+	sparse := fmt.Sprintf(`version = %d
+
+[server]
+port = 9000
+
+[database]
+type     = sqlite3
+filename = writefreely.db
+
+[app]
+site_name  = Sparse Blog
+host       = https://sparse.example.com
+federation = false
+
+[email]
+smtp_password = hunter2
+`, CurrentVersion)
+	if err := ioutil.WriteFile(fname, []byte(sparse), 0600); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+	loaded, err := Load(fname)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	ec := loaded.Effective()
+	def := New()
+	if ec.Server.Port != 9000 || ec.App.SiteName != "Sparse Blog" || ec.Database.Type != "sqlite3" {
+		t.Errorf("Effective config lost file values: %+v", ec)
+	}
+	if ec.Server.Bind != def.Server.Bind || ec.App.Theme != def.App.Theme || ec.App.MinUsernameLen != def.App.MinUsernameLen || !reflect.DeepEqual(ec.Log, def.Log) {
+		t.Errorf("Effective config is missing defaults: bind %q, theme %q, min_username_len %d, log %+v", ec.Server.Bind, ec.App.Theme, ec.App.MinUsernameLen, ec.Log)
+	}
+	if ec.App.Federation || ec.App.WebFonts {
+		t.Error("Effective config turned on booleans that are off in the file")
+	}
+	if ec.Server.ReadTimeout != "" || ec.Database.Host != "" || ec.Database.MaxOpenConns != 0 {
+		t.Errorf("Effective config filled in meaningful zero values: read_timeout %q, host %q, max_open_conns %d", ec.Server.ReadTimeout, ec.Database.Host, ec.Database.MaxOpenConns)
+	}
+	if loaded.App.Theme != "" {
+		t.Error("Effective modified the loaded Config")
+	}
+
+	var buf bytes.Buffer
+	if err = loaded.DumpEffective(&buf); err != nil {
+		t.Fatalf("DumpEffective failed: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "hunter2") {
+		t.Error("DumpEffective output includes a secret")
+	}
+	for _, l := range []string{"Sparse Blog", "theme", def.App.Theme} {
+		if !strings.Contains(out, l) {
+			t.Errorf("DumpEffective output doesn't include %q:\n%s", l, out)
+		}
+	}
+}
+
+func TestEffectiveDatabaseAddr(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Database DatabaseCfg
+		Host     string
+		Port     int
+	}{
+		{"MySQL", DatabaseCfg{Type: "mysql"}, "localhost", 3306},
+		{"Socket", DatabaseCfg{Type: "mysql", Socket: "/run/mysqld/mysqld.sock"}, "", 0},
+		{"DSN", DatabaseCfg{Type: "mysql", DSN: "wf@unix(/run/mysqld/mysqld.sock)/writefreely"}, "", 0},
+		{"SQLite", DatabaseCfg{Type: "sqlite3"}, "", 0},
+	} {
+		cfg := &Config{Database: tc.Database}
+		if db := cfg.Effective().Database; db.Host != tc.Host || db.Port != tc.Port {
+			t.Errorf("%s: effective host and port = %q, %d; expected %q, %d", tc.Name, db.Host, db.Port, tc.Host, tc.Port)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/writeas/web-core/log"
+)
+
+// CurrentVersion is the version of the configuration layout this package
+// reads and writes.
+const CurrentVersion = 9
+
+// configMigrations upgrades a Config from the version at its index to the
+// next one, returning a description of each change made.
+var configMigrations = []func(*Config) []string{
+	migrateV0,
+	migrateV1,
+	migrateV2,
+	migrateV3,
+	migrateV4,
+	migrateV5,
+	migrateV6,
+	migrateV7,
+	migrateV8,
+}
+
+// Migrate upgrades a Config written for an older version of the application
+// to the current layout, returning a description of each change made.
+func (cfg *Config) Migrate() []string {
+	var changes []string
+	if cfg.Version < 0 {
+		cfg.Version = 0
+	}
+	for cfg.Version < len(configMigrations) {
+		changes = append(changes, configMigrations[cfg.Version](cfg)...)
+		cfg.Version++
+	}
+	return changes
+}
+
+// migrateLoaded migrates a Config that was just loaded from a file, logging
+// anything that changed, and falls back from settings this version can't
+// use.
+func migrateLoaded(uc *Config) {
+	from := uc.Version
+	changes := uc.Migrate()
+	if len(changes) > 0 {
+		log.Info("Migrated configuration from version %d to %d: %s", from, uc.Version, strings.Join(changes, "; "))
+	}
+	// Templates and NodeInfo still read OpenRegistration
+	uc.App.SetRegistrationMode(uc.App.Registration())
+
+	for _, c := range uc.Normalize() {
+		log.Error("[WARNING] %s", c)
+	}
+
+	if !uc.App.IsLangSupported() {
+		log.Error("[WARNING] Language '%s' isn't supported; supported languages are %s. Using %s instead.", uc.App.Lang, strings.Join(uc.App.SupportedLangs(), ", "), DefaultLang)
+		uc.App.Lang = DefaultLang
+	}
+}
+
+// Normalize resolves settings that contradict each other, returning a
+// description of each change made. A single-user instance only ever serves
+// its first blog, so it can't accept new users or create more blogs.
+func (cfg *Config) Normalize() []string {
+	var changes []string
+	if !cfg.App.SingleUser {
+		return changes
+	}
+	if mode := cfg.App.Registration(); mode != RegistrationClosed {
+		cfg.App.SetRegistrationMode(RegistrationClosed)
+		changes = append(changes, fmt.Sprintf("Registration mode '%s' isn't supported in single-user mode. Closing registration.", mode))
+	} else if cfg.App.OpenRegistration {
+		cfg.App.OpenRegistration = false
+		changes = append(changes, "Open registration isn't supported in single-user mode. Closing registration.")
+	}
+	if cfg.App.MaxBlogs != 1 {
+		changes = append(changes, fmt.Sprintf("max_blogs %d isn't supported in single-user mode. Using 1 instead.", cfg.App.MaxBlogs))
+		cfg.App.MaxBlogs = 1
+	}
+	return changes
+}
+
+// migrateV0 upgrades configurations from before the version key was added.
+func migrateV0(cfg *Config) []string {
+	var changes []string
+	def := New()
+
+	if cfg.Server.Autocert {
+		if cfg.Server.AutoCertDir == "" && cfg.Server.TLSCertPath != "" {
+			cfg.Server.AutoCertDir = cfg.Server.TLSCertPath
+			cfg.Server.TLSCertPath = ""
+			cfg.Server.TLSKeyPath = ""
+			changes = append(changes, "moved autocert cache directory from tls_cert_path to autocert_dir")
+		}
+		if len(cfg.Server.AutoCertHosts) == 0 {
+			if u, err := url.Parse(cfg.App.Host); err == nil && u.Hostname() != "" {
+				cfg.Server.AutoCertHosts = []string{u.Hostname()}
+				changes = append(changes, fmt.Sprintf("set autocert_hosts to %s", u.Hostname()))
+			}
+		}
+	}
+	if cfg.IsSecureStandalone() && !cfg.Server.RedirectHTTP {
+		// Older versions always redirected port 80 to HTTPS
+		cfg.Server.RedirectHTTP = true
+		changes = append(changes, "enabled redirect_http")
+	}
+
+	for _, d := range []struct {
+		key      string
+		val      *string
+		defValue string
+	}{
+		{"read_timeout", &cfg.Server.ReadTimeout, def.Server.ReadTimeout},
+		{"write_timeout", &cfg.Server.WriteTimeout, def.Server.WriteTimeout},
+		{"idle_timeout", &cfg.Server.IdleTimeout, def.Server.IdleTimeout},
+		{"shutdown_timeout", &cfg.Server.ShutdownTimeout, def.Server.ShutdownTimeout},
+	} {
+		if *d.val == "" {
+			*d.val = d.defValue
+			changes = append(changes, fmt.Sprintf("set %s to %s", d.key, d.defValue))
+		}
+	}
+
+	if cfg.Database.Type != "sqlite3" && cfg.Database.MaxOpenConns == 0 && cfg.Database.MaxIdleConns == 0 {
+		cfg.Database.MaxOpenConns = def.Database.MaxOpenConns
+		cfg.Database.MaxIdleConns = def.Database.MaxIdleConns
+		changes = append(changes, fmt.Sprintf("set max_open_conns to %d and max_idle_conns to %d", cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns))
+	}
+
+	if cfg.App.MinUsernameLen == 0 {
+		cfg.App.MinUsernameLen = def.App.MinUsernameLen
+		changes = append(changes, fmt.Sprintf("set min_username_len to %d", cfg.App.MinUsernameLen))
+	}
+	return changes
+}
+
+// migrateV1 adds the default reserved usernames.
+func migrateV1(cfg *Config) []string {
+	if len(cfg.App.ReservedUsernames) > 0 {
+		return nil
+	}
+	cfg.App.ReservedUsernames = New().App.ReservedUsernames
+	return []string{"set reserved_usernames to the default list"}
+}
+
+// migrateV2 replaces open_registration with registration_mode.
+func migrateV2(cfg *Config) []string {
+	if cfg.App.RegistrationMode != "" {
+		return nil
+	}
+	cfg.App.RegistrationMode = legacyRegistrationMode(cfg.App.OpenRegistration, cfg.App.UserInvites)
+	return []string{fmt.Sprintf("set registration_mode to %s", cfg.App.RegistrationMode)}
+}
+
+// migrateV3 replaces the public_stats bool with a stats level.
+func migrateV3(cfg *Config) []string {
+	switch cfg.App.PublicStats {
+	case "true":
+		cfg.App.PublicStats = StatsFull
+	case "", "false":
+		cfg.App.PublicStats = StatsNone
+	default:
+		return nil
+	}
+	return []string{fmt.Sprintf("set public_stats to %s", cfg.App.PublicStats)}
+}
+
+// migrateV4 keeps new blogs federated, as they were before
+// federate_new_blogs was added.
+func migrateV4(cfg *Config) []string {
+	if cfg.App.FederateNewBlogs {
+		return nil
+	}
+	cfg.App.FederateNewBlogs = true
+	return []string{"enabled federate_new_blogs"}
+}
+
+// migrateV5 replaces the webfonts bool with webfont_source.
+func migrateV5(cfg *Config) []string {
+	if cfg.App.WebFontSource != "" {
+		return nil
+	}
+	cfg.App.WebFontSource = legacyWebFontSource(cfg.App.WebFonts)
+	return []string{fmt.Sprintf("set webfont_source to %s", cfg.App.WebFontSource)}
+}
+
+// migrateV6 keeps serving NodeInfo, as before the nodeinfo setting was
+// added.
+func migrateV6(cfg *Config) []string {
+	if cfg.App.NodeInfoEnabled {
+		return nil
+	}
+	cfg.App.NodeInfoEnabled = true
+	return []string{"enabled nodeinfo"}
+}
+
+// migrateV7 keeps showing the local timeline to everyone, as before it
+// could be built without being shown.
+func migrateV7(cfg *Config) []string {
+	if cfg.App.LocalTimelinePublic {
+		return nil
+	}
+	cfg.App.LocalTimelinePublic = true
+	return []string{"enabled local_timeline_public"}
+}
+
+// migrateV8 keeps rendering Markdown with the extensions that were always
+// enabled, before markdown_extensions was added.
+func migrateV8(cfg *Config) []string {
+	if len(cfg.App.MarkdownExtensions) > 0 {
+		return nil
+	}
+	cfg.App.MarkdownExtensions = append([]string(nil), DefaultMarkdownExtensions...)
+	return []string{"set markdown_extensions to " + strings.Join(cfg.App.MarkdownExtensions, ",")}
+}
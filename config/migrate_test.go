@@ -0,0 +1,300 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateV0(t *testing.T) {
+	cfg, err := Load(filepath.Join("testdata", "config-v0.ini"))
+	if err != nil {
+		t.Fatalf("Loading v0 fixture failed: %v", err)
+	}
+	expected := New()
+	// Existing instances stay unlimited until they opt in to rate limits
+	expected.RateLimit = RateLimitCfg{}
+	// Empty log settings mean the same as the defaults
+	expected.Log = LogCfg{}
+	// Uploads stay unlimited
+	expected.Storage.MaxUploadBytes = 0
+	// So do API requests
+	expected.App.MaxAPIBodyBytes = 0
+	// Blog titles and descriptions stay unlimited
+	expected.App.MaxBlogTitleLen = 0
+	expected.App.MaxBlogDescLen = 0
+	expected.App.BcryptCost = 0
+	expected.App.MinPasswordLen = 0
+	expected.App.ScheduledPublishInterval = ""
+	// Remote actors aren't cached until configured
+	expected.App.FederationCacheTTL = ""
+	// An empty federation timeout means the default
+	expected.App.FederationTimeout = ""
+	// Deliveries aren't limited
+	expected.App.MaxFederationConcurrency = 0
+	// Startup doesn't wait for the database
+	expected.Database.ConnectRetries = 0
+	expected.Database.ConnectRetryDelay = ""
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Migrated v0 config doesn't match defaults:\n%+v\n%+v", cfg, expected)
+	}
+	if changes := cfg.Migrate(); len(changes) > 0 {
+		t.Errorf("Migrating a current config changed %v", changes)
+	}
+}
+
+func TestMigrateV0Autocert(t *testing.T) {
+	cfg, err := LoadReader(strings.NewReader(`[server]
+port          = 443
+tls_cert_path = certs
+tls_key_path  = certs
+autocert      = true
+
+[app]
+host = https://blog.example.com
+`))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d; expected %d", cfg.Version, CurrentVersion)
+	}
+	if cfg.Server.AutoCertDir != "certs" || cfg.Server.TLSCertPath != "" || cfg.Server.TLSKeyPath != "" {
+		t.Errorf("Expected cache dir moved to autocert_dir; got %+v", cfg.Server)
+	}
+	if len(cfg.Server.AutoCertHosts) != 1 || cfg.Server.AutoCertHosts[0] != "blog.example.com" {
+		t.Errorf("AutoCertHosts = %v; expected blog.example.com", cfg.Server.AutoCertHosts)
+	}
+	if !cfg.Server.RedirectHTTP {
+		t.Error("RedirectHTTP = false; expected older secure configs to keep redirecting")
+	}
+}
+
+func TestMigrateV2Registration(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		App      string
+		Expected string
+	}{
+		{"Open", "open_registration = true", RegistrationOpen},
+		{"Closed", "open_registration = false", RegistrationClosed},
+		{"Closed with invites", "open_registration = false\nuser_invites = user", RegistrationInvite},
+		{"Explicit mode", "open_registration = true\nregistration_mode = invite", RegistrationInvite},
+	} {
+		cfg, err := LoadReader(strings.NewReader("version = 2\n\n[app]\n" + tc.App + "\n"))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if cfg.App.RegistrationMode != tc.Expected {
+			t.Errorf("%s: RegistrationMode = %s; expected %s", tc.Name, cfg.App.RegistrationMode, tc.Expected)
+		}
+		if cfg.App.OpenRegistration != (tc.Expected == RegistrationOpen) {
+			t.Errorf("%s: OpenRegistration = %t doesn't match mode %s", tc.Name, cfg.App.OpenRegistration, tc.Expected)
+		}
+	}
+}
+
+func TestMigrateV3PublicStats(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		App      string
+		Expected string
+	}{
+		{"Public", "public_stats = true", StatsFull},
+		{"Private", "public_stats = false", StatsNone},
+		{"Missing", "", StatsNone},
+		{"Explicit level", "public_stats = basic", StatsBasic},
+	} {
+		cfg, err := LoadReader(strings.NewReader("version = 3\n\n[app]\n" + tc.App + "\n"))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if cfg.App.PublicStats != tc.Expected {
+			t.Errorf("%s: PublicStats = %s; expected %s", tc.Name, cfg.App.PublicStats, tc.Expected)
+		}
+	}
+
+	for _, tc := range []struct {
+		Level       string
+		Basic, Full bool
+	}{
+		{StatsNone, false, false},
+		{"", false, false},
+		{"true", false, false},
+		{StatsBasic, true, false},
+		{StatsFull, true, true},
+	} {
+		ac := AppCfg{PublicStats: tc.Level}
+		if ac.ShowsStats(StatsBasic) != tc.Basic || ac.ShowsStats(StatsFull) != tc.Full {
+			t.Errorf("Level %q: basic %t, full %t; expected %t, %t", tc.Level, ac.ShowsStats(StatsBasic), ac.ShowsStats(StatsFull), tc.Basic, tc.Full)
+		}
+	}
+}
+
+func TestMigrateV4FederateNewBlogs(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Config   string
+		Expected bool
+	}{
+		{"Before the setting", "version = 4\n\n[app]\nfederation = true\n", true},
+		{"Disabled", "version = 5\n\n[app]\nfederation = true\nfederate_new_blogs = false\n", false},
+		{"Enabled", "version = 5\n\n[app]\nfederation = true\nfederate_new_blogs = true\n", true},
+	} {
+		cfg, err := LoadReader(strings.NewReader(tc.Config))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if cfg.App.FederateNewBlogs != tc.Expected {
+			t.Errorf("%s: FederateNewBlogs = %t; expected %t", tc.Name, cfg.App.FederateNewBlogs, tc.Expected)
+		}
+	}
+	if !New().App.FederateNewBlogs {
+		t.Error("New blogs aren't federated by default")
+	}
+}
+
+func TestMigrateV6NodeInfo(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Config   string
+		Expected bool
+	}{
+		{"Before the setting", "version = 6\n\n[app]\n", true},
+		{"Disabled", "version = 7\n\n[app]\nnodeinfo = false\n", false},
+		{"Enabled", "version = 7\n\n[app]\nnodeinfo = true\n", true},
+	} {
+		cfg, err := LoadReader(strings.NewReader(tc.Config))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if cfg.App.NodeInfoEnabled != tc.Expected {
+			t.Errorf("%s: NodeInfoEnabled = %t; expected %t", tc.Name, cfg.App.NodeInfoEnabled, tc.Expected)
+		}
+	}
+	if !New().App.NodeInfoEnabled {
+		t.Error("NodeInfo isn't enabled by default")
+	}
+}
+
+func TestMigrateV7LocalTimelinePublic(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Config string
+		Built  bool
+		Shown  bool
+	}{
+		{"Before the setting", "version = 7\n\n[app]\nlocal_timeline = true\n", true, true},
+		{"Off before the setting", "version = 7\n\n[app]\nlocal_timeline = false\n", false, false},
+		{"Hidden", "version = 8\n\n[app]\nlocal_timeline = true\nlocal_timeline_public = false\n", true, false},
+		{"Public", "version = 8\n\n[app]\nlocal_timeline = true\nlocal_timeline_public = true\n", true, true},
+	} {
+		cfg, err := LoadReader(strings.NewReader(tc.Config))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if cfg.App.LocalTimeline != tc.Built || cfg.App.ShowsLocalTimeline() != tc.Shown {
+			t.Errorf("%s: LocalTimeline = %t, shown %t; expected %t, shown %t", tc.Name, cfg.App.LocalTimeline, cfg.App.ShowsLocalTimeline(), tc.Built, tc.Shown)
+		}
+	}
+	if !New().App.LocalTimelinePublic {
+		t.Error("The local timeline isn't public by default")
+	}
+}
+
+func TestMigrateV8MarkdownExtensions(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Config   string
+		Footnote bool
+		RawHTML  bool
+	}{
+		{"Before the setting", "version = 8\n", false, true},
+		{"Chosen", "version = 9\n\n[app]\nmarkdown_extensions = tables,footnotes\n", true, false},
+	} {
+		cfg, err := LoadReader(strings.NewReader(tc.Config))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if !cfg.App.MarkdownExtensionEnabled(MarkdownTables) {
+			t.Errorf("%s: Tables aren't enabled", tc.Name)
+		}
+		if cfg.App.MarkdownExtensionEnabled(MarkdownFootnotes) != tc.Footnote || cfg.App.MarkdownExtensionEnabled(MarkdownRawHTML) != tc.RawHTML {
+			t.Errorf("%s: MarkdownExtensions = %v", tc.Name, cfg.App.MarkdownExtensions)
+		}
+	}
+}
+
+func TestMigrateV5WebFontSource(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Config   string
+		Expected string
+	}{
+		{"Enabled", "version = 5\n\n[app]\nwebfonts = true\n", WebFontsLocal},
+		{"Disabled", "version = 5\n\n[app]\nwebfonts = false\n", WebFontsNone},
+		{"Missing", "version = 5\n\n[app]\n", WebFontsNone},
+		{"Explicit source", "version = 6\n\n[app]\nwebfonts = true\nwebfont_source = cdn\n", WebFontsCDN},
+	} {
+		cfg, err := LoadReader(strings.NewReader(tc.Config))
+		if err != nil {
+			t.Fatalf("%s: LoadReader failed: %v", tc.Name, err)
+		}
+		if cfg.App.WebFontSource != tc.Expected {
+			t.Errorf("%s: WebFontSource = %s; expected %s", tc.Name, cfg.App.WebFontSource, tc.Expected)
+		}
+	}
+
+	for _, tc := range []struct {
+		App      AppCfg
+		Expected string
+	}{
+		{AppCfg{WebFonts: true}, WebFontsLocal},
+		{AppCfg{}, WebFontsNone},
+		{AppCfg{WebFonts: false, WebFontSource: WebFontsCDN}, WebFontsCDN},
+	} {
+		if src := tc.App.FontSource(); src != tc.Expected {
+			t.Errorf("%+v: FontSource = %s; expected %s", tc.App, src, tc.Expected)
+		}
+	}
+}
+
+func TestNormalizeSingleUser(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		App      AppCfg
+		Changes  int
+		MaxBlogs int
+	}{
+		{"Consistent", AppCfg{SingleUser: true, RegistrationMode: RegistrationClosed, MaxBlogs: 1}, 0, 1},
+		{"Open registration", AppCfg{SingleUser: true, RegistrationMode: RegistrationOpen, OpenRegistration: true, MaxBlogs: 1}, 1, 1},
+		{"Invites", AppCfg{SingleUser: true, RegistrationMode: RegistrationInvite, MaxBlogs: 1}, 1, 1},
+		{"Legacy open registration", AppCfg{SingleUser: true, OpenRegistration: true, MaxBlogs: 1}, 1, 1},
+		{"Many blogs", AppCfg{SingleUser: true, RegistrationMode: RegistrationClosed, MaxBlogs: 5}, 1, 1},
+		{"Both", AppCfg{SingleUser: true, RegistrationMode: RegistrationOpen, OpenRegistration: true, MaxBlogs: 0}, 2, 1},
+		{"Multi-user", AppCfg{RegistrationMode: RegistrationOpen, OpenRegistration: true, MaxBlogs: 5}, 0, 5},
+	} {
+		cfg := &Config{App: tc.App}
+		changes := cfg.Normalize()
+		if len(changes) != tc.Changes {
+			t.Errorf("%s: changes = %v; expected %d", tc.Name, changes, tc.Changes)
+		}
+		if cfg.App.SingleUser && (cfg.App.Registration() != RegistrationClosed || cfg.App.OpenRegistration) {
+			t.Errorf("%s: single-user registration = %s (open: %t); expected closed", tc.Name, cfg.App.Registration(), cfg.App.OpenRegistration)
+		}
+		if cfg.App.MaxBlogs != tc.MaxBlogs {
+			t.Errorf("%s: MaxBlogs = %d; expected %d", tc.Name, cfg.App.MaxBlogs, tc.MaxBlogs)
+		}
+	}
+}
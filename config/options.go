@@ -0,0 +1,43 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+// Option changes a Config created by NewWithOptions.
+type Option func(*Config)
+
+// WithPort sets the port the server listens on.
+func WithPort(port int) Option {
+	return func(cfg *Config) {
+		cfg.Server.Port = port
+	}
+}
+
+// WithSQLite uses a SQLite database stored in the given file.
+func WithSQLite(file string) Option {
+	return func(cfg *Config) {
+		cfg.UseSQLite(true)
+		cfg.Database.FileName = file
+	}
+}
+
+// WithHost sets the public URL of the instance, like https://example.com.
+func WithHost(host string) Option {
+	return func(cfg *Config) {
+		cfg.App.Host = host
+	}
+}
+
+// WithSingleUser sets whether the instance hosts a single user.
+func WithSingleUser(single bool) Option {
+	return func(cfg *Config) {
+		cfg.App.SingleUser = single
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	if cfg := NewWithOptions(); !reflect.DeepEqual(cfg, New()) {
+		t.Errorf("NewWithOptions() = %+v; expected New()", cfg)
+	}
+
+	cfg := NewWithOptions(
+		WithPort(9000),
+		WithSQLite("test.db"),
+		WithHost("https://example.com"),
+		WithSingleUser(false),
+		WithPort(9001),
+	)
+	if cfg.Server.Port != 9001 {
+		t.Errorf("Port = %d; expected the last option's 9001", cfg.Server.Port)
+	}
+	if cfg.Database.Type != "sqlite3" || cfg.Database.FileName != "test.db" || !cfg.Database.WAL {
+		t.Errorf("Database = %+v; expected SQLite defaults with test.db", cfg.Database)
+	}
+	if cfg.App.Host != "https://example.com" {
+		t.Errorf("Host = %s; expected https://example.com", cfg.App.Host)
+	}
+	if cfg.App.SingleUser {
+		t.Error("SingleUser = true; expected false")
+	}
+	// Defaults not touched by options are kept
+	if cfg.Server.Bind != "localhost" || cfg.App.Theme != "write" {
+		t.Errorf("Bind = %s, Theme = %s; expected defaults", cfg.Server.Bind, cfg.App.Theme)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxy parses a CIDR, or a single IP address as a network
+// containing only that address.
+func parseTrustedProxy(p string) (*net.IPNet, error) {
+	if !strings.Contains(p, "/") {
+		if ip := net.ParseIP(p); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+		}
+	}
+	_, n, err := net.ParseCIDR(p)
+	return n, err
+}
+
+// isTrustedProxy returns whether the given IP address belongs to one of the
+// TrustedProxies.
+func (sc ServerCfg) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, p := range sc.TrustedProxies {
+		n, err := parseTrustedProxy(p)
+		if err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerAddr returns the IP address the given request came from directly.
+func peerAddr(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return peer
+}
+
+// RealIP returns the IP address of the client that made the given request.
+// The X-Forwarded-For and X-Real-IP headers are only used when the request
+// comes from one of the TrustedProxies.
+func (sc ServerCfg) RealIP(r *http.Request) string {
+	peer := peerAddr(r)
+	if !sc.isTrustedProxy(peer) {
+		return peer
+	}
+
+	// Find the last address in the chain that isn't one of our proxies
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			peer = hop
+			if !sc.isTrustedProxy(hop) {
+				return hop
+			}
+		}
+		return peer
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return peer
+}
+
+// AbsoluteURL returns the URL of the given path on the scheme and host the
+// request was made to. With UseProxyHeaders, the X-Forwarded-Proto and
+// X-Forwarded-Host headers set by one of the TrustedProxies take precedence.
+func (sc ServerCfg) AbsoluteURL(r *http.Request, path string) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if sc.UseProxyHeaders && sc.isTrustedProxy(peerAddr(r)) {
+		if p := strings.ToLower(firstHeaderVal(r, "X-Forwarded-Proto")); p == "http" || p == "https" {
+			scheme = p
+		}
+		if h := firstHeaderVal(r, "X-Forwarded-Host"); h != "" {
+			host = h
+		}
+	}
+	return scheme + "://" + host + path
+}
+
+// firstHeaderVal returns the first of the comma-separated values in the
+// given header, which is the one set by the proxy closest to the client.
+func firstHeaderVal(r *http.Request, key string) string {
+	return strings.TrimSpace(strings.Split(r.Header.Get(key), ",")[0])
+}
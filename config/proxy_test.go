@@ -0,0 +1,82 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+var realIPTestTable = []struct {
+	Name       string
+	RemoteAddr string
+	XFF        string
+	XRealIP    string
+	Expected   string
+}{
+	{"Direct client", "203.0.113.5:1234", "", "", "203.0.113.5"},
+	{"Spoofed XFF from untrusted peer", "203.0.113.5:1234", "198.51.100.1", "", "203.0.113.5"},
+	{"Spoofed X-Real-IP from untrusted peer", "203.0.113.5:1234", "", "198.51.100.1", "203.0.113.5"},
+	{"Trusted proxy with XFF", "127.0.0.1:4000", "198.51.100.1", "", "198.51.100.1"},
+	{"Trusted proxy with X-Real-IP", "10.1.2.3:4000", "", "198.51.100.1", "198.51.100.1"},
+	{"Trusted proxy without headers", "127.0.0.1:4000", "", "", "127.0.0.1"},
+	{"Spoofed hop before real client", "127.0.0.1:4000", "192.0.2.66, 198.51.100.1", "", "198.51.100.1"},
+	{"Chain of trusted proxies", "127.0.0.1:4000", "198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+	{"Garbage XFF", "127.0.0.1:4000", "not-an-ip", "", "127.0.0.1"},
+	{"Trusted IPv6 proxy", "[::1]:4000", "2001:db8::7", "", "2001:db8::7"},
+}
+
+func TestRealIP(t *testing.T) {
+	sc := ServerCfg{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "::1"}}
+	for _, tc := range realIPTestTable {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.RemoteAddr
+		if tc.XFF != "" {
+			r.Header.Set("X-Forwarded-For", tc.XFF)
+		}
+		if tc.XRealIP != "" {
+			r.Header.Set("X-Real-IP", tc.XRealIP)
+		}
+		if ip := sc.RealIP(r); ip != tc.Expected {
+			t.Errorf("%s: RealIP = %s; expected %s", tc.Name, ip, tc.Expected)
+		}
+	}
+
+	// Nothing is trusted by default
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if ip := (ServerCfg{}).RealIP(r); ip != "127.0.0.1" {
+		t.Errorf("RealIP with no trusted proxies = %s; expected 127.0.0.1", ip)
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	for _, tc := range []struct {
+		Name       string
+		UseHeaders bool
+		RemoteAddr string
+		Expected   string
+	}{
+		{"Headers ignored by default", false, "127.0.0.1:4000", "http://localhost:8080/feed/"},
+		{"Headers from trusted proxy", true, "127.0.0.1:4000", "https://blog.example.com/feed/"},
+		{"Headers from untrusted peer", true, "203.0.113.5:1234", "http://localhost:8080/feed/"},
+	} {
+		sc := ServerCfg{TrustedProxies: []string{"127.0.0.1"}, UseProxyHeaders: tc.UseHeaders}
+		r := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+		r.RemoteAddr = tc.RemoteAddr
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Forwarded-Host", "blog.example.com, internal:8080")
+		if u := sc.AbsoluteURL(r, "/feed/"); u != tc.Expected {
+			t.Errorf("%s: AbsoluteURL = %s; expected %s", tc.Name, u, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Reload reads and validates the given configuration file again, with
+// environment overrides, so its changes can be applied to a running app.
+// Dev is whether the app is running in development mode, which Validate
+// is more lenient about.
+func Reload(fname string, dev bool) (*Config, error) {
+	if fname == StdinFileName {
+		return nil, fmt.Errorf("Configuration read from standard input can't be reloaded")
+	}
+	uc, err := LoadWithEnv(fname)
+	if err != nil {
+		return nil, err
+	}
+	uc.Server.Dev = dev
+	if err = uc.Validate(); err != nil {
+		return nil, err
+	}
+	return uc, nil
+}
+
+// ApplyReloadable copies the settings that can change while the app is
+// running from next into cfg: the site name and description, logging, and
+// rate limits. Requests read the running config without locking it, so cfg
+// should be a copy that replaces it afterward.
+func (cfg *Config) ApplyReloadable(next *Config) {
+	cfg.App.SiteName = next.App.SiteName
+	cfg.App.SiteDesc = next.App.SiteDesc
+	cfg.Log = next.Log
+	cfg.RateLimit = next.RateLimit
+}
+
+// RestartKeys returns the "section key" names of the settings other than
+// the reloadable ones that differ between cfg, as it was loaded, and next.
+// Those need a restart to take effect.
+func (cfg *Config) RestartKeys(next *Config) []string {
+	applied := *cfg
+	applied.ApplyReloadable(next)
+	return changedKeys(reflect.ValueOf(&applied).Elem(), reflect.ValueOf(next).Elem())
+}
+
+// changedKeys returns the "section key" names of the values that differ
+// between the two Configs.
+func changedKeys(a, b reflect.Value) []string {
+	var keys []string
+	ct := a.Type()
+	for i := 0; i < ct.NumField(); i++ {
+		sec := ct.Field(i).Tag.Get("ini")
+		if sec == "" || sec == "-" || ct.Field(i).Type.Kind() != reflect.Struct {
+			continue
+		}
+		sa, sb := a.Field(i), b.Field(i)
+		st := sa.Type()
+		for j := 0; j < st.NumField(); j++ {
+			key := strings.Split(st.Field(j).Tag.Get("ini"), ",")[0]
+			if key == "" || key == "-" {
+				continue
+			}
+			if !reflect.DeepEqual(sa.Field(j).Interface(), sb.Field(j).Interface()) {
+				keys = append(keys, sec+" "+key)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	fname, cleanup := tempConfigPath(t)
+	defer cleanup()
+
+	running := newValidConfig()
+	if err := Save(running, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	changed := newValidConfig()
+	changed.App.SiteName = "Renamed Blog"
+	changed.Log.Level = "debug"
+	changed.RateLimit.APIPerMinute = 30
+	changed.Server.Port = 9000
+	if err := Save(changed, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	next, err := Reload(fname, false)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	applied := *running
+	applied.ApplyReloadable(next)
+	if applied.App.SiteName != "Renamed Blog" || applied.Log.Level != "debug" || applied.RateLimit.APIPerMinute != 30 {
+		t.Errorf("Reloadable settings weren't applied: site name %q, log %+v, rate limits %+v", applied.App.SiteName, applied.Log, applied.RateLimit)
+	}
+	if applied.Server.Port != 8080 {
+		t.Errorf("Port = %d; expected it to need a restart", applied.Server.Port)
+	}
+	if running.App.SiteName == "Renamed Blog" {
+		t.Error("ApplyReloadable changed the config it was copied from")
+	}
+	if restart := running.RestartKeys(next); !reflect.DeepEqual(restart, []string{"server port"}) {
+		t.Errorf("Settings needing a restart = %v; expected [server port]", restart)
+	}
+
+	changed.Server.Port = 0
+	if err = Save(changed, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err = Reload(fname, false); err == nil {
+		t.Error("Reloading an invalid configuration succeeded")
+	}
+
+	// Placeholder secrets are only warnings in development
+	changed.Server.Port = 9000
+	changed.Server.MetricsToken = "changeme"
+	if err = Save(changed, fname); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err = Reload(fname, false); err == nil {
+		t.Error("Reloading with a placeholder secret succeeded")
+	}
+	if _, err = Reload(fname, true); err != nil {
+		t.Errorf("Reloading with a placeholder secret in development failed: %v", err)
+	}
+}
@@ -11,15 +11,20 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
 	"github.com/mitchellh/go-wordwrap"
-	"github.com/writeas/web-core/auth"
+	"golang.org/x/crypto/bcrypt"
 	"strconv"
 	"strings"
 )
 
+// ErrConfigNotSaved is returned when the configuration isn't confirmed at
+// the end of the setup process.
+var ErrConfigNotSaved = errors.New("Configuration not saved")
+
 type SetupData struct {
 	User   *UserCreation
 	Config *Config
@@ -29,13 +34,17 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 	data := &SetupData{}
 	var err error
 	if fname == "" {
-		fname = FileName
+		fname = DefaultFileName()
 	}
 
-	data.Config, err = Load(fname)
+	data.Config, err = LoadFile(fname)
 	var action string
 	isNewCfg := false
 	if err != nil {
+		if !errors.Is(err, ErrConfigNotFound) {
+			// Don't overwrite a config file we couldn't read
+			return data, err
+		}
 		fmt.Printf("No %s configuration yet. Creating new.\n", fname)
 		data.Config = New()
 		action = "generate"
@@ -109,12 +118,15 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 			}
 			if sel == 0 {
 				data.Config.Server.Autocert = false
+				data.Config.Server.AutoCertHosts = nil
+				data.Config.Server.RedirectHTTP = false
 				data.Config.Server.Port = 80
 				data.Config.Server.TLSCertPath = ""
 				data.Config.Server.TLSKeyPath = ""
 			} else if sel == 1 || sel == 2 {
 				data.Config.Server.Port = 443
 				data.Config.Server.Autocert = sel == 2
+				data.Config.Server.RedirectHTTP = true
 
 				if sel == 1 {
 					// Manual certificate configuration
@@ -141,8 +153,11 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 					}
 				} else {
 					// Automatic certificate
-					data.Config.Server.TLSCertPath = "certs"
-					data.Config.Server.TLSKeyPath = "certs"
+					data.Config.Server.TLSCertPath = ""
+					data.Config.Server.TLSKeyPath = ""
+					if data.Config.Server.AutoCertDir == "" {
+						data.Config.Server.AutoCertDir = "certs"
+					}
 				}
 			}
 		} else {
@@ -286,7 +301,7 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 				return data, err
 			}
 
-			data.User.HashedPass, err = auth.HashPass([]byte(newUserPass))
+			data.User.HashedPass, err = bcrypt.GenerateFromPassword([]byte(newUserPass), data.Config.App.PasswordCost())
 			if err != nil {
 				return data, err
 			}
@@ -317,18 +332,33 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 		if err != nil {
 			return data, err
 		}
+		if data.Config.Server.Autocert && len(data.Config.Server.AutoCertHosts) == 0 {
+			// Get certificates for the public URL's host by default
+			data.Config.Server.AutoCertHosts = []string{data.Config.App.FriendlyHost()}
+		}
 
 		if !data.Config.App.SingleUser {
 			selPrompt = promptui.Select{
 				Templates: selTmpls,
 				Label:     "Registration",
-				Items:     []string{"Open", "Closed"},
+				Items:     []string{"Open", "Closed", "Invite only"},
 			}
 			_, regType, err := selPrompt.Run()
 			if err != nil {
 				return data, err
 			}
-			data.Config.App.OpenRegistration = regType == "Open"
+			switch regType {
+			case "Open":
+				data.Config.App.SetRegistrationMode(RegistrationOpen)
+			case "Invite only":
+				data.Config.App.SetRegistrationMode(RegistrationInvite)
+				if data.Config.App.UserInvites == "" {
+					// Someone has to be able to send invites
+					data.Config.App.UserInvites = "admin"
+				}
+			default:
+				data.Config.App.SetRegistrationMode(RegistrationClosed)
+			}
 
 			prompt = promptui.Prompt{
 				Templates: tmpls,
@@ -357,20 +387,20 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 			selPrompt = promptui.Select{
 				Templates: selTmpls,
 				Label:     "Federation usage stats",
-				Items:     []string{"Public", "Private"},
+				Items:     []string{"Public", "Totals only", "Private"},
 			}
-			_, fedStatsType, err := selPrompt.Run()
+			sel, _, err := selPrompt.Run()
 			if err != nil {
 				return data, err
 			}
-			data.Config.App.PublicStats = fedStatsType == "Public"
+			data.Config.App.PublicStats = []string{StatsFull, StatsBasic, StatsNone}[sel]
 
 			selPrompt = promptui.Select{
 				Templates: selTmpls,
 				Label:     "Instance metadata privacy",
 				Items:     []string{"Public", "Private"},
 			}
-			_, fedStatsType, err = selPrompt.Run()
+			_, fedStatsType, err := selPrompt.Run()
 			if err != nil {
 				return data, err
 			}
@@ -378,5 +408,21 @@ func Configure(fname string, configSections string) (*SetupData, error) {
 		}
 	}
 
-	return data, Save(data.Config, fname)
+	preview, err := SaveString(data.Config.Redacted())
+	if err != nil {
+		return data, err
+	}
+	fmt.Println()
+	title(" Review ")
+	fmt.Println()
+	fmt.Println(preview)
+	prompt = promptui.Prompt{
+		Label:     "Save this configuration to " + fname,
+		IsConfirm: true,
+	}
+	if _, err = prompt.Run(); err != nil {
+		return data, ErrConfigNotSaved
+	}
+
+	return data, SaveBackup(data.Config, fname)
 }
@@ -0,0 +1,66 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Source provides a configuration in the INI file format, so it can be kept
+// somewhere other than a file, like Vault or etcd.
+type Source interface {
+	Read() ([]byte, error)
+}
+
+// FileSource is a Source that reads the configuration file at the given
+// path, or the DefaultFileName when it's empty.
+type FileSource string
+
+func (fs FileSource) path() string {
+	if fs == "" {
+		return DefaultFileName()
+	}
+	return string(fs)
+}
+
+// Read returns the contents of the file. It returns an error wrapping
+// ErrConfigNotFound if the file doesn't exist.
+func (fs FileSource) Read() ([]byte, error) {
+	b, err := ioutil.ReadFile(fs.path())
+	if err != nil {
+		return nil, openError(fs.path(), err)
+	}
+	return b, nil
+}
+
+// LoadSource reads the configuration from the given Source, then parses and
+// returns it as a Config. Included files are found relative to the file for
+// a FileSource, and relative to the working directory otherwise.
+func LoadSource(src Source) (*Config, error) {
+	b, err := src.Read()
+	if err != nil {
+		return nil, err
+	}
+	fname := FileName
+	if fs, ok := src.(FileSource); ok {
+		fname = fs.path()
+	}
+	abs, err := filepath.Abs(fname)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := includeSources(abs, b, map[string]bool{abs: true})
+	if err != nil {
+		return nil, err
+	}
+	return loadINI(sources)
+}
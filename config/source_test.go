@@ -0,0 +1,50 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// memSource is a Source holding the configuration in memory.
+type memSource []byte
+
+func (s memSource) Read() ([]byte, error) { return s, nil }
+
+func TestLoadSource(t *testing.T) {
+	src := memSource(fmt.Sprintf(`version = %d
+
+[server]
+port = 9000
+
+[app]
+site_name = From Memory
+`, CurrentVersion))
+	cfg, err := LoadSource(src)
+	if err != nil {
+		t.Fatalf("LoadSource failed: %v", err)
+	}
+	if cfg.Server.Port != 9000 || cfg.App.SiteName != "From Memory" {
+		t.Errorf("Loaded port %d, site name %q", cfg.Server.Port, cfg.App.SiteName)
+	}
+
+	if _, err = LoadSource(memSource("[server\nport = 9000")); err == nil {
+		t.Error("Loading an invalid source succeeded")
+	}
+
+	missing, cleanup := tempConfigPath(t)
+	defer cleanup()
+	if _, err = LoadSource(FileSource(missing)); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("Loading a missing file = %v; expected ErrConfigNotFound", err)
+	}
+}
@@ -12,12 +12,23 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/writeas/web-core/log"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	domainReg = regexp.MustCompile("^https?://")
+	domainReg     = regexp.MustCompile("^https?://")
+	bindHostReg   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`)
+	cookieNameReg = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
 )
 
 const (
@@ -49,3 +60,500 @@ func validateNonEmpty(i string) error {
 	}
 	return nil
 }
+
+// ValidationError is a problem with a single configuration value.
+type ValidationError struct {
+	// Field is the value's section and key, like database.port.
+	Field string
+	Value interface{}
+	// Message describes the problem, as it's shown to administrators.
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors are all the problems Validate found with a Config.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Message
+	}
+	return "Invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Fields returns the field of each error, in order.
+func (errs ValidationErrors) Fields() []string {
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.Field
+	}
+	return fields
+}
+
+func (errs *ValidationErrors) add(field string, value interface{}, format string, a ...interface{}) {
+	*errs = append(*errs, ValidationError{Field: field, Value: value, Message: fmt.Sprintf(format, a...)})
+}
+
+// validateBindAddr checks that a single bind address is a hostname, an IPv4
+// address, or an IPv6 address optionally wrapped in brackets.
+func validateBindAddr(a string) error {
+	if strings.HasPrefix(a, "[") || strings.HasSuffix(a, "]") {
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(a, "["), "]"))
+		if !strings.HasPrefix(a, "[") || !strings.HasSuffix(a, "]") || ip == nil || ip.To4() != nil {
+			return fmt.Errorf("Brackets must enclose an IPv6 address")
+		}
+		return nil
+	}
+	if strings.Contains(a, ":") {
+		if net.ParseIP(a) == nil {
+			return fmt.Errorf("Must be a host or IP address without a port")
+		}
+		return nil
+	}
+	if !bindHostReg.MatchString(a) {
+		return fmt.Errorf("Must be a host or IP address")
+	}
+	return nil
+}
+
+// insecureSecrets are placeholder values, often copied from examples, that
+// must be changed before an instance is made public.
+var insecureSecrets = []string{"password", "changeme", "change-me", "secret"}
+
+func isInsecureSecret(s string) bool {
+	for _, v := range insecureSecrets {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// insecureSecretErrs returns an error for each secret in the Config that's
+// left at a known placeholder value, or left empty where one is needed.
+func (cfg *Config) insecureSecretErrs() ValidationErrors {
+	var errs ValidationErrors
+	if cfg.Database.Type == "mysql" && cfg.Database.DSN == "" && cfg.Database.Password == "" {
+		errs.add("database.password", cfg.Database.Password, "database password is empty")
+	}
+	if cfg.Email.SMTPUser != "" && cfg.Email.SMTPPassword == "" {
+		errs.add("email.smtp_password", cfg.Email.SMTPPassword, "email smtp_password is empty")
+	}
+	for k, v := range map[string]string{
+		"database.password":     cfg.Database.Password,
+		"server.metrics_token":  cfg.Server.MetricsToken,
+		"app.maintenance_token": cfg.App.MaintenanceToken,
+		"email.smtp_password":   cfg.Email.SMTPPassword,
+		"storage.s3_secret_key": cfg.Storage.S3SecretKey,
+		"cache.redis_password":  cfg.Cache.RedisPassword,
+		"oauth.client_secret":   cfg.OAuth.ClientSecret,
+		"webhook.secret":        cfg.Webhook.Secret,
+	} {
+		if isInsecureSecret(v) {
+			errs.add(k, v, "%s is set to the insecure placeholder '%s'", strings.Replace(k, ".", " ", 1), v)
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Field < errs[j].Field
+	})
+	return errs
+}
+
+// Warnings returns the settings that don't stop the application from
+// running, but likely aren't doing what was intended. Validate doesn't
+// consider these errors, so callers should report them separately.
+func (cfg *Config) Warnings() ValidationErrors {
+	var warns ValidationErrors
+	if !cfg.App.Federation && cfg.App.FederateNewBlogs {
+		warns.add("app.federate_new_blogs", cfg.App.FederateNewBlogs, "app federate_new_blogs has no effect while federation is disabled")
+	}
+	if cfg.Server.Autocert && loopbackOnly(cfg.Server.BindAddrs()) {
+		warns.add("server.bind", cfg.Server.Bind, "server bind '%s' only listens on this machine, so autocert can't get certificates for it", cfg.Server.Bind)
+	}
+	return warns
+}
+
+// loopbackOnly returns whether every one of the given bind addresses is
+// reachable only from this machine.
+func loopbackOnly(addrs []string) bool {
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); a != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the Config for values the application can't run with,
+// returning ValidationErrors with every problem found.
+//
+// Secrets left at placeholder values are errors, unless Server.Dev is set,
+// in which case they're only logged as warnings.
+func (cfg *Config) Validate() error {
+	var errs ValidationErrors
+
+	if secretErrs := cfg.insecureSecretErrs(); len(secretErrs) > 0 {
+		if cfg.Server.Dev {
+			for _, e := range secretErrs {
+				log.Error("[WARNING] %s. Change it before running in production.", e)
+			}
+		} else {
+			errs = append(errs, secretErrs...)
+		}
+	}
+
+	switch cfg.Database.Type {
+	case "mysql", "sqlite3":
+	default:
+		errs.add("database.type", cfg.Database.Type, "database type '%s' must be one of mysql, sqlite3", cfg.Database.Type)
+	}
+
+	if cfg.Database.Socket != "" && cfg.Database.Host != "" {
+		errs.add("database.socket", cfg.Database.Socket, "database socket and host must not both be set")
+	}
+	if cfg.Database.DSN != "" && (cfg.Database.Host != "" || cfg.Database.Socket != "") {
+		errs.add("database.dsn", cfg.Database.DSN, "database dsn and host or socket must not both be set")
+	}
+	if len(cfg.Database.ReadReplicas) > 0 && cfg.Database.Type == "sqlite3" {
+		errs.add("database.read_replicas", cfg.Database.ReadReplicas, "database read_replicas aren't supported with sqlite3")
+	}
+	for _, r := range cfg.Database.ReadReplicas {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			errs.add("database.read_replicas", cfg.Database.ReadReplicas, "database read_replicas must not have empty entries")
+		} else if replicaCfg(cfg.Database, r).DSN == "" && strings.Contains(r, ":") {
+			_, port, err := net.SplitHostPort(r)
+			if p, perr := strconv.Atoi(port); err != nil || perr != nil || p < 1 || p > maxPort {
+				errs.add("database.read_replicas", r, "database read_replicas '%s' must be a host:port or a DSN", r)
+			}
+		}
+	}
+	switch cfg.Database.TLS {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		errs.add("database.tls", cfg.Database.TLS, "database tls '%s' must be one of disable, require, verify-ca, verify-full", cfg.Database.TLS)
+	}
+	if cfg.Database.MaxOpenConns < 0 || cfg.Database.MaxIdleConns < 0 {
+		errs.add("database.max_open_conns", cfg.Database.MaxOpenConns, "database max_open_conns and max_idle_conns must not be negative")
+	} else if cfg.Database.MaxOpenConns > 0 && cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		errs.add("database.max_idle_conns", cfg.Database.MaxIdleConns, "database max_idle_conns %d must not be greater than max_open_conns %d", cfg.Database.MaxIdleConns, cfg.Database.MaxOpenConns)
+	}
+	if d, err := parseDuration(cfg.Database.ConnMaxLifetime); err != nil || d < 0 {
+		errs.add("database.conn_max_lifetime", cfg.Database.ConnMaxLifetime, "database conn_max_lifetime '%s' must be a duration, like 5m", cfg.Database.ConnMaxLifetime)
+	}
+	if d, err := parseDuration(cfg.Database.QueryTimeout); err != nil || d < 0 {
+		errs.add("database.query_timeout", cfg.Database.QueryTimeout, "database query_timeout '%s' must be a duration, like 10s", cfg.Database.QueryTimeout)
+	}
+	if cfg.Database.ConnectRetries < 0 {
+		errs.add("database.connect_retries", cfg.Database.ConnectRetries, "database connect_retries must not be negative")
+	}
+	if d, err := parseDuration(cfg.Database.ConnectRetryDelay); err != nil || d < 0 {
+		errs.add("database.connect_retry_delay", cfg.Database.ConnectRetryDelay, "database connect_retry_delay '%s' must be a duration, like 1s", cfg.Database.ConnectRetryDelay)
+	}
+
+	if cfg.Server.Port < 1 || cfg.Server.Port > maxPort {
+		errs.add("server.port", cfg.Server.Port, "server port %d must be a number 1 - %d", cfg.Server.Port, maxPort)
+	}
+	for _, a := range strings.Split(cfg.Server.Bind, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" && cfg.Server.Bind == "" {
+			continue
+		}
+		if err := validateBindAddr(a); err != nil {
+			errs.add("server.bind", a, "server bind '%s': %v", a, err)
+		}
+	}
+	for _, p := range cfg.Server.TrustedProxies {
+		if _, err := parseTrustedProxy(p); err != nil {
+			errs.add("server.trusted_proxies", p, "server trusted_proxies '%s' must be a CIDR or IP address", p)
+		}
+	}
+	if cfg.Server.UseProxyHeaders && len(cfg.Server.TrustedProxies) == 0 {
+		errs.add("server.use_proxy_headers", cfg.Server.UseProxyHeaders, "server use_proxy_headers requires trusted_proxies")
+	}
+	for k, v := range map[string]string{
+		"read_timeout":     cfg.Server.ReadTimeout,
+		"write_timeout":    cfg.Server.WriteTimeout,
+		"idle_timeout":     cfg.Server.IdleTimeout,
+		"shutdown_timeout": cfg.Server.ShutdownTimeout,
+	} {
+		if d, err := parseDuration(v); err != nil || d < 0 {
+			errs.add("server."+k, v, "server %s '%s' must be a duration, like 10s", k, v)
+		}
+	}
+	for k, v := range map[string]string{
+		"metrics_path": cfg.Server.MetricsPath,
+		"health_path":  cfg.Server.HealthPath,
+		"ready_path":   cfg.Server.ReadyPath,
+	} {
+		if v != "" && !strings.HasPrefix(v, "/") {
+			errs.add("server."+k, v, "server %s '%s' must start with /", k, v)
+		}
+	}
+	if !cfg.Server.HealthDisabled && cfg.Server.HealthEndpoint() == cfg.Server.ReadyEndpoint() {
+		errs.add("server.ready_path", cfg.Server.ReadyPath, "server health_path and ready_path must be different")
+	}
+	if cfg.Server.Autocert && len(cfg.Server.AutoCertHosts) == 0 {
+		errs.add("server.autocert_hosts", cfg.Server.AutoCertHosts, "server autocert_hosts must list at least one host when autocert is enabled")
+	}
+	if cfg.Server.Port == 443 && !cfg.Server.Autocert {
+		certPath, keyPath := cfg.Server.TLSCertPath, cfg.Server.TLSKeyPath
+		if (certPath == "") != (keyPath == "") {
+			errs.add("server.tls_cert_path", certPath, "server TLS cert and key paths must both be set, or both be empty")
+		} else if certPath != "" {
+			for _, f := range []struct{ Field, Path string }{
+				{"server.tls_cert_path", certPath},
+				{"server.tls_key_path", keyPath},
+			} {
+				if _, err := os.Stat(f.Path); err != nil {
+					errs.add(f.Field, f.Path, "server TLS file %s: %v", f.Path, err)
+				}
+			}
+		}
+	}
+
+	if u, err := url.Parse(cfg.App.Host); err != nil || u.Scheme == "" || u.Host == "" {
+		errs.add("app.host", cfg.App.Host, "app host '%s' must be an absolute URL, like https://example.com", cfg.App.Host)
+	}
+	if cfg.App.AdminEmail != "" {
+		if a, err := mail.ParseAddress(cfg.App.AdminEmail); err != nil || a.Address != cfg.App.AdminEmail {
+			errs.add("app.admin_email", cfg.App.AdminEmail, "app admin_email '%s' must be an email address, like admin@example.com", cfg.App.AdminEmail)
+		}
+	}
+	if cfg.App.ContactURL != "" {
+		if u, err := url.Parse(cfg.App.ContactURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("app.contact_url", cfg.App.ContactURL, "app contact_url '%s' must be an http or https URL", cfg.App.ContactURL)
+		}
+	}
+	if err := cfg.Server.CheckDataDir(); err != nil {
+		errs.add("server.data_dir", cfg.Server.DataDir, "server data_dir '%s': %v", cfg.Server.DataDir, err)
+	}
+	if _, err := cfg.App.ThemesPath(); err != nil {
+		errs.add("app.themes_dir", cfg.App.ThemesDir, "app themes_dir '%s': %v", cfg.App.ThemesDir, err)
+	}
+	if cfg.App.RobotsTxtPath != "" {
+		if fi, err := os.Stat(cfg.App.RobotsTxtPath); err != nil {
+			errs.add("app.robots_txt_path", cfg.App.RobotsTxtPath, "app robots_txt_path '%s': %v", cfg.App.RobotsTxtPath, err)
+		} else if fi.IsDir() {
+			errs.add("app.robots_txt_path", cfg.App.RobotsTxtPath, "app robots_txt_path '%s' is a directory", cfg.App.RobotsTxtPath)
+		}
+	}
+	if cfg.App.CustomCSSPath != "" {
+		if fi, err := os.Stat(cfg.App.CustomCSSPath); err != nil {
+			errs.add("app.custom_css_path", cfg.App.CustomCSSPath, "app custom_css_path '%s': %v", cfg.App.CustomCSSPath, err)
+		} else if fi.IsDir() {
+			errs.add("app.custom_css_path", cfg.App.CustomCSSPath, "app custom_css_path '%s' is a directory", cfg.App.CustomCSSPath)
+		}
+	}
+	if d, err := parseDuration(cfg.App.FederationCacheTTL); err != nil || d < 0 {
+		errs.add("app.federation_cache_ttl", cfg.App.FederationCacheTTL, "app federation_cache_ttl '%s' must be a duration, like 1h", cfg.App.FederationCacheTTL)
+	}
+	if d, err := parseDuration(cfg.App.FederationTimeout); err != nil || d < 0 {
+		errs.add("app.federation_timeout", cfg.App.FederationTimeout, "app federation_timeout '%s' must be a duration, like 30s", cfg.App.FederationTimeout)
+	}
+	if cfg.App.MaxFederationConcurrency < 0 {
+		errs.add("app.max_federation_concurrency", cfg.App.MaxFederationConcurrency, "app max_federation_concurrency must not be negative")
+	}
+	if d, err := parseDuration(cfg.App.ScheduledPublishInterval); err != nil || d < 0 {
+		errs.add("app.scheduled_publish_interval", cfg.App.ScheduledPublishInterval, "app scheduled_publish_interval '%s' must be a duration, like 1m", cfg.App.ScheduledPublishInterval)
+	}
+	if d, err := parseDuration(cfg.App.APITokenTTL); err != nil || d < 0 {
+		errs.add("app.api_token_ttl", cfg.App.APITokenTTL, "app api_token_ttl '%s' must be a duration, like 720h", cfg.App.APITokenTTL)
+	}
+	for _, a := range cfg.App.AllowedSignatureAlgs {
+		if strings.TrimSpace(a) == "" {
+			errs.add("app.allowed_signature_algs", cfg.App.AllowedSignatureAlgs, "app allowed_signature_algs can't include an empty algorithm")
+		}
+	}
+	for _, o := range cfg.App.CORSOrigins {
+		if o == "*" {
+			if cfg.App.CORSCredentials {
+				errs.add("app.cors_origins", o, "app cors_origins '*' can't be used with cors_credentials")
+			}
+			continue
+		}
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs.add("app.cors_origins", o, "app cors_origins '%s' must be an origin, like https://example.com", o)
+		}
+	}
+	switch cfg.App.DefaultVisibility {
+	case "", "unlisted", "public", "private":
+	default:
+		errs.add("app.default_visibility", cfg.App.DefaultVisibility, "app default_visibility '%s' must be one of unlisted, public, private", cfg.App.DefaultVisibility)
+	}
+	switch cfg.App.RegistrationMode {
+	case "", RegistrationOpen, RegistrationClosed, RegistrationInvite:
+	default:
+		errs.add("app.registration_mode", cfg.App.RegistrationMode, "app registration_mode '%s' must be one of open, closed, invite", cfg.App.RegistrationMode)
+	}
+	switch cfg.App.BlockedWordAction {
+	case "", BlockedWordReject, BlockedWordFlag:
+	default:
+		errs.add("app.blocked_word_action", cfg.App.BlockedWordAction, "app blocked_word_action '%s' must be one of reject, flag", cfg.App.BlockedWordAction)
+	}
+	switch cfg.App.WebFontSource {
+	case "", WebFontsCDN, WebFontsLocal, WebFontsNone:
+	default:
+		errs.add("app.webfont_source", cfg.App.WebFontSource, "app webfont_source '%s' must be one of cdn, local, none", cfg.App.WebFontSource)
+	}
+	switch cfg.App.PublicStats {
+	case "", StatsNone, StatsBasic, StatsFull:
+	default:
+		errs.add("app.public_stats", cfg.App.PublicStats, "app public_stats '%s' must be one of none, basic, full", cfg.App.PublicStats)
+	}
+	if cfg.App.MinUsernameLen < 1 {
+		errs.add("app.min_username_len", cfg.App.MinUsernameLen, "app min_username_len %d must be at least 1", cfg.App.MinUsernameLen)
+	}
+	if cfg.App.MaxDrafts < 0 {
+		errs.add("app.max_drafts", cfg.App.MaxDrafts, "app max_drafts %d must not be negative", cfg.App.MaxDrafts)
+	}
+	if cfg.App.MaxBlogTitleLen < 0 {
+		errs.add("app.max_blog_title_len", cfg.App.MaxBlogTitleLen, "app max_blog_title_len %d must not be negative", cfg.App.MaxBlogTitleLen)
+	}
+	if cfg.App.MaxBlogDescLen < 0 {
+		errs.add("app.max_blog_desc_len", cfg.App.MaxBlogDescLen, "app max_blog_desc_len %d must not be negative", cfg.App.MaxBlogDescLen)
+	}
+	if cfg.App.MinPasswordLen < 0 {
+		errs.add("app.min_password_len", cfg.App.MinPasswordLen, "app min_password_len %d must not be negative", cfg.App.MinPasswordLen)
+	}
+	if c := cfg.App.BcryptCost; c != 0 && (c < bcrypt.MinCost || c > bcrypt.MaxCost) {
+		errs.add("app.bcrypt_cost", c, "app bcrypt_cost %d must be from %d to %d", c, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if cfg.Email.SMTPPort < 0 || cfg.Email.SMTPPort > maxPort {
+		errs.add("email.smtp_port", cfg.Email.SMTPPort, "email smtp_port %d must be a number 1 - %d", cfg.Email.SMTPPort, maxPort)
+	}
+
+	if cfg.RateLimit.LoginPerMinute < 0 || cfg.RateLimit.SignupPerHour < 0 || cfg.RateLimit.APIPerMinute < 0 || cfg.RateLimit.AnonymousPostsPerHour < 0 {
+		errs.add("rate_limit", cfg.RateLimit, "rate_limit values must not be negative")
+	}
+
+	if _, err := ParseLogLevel(cfg.Log.Level); err != nil {
+		errs.add("log.level", cfg.Log.Level, "log level '%s' must be one of debug, info, warn, error", cfg.Log.Level)
+	}
+	switch cfg.Log.Format {
+	case "", "text", "json":
+	default:
+		errs.add("log.format", cfg.Log.Format, "log format '%s' must be one of text, json", cfg.Log.Format)
+	}
+	for _, p := range cfg.Log.ExcludePaths {
+		if !strings.HasPrefix(p, "/") {
+			errs.add("log.exclude_paths", p, "log exclude_paths '%s' must start with /", p)
+		}
+	}
+
+	switch cfg.Storage.Type {
+	case "", "local":
+	case "s3":
+		for k, v := range map[string]string{
+			"s3_bucket":     cfg.Storage.S3Bucket,
+			"s3_access_key": cfg.Storage.S3AccessKey,
+			"s3_secret_key": cfg.Storage.S3SecretKey,
+		} {
+			if v == "" {
+				errs.add("storage."+k, v, "storage %s is required for s3 storage", k)
+			}
+		}
+		if cfg.Storage.S3Endpoint == "" && cfg.Storage.S3Region == "" {
+			errs.add("storage.s3_endpoint", cfg.Storage.S3Endpoint, "storage s3_endpoint or s3_region is required for s3 storage")
+		}
+	default:
+		errs.add("storage.type", cfg.Storage.Type, "storage type '%s' must be one of local, s3", cfg.Storage.Type)
+	}
+	if cfg.App.MaxAPIBodyBytes < 0 {
+		errs.add("app.max_api_body_bytes", cfg.App.MaxAPIBodyBytes, "app max_api_body_bytes must not be negative")
+	}
+	if cfg.Storage.MaxUploadBytes < 0 {
+		errs.add("storage.max_upload_bytes", cfg.Storage.MaxUploadBytes, "storage max_upload_bytes must not be negative")
+	}
+	if cfg.Storage.BaseURL != "" {
+		if u, err := url.Parse(cfg.Storage.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs.add("storage.base_url", cfg.Storage.BaseURL, "storage base_url '%s' must be an absolute URL", cfg.Storage.BaseURL)
+		}
+	}
+
+	switch cfg.Cache.Type {
+	case "", "memory":
+	case "redis":
+		if cfg.Cache.RedisHost == "" {
+			errs.add("cache.redis_host", cfg.Cache.RedisHost, "cache redis_host is required for redis")
+		}
+		if cfg.Cache.RedisPort < 0 || cfg.Cache.RedisPort > maxPort {
+			errs.add("cache.redis_port", cfg.Cache.RedisPort, "cache redis_port %d is out of range", cfg.Cache.RedisPort)
+		}
+		if cfg.Cache.RedisDB < 0 {
+			errs.add("cache.redis_db", cfg.Cache.RedisDB, "cache redis_db %d must not be negative", cfg.Cache.RedisDB)
+		}
+	default:
+		errs.add("cache.type", cfg.Cache.Type, "cache type '%s' must be one of memory, redis", cfg.Cache.Type)
+	}
+
+	if cfg.OAuth.Enabled() {
+		if cfg.OAuth.ClientSecret == "" {
+			errs.add("oauth.client_secret", cfg.OAuth.ClientSecret, "oauth client_secret is required when client_id is set")
+		}
+		for _, u := range []struct {
+			Key, Val string
+			Required bool
+		}{
+			{"auth_url", cfg.OAuth.AuthURL, true},
+			{"token_url", cfg.OAuth.TokenURL, true},
+			{"userinfo_url", cfg.OAuth.UserInfoURL, true},
+			{"callback_url", cfg.OAuth.CallbackURL, false},
+		} {
+			if u.Val == "" {
+				if u.Required {
+					errs.add("oauth."+u.Key, u.Val, "oauth %s is required when client_id is set", u.Key)
+				}
+				continue
+			}
+			if pu, err := url.Parse(u.Val); err != nil || pu.Scheme == "" || pu.Host == "" {
+				errs.add("oauth."+u.Key, u.Val, "oauth %s '%s' must be an absolute URL", u.Key, u.Val)
+			}
+		}
+	}
+
+	if cfg.Webhook.Enabled() {
+		if u, err := url.Parse(cfg.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("webhook.url", cfg.Webhook.URL, "webhook url '%s' must be an absolute http or https URL", cfg.Webhook.URL)
+		}
+	}
+	for _, e := range cfg.App.MarkdownExtensions {
+		if !isMarkdownExtension(e) {
+			errs.add("app.markdown_extensions", cfg.App.MarkdownExtensions, "app markdown_extensions '%s' must be one of %s", e, strings.Join(markdownExtensions, ", "))
+		}
+	}
+	for _, e := range cfg.Webhook.Events {
+		if !isWebhookEvent(e) {
+			errs.add("webhook.events", cfg.Webhook.Events, "webhook events '%s' must be one of %s", e, strings.Join(webhookEvents, ", "))
+		}
+	}
+	if cfg.Webhook.Retries < 0 {
+		errs.add("webhook.retries", cfg.Webhook.Retries, "webhook retries %d must not be negative", cfg.Webhook.Retries)
+	}
+
+	if cfg.Session.CookieName != "" && !cookieNameReg.MatchString(cfg.Session.CookieName) {
+		errs.add("session.cookie_name", cfg.Session.CookieName, "session cookie_name '%s' may only contain letters, numbers, and -_.", cfg.Session.CookieName)
+	}
+	switch strings.ToLower(cfg.Session.CookieSameSite) {
+	case "", "lax", "strict":
+	case "none":
+		if !cfg.Session.Secure(cfg.App.Host) {
+			errs.add("session.cookie_samesite", cfg.Session.CookieSameSite, "session cookie_samesite none requires cookie_secure, or an https host")
+		}
+	default:
+		errs.add("session.cookie_samesite", cfg.Session.CookieSameSite, "session cookie_samesite '%s' must be one of lax, strict, none", cfg.Session.CookieSameSite)
+	}
+	if d, err := parseDuration(cfg.Session.CookieMaxAge); err != nil || d < 0 || (d == 0 && cfg.Session.CookieMaxAge != "") {
+		errs.add("session.cookie_max_age", cfg.Session.CookieMaxAge, "session cookie_max_age '%s' must be a duration, like 720h", cfg.Session.CookieMaxAge)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,636 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var validateTestTable = []struct {
+	Name     string
+	Modify   func(*Config)
+	Expected []string
+}{
+	{
+		"Unsupported database",
+		func(c *Config) { c.Database.Type = "oracle" },
+		[]string{"database type 'oracle'"},
+	},
+	{
+		"Port out of range",
+		func(c *Config) { c.Server.Port = 70000 },
+		[]string{"server port 70000"},
+	},
+	{
+		"Relative host",
+		func(c *Config) { c.App.Host = "example.com" },
+		[]string{"app host 'example.com'"},
+	},
+	{
+		"Unknown default visibility",
+		func(c *Config) { c.App.DefaultVisibility = "friends" },
+		[]string{"default_visibility 'friends'"},
+	},
+	{
+		"Unknown registration mode",
+		func(c *Config) { c.App.RegistrationMode = "approval" },
+		[]string{"registration_mode 'approval'"},
+	},
+	{
+		"Bad federation cache TTL",
+		func(c *Config) { c.App.FederationCacheTTL = "forever" },
+		[]string{"federation_cache_ttl 'forever'"},
+	},
+	{
+		"Wildcard CORS with credentials",
+		func(c *Config) {
+			c.App.CORSOrigins = []string{"*"}
+			c.App.CORSCredentials = true
+		},
+		[]string{"cors_origins '*' can't be used with cors_credentials"},
+	},
+	{
+		"Invalid CORS origin",
+		func(c *Config) {
+			c.App.CORSOrigins = []string{"https://ok.example.com", "admin.example.com", "https://example.com/admin"}
+		},
+		[]string{"cors_origins 'admin.example.com'", "cors_origins 'https://example.com/admin'"},
+	},
+	{
+		"OAuth missing settings",
+		func(c *Config) { c.OAuth.ClientID = "writefreely" },
+		[]string{"oauth client_secret is required", "oauth auth_url is required", "oauth token_url is required", "oauth userinfo_url is required"},
+	},
+	{
+		"OAuth relative URLs",
+		func(c *Config) {
+			c.OAuth = OAuthCfg{
+				ClientID:     "writefreely",
+				ClientSecret: "wJalrXUtnFEMI",
+				AuthURL:      "https://sso.example.com/authorize",
+				TokenURL:     "/token",
+				UserInfoURL:  "https://sso.example.com/userinfo",
+				CallbackURL:  "example.com/oauth/callback",
+			}
+		},
+		[]string{"oauth token_url '/token'", "oauth callback_url 'example.com/oauth/callback'"},
+	},
+	{
+		"Missing custom CSS",
+		func(c *Config) { c.App.CustomCSSPath = "/nonexistent/custom.css" },
+		[]string{"app custom_css_path '/nonexistent/custom.css'"},
+	},
+	{
+		"Unknown stats level",
+		func(c *Config) { c.App.PublicStats = "true" },
+		[]string{"app public_stats 'true'"},
+	},
+	{
+		"Zero username length",
+		func(c *Config) { c.App.MinUsernameLen = 0 },
+		[]string{"min_username_len 0"},
+	},
+	{
+		"Bind address with port",
+		func(c *Config) { c.Server.Bind = "localhost, 127.0.0.1:8080" },
+		[]string{"server bind '127.0.0.1:8080'"},
+	},
+	{
+		"Unclosed IPv6 bracket",
+		func(c *Config) { c.Server.Bind = "[::1" },
+		[]string{"server bind '[::1'"},
+	},
+	{
+		"Invalid bind host",
+		func(c *Config) { c.Server.Bind = "local_host,,::1" },
+		[]string{"server bind 'local_host'", "server bind ''"},
+	},
+	{
+		"Invalid trusted proxy",
+		func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} },
+		[]string{"trusted_proxies 'proxy.local'"},
+	},
+	{
+		"Bad server timeout",
+		func(c *Config) { c.Server.WriteTimeout = "10" },
+		[]string{"write_timeout '10'"},
+	},
+	{
+		"Relative metrics path",
+		func(c *Config) { c.Server.MetricsPath = "metrics" },
+		[]string{"metrics_path 'metrics'"},
+	},
+	{
+		"Relative health path",
+		func(c *Config) { c.Server.HealthPath = "healthz" },
+		[]string{"health_path 'healthz'"},
+	},
+	{
+		"Same health and ready paths",
+		func(c *Config) { c.Server.ReadyPath = "/healthz" },
+		[]string{"health_path and ready_path must be different"},
+	},
+	{
+		"TLS cert without key",
+		func(c *Config) {
+			c.Server.Port = 443
+			c.Server.TLSCertPath = "cert.pem"
+		},
+		[]string{"cert and key paths must both be set"},
+	},
+	{
+		"Missing TLS files",
+		func(c *Config) {
+			c.Server.Port = 443
+			c.Server.TLSCertPath = "/nonexistent/cert.pem"
+			c.Server.TLSKeyPath = "/nonexistent/key.pem"
+		},
+		[]string{"/nonexistent/cert.pem", "/nonexistent/key.pem"},
+	},
+	{
+		"Autocert without hosts",
+		func(c *Config) {
+			c.Server.Port = 443
+			c.Server.Autocert = true
+		},
+		[]string{"autocert_hosts"},
+	},
+	{
+		"Socket and host",
+		func(c *Config) { c.Database.Socket = "/var/run/mysqld/mysqld.sock" },
+		[]string{"socket and host must not both be set"},
+	},
+	{
+		"DSN and host",
+		func(c *Config) { c.Database.DSN = "wf:pass@tcp(db:3306)/writefreely?parseTime=true" },
+		[]string{"dsn and host or socket must not both be set"},
+	},
+	{
+		"Unknown database TLS mode",
+		func(c *Config) { c.Database.TLS = "sometimes" },
+		[]string{"database tls 'sometimes'"},
+	},
+	{
+		"More idle than open connections",
+		func(c *Config) {
+			c.Database.MaxOpenConns = 5
+			c.Database.MaxIdleConns = 10
+		},
+		[]string{"max_idle_conns 10 must not be greater than max_open_conns 5"},
+	},
+	{
+		"Bad connection lifetime",
+		func(c *Config) { c.Database.ConnMaxLifetime = "forever" },
+		[]string{"conn_max_lifetime 'forever'"},
+	},
+	{
+		"Negative blog title length",
+		func(c *Config) { c.App.MaxBlogTitleLen = -1 },
+		[]string{"max_blog_title_len -1"},
+	},
+	{
+		"Unknown blocked word action",
+		func(c *Config) { c.App.BlockedWordAction = "hide" },
+		[]string{"blocked_word_action 'hide'"},
+	},
+	{
+		"Unknown webfont source",
+		func(c *Config) { c.App.WebFontSource = "google" },
+		[]string{"webfont_source 'google'"},
+	},
+	{
+		"Relative webhook URL",
+		func(c *Config) { c.Webhook.URL = "/hooks/wf" },
+		[]string{"webhook url '/hooks/wf'"},
+	},
+	{
+		"Unknown webhook event",
+		func(c *Config) {
+			c.Webhook.URL = "https://hooks.example.com/wf"
+			c.Webhook.Events = []string{"post.published", "user.created"}
+		},
+		[]string{"webhook events 'user.created'"},
+	},
+	{
+		"Unknown Markdown extension",
+		func(c *Config) { c.App.MarkdownExtensions = []string{"tables", "mathjax"} },
+		[]string{"app markdown_extensions 'mathjax'"},
+	},
+	{
+		"Negative webhook retries",
+		func(c *Config) { c.Webhook.Retries = -1 },
+		[]string{"webhook retries -1"},
+	},
+	{
+		"Bad admin email",
+		func(c *Config) { c.App.AdminEmail = "Admin <admin@example.com>" },
+		[]string{"admin_email 'Admin <admin@example.com>' must be an email address"},
+	},
+	{
+		"Bad contact URL",
+		func(c *Config) { c.App.ContactURL = "example.com/contact" },
+		[]string{"contact_url 'example.com/contact' must be an http or https URL"},
+	},
+	{
+		"Negative federation concurrency",
+		func(c *Config) { c.App.MaxFederationConcurrency = -1 },
+		[]string{"max_federation_concurrency must not be negative"},
+	},
+	{
+		"Missing data dir",
+		func(c *Config) { c.Server.DataDir = "/nonexistent/writefreely" },
+		[]string{"server data_dir '/nonexistent/writefreely'"},
+	},
+	{
+		"Bad query timeout",
+		func(c *Config) { c.Database.QueryTimeout = "-1s" },
+		[]string{"query_timeout '-1s'"},
+	},
+	{
+		"Negative connect retries",
+		func(c *Config) { c.Database.ConnectRetries = -1 },
+		[]string{"connect_retries must not be negative"},
+	},
+	{
+		"Bad connect retry delay",
+		func(c *Config) { c.Database.ConnectRetryDelay = "soon" },
+		[]string{"connect_retry_delay 'soon'"},
+	},
+	{
+		"Unknown log level",
+		func(c *Config) { c.Log.Level = "verbose" },
+		[]string{"log level 'verbose'"},
+	},
+	{
+		"Relative log exclude path",
+		func(c *Config) { c.Log.ExcludePaths = []string{"/css/", "favicon.ico"} },
+		[]string{"exclude_paths 'favicon.ico' must start with /"},
+	},
+	{
+		"Unknown log format",
+		func(c *Config) { c.Log.Format = "xml" },
+		[]string{"log format 'xml'"},
+	},
+	{
+		"Unknown storage type",
+		func(c *Config) { c.Storage.Type = "ftp" },
+		[]string{"storage type 'ftp'"},
+	},
+	{
+		"Incomplete S3 storage",
+		func(c *Config) {
+			c.Storage.Type = "s3"
+			c.Storage.S3Bucket = "media"
+		},
+		[]string{"s3_access_key is required", "s3_secret_key is required", "s3_endpoint or s3_region is required"},
+	},
+	{
+		"Relative storage base URL",
+		func(c *Config) { c.Storage.BaseURL = "media.example.com" },
+		[]string{"storage base_url 'media.example.com'"},
+	},
+	{
+		"Unknown cache type",
+		func(c *Config) { c.Cache.Type = "memcached" },
+		[]string{"cache type 'memcached'"},
+	},
+	{
+		"Incomplete Redis cache",
+		func(c *Config) {
+			c.Cache.Type = "redis"
+			c.Cache.RedisPort = 70000
+			c.Cache.RedisDB = -1
+		},
+		[]string{"redis_host is required", "redis_port 70000", "redis_db -1"},
+	},
+	{
+		"Bad read replicas",
+		func(c *Config) { c.Database.ReadReplicas = []string{"db2:port", " "} },
+		[]string{"read_replicas 'db2:port'", "read_replicas must not have empty entries"},
+	},
+	{
+		"Read replicas with SQLite",
+		func(c *Config) {
+			c.Database.Type = "sqlite3"
+			c.Database.ReadReplicas = []string{"db2"}
+		},
+		[]string{"read_replicas aren't supported with sqlite3"},
+	},
+	{
+		"Invalid API token TTL",
+		func(c *Config) { c.App.APITokenTTL = "a month" },
+		[]string{"app api_token_ttl 'a month'"},
+	},
+	{
+		"Empty signature algorithm",
+		func(c *Config) { c.App.AllowedSignatureAlgs = []string{"rsa-sha256", " "} },
+		[]string{"app allowed_signature_algs"},
+	},
+	{
+		"Negative max drafts",
+		func(c *Config) { c.App.MaxDrafts = -1 },
+		[]string{"app max_drafts -1"},
+	},
+	{
+		"Negative min password length",
+		func(c *Config) { c.App.MinPasswordLen = -1 },
+		[]string{"app min_password_len -1"},
+	},
+	{
+		"Bcrypt cost too low",
+		func(c *Config) { c.App.BcryptCost = 3 },
+		[]string{"app bcrypt_cost 3"},
+	},
+	{
+		"Bcrypt cost too high",
+		func(c *Config) { c.App.BcryptCost = 32 },
+		[]string{"app bcrypt_cost 32 must be from 4 to 31"},
+	},
+	{
+		"Proxy headers without trusted proxies",
+		func(c *Config) { c.Server.UseProxyHeaders = true },
+		[]string{"use_proxy_headers requires trusted_proxies"},
+	},
+	{
+		"SameSite none without Secure",
+		func(c *Config) { c.Session.CookieSameSite = "none" },
+		[]string{"cookie_samesite none requires cookie_secure"},
+	},
+	{
+		"Bad session cookie",
+		func(c *Config) {
+			c.Session.CookieName = "wf session"
+			c.Session.CookieSameSite = "sometimes"
+			c.Session.CookieMaxAge = "forever"
+		},
+		[]string{"cookie_name 'wf session'", "cookie_samesite 'sometimes'", "cookie_max_age 'forever'"},
+	},
+	{
+		"Multiple problems",
+		func(c *Config) {
+			c.Database.Type = ""
+			c.Server.Port = 0
+			c.App.Host = ""
+		},
+		[]string{"database type ''", "server port 0", "app host ''"},
+	},
+}
+
+// newValidConfig returns a default Config with the database password that
+// Validate requires filled in.
+func newValidConfig() *Config {
+	cfg := New()
+	cfg.Database.Password = "c0rrect-h0rse"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := newValidConfig().Validate(); err != nil {
+		t.Errorf("Default config failed validation: %v", err)
+	}
+	unlimited := newValidConfig()
+	unlimited.Database.MaxOpenConns = 0
+	unlimited.Database.MaxIdleConns = 10
+	unlimited.Database.ConnMaxLifetime = "5m"
+	if err := unlimited.Validate(); err != nil {
+		t.Errorf("Unlimited open connections failed validation: %v", err)
+	}
+	dsn := newValidConfig()
+	dsn.Database.Host = ""
+	dsn.Database.DSN = "wf:pass@tcp(db:3306)/writefreely?parseTime=true"
+	if err := dsn.Validate(); err != nil {
+		t.Errorf("DSN config failed validation: %v", err)
+	}
+	s3 := newValidConfig()
+	s3.Storage = StorageCfg{
+		Type:        "s3",
+		S3Endpoint:  "https://minio.example.com",
+		S3Bucket:    "media",
+		S3AccessKey: "access",
+		S3SecretKey: "wJalrXUtnFEMI",
+		BaseURL:     "https://media.example.com",
+	}
+	if err := s3.Validate(); err != nil {
+		t.Errorf("S3 storage config failed validation: %v", err)
+	}
+	if !s3.Storage.IsS3() || newValidConfig().Storage.IsS3() {
+		t.Error("IsS3() doesn't match storage type")
+	}
+	for _, v := range []string{"", "unlisted", "public", "private"} {
+		vis := newValidConfig()
+		vis.App.DefaultVisibility = v
+		if err := vis.Validate(); err != nil {
+			t.Errorf("Default visibility '%s' failed validation: %v", v, err)
+		}
+	}
+	for _, m := range []string{"", "open", "closed", "invite"} {
+		reg := newValidConfig()
+		reg.App.RegistrationMode = m
+		if err := reg.Validate(); err != nil {
+			t.Errorf("Registration mode '%s' failed validation: %v", m, err)
+		}
+	}
+	multi := newValidConfig()
+	multi.Server.Bind = "localhost, 192.168.1.10, [::1], fe80::1, example.com"
+	if err := multi.Validate(); err != nil {
+		t.Errorf("Multiple bind addresses failed validation: %v", err)
+	}
+	// Cert paths aren't used with autocert, so they aren't checked
+	ac := newValidConfig()
+	ac.Server.Port = 443
+	ac.Server.Autocert = true
+	ac.Server.AutoCertHosts = []string{"example.com"}
+	ac.Server.TLSCertPath = "/nonexistent/cert.pem"
+	if err := ac.Validate(); err != nil {
+		t.Errorf("Autocert config failed validation: %v", err)
+	}
+
+	for _, sc := range []struct {
+		Host   string
+		Secure bool
+	}{
+		{"https://example.com", false},
+		{"http://localhost:8080", true},
+	} {
+		cookies := newValidConfig()
+		cookies.App.Host = sc.Host
+		cookies.Session = SessionCfg{CookieSecure: sc.Secure, CookieSameSite: "none"}
+		if err := cookies.Validate(); err != nil {
+			t.Errorf("SameSite none on %s (cookie_secure %t) failed validation: %v", sc.Host, sc.Secure, err)
+		}
+	}
+
+	for _, tc := range validateTestTable {
+		cfg := newValidConfig()
+		tc.Modify(cfg)
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("%s: expected error", tc.Name)
+			continue
+		}
+		for _, e := range tc.Expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("%s: error '%s' doesn't mention '%s'", tc.Name, err, e)
+			}
+		}
+	}
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Modify   func(*Config)
+		Expected []string
+		Value    interface{}
+	}{
+		{"Database type", func(c *Config) { c.Database.Type = "oracle" }, []string{"database.type"}, "oracle"},
+		{"Server port", func(c *Config) { c.Server.Port = 70000 }, []string{"server.port"}, 70000},
+		{"Timeout", func(c *Config) { c.Server.IdleTimeout = "soon" }, []string{"server.idle_timeout"}, "soon"},
+		{"Several", func(c *Config) {
+			c.App.Host = "example.com"
+			c.Log.Format = "xml"
+		}, []string{"app.host", "log.format"}, "example.com"},
+		{"Secret", func(c *Config) { c.Database.Password = "changeme" }, []string{"database.password"}, "changeme"},
+	} {
+		cfg := newValidConfig()
+		tc.Modify(cfg)
+		err := cfg.Validate()
+		verrs, ok := err.(ValidationErrors)
+		if !ok {
+			t.Errorf("%s: Validate() = %#v; expected ValidationErrors", tc.Name, err)
+			continue
+		}
+		if fields := verrs.Fields(); !reflect.DeepEqual(fields, tc.Expected) {
+			t.Errorf("%s: fields = %q; expected %q", tc.Name, fields, tc.Expected)
+		}
+		if verrs[0].Value != tc.Value {
+			t.Errorf("%s: value = %v; expected %v", tc.Name, verrs[0].Value, tc.Value)
+		}
+		if verrs[0].Message == "" || !strings.Contains(err.Error(), verrs[0].Message) {
+			t.Errorf("%s: error %q doesn't include message %q", tc.Name, err, verrs[0].Message)
+		}
+	}
+
+	if err := newValidConfig().Validate(); err != nil {
+		t.Errorf("Default config returned %#v; expected a nil error", err)
+	}
+}
+
+func TestValidateInsecureSecrets(t *testing.T) {
+	cfg := New()
+	cfg.Database.Password = "password"
+	cfg.Server.MetricsToken = "ChangeMe"
+
+	cfg.Server.Dev = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Placeholder secrets failed validation in dev mode: %v", err)
+	}
+
+	cfg.Server.Dev = false
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Placeholder secrets passed validation in production mode")
+	}
+	for _, e := range []string{"database password", "server metrics_token"} {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("Error %q doesn't mention %q", err, e)
+		}
+	}
+
+	cfg.Database.Password = "c0rrect-h0rse"
+	cfg.Server.MetricsToken = ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Real secrets failed validation: %v", err)
+	}
+
+	// "writefreely" is the default database name, not a placeholder
+	cfg.Database.Password = "writefreely"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Database password 'writefreely' failed validation: %v", err)
+	}
+}
+
+func TestValidateEmptySecrets(t *testing.T) {
+	cfg := New()
+	cfg.Email.SMTPUser = "writefreely"
+
+	cfg.Server.Dev = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Empty secrets failed validation in dev mode: %v", err)
+	}
+
+	cfg.Server.Dev = false
+	err := cfg.Validate()
+	verrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Validate() = %#v; expected ValidationErrors", err)
+	}
+	if fields := verrs.Fields(); !reflect.DeepEqual(fields, []string{"database.password", "email.smtp_password"}) {
+		t.Errorf("Empty secret fields = %q; expected database.password and email.smtp_password", fields)
+	}
+
+	// Secrets that aren't needed can be left empty
+	cfg.UseSQLite(true)
+	cfg.Email.SMTPUser = ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Config without secrets failed validation: %v", err)
+	}
+	cfg.UseMySQL(false)
+	cfg.Database.Host = ""
+	cfg.Database.DSN = "wf:pass@tcp(db:3306)/writefreely?parseTime=true"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("MySQL DSN without a password failed validation: %v", err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	for _, tc := range []struct {
+		Name                 string
+		Federation, NewBlogs bool
+		Expected             string
+	}{
+		{"Federating new blogs", true, true, ""},
+		{"Not federating new blogs", true, false, ""},
+		{"Federation disabled", false, false, ""},
+		{"New blogs without federation", false, true, "app.federate_new_blogs"},
+	} {
+		cfg := newValidConfig()
+		cfg.App.Federation = tc.Federation
+		cfg.App.FederateNewBlogs = tc.NewBlogs
+		if fields := strings.Join(cfg.Warnings().Fields(), ","); fields != tc.Expected {
+			t.Errorf("%s: Warnings() fields = %q; expected %q", tc.Name, fields, tc.Expected)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: Validate() = %v; expected warnings not to be errors", tc.Name, err)
+		}
+	}
+}
+
+func TestAutocertBindWarning(t *testing.T) {
+	for _, tc := range []struct {
+		Bind     string
+		Expected string
+	}{
+		{"localhost", "server.bind"},
+		{"127.0.0.1, [::1]", "server.bind"},
+		{"0.0.0.0", ""},
+		{"localhost, 192.0.2.10", ""},
+	} {
+		cfg := newValidConfig()
+		cfg.Server.Autocert = true
+		cfg.Server.Bind = tc.Bind
+		if fields := strings.Join(cfg.Warnings().Fields(), ","); fields != tc.Expected {
+			t.Errorf("Bind %q: Warnings() fields = %q; expected %q", tc.Bind, fields, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// isYAML returns whether the given file name has a YAML extension.
+func isYAML(fname string) bool {
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// LoadFile reads the given configuration file, as YAML if it has a .yaml or
+// .yml extension, and as INI otherwise.
+func LoadFile(fname string) (*Config, error) {
+	if isYAML(fname) {
+		return LoadYAML(fname)
+	}
+	return Load(fname)
+}
+
+// SaveFile writes the given Config to the given file, in the format
+// LoadFile would read it with.
+func SaveFile(uc *Config, fname string) error {
+	if isYAML(fname) {
+		return SaveYAML(uc, fname)
+	}
+	return Save(uc, fname)
+}
+
+// LoadYAML reads the given YAML configuration file, which uses the same
+// sections and keys as the INI file, with lists for comma-separated values.
+// It's loaded just like an INI file: included files, which can be in either
+// format, are read first, unknown keys are warned about, and older
+// configurations are migrated.
+func LoadYAML(fname string) (*Config, error) {
+	b, err := FileSource(fname).Read()
+	if err != nil {
+		return nil, err
+	}
+	b, err = yamlToINI(b)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(fname)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := includeSources(abs, b, map[string]bool{abs: true})
+	if err != nil {
+		return nil, err
+	}
+	return loadINI(sources)
+}
+
+// yamlToINI converts YAML configuration data to the INI data it stands for,
+// joining lists into comma-separated values.
+func yamlToINI(b []byte) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("Unable to parse configuration: %w", err)
+	}
+	f := ini.Empty()
+	for _, item := range doc {
+		name := fmt.Sprint(item.Key)
+		keys, isSection := item.Value.(yaml.MapSlice)
+		if !isSection {
+			if item.Value != nil {
+				f.Section(ini.DEFAULT_SECTION).Key(name).SetValue(yamlValue(item.Value))
+			}
+			continue
+		}
+		sec := f.Section(name)
+		for _, k := range keys {
+			sec.Key(fmt.Sprint(k.Key)).SetValue(yamlValue(k.Value))
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlValue returns the INI value for the given YAML value.
+func yamlValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		vals := make([]string, len(v))
+		for i := range v {
+			vals[i] = yamlValue(v[i])
+		}
+		return strings.Join(vals, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// SaveYAML writes the given Config to the given file as YAML. If the file
+// already exists, its includes are kept, and values that the included files
+// already set are left to them, unless the file sets them itself.
+func SaveYAML(uc *Config, fname string) error {
+	if err := checkWritable(fname); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(uc)
+	if err != nil {
+		return err
+	}
+	var doc yaml.MapSlice
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	if old, err := ioutil.ReadFile(fname); err == nil {
+		var oldDoc yaml.MapSlice
+		if err = yaml.Unmarshal(old, &oldDoc); err != nil {
+			return fmt.Errorf("Unable to parse configuration: %w", err)
+		}
+		data, err := yamlToINI(old)
+		if err != nil {
+			return err
+		}
+		inherited, err := inheritedValues(fname, data)
+		if err != nil {
+			return err
+		}
+		local, err := ini.Load(data)
+		if err != nil {
+			return err
+		}
+		doc = dropInherited(doc, inherited, iniKeys(local))
+		for _, item := range oldDoc {
+			if fmt.Sprint(item.Key) == includeSection {
+				doc = append(yaml.MapSlice{item}, doc...)
+			}
+		}
+		if b, err = yaml.Marshal(doc); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(fname, 0644, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// dropInherited removes the keys from the given YAML document that have the
+// inherited value and aren't among the local keys, along with any sections
+// left empty.
+func dropInherited(doc yaml.MapSlice, inherited map[string]string, local map[string]bool) yaml.MapSlice {
+	var kept yaml.MapSlice
+	for _, item := range doc {
+		keys, isSection := item.Value.(yaml.MapSlice)
+		if !isSection {
+			kept = append(kept, item)
+			continue
+		}
+		var sec yaml.MapSlice
+		for _, k := range keys {
+			name := fmt.Sprint(item.Key) + "." + fmt.Sprint(k.Key)
+			if v, ok := inherited[name]; ok && !local[name] && v == yamlValue(k.Value) {
+				continue
+			}
+			sec = append(sec, k)
+		}
+		if len(sec) > 0 {
+			kept = append(kept, yaml.MapItem{Key: item.Key, Value: sec})
+		}
+	}
+	return kept
+}
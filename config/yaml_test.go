@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadYAML(t *testing.T) {
+	iniCfg, err := LoadFile(filepath.Join("testdata", "config.ini"))
+	if err != nil {
+		t.Fatalf("Loading INI fixture failed: %v", err)
+	}
+	yamlCfg, err := LoadFile(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatalf("Loading YAML fixture failed: %v", err)
+	}
+	if !reflect.DeepEqual(iniCfg, yamlCfg) {
+		t.Errorf("YAML config doesn't match INI config:\n%+v\n%+v", yamlCfg, iniCfg)
+	}
+	if err := yamlCfg.Validate(); err != nil {
+		t.Errorf("YAML config failed validation: %v", err)
+	}
+
+	iniPath, cleanup := tempConfigPath(t)
+	defer cleanup()
+	fname := filepath.Join(filepath.Dir(iniPath), "config.yml")
+	if err := SaveFile(yamlCfg, fname); err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+	saved, err := LoadFile(fname)
+	if err != nil {
+		t.Fatalf("Loading saved YAML failed: %v", err)
+	}
+	if !reflect.DeepEqual(yamlCfg, saved) {
+		t.Errorf("YAML round trip changed Config:\n%+v\n%+v", yamlCfg, saved)
+	}
+}
+
+func TestLoadYAMLIncludes(t *testing.T) {
+	iniPath, cleanup := tempConfigPath(t)
+	defer cleanup()
+	dir := filepath.Dir(iniPath)
+	files := map[string]string{
+		"common.ini": "[server]\nport = 8081\n\n[app]\nsite_name = Common\n",
+		"config.yaml": `include:
+  path: common.ini
+app:
+  site_name: Local
+  reserved_usernames:
+    - admin
+    - ""
+    - " root "
+  not_a_key: true
+`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := LoadFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.Server.Port != 8081 || cfg.App.SiteName != "Local" {
+		t.Errorf("Port, site name = %d, %q; expected 8081 from the include and Local", cfg.Server.Port, cfg.App.SiteName)
+	}
+	if !reflect.DeepEqual(cfg.App.ReservedUsernames, []string{"admin", "root"}) {
+		t.Errorf("Reserved usernames = %q; expected the list cleaned up", cfg.App.ReservedUsernames)
+	}
+}
+
+func TestSaveBackupYAML(t *testing.T) {
+	iniPath, cleanup := tempConfigPath(t)
+	defer cleanup()
+	fname := filepath.Join(filepath.Dir(iniPath), "config.yaml")
+
+	cfg := New()
+	cfg.Server.Port = 9000
+	if err := SaveBackup(cfg, fname); err != nil {
+		t.Fatalf("SaveBackup failed: %v", err)
+	}
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatalf("Unable to stat config: %v", err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Errorf("Config mode = %o; expected 644, like INI files", fi.Mode().Perm())
+	}
+	if loaded, err := LoadYAML(fname); err != nil || loaded.Server.Port != 9000 {
+		t.Errorf("Loaded YAML port after SaveBackup = %v, %v; expected 9000", loaded, err)
+	}
+}
+
+func TestSaveYAMLIncludes(t *testing.T) {
+	iniPath, cleanup := tempConfigPath(t)
+	defer cleanup()
+	dir := filepath.Dir(iniPath)
+	fname := filepath.Join(dir, "config.yaml")
+	files := map[string]string{
+		"common.ini":  "[server]\nport = 8081\nbind = 0.0.0.0\n\n[app]\nsite_name = Common\n",
+		"config.yaml": "include:\n  path: common.ini\napp:\n  site_name: Common\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := LoadYAML(fname)
+	if err != nil {
+		t.Fatalf("LoadYAML failed: %v", err)
+	}
+	cfg.Server.Port = 9000
+	if err = SaveYAML(cfg, fname); err != nil {
+		t.Fatalf("SaveYAML failed: %v", err)
+	}
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("Unable to read saved config: %v", err)
+	}
+	var saved struct {
+		Include map[string]interface{} `yaml:"include"`
+		Server  map[string]interface{} `yaml:"server"`
+		App     map[string]interface{} `yaml:"app"`
+	}
+	if err = yaml.Unmarshal(b, &saved); err != nil {
+		t.Fatalf("Unable to parse saved config: %v", err)
+	}
+	if _, ok := saved.Server["bind"]; ok {
+		t.Errorf("Saved config copied the included bind:\n%s", b)
+	}
+	if saved.Server["port"] != 9000 || saved.App["site_name"] != "Common" {
+		t.Errorf("Saved port, site name = %v, %v; expected 9000 and the local site name", saved.Server["port"], saved.App["site_name"])
+	}
+	if saved.Include["path"] != "common.ini" {
+		t.Errorf("Saved config lost its include:\n%s", b)
+	}
+
+	loaded, err := LoadYAML(fname)
+	if err != nil {
+		t.Fatalf("LoadYAML after SaveYAML failed: %v", err)
+	}
+	if loaded.Server.Bind != "0.0.0.0" || loaded.Server.Port != 9000 {
+		t.Errorf("Bind, port = %s, %d; expected the included bind and the changed port", loaded.Server.Bind, loaded.Server.Port)
+	}
+}
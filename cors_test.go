@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		Desc        string
+		Origins     []string
+		Credentials bool
+		Method      string
+		Path        string
+		Origin      string
+
+		Status      int
+		AllowOrigin string
+		AllowCreds  string
+		AllowMethod string
+	}{
+		{"Allowed", []string{"https://app.example.com"}, false, "GET", "/api/me", "https://app.example.com",
+			http.StatusOK, "https://app.example.com", "", ""},
+		{"Allowed with credentials", []string{"https://app.example.com/"}, true, "GET", "/api/me", "https://app.example.com",
+			http.StatusOK, "https://app.example.com", "true", ""},
+		{"Disallowed", []string{"https://app.example.com"}, true, "GET", "/api/me", "https://evil.example.com",
+			http.StatusOK, "", "", ""},
+		{"No origin", []string{"https://app.example.com"}, false, "GET", "/api/me", "",
+			http.StatusOK, "", "", ""},
+		{"Outside the API", []string{"https://app.example.com"}, false, "GET", "/login", "https://app.example.com",
+			http.StatusOK, "", "", ""},
+		{"Wildcard", []string{"*"}, false, "GET", "/api/me", "https://any.example.com",
+			http.StatusOK, "*", "", ""},
+		{"Preflight", []string{"https://app.example.com"}, true, "OPTIONS", "/api/posts", "https://app.example.com",
+			http.StatusNoContent, "https://app.example.com", "true", corsAllowMethods},
+		{"Disallowed preflight", []string{"https://app.example.com"}, false, "OPTIONS", "/api/posts", "https://evil.example.com",
+			http.StatusNoContent, "", "", ""},
+	} {
+		r := mux.NewRouter()
+		r.Use(corsMiddleware(config.AppCfg{CORSOrigins: tc.Origins, CORSCredentials: tc.Credentials}))
+		r.PathPrefix("/api/").Methods("OPTIONS").HandlerFunc(handleCORSPreflight)
+		ok := func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hi"))
+		}
+		r.HandleFunc("/api/me", ok).Methods("GET")
+		r.HandleFunc("/api/posts", ok).Methods("POST")
+		r.HandleFunc("/login", ok).Methods("GET")
+
+		req := httptest.NewRequest(tc.Method, tc.Path, nil)
+		if tc.Origin != "" {
+			req.Header.Set("Origin", tc.Origin)
+		}
+		if tc.Method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.Status {
+			t.Errorf("%s: status = %d; expected %d", tc.Desc, w.Code, tc.Status)
+		}
+		for _, h := range []struct{ Name, Expected string }{
+			{"Access-Control-Allow-Origin", tc.AllowOrigin},
+			{"Access-Control-Allow-Credentials", tc.AllowCreds},
+			{"Access-Control-Allow-Methods", tc.AllowMethod},
+		} {
+			if got := w.Header().Get(h.Name); got != h.Expected {
+				t.Errorf("%s: %s = %q; expected %q", tc.Desc, h.Name, got, h.Expected)
+			}
+		}
+	}
+}
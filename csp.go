@@ -0,0 +1,55 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"strings"
+)
+
+// cspMiddleware sets the given Content-Security-Policy on every HTML
+// response.
+func cspMiddleware(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cspResponseWriter{ResponseWriter: w, policy: policy}, r)
+		})
+	}
+}
+
+// cspResponseWriter adds a Content-Security-Policy header before the
+// response is written, if the response is HTML.
+type cspResponseWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (cw *cspResponseWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if strings.HasPrefix(cw.Header().Get("Content-Type"), "text/html") {
+			cw.Header().Set("Content-Security-Policy", cw.policy)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cspResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			// Detect the type now, like the underlying ResponseWriter would
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
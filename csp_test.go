@@ -0,0 +1,53 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestCSPMiddleware(t *testing.T) {
+	policy := config.New().App.DefaultCSP()
+	newRouter := func(csp string) *mux.Router {
+		r := mux.NewRouter()
+		if csp != "" {
+			r.Use(cspMiddleware(csp))
+		}
+		r.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<!DOCTYPE HTML><html><body>Hi</body></html>"))
+		})
+		r.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"code":201}`))
+		})
+		return r
+	}
+
+	for _, tc := range []struct {
+		CSP, Path, Expected string
+	}{
+		{policy, "/page", policy},
+		{policy, "/api", ""},
+		{"", "/page", ""},
+	} {
+		w := httptest.NewRecorder()
+		newRouter(tc.CSP).ServeHTTP(w, httptest.NewRequest("GET", tc.Path, nil))
+		if h := w.Header().Get("Content-Security-Policy"); h != tc.Expected {
+			t.Errorf("CSP %q on %s: header = %q; expected %q", tc.CSP, tc.Path, h, tc.Expected)
+		}
+	}
+}
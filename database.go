@@ -11,10 +11,13 @@
 package writefreely
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/guregu/null"
@@ -89,7 +92,8 @@ type writestore interface {
 	GetCollection(alias string) (*Collection, error)
 	GetCollectionForPad(alias string) (*Collection, error)
 	GetCollectionByID(id int64) (*Collection, error)
-	UpdateCollection(c *SubmittedCollection, alias string) error
+	UpdateCollection(app *App, c *SubmittedCollection, alias string) error
+	SetCollectionAttribute(collID int64, attr string, on bool) error
 	DeleteCollection(alias string, userID int64) error
 
 	UpdatePostPinState(pinned bool, postID string, collID, ownerID, pos int64) error
@@ -108,6 +112,7 @@ type writestore interface {
 	GetPostsCount(c *CollectionObj, includeFuture bool)
 	GetPosts(cfg *config.Config, c *Collection, page int, includeFuture, forceRecentFirst, includePinned bool) (*[]PublicPost, error)
 	GetPostsTagged(cfg *config.Config, c *Collection, tag string, page int, includeFuture bool) (*[]PublicPost, error)
+	GetPostsDue(until time.Time) ([]PublicPost, error)
 
 	GetAPFollowers(c *Collection) (*[]RemoteUser, error)
 	GetAPActorKeys(collectionID int64) ([]byte, []byte)
@@ -130,6 +135,121 @@ type writestore interface {
 type datastore struct {
 	*sql.DB
 	driverName string
+
+	// replicas are read-only copies of the database that reader spreads
+	// reads across.
+	replicas    []*sql.DB
+	nextReplica uint32
+
+	// queryTimeout is how long a query can run before it's cancelled, or
+	// zero for no limit.
+	queryTimeout time.Duration
+}
+
+// reader returns the database to run a read on that can tolerate
+// replication lag: the next of the replicas, or the primary when there
+// aren't any.
+func (db *datastore) reader() *sql.DB {
+	if len(db.replicas) == 0 {
+		return db.DB
+	}
+	n := atomic.AddUint32(&db.nextReplica, 1)
+	return db.replicas[int(n)%len(db.replicas)]
+}
+
+// postsReader returns the database to list a blog's posts from. Including
+// future posts means the owner is looking, and they should see their changes
+// right away, so that's always read from the primary.
+func (db *datastore) postsReader(includeFuture bool) *sql.DB {
+	if includeFuture {
+		return db.DB
+	}
+	return db.reader()
+}
+
+// queryContext returns the context to run a query with, which has a
+// deadline when there's a query_timeout.
+func (db *datastore) queryContext() (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), db.queryTimeout)
+}
+
+// timeoutErr wraps err to say the query timed out when it was cancelled by
+// the query_timeout, and otherwise returns it unchanged.
+func (db *datastore) timeoutErr(err error) error {
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("Query timed out after %s: %w", db.queryTimeout, err)
+	}
+	return err
+}
+
+// Exec runs a statement on the primary within the query_timeout.
+func (db *datastore) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := db.queryContext()
+	defer cancel()
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	return res, db.timeoutErr(err)
+}
+
+// Query runs a query on the primary within the query_timeout.
+func (db *datastore) Query(query string, args ...interface{}) (*timedRows, error) {
+	return db.queryOn(db.DB, query, args...)
+}
+
+// QueryRow runs a query on the primary within the query_timeout.
+func (db *datastore) QueryRow(query string, args ...interface{}) *timedRow {
+	return db.queryRowOn(db.DB, query, args...)
+}
+
+// queryOn runs a query on the given database, like one from reader, within
+// the query_timeout.
+func (db *datastore) queryOn(d *sql.DB, query string, args ...interface{}) (*timedRows, error) {
+	ctx, cancel := db.queryContext()
+	rows, err := d.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, db.timeoutErr(err)
+	}
+	return &timedRows{Rows: rows, db: db, cancel: cancel}, nil
+}
+
+// queryRowOn runs a query expected to return at most one row on the given
+// database within the query_timeout.
+func (db *datastore) queryRowOn(d *sql.DB, query string, args ...interface{}) *timedRow {
+	ctx, cancel := db.queryContext()
+	return &timedRow{row: d.QueryRowContext(ctx, query, args...), db: db, cancel: cancel}
+}
+
+// timedRows are the results of a query with a deadline, which is released
+// when they're closed.
+type timedRows struct {
+	*sql.Rows
+	db     *datastore
+	cancel context.CancelFunc
+}
+
+func (r *timedRows) Err() error {
+	return r.db.timeoutErr(r.Rows.Err())
+}
+
+func (r *timedRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// timedRow is the result of a single-row query with a deadline, which is
+// released once it's scanned.
+type timedRow struct {
+	row    *sql.Row
+	db     *datastore
+	cancel context.CancelFunc
+}
+
+func (r *timedRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.db.timeoutErr(r.row.Scan(dest...))
 }
 
 func (db *datastore) now() string {
@@ -163,6 +283,13 @@ func (db *datastore) dateSub(l int, unit string) string {
 	return fmt.Sprintf("DATE_SUB(NOW(), INTERVAL %d %s)", l, unit)
 }
 
+func (db *datastore) dateAdd(l int, unit string) string {
+	if db.driverName == driverSQLite {
+		return fmt.Sprintf("DATETIME('now', '+%d %s')", l, unit)
+	}
+	return fmt.Sprintf("DATE_ADD(NOW(), INTERVAL %d %s)", l, unit)
+}
+
 func (db *datastore) CreateUser(cfg *config.Config, u *User, collectionTitle string) error {
 	if db.PostIDExists(u.Username) {
 		return impart.HTTPError{http.StatusConflict, "Invalid collection name."}
@@ -176,7 +303,7 @@ func (db *datastore) CreateUser(cfg *config.Config, u *User, collectionTitle str
 
 	// 1. Add to `users` table
 	// NOTE: Assumes User's Password is already hashed!
-	res, err := t.Exec("INSERT INTO users (username, password, email) VALUES (?, ?, ?)", u.Username, u.HashedPass, u.Email)
+	res, err := t.Exec("INSERT INTO users (username, password, email, status) VALUES (?, ?, ?, ?)", u.Username, u.HashedPass, u.Email, u.Status)
 	if err != nil {
 		t.Rollback()
 		if db.isDuplicateKeyErr(err) {
@@ -262,6 +389,18 @@ func (db *datastore) GetUserCollectionCount(userID int64) (uint64, error) {
 	return collCount, nil
 }
 
+// GetUserDraftsCount returns the number of posts the given user has that
+// aren't in a blog.
+func (db *datastore) GetUserDraftsCount(userID int64) (uint64, error) {
+	var count uint64
+	err := db.QueryRow("SELECT COUNT(*) FROM posts WHERE owner_id = ? AND collection_id IS NULL", userID).Scan(&count)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error("Couldn't get drafts count for user %d: %v", userID, err)
+		return 0, err
+	}
+	return count, nil
+}
+
 func (db *datastore) CreateCollection(cfg *config.Config, alias, title string, userID int64) (*Collection, error) {
 	if db.PostIDExists(alias) {
 		return nil, impart.HTTPError{http.StatusConflict, "Invalid collection name."}
@@ -290,6 +429,12 @@ func (db *datastore) CreateCollection(cfg *config.Config, alias, title string, u
 		log.Error("Couldn't get collection LastInsertId: %v\n", err)
 	}
 
+	for _, attr := range newCollectionAttributes(cfg) {
+		if err = db.SetCollectionAttribute(c.ID, attr, true); err != nil {
+			return nil, err
+		}
+	}
+
 	return c, nil
 }
 
@@ -548,7 +693,7 @@ func (db *datastore) GetTemporaryOneTimeAccessToken(userID int64, validSecs int,
 
 	expirationVal := "NULL"
 	if validSecs > 0 {
-		expirationVal = fmt.Sprintf("DATE_ADD("+db.now()+", INTERVAL %d SECOND)", validSecs)
+		expirationVal = db.dateAdd(validSecs, "second")
 	}
 
 	_, err = db.Exec("INSERT INTO accesstokens (token, user_id, one_time, expires) VALUES (?, ?, ?, "+expirationVal+")", string(binTok), userID, oneTime)
@@ -612,9 +757,13 @@ func (db *datastore) CreatePost(userID, collID int64, post *SubmittedPost) (*Pos
 		Valid: false,
 	}
 	slug := sql.NullString{"", false}
+	// Posts without an owner can only be changed with their modify token
+	modifyToken := sql.NullString{"", false}
 
 	// If an alias was supplied, we'll add this to the collection as well.
-	if userID > 0 {
+	if userID <= 0 {
+		modifyToken = sql.NullString{store.GenerateRandomString(modifyTokenChars, modifyTokenLen), true}
+	} else {
 		ownerID.Int64 = userID
 		ownerID.Valid = true
 		if collID > 0 {
@@ -653,18 +802,22 @@ func (db *datastore) CreatePost(userID, collID int64, post *SubmittedPost) (*Pos
 		}
 	}
 
-	stmt, err := db.Prepare("INSERT INTO posts (id, slug, title, content, text_appearance, language, rtl, privacy, owner_id, collection_id, created, updated, view_count) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, " + db.now() + ", ?)")
+	// Posts saved for the future are sent to followers by the scheduler when
+	// they come due, instead of when they're created
+	scheduled := created.After(time.Now())
+
+	stmt, err := db.Prepare("INSERT INTO posts (id, slug, modify_token, title, content, text_appearance, language, rtl, privacy, owner_id, collection_id, created, updated, view_count, scheduled) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, " + db.now() + ", ?, ?)")
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(friendlyID, slug, post.Title, post.Content, appearance, post.Language, post.IsRTL, 0, ownerID, ownerCollID, created, 0)
+	_, err = stmt.Exec(friendlyID, slug, modifyToken, post.Title, post.Content, appearance, post.Language, post.IsRTL, 0, ownerID, ownerCollID, created, 0, scheduled)
 	if err != nil {
 		if db.isDuplicateKeyErr(err) {
 			// Duplicate entry error; try a new slug
 			// TODO: make this a little more robust
 			slug = sql.NullString{id.GenSafeUniqueSlug(slug.String), true}
-			_, err = stmt.Exec(friendlyID, slug, post.Title, post.Content, appearance, post.Language, post.IsRTL, 0, ownerID, ownerCollID, created, 0)
+			_, err = stmt.Exec(friendlyID, slug, modifyToken, post.Title, post.Content, appearance, post.Language, post.IsRTL, 0, ownerID, ownerCollID, created, 0, scheduled)
 			if err != nil {
 				return nil, handleFailedPostInsert(fmt.Errorf("Retried slug generation, still failed: %v", err))
 			}
@@ -686,11 +839,13 @@ func (db *datastore) CreatePost(userID, collID int64, post *SubmittedPost) (*Pos
 		Updated:      time.Now().Truncate(time.Second).UTC(),
 		Title:        zero.NewString(*(post.Title), true),
 		Content:      *(post.Content),
+		Token:        modifyToken.String,
 	}, nil
 }
 
 // UpdateOwnedPost updates an existing post with only the given fields in the
-// supplied AuthenticatedPost.
+// supplied AuthenticatedPost. Without a userID, the post must have no owner
+// and the AuthenticatedPost must hold its modify token.
 func (db *datastore) UpdateOwnedPost(post *AuthenticatedPost, userID int64) error {
 	params := []interface{}{}
 	var queryUpdates, sep, authCondition string
@@ -730,17 +885,24 @@ func (db *datastore) UpdateOwnedPost(post *AuthenticatedPost, userID int64) erro
 			log.Error("Unable to parse Created date: %v", err)
 			return fmt.Errorf("That's the incorrect format for Created date.")
 		}
-		queryUpdates += sep + "created = ?"
+		queryUpdates += sep + "created = ?, scheduled = ?"
 		sep = ", "
-		params = append(params, createTime)
+		params = append(params, createTime, createTime.After(time.Now()))
 	}
 
 	// WHERE parameters...
 	// id = ?
 	params = append(params, post.ID)
-	// AND owner_id = ?
-	authCondition = "(owner_id = ?)"
-	params = append(params, userID)
+	var authParam interface{} = userID
+	if userID == 0 && post.Token != "" {
+		// AND modify_token = ? AND owner_id IS NULL
+		authCondition = "(modify_token = ? AND owner_id IS NULL)"
+		authParam = post.Token
+	} else {
+		// AND owner_id = ?
+		authCondition = "(owner_id = ?)"
+	}
+	params = append(params, authParam)
 
 	if queryUpdates == "" {
 		return ErrPostNoUpdatableVals
@@ -758,7 +920,7 @@ func (db *datastore) UpdateOwnedPost(post *AuthenticatedPost, userID int64) erro
 	if rowsAffected == 0 {
 		// Show the correct error message if nothing was updated
 		var dummy int
-		err := db.QueryRow("SELECT 1 FROM posts WHERE id = ? AND "+authCondition, post.ID, params[len(params)-1]).Scan(&dummy)
+		err := db.QueryRow("SELECT 1 FROM posts WHERE id = ? AND "+authCondition, post.ID, authParam).Scan(&dummy)
 		switch {
 		case err == sql.ErrNoRows:
 			return ErrUnauthorizedEditPost
@@ -826,7 +988,7 @@ func (db *datastore) GetCollectionFromDomain(host string) (*Collection, error) {
 	return db.GetCollectionBy("host = ?", host)
 }
 
-func (db *datastore) UpdateCollection(c *SubmittedCollection, alias string) error {
+func (db *datastore) UpdateCollection(app *App, c *SubmittedCollection, alias string) error {
 	q := query.NewUpdate().
 		SetStringPtr(c.Title, "title").
 		SetStringPtr(c.Description, "description").
@@ -866,20 +1028,11 @@ func (db *datastore) UpdateCollection(c *SubmittedCollection, alias string) erro
 	}
 
 	// Update MathJax value
-	if c.MathJax {
-		if db.driverName == driverSQLite {
-			_, err = db.Exec("INSERT OR REPLACE INTO collectionattributes (collection_id, attribute, value) VALUES (?, ?, ?)", collID, "render_mathjax", "1")
-		} else {
-			_, err = db.Exec("INSERT INTO collectionattributes (collection_id, attribute, value) VALUES (?, ?, ?) "+db.upsert("collection_id", "attribute")+" value = ?", collID, "render_mathjax", "1", "1")
-		}
-		if err != nil {
-			log.Error("Unable to insert render_mathjax value: %v", err)
-			return err
-		}
-	} else {
-		_, err = db.Exec("DELETE FROM collectionattributes WHERE collection_id = ? AND attribute = ?", collID, "render_mathjax")
-		if err != nil {
-			log.Error("Unable to delete render_mathjax value: %v", err)
+	if err = db.SetCollectionAttribute(collID, "render_mathjax", c.MathJax); err != nil {
+		return err
+	}
+	if c.Federation != nil {
+		if err = db.SetCollectionAttribute(collID, collAttrNoFederation, !*c.Federation); err != nil {
 			return err
 		}
 	}
@@ -908,7 +1061,7 @@ func (db *datastore) UpdateCollection(c *SubmittedCollection, alias string) erro
 	}
 
 	if updatePass {
-		hashedPass, err := auth.HashPass([]byte(c.Pass))
+		hashedPass, err := hashPassword(app.Config(), []byte(c.Pass))
 		if err != nil {
 			log.Error("Unable to create hash: %s", err)
 			return impart.HTTPError{http.StatusInternalServerError, "Could not create password hash."}
@@ -974,7 +1127,7 @@ func (db *datastore) GetPost(id string, collectionID int64) (*PublicPost, error)
 	var ownerName sql.NullString
 	p := &Post{}
 
-	var row *sql.Row
+	var row *timedRow
 	var where string
 	params := []interface{}{id}
 	if collectionID > 0 {
@@ -1012,7 +1165,7 @@ func (db *datastore) GetPost(id string, collectionID int64) (*PublicPost, error)
 func (db *datastore) GetOwnedPost(id string, ownerID int64) (*PublicPost, error) {
 	p := &Post{}
 
-	var row *sql.Row
+	var row *timedRow
 	where := "id = ? AND owner_id = ?"
 	params := []interface{}{id, ownerID}
 	row = db.QueryRow("SELECT "+postCols+" FROM posts WHERE "+where+" LIMIT 1", params...)
@@ -1044,7 +1197,7 @@ func (db *datastore) GetPostProperty(id string, collectionID int64, property str
 	}
 
 	var res interface{}
-	var row *sql.Row
+	var row *timedRow
 	if collectionID != 0 {
 		row = db.QueryRow("SELECT "+selectQuery+" FROM posts WHERE slug = ? AND collection_id = ? LIMIT 1", id, collectionID)
 	} else {
@@ -1071,7 +1224,7 @@ func (db *datastore) GetPostsCount(c *CollectionObj, includeFuture bool) {
 	if !includeFuture {
 		timeCondition = "AND created <= " + db.now()
 	}
-	err := db.QueryRow("SELECT COUNT(*) FROM posts WHERE collection_id = ? AND pinned_position IS NULL "+timeCondition, c.ID).Scan(&count)
+	err := db.queryRowOn(db.postsReader(includeFuture), "SELECT COUNT(*) FROM posts WHERE collection_id = ? AND pinned_position IS NULL "+timeCondition, c.ID).Scan(&count)
 	switch {
 	case err == sql.ErrNoRows:
 		c.TotalPosts = 0
@@ -1115,7 +1268,7 @@ func (db *datastore) GetPosts(cfg *config.Config, c *Collection, page int, inclu
 	if !includePinned {
 		pinnedCondition = "AND pinned_position IS NULL"
 	}
-	rows, err := db.Query("SELECT "+postCols+" FROM posts WHERE collection_id = ? "+pinnedCondition+" "+timeCondition+" ORDER BY created "+order+limitStr, collID)
+	rows, err := db.queryOn(db.postsReader(includeFuture), "SELECT "+postCols+" FROM posts WHERE collection_id = ? "+pinnedCondition+" "+timeCondition+" ORDER BY created "+order+limitStr, collID)
 	if err != nil {
 		log.Error("Failed selecting from posts: %v", err)
 		return nil, impart.HTTPError{http.StatusInternalServerError, "Couldn't retrieve collection posts."}
@@ -1173,12 +1326,12 @@ func (db *datastore) GetPostsTagged(cfg *config.Config, c *Collection, tag strin
 		timeCondition = "AND created <= " + db.now()
 	}
 
-	var rows *sql.Rows
+	var rows *timedRows
 	var err error
 	if db.driverName == driverSQLite {
-		rows, err = db.Query("SELECT "+postCols+" FROM posts WHERE collection_id = ? AND LOWER(content) regexp ? "+timeCondition+" ORDER BY created "+order+limitStr, collID, `.*#`+strings.ToLower(tag)+`\b.*`)
+		rows, err = db.queryOn(db.postsReader(includeFuture), "SELECT "+postCols+" FROM posts WHERE collection_id = ? AND LOWER(content) regexp ? "+timeCondition+" ORDER BY created "+order+limitStr, collID, `.*#`+strings.ToLower(tag)+`\b.*`)
 	} else {
-		rows, err = db.Query("SELECT "+postCols+" FROM posts WHERE collection_id = ? AND LOWER(content) RLIKE ? "+timeCondition+" ORDER BY created "+order+limitStr, collID, "#"+strings.ToLower(tag)+"[[:>:]]")
+		rows, err = db.queryOn(db.postsReader(includeFuture), "SELECT "+postCols+" FROM posts WHERE collection_id = ? AND LOWER(content) RLIKE ? "+timeCondition+" ORDER BY created "+order+limitStr, collID, "#"+strings.ToLower(tag)+"[[:>:]]")
 	}
 	if err != nil {
 		log.Error("Failed selecting from posts: %v", err)
@@ -1208,6 +1361,47 @@ func (db *datastore) GetPostsTagged(cfg *config.Config, c *Collection, tag strin
 	return &posts, nil
 }
 
+// GetPostsDue retrieves the scheduled blog posts that are due by until and
+// haven't been published yet. Only posts that were in the future when they
+// were saved are included, since the others were published right away.
+func (db *datastore) GetPostsDue(until time.Time) ([]PublicPost, error) {
+	rows, err := db.Query("SELECT "+postCols+" FROM posts WHERE collection_id IS NOT NULL AND scheduled = 1 AND created <= ? ORDER BY created ASC", until.UTC())
+	if err != nil {
+		log.Error("Failed selecting due posts: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PublicPost{}
+	for rows.Next() {
+		p := &Post{}
+		err = rows.Scan(&p.ID, &p.Slug, &p.Font, &p.Language, &p.RTL, &p.Privacy, &p.OwnerID, &p.CollectionID, &p.PinnedPosition, &p.Created, &p.Updated, &p.ViewCount, &p.Title, &p.Content)
+		if err != nil {
+			log.Error("Failed scanning row: %v", err)
+			return nil, err
+		}
+		p.extractData()
+		posts = append(posts, p.processPost())
+	}
+	return posts, rows.Err()
+}
+
+// ClaimScheduledPost marks the given scheduled post as published, so it's
+// only published once when several app instances share the database. It
+// returns false if the post was already claimed.
+func (db *datastore) ClaimScheduledPost(id string) (bool, error) {
+	res, err := db.Exec("UPDATE posts SET scheduled = 0 WHERE id = ? AND scheduled = 1", id)
+	if err != nil {
+		log.Error("Failed claiming scheduled post: %v", err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 func (db *datastore) GetAPFollowers(c *Collection) (*[]RemoteUser, error) {
 	rows, err := db.Query("SELECT actor_id, inbox, shared_inbox FROM remotefollows f INNER JOIN remoteusers u ON f.remote_user_id = u.id WHERE collection_id = ?", c.ID)
 	if err != nil {
@@ -1844,7 +2038,7 @@ func (db *datastore) ChangeSettings(app *App, u *User, s *userSettings) error {
 			// Username is invalid
 			return *ie
 		}
-		if !author.IsValidUsername(app.cfg, newUsername) {
+		if !author.IsValidUsername(app.Config(), newUsername) {
 			// Ensure the username is syntactically correct.
 			return impart.HTTPError{http.StatusPreconditionFailed, "Username isn't valid."}
 		}
@@ -1898,6 +2092,11 @@ func (db *datastore) ChangeSettings(app *App, u *User, s *userSettings) error {
 
 	// Update passphrase if given
 	if s.NewPass != "" {
+		if err := app.Config().App.ValidatePassword(s.NewPass); err != nil {
+			errPass = impart.HTTPError{http.StatusBadRequest, err.Error()}
+			return errPass
+		}
+
 		// Check if user has already set a password
 		var err error
 		u.HasPass, err = db.IsUserPassSet(u.ID)
@@ -1922,7 +2121,7 @@ func (db *datastore) ChangeSettings(app *App, u *User, s *userSettings) error {
 				return errPass
 			}
 		}
-		hashedPass, err := auth.HashPass([]byte(s.NewPass))
+		hashedPass, err := hashPassword(app.Config(), []byte(s.NewPass))
 		if err != nil {
 			errPass = impart.HTTPError{http.StatusInternalServerError, "Could not create password hash."}
 			return errPass
@@ -2078,6 +2277,27 @@ func (db *datastore) DeleteCollection(alias string, userID int64) error {
 	return nil
 }
 
+// SetCollectionAttribute turns the given collection attribute on or off.
+func (db *datastore) SetCollectionAttribute(collID int64, attr string, on bool) error {
+	var err error
+	if on {
+		if db.driverName == driverSQLite {
+			_, err = db.Exec("INSERT OR REPLACE INTO collectionattributes (collection_id, attribute, value) VALUES (?, ?, ?)", collID, attr, "1")
+		} else {
+			_, err = db.Exec("INSERT INTO collectionattributes (collection_id, attribute, value) VALUES (?, ?, ?) "+db.upsert("collection_id", "attribute")+" value = ?", collID, attr, "1", "1")
+		}
+		if err != nil {
+			log.Error("Unable to insert %s value: %v", attr, err)
+		}
+		return err
+	}
+	_, err = db.Exec("DELETE FROM collectionattributes WHERE collection_id = ? AND attribute = ?", collID, attr)
+	if err != nil {
+		log.Error("Unable to delete %s value: %v", attr, err)
+	}
+	return err
+}
+
 func (db *datastore) IsCollectionAttributeOn(id int64, attr string) bool {
 	var v string
 	err := db.QueryRow("SELECT value FROM collectionattributes WHERE collection_id = ? AND attribute = ?", id, attr).Scan(&v)
@@ -2314,6 +2534,30 @@ func (db *datastore) CreateInvitedUser(inviteID string, userID int64) error {
 	return err
 }
 
+// GetIDForRemoteUser returns the ID of the local user linked to the given
+// OAuth provider user ID, or -1 if there isn't one.
+func (db *datastore) GetIDForRemoteUser(remoteUserID string) (int64, error) {
+	var userID int64 = -1
+	err := db.QueryRow("SELECT user_id FROM oauth_users WHERE remote_user_id = ?", remoteUserID).Scan(&userID)
+	switch {
+	case err == sql.ErrNoRows:
+		return -1, nil
+	case err != nil:
+		log.Error("Couldn't get user for remote user %s: %v", remoteUserID, err)
+		return -1, err
+	}
+	return userID, nil
+}
+
+// RecordRemoteUserID links the given local user to an OAuth provider user ID.
+func (db *datastore) RecordRemoteUserID(localUserID int64, remoteUserID string) error {
+	_, err := db.Exec("INSERT INTO oauth_users (user_id, remote_user_id) VALUES (?, ?)", localUserID, remoteUserID)
+	if err != nil && db.isDuplicateKeyErr(err) {
+		return impart.HTTPError{http.StatusConflict, "This account is already linked to another login."}
+	}
+	return err
+}
+
 func (db *datastore) GetInstancePages() ([]*instanceContent, error) {
 	return db.GetAllDynamicContent("page")
 }
@@ -2442,7 +2686,7 @@ func (db *datastore) SetUserStatus(id int64, status UserStatus) error {
 
 func (db *datastore) GetCollectionLastPostTime(id int64) (*time.Time, error) {
 	var t time.Time
-	err := db.QueryRow("SELECT created FROM posts WHERE collection_id = ? ORDER BY created DESC LIMIT 1", id).Scan(&t)
+	err := db.queryRowOn(db.reader(), "SELECT created FROM posts WHERE collection_id = ? ORDER BY created DESC LIMIT 1", id).Scan(&t)
 	switch {
 	case err == sql.ErrNoRows:
 		return nil, nil
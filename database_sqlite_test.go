@@ -0,0 +1,239 @@
+// +build sqlite
+
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/writeas/writefreely/config"
+	"github.com/writeas/writefreely/migrations"
+)
+
+// newTestSQLiteDB returns a datastore for a new in-memory SQLite database
+// with the full schema, the way adminInitDatabase sets one up.
+func newTestSQLiteDB(t *testing.T) *datastore {
+	db, err := sql.Open("sqlite3_with_regex", "file::memory:?parseTime=true")
+	if err != nil {
+		t.Fatalf("Unable to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	schema, err := ioutil.ReadFile("sqlite.sql")
+	if err != nil {
+		t.Fatalf("Unable to read schema: %v", err)
+	}
+	for _, q := range strings.Split(string(schema), ";\n") {
+		if strings.TrimSpace(q) == "" {
+			continue
+		}
+		if _, err = db.Exec(q); err != nil {
+			t.Fatalf("Unable to create schema: %v", err)
+		}
+	}
+	ds := &datastore{DB: db, driverName: driverSQLite}
+	if err = migrations.SetInitialMigrations(migrations.NewDatastore(db, driverSQLite)); err != nil {
+		t.Fatalf("Unable to set initial migrations: %v", err)
+	}
+	if err = migrations.Migrate(migrations.NewDatastore(db, driverSQLite)); err != nil {
+		t.Fatalf("Unable to migrate: %v", err)
+	}
+	return ds
+}
+
+// newTestBlog creates a user along with their blog, and returns the user.
+func newTestBlog(t *testing.T, db *datastore, username string) *User {
+	u := &User{Username: username, HashedPass: []byte("x"), Created: time.Now()}
+	if err := db.CreateUser(config.New(), u, username); err != nil {
+		t.Fatalf("Unable to create user: %v", err)
+	}
+	return u
+}
+
+func TestGetPostsDue(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	defer db.Close()
+	u := newTestBlog(t, db, "matt")
+	coll, err := db.GetCollection("matt")
+	if err != nil {
+		t.Fatalf("Unable to get blog: %v", err)
+	}
+
+	title, content := "", "Hello"
+	now, err := db.CreatePost(u.ID, coll.ID, &SubmittedPost{Title: &title, Content: &content})
+	if err != nil {
+		t.Fatalf("Unable to create post: %v", err)
+	}
+	due := time.Now().Add(time.Minute).UTC().Format("2006-01-02T15:04:05Z")
+	later, err := db.CreatePost(u.ID, coll.ID, &SubmittedPost{Title: &title, Content: &content, Created: &due})
+	if err != nil {
+		t.Fatalf("Unable to create scheduled post: %v", err)
+	}
+
+	posts, err := db.GetPostsDue(time.Now().Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("GetPostsDue failed: %v", err)
+	}
+	if len(posts) != 1 || posts[0].ID != later.ID {
+		ids := []string{}
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		t.Errorf("Due posts = %v; expected only the scheduled post %s, not %s, which was published when it was created", ids, later.ID, now.ID)
+	}
+}
+
+func TestPublishOverduePosts(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	defer db.Close()
+	u := newTestBlog(t, db, "matt")
+	coll, err := db.GetCollection("matt")
+	if err != nil {
+		t.Fatalf("Unable to get blog: %v", err)
+	}
+	title, content := "", "Hello"
+	due := time.Now().Add(time.Minute).UTC().Format("2006-01-02T15:04:05Z")
+	p, err := db.CreatePost(u.ID, coll.ID, &SubmittedPost{Title: &title, Content: &content, Created: &due})
+	if err != nil {
+		t.Fatalf("Unable to create scheduled post: %v", err)
+	}
+	// The post came due an hour ago, while the app wasn't running
+	if _, err = db.Exec("UPDATE posts SET created = ? WHERE id = ?", time.Now().Add(-time.Hour).UTC(), p.ID); err != nil {
+		t.Fatalf("Unable to backdate post: %v", err)
+	}
+
+	published := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil && payload.Post != nil {
+			published <- payload.Post.ID
+		}
+	}))
+	defer srv.Close()
+	cfg := config.New()
+	cfg.App.Federation = false
+	cfg.App.ScheduledPublishInterval = "10ms"
+	cfg.Webhook = config.WebhookCfg{URL: srv.URL, Events: []string{config.WebhookPostPublished}}
+	app := newTestApp(cfg)
+	app.db = db
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go newPostScheduler(app).run(stop)
+	select {
+	case id := <-published:
+		if id != p.ID {
+			t.Errorf("Published post %s; expected %s", id, p.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Overdue post %s was never published", p.ID)
+	}
+}
+
+func TestUpdateAnonymousPost(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	defer db.Close()
+	app := &App{
+		db:           db,
+		sessionStore: sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef")),
+	}
+	cfg := config.New()
+	cfg.App.AllowAnonymous = true
+	app.SetConfig(cfg)
+	title, content := "", "Just passing through."
+	p, err := db.CreatePost(-1, -1, &SubmittedPost{Title: &title, Content: &content})
+	if err != nil {
+		t.Fatalf("Unable to create post: %v", err)
+	}
+	update := func(token string) error {
+		r := httptest.NewRequest("POST", "/api/posts/"+p.ID, strings.NewReader(`{"token":"`+token+`","body":"Edited."}`))
+		r.Header.Set("Content-Type", "application/json")
+		r = mux.SetURLVars(r, map[string]string{"post": p.ID})
+		return existingPost(app, httptest.NewRecorder(), r)
+	}
+
+	if err = update("wrong"); err != ErrUnauthorizedEditPost {
+		t.Errorf("Update with the wrong token returned %v; expected %v", err, ErrUnauthorizedEditPost)
+	}
+	if err = update(p.Token); err != nil {
+		t.Fatalf("Update with the modify token failed: %v", err)
+	}
+	updated, err := db.GetPost(p.ID, 0)
+	if err != nil {
+		t.Fatalf("Unable to get post: %v", err)
+	}
+	if updated.Content != "Edited." {
+		t.Errorf("Post content = %q; expected the update", updated.Content)
+	}
+
+	// Turning off anonymous posting stops anonymous edits too
+	app.updateConfig(func(cfg *config.Config) {
+		cfg.App.AllowAnonymous = false
+	})
+	if err = update(p.Token); err != ErrNoAccessToken {
+		t.Errorf("Update with anonymous posting off returned %v; expected %v", err, ErrNoAccessToken)
+	}
+	app.updateConfig(func(cfg *config.Config) {
+		cfg.App.AllowAnonymous = true
+	})
+
+	// Claimed posts need their owner's credentials
+	u := newTestBlog(t, db, "matt")
+	if _, err = db.Exec("UPDATE posts SET owner_id = ? WHERE id = ?", u.ID, p.ID); err != nil {
+		t.Fatalf("Unable to claim post: %v", err)
+	}
+	if err = update(p.Token); err != ErrUnauthorizedEditPost {
+		t.Errorf("Update of a claimed post with its token returned %v; expected %v", err, ErrUnauthorizedEditPost)
+	}
+}
+
+func TestClaimScheduledPost(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	defer db.Close()
+	u := newTestBlog(t, db, "matt")
+	coll, err := db.GetCollection("matt")
+	if err != nil {
+		t.Fatalf("Unable to get blog: %v", err)
+	}
+	title, content := "", "Hello"
+	due := time.Now().Add(time.Minute).UTC().Format("2006-01-02T15:04:05Z")
+	p, err := db.CreatePost(u.ID, coll.ID, &SubmittedPost{Title: &title, Content: &content, Created: &due})
+	if err != nil {
+		t.Fatalf("Unable to create scheduled post: %v", err)
+	}
+
+	// Only the first of several app instances gets to publish it
+	for i, expected := range []bool{true, false} {
+		ok, err := db.ClaimScheduledPost(p.ID)
+		if err != nil {
+			t.Fatalf("ClaimScheduledPost failed: %v", err)
+		}
+		if ok != expected {
+			t.Errorf("Claim #%d = %t; expected %t", i+1, ok, expected)
+		}
+	}
+	posts, err := db.GetPostsDue(time.Now().Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("GetPostsDue failed: %v", err)
+	}
+	if len(posts) != 0 {
+		t.Errorf("Claimed post is still due")
+	}
+}
@@ -0,0 +1,273 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/writeas/impart"
+	"github.com/writeas/writefreely/config"
+)
+
+// fakeDB is an in-memory stand-in for a database, opened through the
+// "wffake" driver, so handlers can be tested without a database server. By
+// default, every query returns a single count of 7 and every other statement
+// affects one row; Exec and Query override that. It records the statements
+// run on it, so tests can check which database a query went to.
+type fakeDB struct {
+	Exec  func(query string, args []driver.Value) (driver.Result, error)
+	Query func(query string, args []driver.Value) (driver.Rows, error)
+
+	// Slow makes statements wait until their context is done.
+	Slow bool
+
+	mu      sync.Mutex
+	queries []string
+}
+
+var fakeDBs sync.Map
+
+// open returns a connection pool for db, which is closed when the test ends.
+func (db *fakeDB) open(t *testing.T) *sql.DB {
+	name := fmt.Sprintf("%p", db)
+	fakeDBs.Store(name, db)
+	sdb, err := sql.Open("wffake", name)
+	if err != nil {
+		t.Fatalf("Unable to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		sdb.Close()
+		fakeDBs.Delete(name)
+	})
+	return sdb
+}
+
+// statements returns the number of statements run on db.
+func (db *fakeDB) statements() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.queries)
+}
+
+// lastQuery returns the last statement run on db.
+func (db *fakeDB) lastQuery() string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.queries) == 0 {
+		return ""
+	}
+	return db.queries[len(db.queries)-1]
+}
+
+// run records the given statement and returns its arguments.
+func (db *fakeDB) run(ctx context.Context, query string, args []driver.NamedValue) ([]driver.Value, error) {
+	if db.Slow {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("query wasn't cancelled")
+		}
+	}
+	vals := make([]driver.Value, len(args))
+	for i := range args {
+		vals[i] = args[i].Value
+	}
+	db.mu.Lock()
+	db.queries = append(db.queries, query)
+	db.mu.Unlock()
+	return vals, nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("no fake database %q", name)
+	}
+	return fakeConn{db.(*fakeDB)}, nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c, query}, nil }
+func (fakeConn) Close() error                                { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                   { return nil, errors.New("not supported") }
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	vals, err := c.db.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.db.Exec != nil {
+		return c.db.Exec(query, vals)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	vals, err := c.db.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.db.Query != nil {
+		return c.db.Query(query, vals)
+	}
+	return &fakeRows{cols: []string{"count"}, vals: []driver.Value{int64(7)}}, nil
+}
+
+type fakeStmt struct {
+	conn  fakeConn
+	query string
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// fakeRows is a result with at most one row.
+type fakeRows struct {
+	cols []string
+	vals []driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (*fakeRows) Close() error        { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.vals == nil {
+		return io.EOF
+	}
+	copy(dest, r.vals)
+	r.vals = nil
+	return nil
+}
+
+func init() {
+	sql.Register("wffake", fakeDriver{})
+}
+
+func TestReadReplicas(t *testing.T) {
+	primary, replica1, replica2 := &fakeDB{}, &fakeDB{}, &fakeDB{}
+	db := &datastore{DB: primary.open(t), driverName: "wffake"}
+	if db.reader() != db.DB {
+		t.Error("Reads without replicas don't use the primary")
+	}
+	db.replicas = []*sql.DB{replica1.open(t), replica2.open(t)}
+
+	for i := 0; i < 4; i++ {
+		c := &CollectionObj{}
+		db.GetPostsCount(c, false)
+		if c.TotalPosts != 7 {
+			t.Errorf("TotalPosts = %d; expected 7", c.TotalPosts)
+		}
+	}
+	db.GetPostsCount(&CollectionObj{}, true)
+	if err := db.SetCollectionAttribute(1, collAttrNoFederation, true); err != nil {
+		t.Fatalf("SetCollectionAttribute failed: %v", err)
+	}
+
+	for name, fdb := range map[string]*fakeDB{"primary": primary, "replica1": replica1, "replica2": replica2} {
+		if n := fdb.statements(); n != 2 {
+			t.Errorf("%s ran %d statements; expected 2", name, n)
+		}
+	}
+}
+
+func TestDraftLimit(t *testing.T) {
+	db := &fakeDB{}
+	app := newTestApp(config.New())
+	app.db = &datastore{DB: db.open(t), driverName: "wffake"}
+
+	// The database always has 7 drafts
+	for _, tc := range []struct {
+		MaxDrafts int
+		Allowed   bool
+	}{
+		{0, true},
+		{8, true},
+		{7, false},
+	} {
+		app.Config().App.MaxDrafts = tc.MaxDrafts
+		err := checkDraftLimit(app, 1)
+		if (err == nil) != tc.Allowed {
+			t.Errorf("max_drafts %d: error %v; expected allowed = %t", tc.MaxDrafts, err, tc.Allowed)
+		}
+		if herr, ok := err.(impart.HTTPError); err != nil && (!ok || herr.Status != http.StatusForbidden) {
+			t.Errorf("max_drafts %d: error %v; expected a 403", tc.MaxDrafts, err)
+		}
+	}
+
+	// Published posts don't count toward the limit
+	if q := db.lastQuery(); !strings.Contains(q, "collection_id IS NULL") {
+		t.Errorf("Drafts count query %q doesn't exclude posts in blogs", q)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	slow := &fakeDB{Slow: true}
+	db := &datastore{DB: slow.open(t), driverName: "wffake", queryTimeout: 50 * time.Millisecond}
+
+	var n int64
+	for name, query := range map[string]func() error{
+		"Exec": func() error {
+			_, err := db.Exec("UPDATE posts SET view_count = view_count + 1")
+			return err
+		},
+		"Query": func() error {
+			_, err := db.Query("SELECT id FROM posts")
+			return err
+		},
+		"QueryRow": func() error {
+			return db.QueryRow("SELECT COUNT(*) FROM posts").Scan(&n)
+		},
+		"Read": func() error {
+			return db.queryRowOn(db.reader(), "SELECT COUNT(*) FROM posts").Scan(&n)
+		},
+	} {
+		start := time.Now()
+		err := query()
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s took %s; expected it to be cancelled after 50ms", name, elapsed)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s returned %v; expected a deadline error", name, err)
+		}
+		if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+			t.Errorf("%s returned %v; expected it to say the query timed out", name, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import "sync"
+
+// deliveryPool bounds how many activities are delivered to other instances
+// at once, across every post being federated. A nil deliveryPool delivers
+// to each inbox in turn, without a limit.
+type deliveryPool struct {
+	slots chan struct{}
+}
+
+// newDeliveryPool returns a pool that runs up to max deliveries at once, or
+// nil if max is zero.
+func newDeliveryPool(max int) *deliveryPool {
+	if max <= 0 {
+		return nil
+	}
+	return &deliveryPool{slots: make(chan struct{}, max)}
+}
+
+// deliver calls send for each of the given inboxes, returning once they've
+// all been sent to.
+func (p *deliveryPool) deliver(inboxes []string, send func(inbox string)) {
+	if p == nil {
+		for _, inbox := range inboxes {
+			send(inbox)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, inbox := range inboxes {
+		p.slots <- struct{}{}
+		wg.Add(1)
+		go func(inbox string) {
+			defer func() {
+				<-p.slots
+				wg.Done()
+			}()
+			send(inbox)
+		}(inbox)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeliveryPool(t *testing.T) {
+	inboxes := make([]string, 20)
+	for i := range inboxes {
+		inboxes[i] = fmt.Sprintf("https://%d.example.com/inbox", i)
+	}
+
+	for _, tc := range []struct {
+		Max      int
+		Expected int
+	}{
+		// Without a limit, each post is delivered one inbox at a time
+		{0, 2},
+		{1, 1},
+		{3, 3},
+	} {
+		var mu sync.Mutex
+		running, most := 0, 0
+		sent := map[string]int{}
+		send := func(inbox string) {
+			mu.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			sent[inbox]++
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}
+
+		// Posts are federated in their own goroutines, so the limit has to
+		// hold across them
+		p := newDeliveryPool(tc.Max)
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(inboxes []string) {
+				defer wg.Done()
+				p.deliver(inboxes, send)
+			}(inboxes[i*10 : (i+1)*10])
+		}
+		wg.Wait()
+
+		if most > tc.Expected {
+			t.Errorf("Max %d: %d deliveries ran at once; expected no more than %d", tc.Max, most, tc.Expected)
+		}
+		if len(sent) != len(inboxes) {
+			t.Errorf("Max %d: delivered to %d inboxes; expected %d", tc.Max, len(sent), len(inboxes))
+		}
+		for inbox, n := range sent {
+			if n != 1 {
+				t.Errorf("Max %d: delivered to %s %d times", tc.Max, inbox, n)
+			}
+		}
+	}
+}
@@ -25,12 +25,18 @@ var (
 	ErrNoAccessToken  = impart.HTTPError{http.StatusBadRequest, "Authorization token required."}
 	ErrNotLoggedIn    = impart.HTTPError{http.StatusUnauthorized, "Not logged in."}
 
+	ErrTooManyRequests = impart.HTTPError{http.StatusTooManyRequests, "Too many requests. Please try again later."}
+
 	ErrForbiddenCollection        = impart.HTTPError{http.StatusForbidden, "You don't have permission to add to this collection."}
 	ErrForbiddenEditPost          = impart.HTTPError{http.StatusForbidden, "You don't have permission to update this post."}
 	ErrUnauthorizedEditPost       = impart.HTTPError{http.StatusUnauthorized, "Invalid editing credentials."}
 	ErrUnauthorizedGeneral        = impart.HTTPError{http.StatusUnauthorized, "You don't have permission to do that."}
 	ErrBadRequestedType           = impart.HTTPError{http.StatusNotAcceptable, "Bad requested Content-Type."}
 	ErrCollectionUnauthorizedRead = impart.HTTPError{http.StatusUnauthorized, "You don't have permission to access this collection."}
+	ErrInstanceNotAllowed         = impart.HTTPError{http.StatusForbidden, "This instance doesn't federate with yours."}
+	ErrSignatureAlgNotAllowed     = impart.HTTPError{http.StatusUnauthorized, "Activity is signed with an algorithm this instance doesn't accept."}
+	ErrSignatureMissing           = impart.HTTPError{http.StatusUnauthorized, "Activity isn't signed."}
+	ErrSignatureInvalid           = impart.HTTPError{http.StatusUnauthorized, "Activity signature couldn't be verified."}
 
 	ErrNoPublishableContent = impart.HTTPError{http.StatusBadRequest, "Supply something to publish."}
 
@@ -45,10 +51,14 @@ var (
 	ErrPostUnpublished        = impart.HTTPError{Status: http.StatusGone, Message: "Post unpublished by author."}
 	ErrPostFetchError         = impart.HTTPError{Status: http.StatusInternalServerError, Message: "We encountered an error getting the post. The humans have been alerted."}
 
+	ErrSignupClosed      = impart.HTTPError{http.StatusForbidden, "Registration is closed."}
+	ErrInviteRequired    = impart.HTTPError{http.StatusForbidden, "An invite code is required to sign up."}
+	ErrInviteExpired     = impart.HTTPError{http.StatusForbidden, "This invite link has expired."}
 	ErrUserNotFound      = impart.HTTPError{http.StatusNotFound, "User doesn't exist."}
 	ErrUserNotFoundEmail = impart.HTTPError{http.StatusNotFound, "Please enter your username instead of your email address."}
 
 	ErrUserSuspended = impart.HTTPError{http.StatusForbidden, "Account is silenced."}
+	ErrUserPending   = impart.HTTPError{http.StatusForbidden, "Your account is awaiting approval by an admin."}
 )
 
 // Post operation errors
@@ -73,7 +73,7 @@ type (
 
 type Handler struct {
 	errors       *ErrorPages
-	sessionStore *sessions.CookieStore
+	sessionStore sessions.Store
 	app          Apper
 }
 
@@ -137,7 +137,7 @@ func (h *Handler) User(f userHandlerFunc) http.HandlerFunc {
 					status = http.StatusInternalServerError
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			u := getUserSession(h.app.App(), r)
@@ -175,7 +175,7 @@ func (h *Handler) Admin(f userHandlerFunc) http.HandlerFunc {
 					status = http.StatusInternalServerError
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			u := getUserSession(h.app.App(), r)
@@ -213,7 +213,7 @@ func (h *Handler) AdminApper(f userApperHandlerFunc) http.HandlerFunc {
 					status = http.StatusInternalServerError
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			u := getUserSession(h.app.App(), r)
@@ -295,7 +295,7 @@ func (h *Handler) UserAll(web bool, f userHandlerFunc, a authFunc) http.HandlerF
 					status = 500
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			u, err := a(h.app.App(), r)
@@ -381,26 +381,26 @@ func (h *Handler) WebErrors(f handlerFunc, ul UserLevelFunc) http.HandlerFunc {
 					status = 500
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			var session *sessions.Session
 			var err error
-			if ul(h.app.App().cfg) != UserLevelNoneType {
-				session, err = h.sessionStore.Get(r, cookieName)
-				if err != nil && (ul(h.app.App().cfg) == UserLevelNoneRequiredType || ul(h.app.App().cfg) == UserLevelUserType) {
+			if ul(h.app.App().Config()) != UserLevelNoneType {
+				session, err = h.sessionStore.Get(r, h.app.App().Config().Session.Name())
+				if err != nil && (ul(h.app.App().Config()) == UserLevelNoneRequiredType || ul(h.app.App().Config()) == UserLevelUserType) {
 					// Cookie is required, but we can ignore this error
-					log.Error("Handler: Unable to get session (for user permission %d); ignoring: %v", ul(h.app.App().cfg), err)
+					log.Error("Handler: Unable to get session (for user permission %d); ignoring: %v", ul(h.app.App().Config()), err)
 				}
 
 				_, gotUser := session.Values[cookieUserVal].(*User)
-				if ul(h.app.App().cfg) == UserLevelNoneRequiredType && gotUser {
+				if ul(h.app.App().Config()) == UserLevelNoneRequiredType && gotUser {
 					to := correctPageFromLoginAttempt(r)
 					log.Info("Handler: Required NO user, but got one. Redirecting to %s", to)
 					err := impart.HTTPError{http.StatusFound, to}
 					status = err.Status
 					return err
-				} else if ul(h.app.App().cfg) == UserLevelUserType && !gotUser {
+				} else if ul(h.app.App().Config()) == UserLevelUserType && !gotUser {
 					log.Info("Handler: Required a user, but DIDN'T get one. Sending not logged in.")
 					err := ErrNotLoggedIn
 					status = err.Status
@@ -440,7 +440,7 @@ func (h *Handler) CollectionPostOrStatic(w http.ResponseWriter, r *http.Request)
 		start := time.Now()
 		status := 200
 		defer func() {
-			log.Info(h.app.ReqLog(r, status, time.Since(start)))
+			h.logRequest(r, status, start)
 		}()
 
 		// Serve static file
@@ -472,24 +472,24 @@ func (h *Handler) Web(f handlerFunc, ul UserLevelFunc) http.HandlerFunc {
 					status = 500
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
-			if ul(h.app.App().cfg) != UserLevelNoneType {
-				session, err := h.sessionStore.Get(r, cookieName)
-				if err != nil && (ul(h.app.App().cfg) == UserLevelNoneRequiredType || ul(h.app.App().cfg) == UserLevelUserType) {
+			if ul(h.app.App().Config()) != UserLevelNoneType {
+				session, err := h.sessionStore.Get(r, h.app.App().Config().Session.Name())
+				if err != nil && (ul(h.app.App().Config()) == UserLevelNoneRequiredType || ul(h.app.App().Config()) == UserLevelUserType) {
 					// Cookie is required, but we can ignore this error
-					log.Error("Handler: Unable to get session (for user permission %d); ignoring: %v", ul(h.app.App().cfg), err)
+					log.Error("Handler: Unable to get session (for user permission %d); ignoring: %v", ul(h.app.App().Config()), err)
 				}
 
 				_, gotUser := session.Values[cookieUserVal].(*User)
-				if ul(h.app.App().cfg) == UserLevelNoneRequiredType && gotUser {
+				if ul(h.app.App().Config()) == UserLevelNoneRequiredType && gotUser {
 					to := correctPageFromLoginAttempt(r)
 					log.Info("Handler: Required NO user, but got one. Redirecting to %s", to)
 					err := impart.HTTPError{http.StatusFound, to}
 					status = err.Status
 					return err
-				} else if ul(h.app.App().cfg) == UserLevelUserType && !gotUser {
+				} else if ul(h.app.App().Config()) == UserLevelUserType && !gotUser {
 					log.Info("Handler: Required a user, but DIDN'T get one. Sending not logged in.")
 					err := ErrNotLoggedIn
 					status = err.Status
@@ -530,7 +530,7 @@ func (h *Handler) All(f handlerFunc) http.HandlerFunc {
 					status = 500
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			// TODO: do any needed authentication
@@ -562,10 +562,10 @@ func (h *Handler) AllReader(f handlerFunc) http.HandlerFunc {
 					status = 500
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
-			if h.app.App().cfg.App.Private {
+			if h.app.App().Config().App.Private {
 				// This instance is private, so ensure it's being accessed by a valid user
 				// Check if authenticated with an access token
 				_, apiErr := optionalAPIAuth(h.app.App(), r)
@@ -619,7 +619,7 @@ func (h *Handler) Download(f dataHandlerFunc, ul UserLevelFunc) http.HandlerFunc
 					status = 500
 				}
 
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
 			data, filename, err := f(h.app.App(), w, r)
@@ -658,21 +658,21 @@ func (h *Handler) Redirect(url string, ul UserLevelFunc) http.HandlerFunc {
 			start := time.Now()
 
 			var status int
-			if ul(h.app.App().cfg) != UserLevelNoneType {
-				session, err := h.sessionStore.Get(r, cookieName)
-				if err != nil && (ul(h.app.App().cfg) == UserLevelNoneRequiredType || ul(h.app.App().cfg) == UserLevelUserType) {
+			if ul(h.app.App().Config()) != UserLevelNoneType {
+				session, err := h.sessionStore.Get(r, h.app.App().Config().Session.Name())
+				if err != nil && (ul(h.app.App().Config()) == UserLevelNoneRequiredType || ul(h.app.App().Config()) == UserLevelUserType) {
 					// Cookie is required, but we can ignore this error
-					log.Error("Handler: Unable to get session (for user permission %d); ignoring: %v", ul(h.app.App().cfg), err)
+					log.Error("Handler: Unable to get session (for user permission %d); ignoring: %v", ul(h.app.App().Config()), err)
 				}
 
 				_, gotUser := session.Values[cookieUserVal].(*User)
-				if ul(h.app.App().cfg) == UserLevelNoneRequiredType && gotUser {
+				if ul(h.app.App().Config()) == UserLevelNoneRequiredType && gotUser {
 					to := correctPageFromLoginAttempt(r)
 					log.Info("Handler: Required NO user, but got one. Redirecting to %s", to)
 					err := impart.HTTPError{http.StatusFound, to}
 					status = err.Status
 					return err
-				} else if ul(h.app.App().cfg) == UserLevelUserType && !gotUser {
+				} else if ul(h.app.App().Config()) == UserLevelUserType && !gotUser {
 					log.Info("Handler: Required a user, but DIDN'T get one. Sending not logged in.")
 					err := ErrNotLoggedIn
 					status = err.Status
@@ -682,7 +682,7 @@ func (h *Handler) Redirect(url string, ul UserLevelFunc) http.HandlerFunc {
 
 			status = sendRedirect(w, http.StatusFound, url)
 
-			log.Info(h.app.ReqLog(r, status, time.Since(start)))
+			h.logRequest(r, status, start)
 
 			return nil
 		}())
@@ -789,6 +789,15 @@ func correctPageFromLoginAttempt(r *http.Request) string {
 	return to
 }
 
+// logRequest writes the request to the log, unless its path is excluded in
+// the log configuration.
+func (h *Handler) logRequest(r *http.Request, status int, start time.Time) {
+	if h.app.App().Config().Log.Excludes(r.URL.Path) {
+		return
+	}
+	log.Info(h.app.ReqLog(r, status, time.Since(start)))
+}
+
 func (h *Handler) LogHandlerFunc(f http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		h.handleHTTPError(w, r, func() error {
@@ -803,10 +812,10 @@ func (h *Handler) LogHandlerFunc(f http.HandlerFunc) http.HandlerFunc {
 				}
 
 				// TODO: log actual status code returned
-				log.Info(h.app.ReqLog(r, status, time.Since(start)))
+				h.logRequest(r, status, start)
 			}()
 
-			if h.app.App().cfg.App.Private {
+			if h.app.App().Config().App.Private {
 				// This instance is private, so ensure it's being accessed by a valid user
 				// Check if authenticated with an access token
 				_, apiErr := optionalAPIAuth(h.app.App(), r)
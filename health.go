@@ -0,0 +1,47 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/writeas/web-core/log"
+)
+
+// readyTimeout is how long the readiness check waits for the database.
+const readyTimeout = 5 * time.Second
+
+// handleHealth reports that the process is up and serving requests.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("OK\n"))
+}
+
+// handleReady reports whether the app can serve requests, which requires
+// the database to be reachable.
+func handleReady(app *App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+		if app.db == nil {
+			http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if err := app.db.PingContext(ctx); err != nil {
+			log.Error("Readiness check failed: %v", err)
+			http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handleHealth(w, r)
+	}
+}
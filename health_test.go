@@ -0,0 +1,48 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthChecks(t *testing.T) {
+	db := (&fakeDB{}).open(t)
+	app := &App{db: &datastore{DB: db, driverName: "wffake"}}
+
+	w := httptest.NewRecorder()
+	handleHealth(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Health status = %d; expected %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	handleReady(app)(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Ready status = %d; expected %d", w.Code, http.StatusOK)
+	}
+
+	db.Close()
+	w = httptest.NewRecorder()
+	handleReady(app)(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Ready status with closed DB = %d; expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// Liveness doesn't depend on the database
+	w = httptest.NewRecorder()
+	handleHealth(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Health status with closed DB = %d; expected %d", w.Code, http.StatusOK)
+	}
+}
@@ -19,9 +19,14 @@ func handleViewHostMeta(app *App, w http.ResponseWriter, r *http.Request) error
 	w.Header().Set("Server", serverSoftware)
 	w.Header().Set("Content-Type", "application/xrd+xml; charset=utf-8")
 
+	// Without proxy headers, assume the instance is reached over HTTPS
+	base := "https://" + r.Host
+	if app.Config().Server.UseProxyHeaders {
+		base = app.Config().Server.AbsoluteURL(r, "")
+	}
 	meta := `<?xml version="1.0" encoding="UTF-8"?>
 <XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
-  <Link rel="lrdd" type="application/xrd+xml" template="https://` + r.Host + `/.well-known/webfinger?resource={uri}"/>
+  <Link rel="lrdd" type="application/xrd+xml" template="` + base + `/.well-known/webfinger?resource={uri}"/>
 </XRD>`
 	fmt.Fprintf(w, meta)
 
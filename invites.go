@@ -46,9 +46,36 @@ func (i Invite) ExpiresFriendly() string {
 	return i.Expires.Format("January 2, 2006, 3:04 PM")
 }
 
+// inviteExpired returns whether the given invite has passed its expiration
+// date or been used as many times as it allows.
+func inviteExpired(app *App, i *Invite) bool {
+	if i.Expired() {
+		return true
+	}
+	if i.MaxUses.Valid && i.MaxUses.Int64 > 0 {
+		// Invite has a max-use number, so check if we're past that limit
+		i.uses = app.db.GetUsersInvitedCount(i.ID)
+		return i.uses >= i.MaxUses.Int64
+	}
+	return false
+}
+
+// checkInvite returns an error if the given invite code can't be used to
+// sign up.
+func checkInvite(app *App, code string) error {
+	i, err := app.db.GetUserInvite(code)
+	if err != nil {
+		return err
+	}
+	if i.Inactive || inviteExpired(app, i) {
+		return ErrInviteExpired
+	}
+	return nil
+}
+
 func handleViewUserInvites(app *App, u *User, w http.ResponseWriter, r *http.Request) error {
 	// Don't show page if instance doesn't allow it
-	if !(app.cfg.App.UserInvites != "" && (u.IsAdmin() || app.cfg.App.UserInvites != "admin")) {
+	if !(app.Config().App.UserInvites != "" && (u.IsAdmin() || app.Config().App.UserInvites != "admin")) {
 		return impart.HTTPError{http.StatusNotFound, ""}
 	}
 
@@ -119,12 +146,7 @@ func handleViewInvite(app *App, w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	expired := i.Expired()
-	if !expired && i.MaxUses.Valid && i.MaxUses.Int64 > 0 {
-		// Invite has a max-use number, so check if we're past that limit
-		i.uses = app.db.GetUsersInvitedCount(inviteCode)
-		expired = i.uses >= i.MaxUses.Int64
-	}
+	expired := inviteExpired(app, i)
 
 	if u := getUserSession(app, r); u != nil {
 		// check if invite belongs to another user
@@ -141,7 +163,7 @@ func handleViewInvite(app *App, w http.ResponseWriter, r *http.Request) error {
 			Invite  *Invite
 			Expired bool
 		}{
-			UserPage: NewUserPage(app, r, u, "Invite to "+app.cfg.App.SiteName, nil),
+			UserPage: NewUserPage(app, r, u, "Invite to "+app.Config().App.SiteName, nil),
 			Invite:   i,
 			Expired:  expired,
 		}
@@ -164,7 +186,7 @@ func handleViewInvite(app *App, w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Get error messages
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err != nil {
 		// Ignore this
 		log.Error("Unable to get session in handleViewInvite; ignoring: %v", err)
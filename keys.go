@@ -11,6 +11,7 @@
 package writefreely
 
 import (
+	"fmt"
 	"github.com/writeas/web-core/log"
 	"github.com/writeas/writefreely/key"
 	"io/ioutil"
@@ -39,9 +40,9 @@ func InitKeys(apper Apper) error {
 }
 
 func initKeyPaths(app *App) {
-	emailKeyPath = filepath.Join(app.cfg.Server.KeysParentDir, emailKeyPath)
-	cookieAuthKeyPath = filepath.Join(app.cfg.Server.KeysParentDir, cookieAuthKeyPath)
-	cookieKeyPath = filepath.Join(app.cfg.Server.KeysParentDir, cookieKeyPath)
+	emailKeyPath = filepath.Join(app.Config().Server.KeysDir(), emailKeyPath)
+	cookieAuthKeyPath = filepath.Join(app.Config().Server.KeysDir(), cookieAuthKeyPath)
+	cookieKeyPath = filepath.Join(app.Config().Server.KeysDir(), cookieKeyPath)
 }
 
 // generateKey generates a key at the given path used for the encryption of
@@ -71,3 +72,29 @@ func generateKey(path string) error {
 	log.Info("Success.")
 	return nil
 }
+
+// loadOrGenerateKey reads the key at the given path, first generating it if
+// it doesn't exist yet. This keeps keys stable across restarts, so it should
+// only be used for keys that can safely be created on first run, like those
+// for signing cookies.
+func loadOrGenerateKey(path string) ([]byte, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return nil, err
+		}
+		err = generateKey(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < key.EncKeysBytes {
+		return nil, fmt.Errorf("Key %s is only %d bytes; expected %d. Remove it and restart to generate a new one.", path, len(b), key.EncKeysBytes)
+	}
+	return b, nil
+}
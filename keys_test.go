@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/writeas/writefreely/key"
+)
+
+func TestLoadOrGenerateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfkeys")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, keysDir, "cookies_auth.aes256")
+
+	k1, err := loadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("Generating key failed: %v", err)
+	}
+	if len(k1) != key.EncKeysBytes {
+		t.Errorf("Generated key is %d bytes; expected %d", len(k1), key.EncKeysBytes)
+	}
+	k2, err := loadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("Loading key failed: %v", err)
+	}
+	if !bytes.Equal(k1, k2) {
+		t.Error("Second call generated a new key; expected the existing file to be reused")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("short"), 0600); err != nil {
+		t.Fatalf("Unable to write key: %v", err)
+	}
+	if _, err := loadOrGenerateKey(path); err == nil {
+		t.Error("Expected error loading truncated key")
+	}
+}
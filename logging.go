@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+// initLogging sets the log level, format, and destination. Without a log
+// file, informational messages go to stdout and errors to stderr.
+func initLogging(cfg config.LogCfg) error {
+	lvl, err := config.ParseLogLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+
+	var infoOut, errOut io.Writer = os.Stdout, os.Stderr
+	if cfg.File != "" {
+		w, err := cfg.Writer()
+		if err != nil {
+			return err
+		}
+		infoOut, errOut = w, w
+	}
+	if lvl > config.LogLevelInfo {
+		// Warnings are logged as errors, so those are always shown
+		infoOut = ioutil.Discard
+	}
+
+	// The loggers are changed rather than replaced, since other goroutines
+	// can be logging when the config is reloaded
+	if cfg.Format == "json" {
+		setLogger(log.InfoLog, jsonLogWriter{w: infoOut, level: "info"}, "", 0)
+		setLogger(log.ErrorLog, jsonLogWriter{w: errOut, level: "error"}, "", 0)
+	} else {
+		setLogger(log.InfoLog, infoOut, "", stdlog.Ldate|stdlog.Ltime)
+		setLogger(log.ErrorLog, errOut, "ERROR: ", stdlog.Ldate|stdlog.Ltime)
+	}
+	return nil
+}
+
+func setLogger(l *stdlog.Logger, w io.Writer, prefix string, flags int) {
+	l.SetOutput(w)
+	l.SetPrefix(prefix)
+	l.SetFlags(flags)
+}
+
+// jsonLogWriter writes each log message it receives as a JSON object on its
+// own line.
+type jsonLogWriter struct {
+	w     io.Writer
+	level string
+}
+
+func (jw jsonLogWriter) Write(p []byte) (int, error) {
+	b, err := json.Marshal(struct {
+		Time    string `json:"time"`
+		Level   string `json:"level"`
+		Message string `json:"msg"`
+	}{time.Now().Format(time.RFC3339), jw.level, strings.TrimSuffix(string(p), "\n")})
+	if err != nil {
+		return 0, err
+	}
+	_, err = jw.w.Write(append(b, '\n'))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
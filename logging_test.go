@@ -0,0 +1,67 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"encoding/json"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestJSONLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	l := stdlog.New(jsonLogWriter{w: &buf, level: "error"}, "", 0)
+	l.Printf("Unable to connect: %s", "timeout")
+
+	var entry map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log line isn't JSON: %v: %s", err, buf.String())
+	}
+	if entry["level"] != "error" || entry["msg"] != "Unable to connect: timeout" || entry["time"] == "" {
+		t.Errorf("Log entry = %v; expected error level, message, and time", entry)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("}\n")) {
+		t.Errorf("Log entry doesn't end with a newline: %q", buf.String())
+	}
+}
+
+func TestLogExcludePaths(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.InfoLog.Writer()
+	log.InfoLog.SetOutput(&buf)
+	defer log.InfoLog.SetOutput(out)
+
+	cfg := config.New()
+	cfg.Log.ExcludePaths = []string{"/favicon.ico", "/css/"}
+	h := NewHandler(newTestApp(cfg))
+	f := h.LogHandlerFunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for path, logged := range map[string]bool{
+		"/favicon.ico":    false,
+		"/css/write.css":  false,
+		"/css":            true,
+		"/favicon.ico.gz": true,
+		"/about":          true,
+	} {
+		buf.Reset()
+		f(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if hasLine := strings.Contains(buf.String(), "\"GET "+path+"\""); hasLine != logged {
+			t.Errorf("GET %s: log = %q; expected logged: %t", path, buf.String(), logged)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/writeas/impart"
+)
+
+const (
+	defaultMaintenanceMessage = "This site is down for maintenance. Please check back soon."
+
+	// maintenanceTokenHeader holds the token that lets requests through
+	// during maintenance.
+	maintenanceTokenHeader = "X-Maintenance-Token"
+)
+
+// maintenanceMiddleware responds with a 503 Service Unavailable error while
+// the instance is in maintenance mode, except to admins, requests with the
+// configured bypass token, and the routes admins need to log in.
+func maintenanceMiddleware(app *App) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.Config().App.Maintenance || maintenanceAllowed(app, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			msg := app.Config().App.MaintenanceMessage
+			if msg == "" {
+				msg = defaultMaintenanceMessage
+			}
+			w.Header().Set("Retry-After", "300")
+			if IsJSON(r) || strings.HasPrefix(r.URL.Path, "/api/") {
+				impart.WriteError(w, impart.HTTPError{http.StatusServiceUnavailable, msg})
+			} else {
+				http.Error(w, msg, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// maintenanceAllowed returns whether the given request can be served while
+// in maintenance mode.
+func maintenanceAllowed(app *App, r *http.Request) bool {
+	if token := app.Config().App.MaintenanceToken; token != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(maintenanceTokenHeader)), []byte(token)) == 1 {
+			return true
+		}
+	}
+	switch r.URL.Path {
+	case "/login", "/auth/login", "/api/auth/login", "/me/logout":
+		return true
+	}
+	if u := getUserSession(app, r); u != nil && u.IsAdmin() {
+		return true
+	}
+	return false
+}
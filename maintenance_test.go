@@ -0,0 +1,92 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/writeas/writefreely/config"
+	"github.com/writeas/writefreely/key"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	app := &App{
+		keys: &key.Keychain{
+			CookieAuthKey: []byte("0123456789abcdef0123456789abcdef"),
+			CookieKey:     []byte("0123456789abcdef0123456789abcdef"),
+		},
+	}
+	app.SetConfig(config.New())
+	app.InitSession()
+	app.Config().App.MaintenanceToken = "let-me-in"
+
+	r := mux.NewRouter()
+	r.Use(maintenanceMiddleware(app))
+	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	// sessionCookie returns a session cookie for the user with the given ID
+	sessionCookie := func(id int64) *http.Cookie {
+		req := httptest.NewRequest("GET", "/", nil)
+		session, _ := app.sessionStore.Get(req, app.Config().Session.Name())
+		session.Values[cookieUserVal] = &User{ID: id, Username: "user"}
+		w := httptest.NewRecorder()
+		if err := session.Save(req, w); err != nil {
+			t.Fatalf("Unable to save session: %v", err)
+		}
+		return w.Result().Cookies()[0]
+	}
+	admin, user := sessionCookie(1), sessionCookie(2)
+
+	for _, tc := range []struct {
+		Name        string
+		Maintenance bool
+		Path        string
+		Token       string
+		Cookie      *http.Cookie
+		Expected    int
+	}{
+		{"Normal operation", false, "/read", "", nil, http.StatusOK},
+		{"Blocked page", true, "/read", "", nil, http.StatusServiceUnavailable},
+		{"Blocked API", true, "/api/posts", "", nil, http.StatusServiceUnavailable},
+		{"Wrong token", true, "/read", "nope", nil, http.StatusServiceUnavailable},
+		{"Bypass token", true, "/read", "let-me-in", nil, http.StatusOK},
+		{"Login page", true, "/login", "", nil, http.StatusOK},
+		{"Admin", true, "/admin", "", admin, http.StatusOK},
+		{"Non-admin user", true, "/me/c/", "", user, http.StatusServiceUnavailable},
+	} {
+		app.Config().App.Maintenance = tc.Maintenance
+		req := httptest.NewRequest("GET", tc.Path, nil)
+		if tc.Token != "" {
+			req.Header.Set(maintenanceTokenHeader, tc.Token)
+		}
+		if tc.Cookie != nil {
+			req.AddCookie(tc.Cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.Expected {
+			t.Errorf("%s: status = %d; expected %d", tc.Name, w.Code, tc.Expected)
+		}
+	}
+
+	app.Config().App.Maintenance = true
+	app.Config().App.MaintenanceMessage = "Upgrading, back at 5pm."
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if body := w.Body.String(); body != "Upgrading, back at 5pm.\n" {
+		t.Errorf("Body = %q; expected the maintenance message", body)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// activeSessionWindow is how recently a logged-in user must have made a
+// request to count as an active session.
+const activeSessionWindow = 15 * time.Minute
+
+// appMetrics collects the Prometheus metrics reported at the configured
+// metrics path.
+type appMetrics struct {
+	app      *App
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	mu       sync.Mutex
+	sessions map[int64]time.Time
+}
+
+func newAppMetrics(app *App) *appMetrics {
+	m := &appMetrics{
+		app:      app,
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "writefreely_http_requests_total",
+			Help: "Number of HTTP requests handled, by method and status code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "writefreely_http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		sessions: map[int64]time.Time{},
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "writefreely_active_sessions",
+			Help: "Number of logged-in users who made a request in the last 15 minutes.",
+		}, m.activeSessions),
+	)
+	return m
+}
+
+// middleware records every request's status code, latency, and user for
+// the metrics.
+func (m *appMetrics) middleware(next http.Handler) http.Handler {
+	track := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u := getUserSession(m.app, r); u != nil {
+			m.mu.Lock()
+			m.sessions[u.ID] = time.Now()
+			m.mu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+	return promhttp.InstrumentHandlerDuration(m.latency, promhttp.InstrumentHandlerCounter(m.requests, track))
+}
+
+// activeSessions returns the number of users seen within the
+// activeSessionWindow, forgetting any users seen before it.
+func (m *appMetrics) activeSessions() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := time.Now().Add(-activeSessionWindow)
+	for id, seen := range m.sessions {
+		if seen.Before(cutoff) {
+			delete(m.sessions, id)
+		}
+	}
+	return float64(len(m.sessions))
+}
+
+// handler serves the metrics. When token is set, requests must include it
+// as a bearer token.
+func (m *appMetrics) handler(token string) http.Handler {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
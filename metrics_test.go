@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	app := newTestApp(config.New())
+	app.sessionStore = sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	for _, tc := range []struct {
+		Token, Auth string
+		Expected    int
+	}{
+		{"", "", http.StatusOK},
+		{"s3cret", "Bearer s3cret", http.StatusOK},
+		{"s3cret", "Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", "", http.StatusUnauthorized},
+	} {
+		m := newAppMetrics(app)
+		r := mux.NewRouter()
+		r.Use(m.middleware)
+		r.Handle("/metrics", m.handler(tc.Token))
+		r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hi"))
+		})
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tc.Auth != "" {
+			req.Header.Set("Authorization", tc.Auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.Expected {
+			t.Errorf("Token %q, auth %q: status = %d; expected %d", tc.Token, tc.Auth, w.Code, tc.Expected)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		body := w.Body.String()
+		for _, name := range []string{
+			`writefreely_http_requests_total{code="200",method="get"} 1`,
+			"writefreely_http_request_duration_seconds_count",
+			"writefreely_active_sessions 0",
+		} {
+			if !strings.Contains(body, name) {
+				t.Errorf("Metrics don't include %q:\n%s", name, body)
+			}
+		}
+	}
+}
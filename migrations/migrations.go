@@ -59,6 +59,8 @@ var migrations = []Migration{
 	New("support user invites", supportUserInvites),             // -> V1 (v0.8.0)
 	New("support dynamic instance pages", supportInstancePages), // V1 -> V2 (v0.9.0)
 	New("support users suspension", supportUserStatus),          // V2 -> V3 (v0.11.0)
+	New("support oauth", supportOAuth),                          // V3 -> V4
+	New("support scheduled posts", supportScheduledPosts),       // V4 -> V5
 }
 
 // CurrentVer returns the current migration version the application is on
@@ -0,0 +1,34 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package migrations
+
+func supportOAuth(db *datastore) error {
+	t, err := db.Begin()
+
+	_, err = t.Exec(`CREATE TABLE oauth_users (
+		  user_id ` + db.typeInt() + ` NOT NULL ,
+		  remote_user_id ` + db.typeVarChar(128) + ` NOT NULL ,
+		  PRIMARY KEY (user_id) ,
+		  UNIQUE (remote_user_id)
+		) ` + db.engine() + `;`)
+	if err != nil {
+		t.Rollback()
+		return err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		t.Rollback()
+		return err
+	}
+
+	return nil
+}
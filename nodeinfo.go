@@ -11,10 +11,14 @@
 package writefreely
 
 import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
 	"github.com/writeas/web-core/log"
 	"github.com/writeas/writefreely/config"
 	"github.com/writefreely/go-nodeinfo"
-	"strings"
 )
 
 type nodeInfoResolver struct {
@@ -22,6 +26,54 @@ type nodeInfoResolver struct {
 	db  *datastore
 }
 
+// nodeInfoRoutes adds the NodeInfo discovery and info endpoints to r,
+// unless they're turned off with the app nodeinfo setting.
+func nodeInfoRoutes(r *mux.Router, handler *Handler, app *App) {
+	if !app.Config().App.NodeInfoEnabled {
+		return
+	}
+	niCfg := nodeInfoConfig(app.db, app.Config())
+	ni := nodeinfo.NewService(*niCfg, nodeInfoResolver{app.Config(), app.db})
+	r.HandleFunc(nodeinfo.NodeInfoPath, handler.LogHandlerFunc(http.HandlerFunc(ni.NodeInfoDiscover)))
+	r.HandleFunc(niCfg.InfoURL, handler.LogHandlerFunc(handleNodeInfo(ni, app.Config())))
+}
+
+// nodeInfoProfile is the NodeInfo schema served at the info URL.
+const nodeInfoProfile = "http://nodeinfo.diaspora.software/ns/schema/2.0"
+
+// nodeInfoMetadata adds the instance's contact details to the metadata
+// go-nodeinfo knows about.
+type nodeInfoMetadata struct {
+	nodeinfo.Metadata
+	AdminEmail string `json:"adminEmail,omitempty"`
+	ContactURL string `json:"contactURL,omitempty"`
+}
+
+// handleNodeInfo serves the service's NodeInfo, with the contact details
+// from the config in its metadata.
+func handleNodeInfo(ni *nodeinfo.Service, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := struct {
+			nodeinfo.NodeInfo
+			Metadata nodeInfoMetadata `json:"metadata"`
+		}{NodeInfo: ni.BuildInfo()}
+		info.Metadata = nodeInfoMetadata{
+			Metadata:   info.NodeInfo.Metadata,
+			AdminEmail: cfg.App.AdminEmail,
+			ContactURL: cfg.App.ContactURL,
+		}
+
+		body, err := json.Marshal(info)
+		if err != nil {
+			log.Error("Unable to marshal nodeinfo: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; profile="+nodeInfoProfile+"#")
+		w.Write(body)
+	}
+}
+
 func nodeInfoConfig(db *datastore, cfg *config.Config) *nodeinfo.Config {
 	name := cfg.App.SiteName
 	desc := cfg.App.SiteDesc
@@ -49,7 +101,7 @@ func nodeInfoConfig(db *datastore, cfg *config.Config) *nodeinfo.Config {
 				Follow:   "https://writing.exchange/@write_as",
 			},
 			MaxBlogs:     cfg.App.MaxBlogs,
-			PublicReader: cfg.App.LocalTimeline,
+			PublicReader: cfg.App.ShowsLocalTimeline(),
 			Invites:      cfg.App.UserInvites != "",
 		},
 		Protocols: []nodeinfo.NodeProtocol{
@@ -72,20 +124,26 @@ func (r nodeInfoResolver) IsOpenRegistration() (bool, error) {
 	return r.cfg.App.OpenRegistration, nil
 }
 
+// Usage reports the instance's usage, limited to what the configured
+// public_stats level shows.
 func (r nodeInfoResolver) Usage() (nodeinfo.Usage, error) {
-	var collCount, postCount int64
-	var activeHalfYear, activeMonth int
-	var err error
-	collCount, err = r.db.GetTotalCollections()
+	var u nodeinfo.Usage
+	if !r.cfg.App.ShowsStats(config.StatsBasic) {
+		return u, nil
+	}
+
+	collCount, err := r.db.GetTotalCollections()
 	if err != nil {
 		collCount = 0
 	}
-	postCount, err = r.db.GetTotalPosts()
+	postCount, err := r.db.GetTotalPosts()
 	if err != nil {
 		log.Error("Unable to fetch post counts: %v", err)
 	}
+	u.Users.Total = int(collCount)
+	u.LocalPosts = int(postCount)
 
-	if r.cfg.App.PublicStats {
+	if r.cfg.App.ShowsStats(config.StatsFull) {
 		// Display bi-yearly / monthly stats
 		err = r.db.QueryRow(`SELECT COUNT(*) FROM (
 SELECT DISTINCT collection_id
@@ -93,23 +151,16 @@ FROM posts
 INNER JOIN collections c
 ON collection_id = c.id
 WHERE collection_id IS NOT NULL
-	AND updated > DATE_SUB(NOW(), INTERVAL 6 MONTH)) co`).Scan(&activeHalfYear)
+	AND updated > DATE_SUB(NOW(), INTERVAL 6 MONTH)) co`).Scan(&u.Users.ActiveHalfYear)
 
 		err = r.db.QueryRow(`SELECT COUNT(*) FROM (
 SELECT DISTINCT collection_id
 FROM posts
-INNER JOIN FROM collections c
+INNER JOIN collections c
 ON collection_id = c.id
 WHERE collection_id IS NOT NULL
-	AND updated > DATE_SUB(NOW(), INTERVAL 1 MONTH)) co`).Scan(&activeMonth)
+	AND updated > DATE_SUB(NOW(), INTERVAL 1 MONTH)) co`).Scan(&u.Users.ActiveMonth)
 	}
 
-	return nodeinfo.Usage{
-		Users: nodeinfo.UsageUsers{
-			Total:          int(collCount),
-			ActiveHalfYear: activeHalfYear,
-			ActiveMonth:    activeMonth,
-		},
-		LocalPosts: int(postCount),
-	}, nil
+	return u, nil
 }
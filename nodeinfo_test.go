@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/writeas/writefreely/config"
+	"github.com/writefreely/go-nodeinfo"
+)
+
+func TestNodeInfoUsage(t *testing.T) {
+	db := (&fakeDB{}).open(t)
+
+	for _, tc := range []struct {
+		Level    string
+		Expected nodeinfo.Usage
+	}{
+		{config.StatsNone, nodeinfo.Usage{}},
+		{"", nodeinfo.Usage{}},
+		{config.StatsBasic, nodeinfo.Usage{Users: nodeinfo.UsageUsers{Total: 7}, LocalPosts: 7}},
+		{config.StatsFull, nodeinfo.Usage{Users: nodeinfo.UsageUsers{Total: 7, ActiveHalfYear: 7, ActiveMonth: 7}, LocalPosts: 7}},
+	} {
+		cfg := config.New()
+		cfg.App.PublicStats = tc.Level
+		r := nodeInfoResolver{cfg, &datastore{DB: db, driverName: "wffake"}}
+		u, err := r.Usage()
+		if err != nil {
+			t.Errorf("Level %q: Usage failed: %v", tc.Level, err)
+			continue
+		}
+		if u != tc.Expected {
+			t.Errorf("Level %q: usage = %+v; expected %+v", tc.Level, u, tc.Expected)
+		}
+	}
+}
+
+func TestNodeInfoRoutes(t *testing.T) {
+	db := (&fakeDB{}).open(t)
+
+	for _, tc := range []struct {
+		Name      string
+		Enabled   bool
+		Stats     string
+		Status    int
+		WithUsage bool
+	}{
+		{"Disabled", false, config.StatsFull, http.StatusNotFound, false},
+		{"Private stats", true, config.StatsNone, http.StatusOK, false},
+		{"Public stats", true, config.StatsBasic, http.StatusOK, true},
+	} {
+		cfg := config.New()
+		cfg.App.Host = "https://example.com"
+		cfg.App.NodeInfoEnabled = tc.Enabled
+		cfg.App.PublicStats = tc.Stats
+		app := newTestApp(cfg)
+		app.db = &datastore{DB: db, driverName: "wffake"}
+		r := mux.NewRouter()
+		nodeInfoRoutes(r, NewHandler(app), app)
+
+		for _, path := range []string{nodeinfo.NodeInfoPath, "/api/nodeinfo"} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code != tc.Status {
+				t.Errorf("%s: GET %s = %d; expected %d", tc.Name, path, w.Code, tc.Status)
+			}
+		}
+		if tc.Status != http.StatusOK {
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/nodeinfo", nil))
+		var info struct {
+			Usage map[string]interface{} `json:"usage"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+			t.Fatalf("%s: Unable to decode NodeInfo: %v", tc.Name, err)
+		}
+		_, hasPosts := info.Usage["localPosts"]
+		users, _ := info.Usage["users"].(map[string]interface{})
+		_, hasTotal := users["total"]
+		if hasPosts != tc.WithUsage || hasTotal != tc.WithUsage {
+			t.Errorf("%s: usage = %v; expected counts: %t", tc.Name, info.Usage, tc.WithUsage)
+		}
+	}
+}
+
+func TestNodeInfoContact(t *testing.T) {
+	db := (&fakeDB{}).open(t)
+
+	for _, tc := range []struct {
+		Name       string
+		AdminEmail string
+		ContactURL string
+	}{
+		{"Unset", "", ""},
+		{"Email", "admin@example.com", ""},
+		{"Both", "admin@example.com", "https://example.com/contact"},
+	} {
+		cfg := config.New()
+		cfg.App.Host = "https://example.com"
+		cfg.App.SiteName = "Example"
+		cfg.App.AdminEmail = tc.AdminEmail
+		cfg.App.ContactURL = tc.ContactURL
+		app := newTestApp(cfg)
+		app.db = &datastore{DB: db, driverName: "wffake"}
+		r := mux.NewRouter()
+		nodeInfoRoutes(r, NewHandler(app), app)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/nodeinfo", nil))
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; profile="+nodeInfoProfile+"#" {
+			t.Errorf("%s: Content-Type = %s", tc.Name, ct)
+		}
+		var info struct {
+			Metadata map[string]interface{} `json:"metadata"`
+			Version  string                 `json:"version"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+			t.Fatalf("%s: Unable to decode NodeInfo: %v", tc.Name, err)
+		}
+		if info.Metadata["nodeName"] != "Example" || info.Version != "2.0" {
+			t.Errorf("%s: NodeInfo lost its usual fields: %+v", tc.Name, info)
+		}
+		for key, expected := range map[string]string{"adminEmail": tc.AdminEmail, "contactURL": tc.ContactURL} {
+			v, ok := info.Metadata[key]
+			if expected == "" && ok {
+				t.Errorf("%s: metadata %s = %v; expected it to be left out", tc.Name, key, v)
+			} else if expected != "" && v != expected {
+				t.Errorf("%s: metadata %s = %v; expected %s", tc.Name, key, v, expected)
+			}
+		}
+	}
+}
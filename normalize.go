@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/writeas/writefreely/config"
+)
+
+// nonBlogPaths are the first path segments, besides reserved usernames, that
+// never belong to a blog.
+var nonBlogPaths = []string{"css", "js", "img", "fonts", "oauth", ".well-known"}
+
+// blogSubpaths are the second path segments of the pages a blog has besides
+// its posts, which keep their own form.
+var blogSubpaths = map[string]bool{
+	"page":        true,
+	"tags":        true,
+	"feed":        true,
+	"sitemap.xml": true,
+}
+
+// normalizeURLsMiddleware permanently redirects GET requests for blog and
+// post paths that aren't in their canonical form: lowercase, with posts
+// having no trailing slash. Blogs keep the trailing slash they're always
+// linked with. Reserved routes, like the API, aren't changed.
+func normalizeURLsMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	exempt := map[string]bool{}
+	for _, names := range [][]string{config.DefaultReservedUsernames, cfg.App.ReservedUsernames, nonBlogPaths} {
+		for _, n := range names {
+			exempt[strings.ToLower(n)] = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" || r.Method == "HEAD" {
+				if p := canonicalBlogPath(r.URL.Path, cfg.App.SingleUser, exempt); p != r.URL.Path {
+					to := p
+					if r.URL.RawQuery != "" {
+						to += "?" + r.URL.RawQuery
+					}
+					http.Redirect(w, r, to, http.StatusMovedPermanently)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// canonicalBlogPath returns the canonical form of the given blog or post
+// path, or the path itself if it isn't one or is already canonical.
+func canonicalBlogPath(path string, singleUser bool, exempt map[string]bool) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if segs[0] == "" || exempt[strings.ToLower(segs[0])] || strings.Contains(segs[0], ".") {
+		return path
+	}
+	if singleUser {
+		// Posts are at the top level, where the blog's own pages are too
+		if len(segs) != 1 || blogSubpaths[segs[0]] || strings.Contains(segs[0], ":") {
+			return path
+		}
+		return "/" + strings.ToLower(segs[0])
+	}
+
+	alias := strings.ToLower(segs[0])
+	switch {
+	case len(segs) == 1:
+		// The blog itself, or a draft, which only differ in the slash
+		return strings.Replace(path, segs[0], alias, 1)
+	case len(segs) == 2 && !blogSubpaths[segs[1]] && !strings.Contains(segs[1], ":"):
+		return "/" + alias + "/" + strings.ToLower(segs[1])
+	}
+	return "/" + alias + strings.TrimPrefix(path, "/"+segs[0])
+}
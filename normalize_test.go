@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestNormalizeURLsMiddleware(t *testing.T) {
+	newRouter := func(singleUser bool) *mux.Router {
+		cfg := config.New()
+		cfg.App.SingleUser = singleUser
+		cfg.App.NormalizeURLs = true
+		r := mux.NewRouter()
+		r.Use(normalizeURLsMiddleware(cfg))
+		r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+		return r
+	}
+	multi, single := newRouter(false), newRouter(true)
+
+	for _, tc := range []struct {
+		Name     string
+		Router   *mux.Router
+		Method   string
+		Target   string
+		Location string
+	}{
+		{"Blog", multi, "GET", "/matt/", ""},
+		{"Blog with uppercase", multi, "GET", "/Matt/", "/matt/"},
+		{"Post", multi, "GET", "/matt/hello-world", ""},
+		{"Post with uppercase", multi, "GET", "/Matt/Hello-World?lang=en", "/matt/hello-world?lang=en"},
+		{"Post with trailing slash", multi, "GET", "/matt/hello-world/", "/matt/hello-world"},
+		{"Blog page", multi, "GET", "/Matt/page/2", "/matt/page/2"},
+		{"Blog feed", multi, "GET", "/matt/feed/", ""},
+		{"Blog tag", multi, "GET", "/matt/tag:Go", ""},
+		{"Post edit page", multi, "GET", "/matt/hello-world/edit", ""},
+		{"Update", multi, "POST", "/Matt/Hello-World", ""},
+		{"API", multi, "GET", "/api/collections/Matt/posts/", ""},
+		{"Reserved route", multi, "GET", "/Admin/users/", ""},
+		{"Invite", multi, "GET", "/invite/Bc4Xz9", ""},
+		{"Static file", multi, "GET", "/img/Logo.png", ""},
+		{"Single user post", single, "GET", "/Hello-World/", "/hello-world"},
+		{"Single user feed", single, "GET", "/feed/", ""},
+		{"Single user page", single, "GET", "/page/2", ""},
+		{"Single user API", single, "GET", "/api/posts/ABCDEFGHIJ", ""},
+	} {
+		req := httptest.NewRequest(tc.Method, tc.Target, nil)
+		w := httptest.NewRecorder()
+		tc.Router.ServeHTTP(w, req)
+		status := http.StatusOK
+		if tc.Location != "" {
+			status = http.StatusMovedPermanently
+		}
+		if w.Code != status {
+			t.Errorf("%s: %s %s status = %d; expected %d", tc.Name, tc.Method, tc.Target, w.Code, status)
+		}
+		if loc := w.Header().Get("Location"); loc != tc.Location {
+			t.Errorf("%s: %s %s Location = %q; expected %q", tc.Name, tc.Method, tc.Target, loc, tc.Location)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/writeas/impart"
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/author"
+	"github.com/writeas/writefreely/config"
+)
+
+const (
+	// oauthStateVal is the session key holding the state sent to the OAuth
+	// provider, which must come back unchanged in the callback.
+	oauthStateVal = "oauth_state"
+
+	oauthTimeout = 15 * time.Second
+)
+
+type (
+	oauthTokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		Error       string `json:"error"`
+	}
+
+	// oauthUserInfo holds the standard OpenID Connect claims used to find
+	// or create a local user.
+	oauthUserInfo struct {
+		ID       string `json:"sub"`
+		Username string `json:"preferred_username"`
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+	}
+)
+
+// handleOAuthLogin sends the user to the OAuth provider to log in.
+func handleOAuthLogin(app *App, w http.ResponseWriter, r *http.Request) error {
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
+	if err != nil {
+		log.Error("OAuth: Session: %v; ignoring", err)
+	}
+	state := hex.EncodeToString(securecookie.GenerateRandomKey(16))
+	session.Values[oauthStateVal] = state
+	if err = session.Save(r, w); err != nil {
+		log.Error("OAuth: Couldn't save session: %v", err)
+		return ErrInternalCookieSession
+	}
+
+	authURL, err := oauthAuthURL(app.Config(), state)
+	if err != nil {
+		log.Error("OAuth: Invalid auth_url: %v", err)
+		return impart.HTTPError{http.StatusInternalServerError, "Login provider isn't configured correctly."}
+	}
+	return impart.HTTPError{http.StatusFound, authURL}
+}
+
+// oauthAuthURL returns the provider's authorization URL for the given
+// state.
+func oauthAuthURL(cfg *config.Config, state string) (string, error) {
+	u, err := url.Parse(cfg.OAuth.AuthURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.OAuth.ClientID)
+	q.Set("redirect_uri", cfg.OAuth.Callback(cfg.App.Host))
+	q.Set("scope", strings.Join(cfg.OAuth.ScopeList(), " "))
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// handleOAuthCallback logs in the user the provider sent back, creating an
+// account for them on their first login. A user who's already logged in
+// has their account linked instead. Errors are shown on the login page.
+func handleOAuthCallback(app *App, w http.ResponseWriter, r *http.Request) error {
+	to, err := oauthCallback(app, w, r)
+	if err != nil {
+		if err, ok := err.(impart.HTTPError); ok {
+			session, _ := app.sessionStore.Get(r, app.Config().Session.Name())
+			if session != nil {
+				session.AddFlash(err.Message)
+				session.Save(r, w)
+			}
+			return impart.HTTPError{http.StatusFound, "/login"}
+		}
+		return err
+	}
+	return impart.HTTPError{http.StatusFound, to}
+}
+
+func oauthCallback(app *App, w http.ResponseWriter, r *http.Request) (string, error) {
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
+	if err != nil {
+		log.Error("OAuth: Session: %v; ignoring", err)
+	}
+	state, _ := session.Values[oauthStateVal].(string)
+	delete(session.Values, oauthStateVal)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(r.FormValue("state"))) != 1 {
+		return "", impart.HTTPError{http.StatusBadRequest, "Login expired or was tampered with. Please try again."}
+	}
+	if e := r.FormValue("error"); e != "" {
+		log.Info("OAuth: Provider returned error %s: %s", e, r.FormValue("error_description"))
+		return "", impart.HTTPError{http.StatusUnauthorized, "Login with " + app.Config().OAuth.Name() + " was canceled or failed."}
+	}
+
+	c := &http.Client{Timeout: oauthTimeout}
+	token, err := exchangeOAuthCode(c, app.Config(), r.FormValue("code"))
+	if err != nil {
+		log.Error("OAuth: Unable to get access token: %v", err)
+		return "", impart.HTTPError{http.StatusBadGateway, "Couldn't log in with " + app.Config().OAuth.Name() + "."}
+	}
+	info, err := getOAuthUserInfo(c, app.Config().OAuth.UserInfoURL, token)
+	if err != nil {
+		log.Error("OAuth: Unable to get user info: %v", err)
+		return "", impart.HTTPError{http.StatusBadGateway, "Couldn't log in with " + app.Config().OAuth.Name() + "."}
+	}
+
+	userID, err := app.db.GetIDForRemoteUser(info.ID)
+	if err != nil {
+		return "", err
+	}
+	current := getUserSession(app, r)
+	if current != nil {
+		if userID == -1 {
+			if err = app.db.RecordRemoteUserID(current.ID, info.ID); err != nil {
+				return "", err
+			}
+			log.Info("OAuth: Linked user %d to remote user %s", current.ID, info.ID)
+			return "/me/settings", nil
+		}
+		if userID != current.ID {
+			return "", impart.HTTPError{http.StatusConflict, "This " + app.Config().OAuth.Name() + " login is already linked to another account."}
+		}
+		return "/me/settings", nil
+	}
+
+	var u *User
+	if userID == -1 {
+		u, err = createOAuthUser(app, info)
+	} else {
+		u, err = app.db.GetUserByID(userID)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = checkLoginAllowed(u); err != nil {
+		return "", err
+	}
+
+	session.Values[cookieUserVal] = u.Cookie()
+	if err = session.Save(r, w); err != nil {
+		log.Error("OAuth: Couldn't save session: %v", err)
+		return "", ErrInternalCookieSession
+	}
+	if app.Config().App.SingleUser {
+		return "/me/new", nil
+	}
+	return "/", nil
+}
+
+// createOAuthUser creates a user without a password for the given provider
+// user, and links them. Existing users aren't linked automatically, since
+// the provider's username may belong to someone else here. Accounts are
+// only created while registration is open, since there's no invite code to
+// check.
+func createOAuthUser(app *App, info *oauthUserInfo) (*User, error) {
+	switch app.Config().App.Registration() {
+	case config.RegistrationClosed:
+		return nil, impart.HTTPError{http.StatusForbidden, "Registration is closed, so there's no account for this " + app.Config().OAuth.Name() + " login."}
+	case config.RegistrationInvite:
+		return nil, impart.HTTPError{http.StatusForbidden, "An invite is required to sign up, so there's no account for this " + app.Config().OAuth.Name() + " login."}
+	}
+	username := getSlug(info.Username, "")
+	if !author.IsValidUsername(app.Config(), username) {
+		return nil, impart.HTTPError{http.StatusPreconditionFailed, fmt.Sprintf("Your %s username isn't valid here.", app.Config().OAuth.Name())}
+	}
+	u := &User{
+		Username:   username,
+		HashedPass: []byte{},
+		Created:    time.Now().Truncate(time.Second).UTC(),
+	}
+	if app.Config().App.RequireApproval {
+		u.Status = UserPending
+	}
+	err := app.db.CreateUser(app.Config(), u, info.Name)
+	if err != nil {
+		if err, ok := err.(impart.HTTPError); ok && err.Status == http.StatusConflict {
+			return nil, impart.HTTPError{http.StatusConflict, "Username " + username + " is already taken. If it's yours, log in with your password first, then with " + app.Config().OAuth.Name() + " to link the two."}
+		}
+		return nil, err
+	}
+	if err = app.db.RecordRemoteUserID(u.ID, info.ID); err != nil {
+		return nil, err
+	}
+	log.Info("OAuth: Created user %s for remote user %s", u.Username, info.ID)
+	return u, nil
+}
+
+// exchangeOAuthCode trades the authorization code from the callback for an
+// access token.
+func exchangeOAuthCode(c *http.Client, cfg *config.Config, code string) (string, error) {
+	if code == "" {
+		return "", fmt.Errorf("No code in callback")
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.OAuth.Callback(cfg.App.Host)},
+		"client_id":     {cfg.OAuth.ClientID},
+		"client_secret": {cfg.OAuth.ClientSecret},
+	}
+	req, err := http.NewRequest("POST", cfg.OAuth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var res oauthTokenResponse
+	if err = doOAuthRequest(c, req, &res); err != nil {
+		return "", err
+	}
+	if res.Error != "" {
+		return "", fmt.Errorf("Token error: %s", res.Error)
+	}
+	if res.AccessToken == "" {
+		return "", fmt.Errorf("No access token in response")
+	}
+	return res.AccessToken, nil
+}
+
+// getOAuthUserInfo fetches the provider's claims about the user the given
+// access token belongs to.
+func getOAuthUserInfo(c *http.Client, userInfoURL, token string) (*oauthUserInfo, error) {
+	req, err := http.NewRequest("GET", userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	info := &oauthUserInfo{}
+	if err = doOAuthRequest(c, req, info); err != nil {
+		return nil, err
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("No sub claim in user info")
+	}
+	return info, nil
+}
+
+func doOAuthRequest(c *http.Client, req *http.Request, v interface{}) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("%s returned %s", req.URL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
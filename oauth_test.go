@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/writeas/impart"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestOAuthAuthURL(t *testing.T) {
+	cfg := config.New()
+	cfg.App.Host = "https://blog.example.com"
+	cfg.OAuth.ClientID = "writefreely"
+	cfg.OAuth.AuthURL = "https://sso.example.com/authorize?realm=main"
+
+	authURL, err := oauthAuthURL(cfg, "abc123")
+	if err != nil {
+		t.Fatalf("oauthAuthURL failed: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	for k, v := range map[string]string{
+		"realm":         "main",
+		"response_type": "code",
+		"client_id":     "writefreely",
+		"redirect_uri":  "https://blog.example.com/oauth/callback",
+		"scope":         "openid profile email",
+		"state":         "abc123",
+	} {
+		if q.Get(k) != v {
+			t.Errorf("Auth URL %s = %q; expected %q", k, q.Get(k), v)
+		}
+	}
+}
+
+func TestOAuthExchange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.FormValue("code") != "good" || r.FormValue("client_secret") != "s3cret" {
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "tok", "token_type": "Bearer"})
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"sub": "1234", "preferred_username": "matt", "name": "Matt"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.OAuth.ClientID = "writefreely"
+	cfg.OAuth.ClientSecret = "s3cret"
+	cfg.OAuth.TokenURL = srv.URL + "/token"
+	c := srv.Client()
+
+	if _, err := exchangeOAuthCode(c, cfg, "bad"); err == nil {
+		t.Error("Exchanging a bad code succeeded")
+	}
+	if _, err := exchangeOAuthCode(c, cfg, ""); err == nil {
+		t.Error("Exchanging an empty code succeeded")
+	}
+	token, err := exchangeOAuthCode(c, cfg, "good")
+	if err != nil {
+		t.Fatalf("exchangeOAuthCode failed: %v", err)
+	}
+	if token != "tok" {
+		t.Errorf("Token = %q; expected %q", token, "tok")
+	}
+
+	if _, err = getOAuthUserInfo(c, srv.URL+"/userinfo", "wrong"); err == nil {
+		t.Error("Getting user info with a bad token succeeded")
+	}
+	info, err := getOAuthUserInfo(c, srv.URL+"/userinfo", token)
+	if err != nil {
+		t.Fatalf("getOAuthUserInfo failed: %v", err)
+	}
+	if info.ID != "1234" || info.Username != "matt" || info.Name != "Matt" {
+		t.Errorf("User info = %+v", info)
+	}
+}
+
+func TestCreateOAuthUserRegistration(t *testing.T) {
+	app := newTestApp(config.New())
+	info := &oauthUserInfo{ID: "1234", Username: "matt"}
+	for _, mode := range []string{config.RegistrationClosed, config.RegistrationInvite} {
+		app.Config().App.RegistrationMode = mode
+		_, err := createOAuthUser(app, info)
+		if err, ok := err.(impart.HTTPError); !ok || err.Status != http.StatusForbidden {
+			t.Errorf("Registration %s: createOAuthUser error = %v; expected a 403", mode, err)
+		}
+	}
+}
@@ -27,11 +27,11 @@ func getAboutPage(app *App) (*instanceContent, error) {
 		c = &instanceContent{
 			ID:      "about",
 			Type:    "page",
-			Content: defaultAboutPage(app.cfg),
+			Content: defaultAboutPage(app.Config()),
 		}
 	}
 	if !c.Title.Valid {
-		c.Title = defaultAboutTitle(app.cfg)
+		c.Title = defaultAboutTitle(app.Config())
 	}
 	return c, nil
 }
@@ -49,7 +49,7 @@ func getPrivacyPage(app *App) (*instanceContent, error) {
 		c = &instanceContent{
 			ID:      "privacy",
 			Type:    "page",
-			Content: defaultPrivacyPolicy(app.cfg),
+			Content: defaultPrivacyPolicy(app.Config()),
 			Updated: defaultPageUpdatedTime,
 		}
 	}
@@ -89,7 +89,7 @@ func getLandingBanner(app *App) (*instanceContent, error) {
 		c = &instanceContent{
 			ID:      "landing-banner",
 			Type:    "section",
-			Content: defaultLandingBanner(app.cfg),
+			Content: defaultLandingBanner(app.Config()),
 			Updated: defaultPageUpdatedTime,
 		}
 	}
@@ -105,7 +105,7 @@ func getLandingBody(app *App) (*instanceContent, error) {
 		c = &instanceContent{
 			ID:      "landing-body",
 			Type:    "section",
-			Content: defaultLandingBody(app.cfg),
+			Content: defaultLandingBody(app.Config()),
 			Updated: defaultPageUpdatedTime,
 		}
 	}
@@ -145,12 +145,12 @@ func getReaderSection(app *App) (*instanceContent, error) {
 		c = &instanceContent{
 			ID:      "reader",
 			Type:    "section",
-			Content: defaultReaderBanner(app.cfg),
+			Content: defaultReaderBanner(app.Config()),
 			Updated: defaultPageUpdatedTime,
 		}
 	}
 	if !c.Title.Valid {
-		c.Title = defaultReaderTitle(app.cfg)
+		c.Title = defaultReaderTitle(app.Config())
 	}
 	return c, nil
 }
@@ -53,21 +53,35 @@ func (p *PublicPost) formatContent(cfg *config.Config, isOwner bool) {
 	p.Post.formatContent(cfg, &p.Collection.Collection, isOwner)
 }
 
+// markdownExtensionFlags are the parser flags for the configurable Markdown
+// extensions. Raw HTML is a renderer flag instead, so it's handled apart.
+var markdownExtensionFlags = map[string]int{
+	config.MarkdownTables:        blackfriday.EXTENSION_TABLES,
+	config.MarkdownFencedCode:    blackfriday.EXTENSION_FENCED_CODE,
+	config.MarkdownAutolink:      blackfriday.EXTENSION_AUTOLINK,
+	config.MarkdownStrikethrough: blackfriday.EXTENSION_STRIKETHROUGH,
+	config.MarkdownSpaceHeaders:  blackfriday.EXTENSION_SPACE_HEADERS,
+	config.MarkdownHeaderIDs:     blackfriday.EXTENSION_AUTO_HEADER_IDS,
+	config.MarkdownFootnotes:     blackfriday.EXTENSION_FOOTNOTES,
+}
+
 func applyMarkdown(data []byte, baseURL string, cfg *config.Config) string {
 	return applyMarkdownSpecial(data, false, baseURL, cfg)
 }
 
 func applyMarkdownSpecial(data []byte, skipNoFollow bool, baseURL string, cfg *config.Config) string {
-	mdExtensions := 0 |
-		blackfriday.EXTENSION_TABLES |
-		blackfriday.EXTENSION_FENCED_CODE |
-		blackfriday.EXTENSION_AUTOLINK |
-		blackfriday.EXTENSION_STRIKETHROUGH |
-		blackfriday.EXTENSION_SPACE_HEADERS |
-		blackfriday.EXTENSION_AUTO_HEADER_IDS
+	mdExtensions := 0
+	for name, ext := range markdownExtensionFlags {
+		if cfg.App.MarkdownExtensionEnabled(name) {
+			mdExtensions |= ext
+		}
+	}
 	htmlFlags := 0 |
 		blackfriday.HTML_USE_SMARTYPANTS |
 		blackfriday.HTML_SMARTYPANTS_DASHES
+	if !cfg.App.MarkdownExtensionEnabled(config.MarkdownRawHTML) {
+		htmlFlags |= blackfriday.HTML_SKIP_HTML
+	}
 
 	if baseURL != "" {
 		htmlFlags |= blackfriday.HTML_HASHTAGS
@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/writeas/writefreely/config"
+)
+
+func TestMarkdownExtensions(t *testing.T) {
+	const table = "| a | b |\n|---|---|\n| 1 | 2 |\n"
+	const strike = "This is ~~gone~~.\n"
+
+	cfg := config.New()
+	if out := applyMarkdown([]byte(table), "", cfg); !strings.Contains(out, "<table>") {
+		t.Errorf("Table wasn't rendered by default: %s", out)
+	}
+	if out := applyMarkdown([]byte(strike), "", cfg); !strings.Contains(out, "<del>gone</del>") {
+		t.Errorf("Strikethrough wasn't rendered by default: %s", out)
+	}
+
+	cfg.App.MarkdownExtensions = []string{config.MarkdownStrikethrough}
+	if out := applyMarkdown([]byte(table), "", cfg); strings.Contains(out, "<table>") {
+		t.Errorf("Table was rendered with tables disabled: %s", out)
+	}
+	if out := applyMarkdown([]byte(strike), "", cfg); !strings.Contains(out, "<del>gone</del>") {
+		t.Errorf("Strikethrough wasn't rendered when enabled: %s", out)
+	}
+}
+
+func TestMarkdownRawHTML(t *testing.T) {
+	const content = "Some <sup>raised</sup> text.\n"
+
+	cfg := config.New()
+	if out := applyMarkdown([]byte(content), "", cfg); !strings.Contains(out, "<sup>raised</sup>") {
+		t.Errorf("Raw HTML wasn't kept by default: %s", out)
+	}
+	cfg.App.MarkdownExtensions = []string{config.MarkdownTables}
+	if out := applyMarkdown([]byte(content), "", cfg); strings.Contains(out, "<sup>") {
+		t.Errorf("Raw HTML was kept with raw_html disabled: %s", out)
+	}
+}
@@ -47,6 +47,10 @@ const (
 	userPostIDLen = 10
 	postIDLen     = 10
 
+	// Modify tokens let anonymous authors change their posts
+	modifyTokenChars = "0123456789abcdef"
+	modifyTokenLen   = 32
+
 	postMetaDateFormat = "2006-01-02 15:04:05"
 )
 
@@ -71,6 +75,9 @@ type (
 	AuthenticatedPost struct {
 		ID  string `json:"id" schema:"id"`
 		Web bool   `json:"web" schema:"web"`
+		// Token is the modify token of a post without an owner, which
+		// stands in for an access token when updating it.
+		Token string `json:"token" schema:"token"`
 		*SubmittedPost
 	}
 
@@ -110,6 +117,10 @@ type (
 		Images         []string      `json:"images,omitempty"`
 
 		OwnerName string `json:"owner,omitempty"`
+
+		// Token is the modify token of a post without an owner, only
+		// returned when it's created.
+		Token string `json:"token,omitempty"`
 	}
 
 	// PublicPost holds properties for a publicly returned post, i.e. a post in
@@ -377,7 +388,7 @@ func handleViewPost(app *App, w http.ResponseWriter, r *http.Request) error {
 			Direction:   d,
 		}
 		if !isRaw {
-			post.HTMLContent = template.HTML(applyMarkdown([]byte(content), "", app.cfg))
+			post.HTMLContent = template.HTML(applyMarkdown([]byte(content), "", app.Config()))
 		}
 	}
 
@@ -443,7 +454,7 @@ func handleViewPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		}{
 			AnonymousPost: post,
 			StaticPage:    pageForReq(app, r),
-			SiteURL:       app.cfg.App.Host,
+			SiteURL:       app.Config().App.Host,
 		}
 		if u = getUserSession(app, r); u != nil {
 			page.Username = u.Username
@@ -510,16 +521,23 @@ func newPost(app *App, w http.ResponseWriter, r *http.Request) error {
 	} else {
 		userID = app.db.GetUserID(accessToken)
 	}
-	suspended, err := app.db.IsUserSuspended(userID)
-	if err != nil {
-		log.Error("new post: %v", err)
-	}
-	if suspended {
-		return ErrUserSuspended
-	}
+	// Visitors can post without an account, but only outside of blogs
+	anonymous := userID == -1 && accessToken == "" && app.Config().App.AllowAnonymous
+	var err error
+	if !anonymous {
+		if userID == -1 {
+			return ErrNotLoggedIn
+		}
 
-	if userID == -1 {
-		return ErrNotLoggedIn
+		suspended, err := app.db.IsUserSuspended(userID)
+		if err != nil {
+			log.Error("new post: %v", err)
+		}
+		if suspended {
+			return ErrUserSuspended
+		}
+	} else if app.rateLimits != nil && !app.rateLimits.allowAnonymousPost(r) {
+		return ErrTooManyRequests
 	}
 
 	if accessToken == "" && u == nil && collAlias != "" {
@@ -573,11 +591,25 @@ func newPost(app *App, w http.ResponseWriter, r *http.Request) error {
 	if !p.isFontValid() {
 		p.Font = "norm"
 	}
+	if err = checkPostLength(app, p.Content); err != nil {
+		return err
+	}
+	flagged, err := checkBlockedWords(app, p)
+	if err != nil {
+		return err
+	}
+	// Anonymous drafts have no owner to count them under, so they're rate
+	// limited by client instead
+	if collAlias == "" && !anonymous {
+		if err = checkDraftLimit(app, userID); err != nil {
+			return err
+		}
+	}
 
 	var newPost *PublicPost = &PublicPost{}
 	var coll *Collection
 	if accessToken != "" {
-		newPost, err = app.db.CreateOwnedPost(p, accessToken, collAlias, app.cfg.App.Host)
+		newPost, err = app.db.CreateOwnedPost(p, accessToken, collAlias, app.Config().App.Host)
 	} else {
 		//return ErrNotLoggedIn
 		// TODO: verify user is logged in
@@ -587,7 +619,7 @@ func newPost(app *App, w http.ResponseWriter, r *http.Request) error {
 			if err != nil {
 				return err
 			}
-			coll.hostName = app.cfg.App.Host
+			coll.hostName = app.Config().App.Host
 			if coll.OwnerID != u.ID {
 				return ErrForbiddenCollection
 			}
@@ -604,19 +636,72 @@ func newPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		newPost.Collection = &CollectionObj{Collection: *coll}
 	}
 
+	if flagged != "" {
+		log.Error("[WARNING] Post %s was flagged for containing the blocked word '%s'.", newPost.ID, flagged)
+	}
+
 	newPost.extractData()
 	newPost.OwnerName = username
 
 	// Write success now
 	response := impart.WriteSuccess(w, newPost, http.StatusCreated)
 
-	if newPost.Collection != nil && !app.cfg.App.Private && app.cfg.App.Federation && !newPost.Created.After(time.Now()) {
-		go federatePost(app, newPost, newPost.Collection.ID, false)
+	if newPost.Collection != nil && !newPost.Created.After(time.Now()) {
+		if !app.Config().App.Private && app.Config().App.Federation {
+			go federatePost(app, newPost, newPost.Collection.ID, false)
+		}
+		go sendPostWebhook(app, config.WebhookPostPublished, newPost)
 	}
 
 	return response
 }
 
+// checkDraftLimit returns an error if the given user can't create another
+// draft.
+func checkDraftLimit(app *App, userID int64) error {
+	if app.Config().App.MaxDrafts <= 0 {
+		return nil
+	}
+	count, err := app.db.GetUserDraftsCount(userID)
+	if err != nil {
+		return ErrInternalGeneral
+	}
+	if ok, err := app.Config().App.CanCreateDraft(int(count)); !ok {
+		return impart.HTTPError{http.StatusForbidden, err.Error()}
+	}
+	return nil
+}
+
+// checkPostLength returns an error if the given post content is longer than
+// the instance allows.
+func checkPostLength(app *App, content *string) error {
+	if content != nil && app.Config().App.PostTooLong(*content) {
+		return impart.HTTPError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Post is too long. The maximum length is %d characters.", app.Config().App.MaxPostLength)}
+	}
+	return nil
+}
+
+// checkBlockedWords rejects a new or updated post whose title or content
+// contains one of the configured blocked words. When they're only flagged,
+// it returns the word found instead.
+func checkBlockedWords(app *App, p *SubmittedPost) (string, error) {
+	var text string
+	if p.Title != nil {
+		text = *p.Title + "\n"
+	}
+	if p.Content != nil {
+		text += *p.Content
+	}
+	found, word := app.Config().App.ContainsBlockedWord(text)
+	if !found {
+		return "", nil
+	}
+	if app.Config().App.BlockedWordAction == config.BlockedWordFlag {
+		return word, nil
+	}
+	return "", impart.HTTPError{http.StatusUnprocessableEntity, "Post contains a word that isn't allowed here."}
+}
+
 func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 	reqJSON := IsJSON(r)
 	vars := mux.Vars(r)
@@ -656,6 +741,13 @@ func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 	if p.SubmittedPost == nil {
 		return ErrPostNoUpdatableVals
 	}
+	if err = checkPostLength(app, p.Content); err != nil {
+		return err
+	}
+	flagged, err := checkBlockedWords(app, p.SubmittedPost)
+	if err != nil {
+		return err
+	}
 
 	// Ensure an access token was given
 	accessToken := r.Header.Get("Authorization")
@@ -669,7 +761,11 @@ func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 	if u == nil && accessToken == "" {
-		return ErrNoAccessToken
+		// Anonymous posts can only be edited with their modify token while
+		// anonymous posting is allowed
+		if p.Token == "" || !app.Config().App.AllowAnonymous {
+			return ErrNoAccessToken
+		}
 	}
 
 	// Get user ID from current session or given access token, if one was given.
@@ -683,12 +779,14 @@ func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	suspended, err := app.db.IsUserSuspended(userID)
-	if err != nil {
-		log.Error("existing post: %v", err)
-	}
-	if suspended {
-		return ErrUserSuspended
+	if userID != 0 {
+		suspended, err := app.db.IsUserSuspended(userID)
+		if err != nil {
+			log.Error("existing post: %v", err)
+		}
+		if suspended {
+			return ErrUserSuspended
+		}
 	}
 
 	// Modify post struct
@@ -705,6 +803,8 @@ func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		} else {
 			addSessionFlash(app, w, r, err.Error(), nil)
 		}
+	} else if flagged != "" {
+		log.Error("[WARNING] Post %s was flagged for containing the blocked word '%s'.", p.ID, flagged)
 	}
 
 	var pRes *PublicPost
@@ -718,10 +818,17 @@ func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 
 	if pRes.CollectionID.Valid {
 		coll, err := app.db.GetCollectionBy("id = ?", pRes.CollectionID.Int64)
-		if err == nil && !app.cfg.App.Private && app.cfg.App.Federation {
-			coll.hostName = app.cfg.App.Host
+		federate := !app.Config().App.Private && app.Config().App.Federation
+		notify := app.Config().Webhook.Sends(config.WebhookPostUpdated)
+		if err == nil && (federate || notify) {
+			coll.hostName = app.Config().App.Host
 			pRes.Collection = &CollectionObj{Collection: *coll}
-			go federatePost(app, pRes, pRes.Collection.ID, true)
+			if federate {
+				go federatePost(app, pRes, pRes.Collection.ID, true)
+			}
+			if notify {
+				go sendPostWebhook(app, config.WebhookPostUpdated, pRes)
+			}
 		}
 	}
 
@@ -735,12 +842,12 @@ func existingPost(app *App, w http.ResponseWriter, r *http.Request) error {
 	redirect := "/" + postID + "/meta"
 	if collectionAlias != "" {
 		collPre := "/" + collectionAlias
-		if app.cfg.App.SingleUser {
+		if app.Config().App.SingleUser {
 			collPre = ""
 		}
 		redirect = collPre + "/" + pRes.Slug.String + "/edit/meta"
 	} else {
-		if app.cfg.App.SingleUser {
+		if app.Config().App.SingleUser {
 			redirect = "/d" + redirect
 		}
 	}
@@ -817,7 +924,7 @@ func deletePost(app *App, w http.ResponseWriter, r *http.Request) error {
 				log.Error("Unable to get collection: %v", err)
 				return err
 			}
-			if app.cfg.App.Federation {
+			if app.Config().App.Federation || app.Config().Webhook.Sends(config.WebhookPostDeleted) {
 				// First fetch full post for federation
 				pp, err = app.db.GetOwnedPost(friendlyID, ownerID)
 				if err != nil {
@@ -859,9 +966,12 @@ func deletePost(app *App, w http.ResponseWriter, r *http.Request) error {
 	if t != nil {
 		t.Commit()
 	}
-	if coll != nil && !app.cfg.App.Private && app.cfg.App.Federation {
+	if coll != nil && !app.Config().App.Private && app.Config().App.Federation {
 		go deleteFederatedPost(app, pp, collID.Int64)
 	}
+	if coll != nil && app.Config().Webhook.Sends(config.WebhookPostDeleted) {
+		go sendPostWebhook(app, config.WebhookPostDeleted, pp)
+	}
 
 	return impart.HTTPError{Status: http.StatusNoContent}
 }
@@ -906,21 +1016,21 @@ func addPost(app *App, w http.ResponseWriter, r *http.Request) error {
 	collAlias := vars["alias"]
 
 	// Update all given posts
-	res, err := app.db.ClaimPosts(app.cfg, ownerID, collAlias, claims)
+	res, err := app.db.ClaimPosts(app.Config(), ownerID, collAlias, claims)
 	if err != nil {
 		return err
 	}
 
-	if !app.cfg.App.Private && app.cfg.App.Federation {
-		for _, pRes := range *res {
-			if pRes.Code != http.StatusOK {
-				continue
-			}
-			if !pRes.Post.Created.After(time.Now()) {
-				pRes.Post.Collection.hostName = app.cfg.App.Host
-				go federatePost(app, pRes.Post, pRes.Post.Collection.ID, false)
-			}
+	federate := !app.Config().App.Private && app.Config().App.Federation
+	for _, pRes := range *res {
+		if pRes.Code != http.StatusOK || pRes.Post.Created.After(time.Now()) {
+			continue
+		}
+		pRes.Post.Collection.hostName = app.Config().App.Host
+		if federate {
+			go federatePost(app, pRes.Post, pRes.Post.Collection.ID, false)
 		}
+		go sendPostWebhook(app, config.WebhookPostPublished, pRes.Post)
 	}
 	return impart.WriteSuccess(w, res, http.StatusOK)
 }
@@ -1061,7 +1171,7 @@ func fetchPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 	if coll != nil {
-		coll.hostName = app.cfg.App.Host
+		coll.hostName = app.Config().App.Host
 		_, err = apiCheckCollectionPermissions(app, r, coll)
 		if err != nil {
 			return err
@@ -1087,7 +1197,7 @@ func fetchPost(app *App, w http.ResponseWriter, r *http.Request) error {
 		}
 
 		p.Collection = &CollectionObj{Collection: *coll}
-		po := p.ActivityObject(app.cfg)
+		po := p.ActivityObject(app.Config())
 		po.Context = []interface{}{activitystreams.Namespace}
 		return impart.RenderActivityJSON(w, po, http.StatusOK)
 	}
@@ -1236,7 +1346,7 @@ func getRawCollectionPost(app *App, slug, collAlias string) *RawPost {
 	var views int64
 	var err error
 
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		err = app.db.QueryRow("SELECT id, title, content, text_appearance, language, rtl, view_count, created, owner_id FROM posts WHERE slug = ? AND collection_id = 1", slug).Scan(&id, &title, &content, &font, &lang, &isRTL, &views, &created, &ownerID)
 	} else {
 		err = app.db.QueryRow("SELECT id, title, content, text_appearance, language, rtl, view_count, created, owner_id FROM posts WHERE slug = ? AND collection_id = (SELECT id FROM collections WHERE alias = ?)", slug, collAlias).Scan(&id, &title, &content, &font, &lang, &isRTL, &views, &created, &ownerID)
@@ -1302,7 +1412,7 @@ func viewCollectionPost(app *App, w http.ResponseWriter, r *http.Request) error
 	// Normalize the URL, redirecting user to consistent post URL
 	if slug != strings.ToLower(slug) {
 		loc := fmt.Sprintf("/%s", strings.ToLower(slug))
-		if !app.cfg.App.SingleUser {
+		if !app.Config().App.SingleUser {
 			loc = "/" + cr.alias + loc
 		}
 		return impart.HTTPError{http.StatusMovedPermanently, loc}
@@ -1310,7 +1420,7 @@ func viewCollectionPost(app *App, w http.ResponseWriter, r *http.Request) error
 
 	// Display collection if this is a collection
 	var c *Collection
-	if app.cfg.App.SingleUser {
+	if app.Config().App.SingleUser {
 		c, err = app.db.GetCollectionByID(1)
 	} else {
 		c, err = app.db.GetCollection(cr.alias)
@@ -1327,7 +1437,7 @@ func viewCollectionPost(app *App, w http.ResponseWriter, r *http.Request) error
 		}
 		return err
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	suspended, err := app.db.IsUserSuspended(c.OwnerID)
 	if err != nil {
@@ -1392,7 +1502,7 @@ Are you sure it was ever here?`,
 	}
 	p.IsOwner = owner != nil && p.OwnerID.Valid && owner.ID == p.OwnerID.Int64
 	p.Collection = coll
-	p.IsTopLevel = app.cfg.App.SingleUser
+	p.IsTopLevel = app.Config().App.SingleUser
 
 	if !p.IsOwner && suspended {
 		return ErrPostNotFound
@@ -1428,14 +1538,14 @@ Are you sure it was ever here?`,
 			return ErrCollectionPageNotFound
 		}
 		p.extractData()
-		ap := p.ActivityObject(app.cfg)
+		ap := p.ActivityObject(app.Config())
 		ap.Context = []interface{}{activitystreams.Namespace}
 		return impart.RenderActivityJSON(w, ap, http.StatusOK)
 	} else {
 		p.extractData()
 		p.Content = strings.Replace(p.Content, "<!--more-->", "", 1)
 		// TODO: move this to function
-		p.formatContent(app.cfg, cr.isCollOwner)
+		p.formatContent(app.Config(), cr.isCollOwner)
 		tp := struct {
 			*PublicPost
 			page.StaticPage
@@ -1456,7 +1566,7 @@ Are you sure it was ever here?`,
 			Suspended:      suspended,
 		}
 		tp.IsAdmin = u != nil && u.IsAdmin()
-		tp.CanInvite = canUserInvite(app.cfg, tp.IsAdmin)
+		tp.CanInvite = canUserInvite(app.Config(), tp.IsAdmin)
 		tp.PinnedPosts, _ = app.db.GetPinnedPosts(coll, p.IsOwner)
 		tp.IsPinned = len(*tp.PinnedPosts) > 0 && PostsContains(tp.PinnedPosts, p)
 
@@ -1464,7 +1574,7 @@ Are you sure it was ever here?`,
 			w.WriteHeader(http.StatusNotFound)
 		}
 		postTmpl := "collection-post"
-		if app.cfg.App.Chorus {
+		if app.Config().App.Chorus {
 			postTmpl = "chorus-collection-post"
 		}
 		if err := templates[postTmpl].ExecuteTemplate(w, "post", tp); err != nil {
@@ -0,0 +1,135 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/writeas/impart"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestAnonymousPost(t *testing.T) {
+	db := &fakeDB{}
+	app := &App{
+		db:           &datastore{DB: db.open(t), driverName: "wffake"},
+		sessionStore: sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef")),
+	}
+	app.SetConfig(config.New())
+	post := func(target string) (*httptest.ResponseRecorder, error) {
+		r := httptest.NewRequest("POST", target, strings.NewReader(`{"title":"Hello","body":"Just passing through."}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		return w, newPost(app, w, r)
+	}
+
+	// Disabled by default
+	if _, err := post("/api/posts"); err != ErrNotLoggedIn {
+		t.Errorf("Anonymous post while disabled returned %v; expected %v", err, ErrNotLoggedIn)
+	}
+	if n := db.statements(); n != 0 {
+		t.Errorf("Anonymous post while disabled ran %d queries; expected none", n)
+	}
+
+	app.Config().App.AllowAnonymous = true
+	w, err := post("/api/posts")
+	if err != nil {
+		t.Fatalf("Anonymous post failed: %v", err)
+	}
+	if w.Code != http.StatusCreated {
+		t.Errorf("Anonymous post status = %d; expected %d", w.Code, http.StatusCreated)
+	}
+	var res struct {
+		Data struct {
+			ID    string `json:"id"`
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Unable to decode response: %v", err)
+	}
+	if res.Data.ID == "" || len(res.Data.Token) != modifyTokenLen {
+		t.Errorf("Anonymous post = %+v; expected an ID and a %d-character token", res.Data, modifyTokenLen)
+	}
+	if q := db.lastQuery(); !strings.HasPrefix(q, "INSERT INTO posts") || !strings.Contains(q, "modify_token") {
+		t.Errorf("Last query %q doesn't store the post's modify token", q)
+	}
+
+	// Blogs still need an owner
+	if _, err = post("/api/posts?collection=matt"); err == nil {
+		t.Error("Anonymous post to a blog succeeded")
+	}
+}
+
+func TestBlockedWords(t *testing.T) {
+	db := &fakeDB{}
+	app := &App{
+		db:           &datastore{DB: db.open(t), driverName: "wffake"},
+		sessionStore: sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef")),
+	}
+	app.SetConfig(config.New())
+	app.Config().App.AllowAnonymous = true
+	app.Config().App.BlockedWords = []string{"spam", "buy now"}
+	post := func(body string) (*httptest.ResponseRecorder, error) {
+		b, _ := json.Marshal(map[string]string{"body": body})
+		r := httptest.NewRequest("POST", "/api/posts", strings.NewReader(string(b)))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		return w, newPost(app, w, r)
+	}
+
+	for _, tc := range []struct {
+		Action  string
+		Body    string
+		Created bool
+	}{
+		{"", "Tired of SPAM? Me too.", false},
+		{config.BlockedWordReject, "Buy\nnow!", false},
+		{config.BlockedWordReject, "Canned spammers and spamalot.", true},
+		{config.BlockedWordFlag, "This is spam.", true},
+	} {
+		app.Config().App.BlockedWordAction = tc.Action
+		before := db.statements()
+		w, err := post(tc.Body)
+		if tc.Created {
+			if err != nil || w.Code != http.StatusCreated {
+				t.Errorf("Action %q, body %q: status %d, error %v; expected it created", tc.Action, tc.Body, w.Code, err)
+			}
+			continue
+		}
+		if herr, ok := err.(impart.HTTPError); !ok || herr.Status != http.StatusUnprocessableEntity {
+			t.Errorf("Action %q, body %q: error %v; expected a 422", tc.Action, tc.Body, err)
+		}
+		if n := db.statements() - before; n != 0 {
+			t.Errorf("Action %q, body %q: rejected post ran %d queries", tc.Action, tc.Body, n)
+		}
+	}
+
+	// Updates can't sneak words in either
+	app.Config().App.BlockedWordAction = config.BlockedWordReject
+	before := db.statements()
+	r := httptest.NewRequest("POST", "/api/posts/abcdefghij", strings.NewReader(`{"token":"x","body":"Now with spam."}`))
+	r.Header.Set("Content-Type", "application/json")
+	r = mux.SetURLVars(r, map[string]string{"post": "abcdefghij"})
+	err := existingPost(app, httptest.NewRecorder(), r)
+	if herr, ok := err.(impart.HTTPError); !ok || herr.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Update with a blocked word returned %v; expected a 422", err)
+	}
+	if n := db.statements() - before; n != 0 {
+		t.Errorf("Rejected update ran %d queries", n)
+	}
+}
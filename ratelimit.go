@@ -0,0 +1,149 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/writeas/impart"
+	"github.com/writeas/writefreely/config"
+)
+
+// rateLimiter counts requests from each client over a fixed window of time.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*rateWindow
+	lastPrune time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// newRateLimiter returns a rateLimiter that allows limit requests per window
+// from each client, or nil if limit is zero.
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: map[string]*rateWindow{},
+	}
+}
+
+// allow records a request from the given client, returning false if the
+// client has gone over the limit. A nil rateLimiter allows everything.
+func (l *rateLimiter) allow(client string) bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastPrune) > l.window {
+		for c, w := range l.clients {
+			if now.Sub(w.start) > l.window {
+				delete(l.clients, c)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	w, ok := l.clients[client]
+	if !ok || now.Sub(w.start) > l.window {
+		w = &rateWindow{start: now}
+		l.clients[client] = w
+	}
+	w.count++
+	return w.count <= l.limit
+}
+
+// rateLimits holds the limiters for each group of rate-limited routes.
+type rateLimits struct {
+	mu                            sync.RWMutex
+	login, signup, api, anonymous *rateLimiter
+	server                        config.ServerCfg
+}
+
+func newRateLimits(cfg *config.Config) *rateLimits {
+	rl := &rateLimits{server: cfg.Server}
+	rl.update(cfg.RateLimit)
+	return rl
+}
+
+// update replaces the limiters with ones for the given limits, starting
+// every client's count over.
+func (rl *rateLimits) update(cfg config.RateLimitCfg) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.login = newRateLimiter(cfg.LoginPerMinute, time.Minute)
+	rl.signup = newRateLimiter(cfg.SignupPerHour, time.Hour)
+	rl.api = newRateLimiter(cfg.APIPerMinute, time.Minute)
+	rl.anonymous = newRateLimiter(cfg.AnonymousPostsPerHour, time.Hour)
+}
+
+// allowAnonymousPost records a post made without an account, returning
+// false if the client has made too many. Handlers check this themselves,
+// since the route is shared with posts from signed-in users.
+func (rl *rateLimits) allowAnonymousPost(r *http.Request) bool {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.anonymous.allow(rl.server.RealIP(r))
+}
+
+// middleware responds with a 429 Too Many Requests error when a client goes
+// over the limit for the route it's requesting.
+func (rl *rateLimits) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, l := range rl.limitersFor(r) {
+			if !l.allow(rl.server.RealIP(r)) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(l.window.Seconds())))
+				if IsJSON(r) || strings.HasPrefix(r.URL.Path, "/api/") {
+					impart.WriteError(w, ErrTooManyRequests)
+				} else {
+					http.Error(w, ErrTooManyRequests.Message, ErrTooManyRequests.Status)
+				}
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitersFor returns the limiters that apply to the given request.
+func (rl *rateLimits) limitersFor(r *http.Request) []*rateLimiter {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	var ls []*rateLimiter
+	if r.Method == http.MethodPost {
+		switch r.URL.Path {
+		case "/api/auth/login", "/auth/login":
+			ls = append(ls, rl.login)
+		case "/api/auth/signup", "/auth/signup":
+			ls = append(ls, rl.signup)
+		}
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		ls = append(ls, rl.api)
+	}
+	return ls
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	rl := newRateLimits(&config.Config{RateLimit: config.RateLimitCfg{LoginPerMinute: 2}})
+	h := rl.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	login := func(addr string) int {
+		req := httptest.NewRequest("POST", "/auth/login", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i := 0; i < 2; i++ {
+		if code := login("192.0.2.1:1234"); code != http.StatusOK {
+			t.Fatalf("Login %d = %d; expected %d", i+1, code, http.StatusOK)
+		}
+	}
+	if code := login("192.0.2.1:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("Login over limit = %d; expected %d", code, http.StatusTooManyRequests)
+	}
+	if code := login("192.0.2.2:1234"); code != http.StatusOK {
+		t.Errorf("Login from another client = %d; expected %d", code, http.StatusOK)
+	}
+
+	// Zero limits mean unlimited
+	for i := 0; i < 50; i++ {
+		req := httptest.NewRequest("GET", "/api/me", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unlimited API request %d = %d; expected %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+
+	// Updated limits apply right away, with counts started over
+	rl.update(config.RateLimitCfg{LoginPerMinute: 1})
+	if code := login("192.0.2.1:1234"); code != http.StatusOK {
+		t.Errorf("Login after update = %d; expected %d", code, http.StatusOK)
+	}
+	if code := login("192.0.2.1:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("Login over updated limit = %d; expected %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestAnonymousPostLimit(t *testing.T) {
+	db := &fakeDB{}
+	cfg := config.New()
+	cfg.App.AllowAnonymous = true
+	cfg.RateLimit.AnonymousPostsPerHour = 1
+	app := newTestApp(cfg)
+	app.db = &datastore{DB: db.open(t), driverName: "wffake"}
+	app.sessionStore = sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	app.rateLimits = newRateLimits(cfg)
+	post := func(addr string) error {
+		r := httptest.NewRequest("POST", "/api/posts", strings.NewReader(`{"body":"Just passing through."}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.RemoteAddr = addr
+		return newPost(app, httptest.NewRecorder(), r)
+	}
+
+	if err := post("192.0.2.1:1234"); err != nil {
+		t.Fatalf("First anonymous post failed: %v", err)
+	}
+	if err := post("192.0.2.1:5678"); err != ErrTooManyRequests {
+		t.Errorf("Anonymous post over the limit returned %v; expected %v", err, ErrTooManyRequests)
+	}
+	if err := post("192.0.2.2:1234"); err != nil {
+		t.Errorf("Anonymous post from another client failed: %v", err)
+	}
+}
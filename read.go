@@ -91,7 +91,7 @@ func (app *App) FetchPublicPosts() (interface{}, error) {
 			log.Error("[READ] Unable to scan row, skipping: %v", err)
 			continue
 		}
-		c.hostName = app.cfg.App.Host
+		c.hostName = app.Config().App.Host
 
 		isCollectionPost := alias.Valid
 		if isCollectionPost {
@@ -106,7 +106,7 @@ func (app *App) FetchPublicPosts() (interface{}, error) {
 		}
 
 		p.extractData()
-		p.HTMLContent = template.HTML(applyMarkdown([]byte(p.Content), "", app.cfg))
+		p.HTMLContent = template.HTML(applyMarkdown([]byte(p.Content), "", app.Config()))
 		fp := p.processPost()
 		if isCollectionPost {
 			fp.Collection = &CollectionObj{Collection: *c}
@@ -119,7 +119,25 @@ func (app *App) FetchPublicPosts() (interface{}, error) {
 	return posts, nil
 }
 
+// canViewLocalTimeline returns whether the local timeline is built and
+// shown to the user making the request. Admins can see it even when it
+// isn't public, to moderate it.
+func canViewLocalTimeline(app *App, r *http.Request) bool {
+	if app.Config().App.ShowsLocalTimeline() {
+		return true
+	}
+	if !app.Config().App.LocalTimeline {
+		return false
+	}
+	u := getUserSession(app, r)
+	return u != nil && u.IsAdmin()
+}
+
 func viewLocalTimelineAPI(app *App, w http.ResponseWriter, r *http.Request) error {
+	if !canViewLocalTimeline(app, r) {
+		return impart.HTTPError{http.StatusNotFound, "Page doesn't exist."}
+	}
+
 	updateTimelineCache(app.timeline)
 
 	skip, _ := strconv.Atoi(r.FormValue("skip"))
@@ -133,7 +151,7 @@ func viewLocalTimelineAPI(app *App, w http.ResponseWriter, r *http.Request) erro
 }
 
 func viewLocalTimeline(app *App, w http.ResponseWriter, r *http.Request) error {
-	if !app.cfg.App.LocalTimeline {
+	if !canViewLocalTimeline(app, r) {
 		return impart.HTTPError{http.StatusNotFound, "Page doesn't exist."}
 	}
 
@@ -212,17 +230,17 @@ func showLocalTimeline(app *App, w http.ResponseWriter, r *http.Request, page in
 		TotalPages:  ttlPages,
 		SelTopic:    tag,
 	}
-	if app.cfg.App.Chorus {
+	if app.Config().App.Chorus {
 		u := getUserSession(app, r)
 		d.IsAdmin = u != nil && u.IsAdmin()
-		d.CanInvite = canUserInvite(app.cfg, d.IsAdmin)
+		d.CanInvite = canUserInvite(app.Config(), d.IsAdmin)
 	}
 	c, err := getReaderSection(app)
 	if err != nil {
 		return err
 	}
 	d.ContentTitle = c.Title.String
-	d.Content = template.HTML(applyMarkdown([]byte(c.Content), "", app.cfg))
+	d.Content = template.HTML(applyMarkdown([]byte(c.Content), "", app.Config()))
 
 	err = templates["read"].ExecuteTemplate(w, "base", d)
 	if err != nil {
@@ -260,30 +278,30 @@ func handlePostIDRedirect(app *App, w http.ResponseWriter, r *http.Request) erro
 	if !p.CollectionID.Valid {
 		// No collection; send to normal URL
 		// NOTE: not handling single user blogs here since this handler is only used for the Reader
-		return impart.HTTPError{http.StatusFound, app.cfg.App.Host + "/" + postID + ".md"}
+		return impart.HTTPError{http.StatusFound, app.Config().App.Host + "/" + postID + ".md"}
 	}
 
 	c, err := app.db.GetCollectionBy("id = ?", fmt.Sprintf("%d", p.CollectionID.Int64))
 	if err != nil {
 		return err
 	}
-	c.hostName = app.cfg.App.Host
+	c.hostName = app.Config().App.Host
 
 	// Retrieve collection information and send user to canonical URL
 	return impart.HTTPError{http.StatusFound, c.CanonicalURL() + p.Slug.String}
 }
 
 func viewLocalTimelineFeed(app *App, w http.ResponseWriter, req *http.Request) error {
-	if !app.cfg.App.LocalTimeline {
+	if !canViewLocalTimeline(app, req) {
 		return impart.HTTPError{http.StatusNotFound, "Page doesn't exist."}
 	}
 
 	updateTimelineCache(app.timeline)
 
 	feed := &Feed{
-		Title:       app.cfg.App.SiteName + " Reader",
-		Link:        &Link{Href: app.cfg.App.Host},
-		Description: "Read the latest posts from " + app.cfg.App.SiteName + ".",
+		Title:       app.Config().App.SiteName + " Reader",
+		Link:        &Link{Href: app.Config().App.Host},
+		Description: "Read the latest posts from " + app.Config().App.SiteName + ".",
 		Created:     time.Now(),
 	}
 
@@ -295,7 +313,7 @@ func viewLocalTimelineFeed(app *App, w http.ResponseWriter, req *http.Request) e
 		}
 
 		title = p.PlainDisplayTitle()
-		permalink = p.CanonicalURL(app.cfg.App.Host)
+		permalink = p.CanonicalURL(app.Config().App.Host)
 		if p.Collection != nil {
 			author = p.Collection.Title
 		} else {
@@ -303,11 +321,11 @@ func viewLocalTimelineFeed(app *App, w http.ResponseWriter, req *http.Request) e
 			permalink += ".md"
 		}
 		i := &Item{
-			Id:          app.cfg.App.Host + "/read/a/" + p.ID,
+			Id:          app.Config().App.Host + "/read/a/" + p.ID,
 			Title:       title,
 			Link:        &Link{Href: permalink},
 			Description: "<![CDATA[" + stripmd.Strip(p.Content) + "]]>",
-			Content:     applyMarkdown([]byte(p.Content), "", app.cfg),
+			Content:     applyMarkdown([]byte(p.Content), "", app.Config()),
 			Author:      &Author{author, ""},
 			Created:     p.Created,
 			Updated:     p.Updated,
@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/writeas/impart"
+	"github.com/writeas/web-core/memo"
+	"github.com/writeas/writefreely/config"
+	"github.com/writeas/writefreely/key"
+)
+
+func TestLocalTimelineVisibility(t *testing.T) {
+	app := &App{
+		keys: &key.Keychain{
+			CookieAuthKey: []byte("0123456789abcdef0123456789abcdef"),
+			CookieKey:     []byte("0123456789abcdef0123456789abcdef"),
+		},
+	}
+	app.SetConfig(config.New())
+	app.InitSession()
+	app.timeline = &localTimeline{
+		postsPerPage: tlPostsPerPage,
+		m: memo.New(func() (interface{}, error) {
+			return []PublicPost{}, nil
+		}, time.Hour),
+	}
+
+	sessionCookie := func(id int64) *http.Cookie {
+		req := httptest.NewRequest("GET", "/", nil)
+		session, _ := app.sessionStore.Get(req, app.Config().Session.Name())
+		session.Values[cookieUserVal] = &User{ID: id, Username: "user"}
+		w := httptest.NewRecorder()
+		if err := session.Save(req, w); err != nil {
+			t.Fatalf("Unable to save session: %v", err)
+		}
+		return w.Result().Cookies()[0]
+	}
+	admin, user := sessionCookie(1), sessionCookie(2)
+
+	for _, tc := range []struct {
+		Name     string
+		Built    bool
+		Public   bool
+		Cookie   *http.Cookie
+		Expected bool
+	}{
+		{"Off", false, true, admin, false},
+		{"Public", true, true, nil, true},
+		{"Hidden", true, false, nil, false},
+		{"Hidden from users", true, false, user, false},
+		{"Hidden but admin", true, false, admin, true},
+	} {
+		app.Config().App.LocalTimeline = tc.Built
+		app.Config().App.LocalTimelinePublic = tc.Public
+
+		for name, h := range map[string]handlerFunc{
+			"API":  viewLocalTimelineAPI,
+			"Feed": viewLocalTimelineFeed,
+		} {
+			req := httptest.NewRequest("GET", "/read/", nil)
+			if tc.Cookie != nil {
+				req.AddCookie(tc.Cookie)
+			}
+			err := h(app, httptest.NewRecorder(), req)
+			herr, ok := err.(impart.HTTPError)
+			if tc.Expected && err != nil {
+				t.Errorf("%s: %s returned %v; expected it to be shown", tc.Name, name, err)
+			} else if !tc.Expected && (!ok || herr.Status != http.StatusNotFound) {
+				t.Errorf("%s: %s returned %v; expected a 404", tc.Name, name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/writeas/writefreely/config"
+)
+
+const sessionKeyPrefix = "wf_session_"
+
+// sessionCache holds encoded session values outside of the session cookie,
+// so they can be shared between app instances.
+type sessionCache interface {
+	Get(key string) (string, bool, error)
+	Set(key, val string, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// cacheStore is a sessions.Store that keeps only the session ID in the
+// cookie, and the session values in a sessionCache.
+type cacheStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+
+	cache sessionCache
+}
+
+func newCacheStore(cache sessionCache, keyPairs ...[]byte) *cacheStore {
+	return &cacheStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: sessionLength,
+		},
+		cache: cache,
+	}
+}
+
+// Get returns a session for the given name after adding it to the registry.
+func (s *cacheStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name without adding it to the
+// registry, loading its values from the cache if the request has a session
+// cookie.
+func (s *cacheStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
+	if err != nil {
+		return session, err
+	}
+	val, ok, err := s.cache.Get(sessionKeyPrefix + session.ID)
+	if err != nil || !ok {
+		return session, err
+	}
+	err = securecookie.DecodeMulti(name, val, &session.Values, s.Codecs...)
+	if err != nil {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save writes the session's values to the cache and its ID to the response,
+// or deletes both when the session's MaxAge is below zero.
+func (s *cacheStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.cache.Delete(sessionKeyPrefix + session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	val, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	// A MaxAge of zero makes the cookie last for the browser session, but
+	// the cached values still need to expire
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if ttl == 0 {
+		ttl = time.Duration(s.Options.MaxAge) * time.Second
+	}
+	if ttl <= 0 {
+		ttl = sessionLength * time.Second
+	}
+	if err = s.cache.Set(sessionKeyPrefix+session.ID, val, ttl); err != nil {
+		return err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// redisCache is a sessionCache backed by a Redis server, through a pool of
+// connections that requests share.
+type redisCache struct {
+	pool *redis.Pool
+}
+
+func newRedisCache(cfg config.CacheCfg) *redisCache {
+	return &redisCache{pool: &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			conn, err := redis.Dial("tcp", cfg.RedisAddr(),
+				redis.DialPassword(cfg.RedisPassword),
+				redis.DialDatabase(cfg.RedisDB),
+				redis.DialConnectTimeout(5*time.Second),
+				redis.DialReadTimeout(5*time.Second),
+				redis.DialWriteTimeout(5*time.Second))
+			if err != nil {
+				return nil, fmt.Errorf("Unable to connect to Redis: %s", err)
+			}
+			return conn, nil
+		},
+		TestOnBorrow: func(conn redis.Conn, idleSince time.Time) error {
+			if time.Since(idleSince) < time.Minute {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}}
+}
+
+func (rc *redisCache) Get(key string) (string, bool, error) {
+	conn := rc.pool.Get()
+	defer conn.Close()
+	val, err := redis.String(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return val, true, nil
+}
+
+// Set stores val under key for ttl, rounded down to the millisecond. A ttl
+// shorter than that keeps val until it's deleted.
+func (rc *redisCache) Set(key, val string, ttl time.Duration) error {
+	conn := rc.pool.Get()
+	defer conn.Close()
+	var err error
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		_, err = conn.Do("SET", key, val, "PX", ms)
+	} else {
+		_, err = conn.Do("SET", key, val)
+	}
+	return err
+}
+
+func (rc *redisCache) Delete(key string) error {
+	conn := rc.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", key)
+	return err
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type memSessionCache map[string]string
+
+func (c memSessionCache) Get(key string) (string, bool, error) {
+	val, ok := c[key]
+	return val, ok, nil
+}
+
+func (c memSessionCache) Set(key, val string, ttl time.Duration) error {
+	c[key] = val
+	return nil
+}
+
+func (c memSessionCache) Delete(key string) error {
+	delete(c, key)
+	return nil
+}
+
+func TestCacheStore(t *testing.T) {
+	cache := memSessionCache{}
+	store := newCacheStore(cache, []byte("0123456789abcdef0123456789abcdef"))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	session, err := store.Get(r, "wfu")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !session.IsNew {
+		t.Error("Session without a cookie isn't new")
+	}
+	session.Values[cookieUserVal] = "matt"
+	w := httptest.NewRecorder()
+	if err = session.Save(r, w); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(cache) != 1 {
+		t.Fatalf("Cache has %d sessions; expected 1", len(cache))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || strings.Contains(cookies[0].Value, "matt") {
+		t.Fatalf("Expected one cookie holding only the session ID; got %v", cookies)
+	}
+
+	// Another instance sharing the cache and keys sees the same session
+	other := newCacheStore(cache, []byte("0123456789abcdef0123456789abcdef"))
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	session, err = other.New(r, "wfu")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if session.IsNew || session.Values[cookieUserVal] != "matt" {
+		t.Errorf("Loaded session = %v (new: %t); expected saved values", session.Values, session.IsNew)
+	}
+
+	session.Options.MaxAge = -1
+	if err = other.Save(r, httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("Deleting session failed: %v", err)
+	}
+	if len(cache) != 0 {
+		t.Errorf("Cache has %d sessions after delete; expected 0", len(cache))
+	}
+}
+
+// fakeRedisConn is a redis.Conn that answers GET, SET, and DEL from a map,
+// keeping the last command it was sent.
+type fakeRedisConn struct {
+	vals map[string]string
+	last []interface{}
+}
+
+func (c *fakeRedisConn) Close() error { return nil }
+func (c *fakeRedisConn) Err() error   { return nil }
+func (c *fakeRedisConn) Flush() error { return nil }
+
+func (c *fakeRedisConn) Send(cmd string, args ...interface{}) error {
+	return errors.New("Send isn't supported")
+}
+
+func (c *fakeRedisConn) Receive() (interface{}, error) {
+	return nil, errors.New("Receive isn't supported")
+}
+
+func (c *fakeRedisConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		// Sent by the pool to flush a connection before reusing it
+		return nil, nil
+	}
+	c.last = append([]interface{}{cmd}, args...)
+	key := args[0].(string)
+	switch cmd {
+	case "GET":
+		if val, ok := c.vals[key]; ok {
+			return []byte(val), nil
+		}
+		return nil, nil
+	case "SET":
+		c.vals[key] = args[1].(string)
+		return "OK", nil
+	case "DEL":
+		delete(c.vals, key)
+		return int64(1), nil
+	}
+	return nil, redis.Error("ERR unknown command '" + cmd + "'")
+}
+
+func TestRedisCache(t *testing.T) {
+	conn := &fakeRedisConn{vals: map[string]string{}}
+	rc := &redisCache{pool: &redis.Pool{
+		Dial: func() (redis.Conn, error) { return conn, nil },
+	}}
+
+	if _, ok, err := rc.Get("missing"); ok || err != nil {
+		t.Errorf("Get of a missing key = %t, %v; expected false, nil", ok, err)
+	}
+	if err := rc.Set("key", "a value", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if fmt.Sprint(conn.last) != "[SET key a value PX 3600000]" {
+		t.Errorf("Set sent %v; expected an expiry of an hour", conn.last)
+	}
+	if val, ok, err := rc.Get("key"); val != "a value" || !ok || err != nil {
+		t.Errorf("Get = %q, %t, %v; expected the value that was set", val, ok, err)
+	}
+
+	// Redis rejects an expiry of zero
+	if err := rc.Set("key", "a value", 0); err != nil {
+		t.Fatalf("Set without a ttl failed: %v", err)
+	}
+	if fmt.Sprint(conn.last) != "[SET key a value]" {
+		t.Errorf("Set without a ttl sent %v; expected no expiry", conn.last)
+	}
+
+	if err := rc.Delete("key"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok, _ := rc.Get("key"); ok {
+		t.Error("Get found a deleted key")
+	}
+}
+
+// ttlSessionCache is a memSessionCache that records the ttl of each value.
+type ttlSessionCache struct {
+	memSessionCache
+	ttls map[string]time.Duration
+}
+
+func (c ttlSessionCache) Set(key, val string, ttl time.Duration) error {
+	c.ttls[key] = ttl
+	return c.memSessionCache.Set(key, val, ttl)
+}
+
+func TestCacheStoreBrowserSession(t *testing.T) {
+	cache := ttlSessionCache{memSessionCache{}, map[string]time.Duration{}}
+	store := newCacheStore(cache, []byte("0123456789abcdef0123456789abcdef"))
+	store.Options.MaxAge = 0
+
+	r := httptest.NewRequest("GET", "/", nil)
+	session, err := store.Get(r, "wfu")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err = session.Save(r, httptest.NewRecorder()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(cache.ttls) != 1 {
+		t.Fatalf("Cache has %d sessions; expected 1", len(cache.ttls))
+	}
+	for _, ttl := range cache.ttls {
+		if ttl != sessionLength*time.Second {
+			t.Errorf("Browser session cached for %s; expected the default session length", ttl)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"sync"
+	"time"
+)
+
+// remoteCache keeps responses fetched from other instances in memory, so
+// the same remote resources aren't fetched again and again. A nil
+// remoteCache, or one with no TTL, doesn't cache anything.
+type remoteCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]remoteCacheEntry
+}
+
+type remoteCacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+func newRemoteCache(ttl time.Duration) *remoteCache {
+	return &remoteCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]remoteCacheEntry{},
+	}
+}
+
+// Get returns the cached response for the given IRI, calling fetch and
+// caching its response if there isn't one, or it has expired. Failed
+// fetches aren't cached.
+func (c *remoteCache) Get(iri string, fetch func(iri string) ([]byte, error)) ([]byte, error) {
+	if c == nil || c.ttl <= 0 {
+		return fetch(iri)
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	e, ok := c.entries[iri]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.data, nil
+	}
+
+	data, err := fetch(iri)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[iri] = remoteCacheEntry{data: data, expires: now.Add(c.ttl)}
+	c.prune(now)
+	c.mu.Unlock()
+	return data, nil
+}
+
+// prune removes expired entries. The caller must hold c.mu.
+func (c *remoteCache) prune(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRemoteCache(t *testing.T) {
+	fetches := 0
+	fetch := func(iri string) ([]byte, error) {
+		fetches++
+		return []byte(iri), nil
+	}
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := newRemoteCache(time.Hour)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		data, err := c.Get("https://example.com/users/matt", fetch)
+		if err != nil || string(data) != "https://example.com/users/matt" {
+			t.Fatalf("Get = %q, %v", data, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Fetched %d times within the TTL; expected 1", fetches)
+	}
+
+	now = now.Add(time.Hour)
+	c.Get("https://example.com/users/matt", fetch)
+	if fetches != 2 {
+		t.Errorf("Fetched %d times after the TTL; expected 2", fetches)
+	}
+
+	// Failures are returned, and not cached
+	_, err := c.Get("https://down.example.com/users/matt", func(string) ([]byte, error) {
+		return nil, errors.New("timeout")
+	})
+	if err == nil {
+		t.Error("Expected fetch error")
+	}
+	c.Get("https://down.example.com/users/matt", fetch)
+	if fetches != 3 {
+		t.Errorf("Fetched %d times after a failure; expected 3", fetches)
+	}
+
+	for _, disabled := range []*remoteCache{nil, newRemoteCache(0)} {
+		fetches = 0
+		disabled.Get("https://example.com/users/matt", fetch)
+		disabled.Get("https://example.com/users/matt", fetch)
+		if fetches != 2 {
+			t.Errorf("Disabled cache fetched %d times; expected 2", fetches)
+		}
+	}
+}
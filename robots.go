@@ -0,0 +1,46 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+const (
+	robotsAllowAll    = "User-agent: *\nDisallow:\n"
+	robotsDisallowAll = "User-agent: *\nDisallow: /\n"
+)
+
+// handleRobots serves /robots.txt from the configured robots_txt_path, or
+// generates one that allows or disallows all crawlers.
+func handleRobots(cfg config.AppCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := []byte(robotsAllowAll)
+		if cfg.DisallowCrawlers {
+			body = []byte(robotsDisallowAll)
+		}
+		if cfg.RobotsTxtPath != "" {
+			var err error
+			body, err = ioutil.ReadFile(cfg.RobotsTxtPath)
+			if err != nil {
+				log.Error("Unable to read robots_txt_path: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(body)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/writeas/writefreely/config"
+)
+
+func TestRobots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfrobots")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	custom := "User-agent: GPTBot\nDisallow: /\n"
+	fname := filepath.Join(dir, "robots.txt")
+	if err = ioutil.WriteFile(fname, []byte(custom), 0644); err != nil {
+		t.Fatalf("Unable to write robots.txt: %v", err)
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		Cfg      config.AppCfg
+		Status   int
+		Expected string
+	}{
+		{"Default", config.AppCfg{}, http.StatusOK, robotsAllowAll},
+		{"Disallowed", config.AppCfg{DisallowCrawlers: true}, http.StatusOK, robotsDisallowAll},
+		{"Custom file", config.AppCfg{DisallowCrawlers: true, RobotsTxtPath: fname}, http.StatusOK, custom},
+		{"Missing file", config.AppCfg{RobotsTxtPath: filepath.Join(dir, "missing.txt")}, http.StatusInternalServerError, ""},
+	} {
+		w := httptest.NewRecorder()
+		handleRobots(tc.Cfg)(w, httptest.NewRequest("GET", "/robots.txt", nil))
+		if w.Code != tc.Status {
+			t.Errorf("%s: status = %d; expected %d", tc.Name, w.Code, tc.Status)
+			continue
+		}
+		if tc.Status == http.StatusOK && w.Body.String() != tc.Expected {
+			t.Errorf("%s: body = %q; expected %q", tc.Name, w.Body.String(), tc.Expected)
+		}
+	}
+}
@@ -18,27 +18,53 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/writeas/go-webfinger"
 	"github.com/writeas/web-core/log"
-	"github.com/writefreely/go-nodeinfo"
+	"github.com/writeas/writefreely/config"
 )
 
 // InitStaticRoutes adds routes for serving static files.
 // TODO: this should just be a func, not method
 func (app *App) InitStaticRoutes(r *mux.Router) {
 	// Handle static files
-	fs := http.FileServer(http.Dir(filepath.Join(app.cfg.Server.StaticParentDir, staticDir)))
+	fs := http.FileServer(http.Dir(filepath.Join(app.Config().Server.StaticParentDir, staticDir)))
 	app.shttp = http.NewServeMux()
 	app.shttp.Handle("/", fs)
+	if themesDir, err := app.Config().App.ThemesPath(); err == nil && themesDir != "" {
+		// Serve custom themes before the built-in ones
+		css := themeFileServer(themesDir, fs)
+		app.shttp.Handle("/css/", css)
+		r.PathPrefix("/css/").Handler(css)
+	}
+	if app.Config().App.CustomCSSPath != "" {
+		app.handleStaticFile(r, customCSSURL, http.HandlerFunc(app.handleCustomCSS))
+	}
+	if app.Config().App.FaviconPath != "" {
+		favicon := brandFileServer(app.Config().App.FaviconPath)
+		app.handleStaticFile(r, app.Config().App.FaviconURL(), favicon)
+		// Browsers and templates that don't know the configured path still
+		// ask for the default one
+		app.handleStaticFile(r, "/favicon.ico", favicon)
+	}
+	if app.Config().App.LogoPath != "" {
+		app.handleStaticFile(r, app.Config().App.LogoURL(), brandFileServer(app.Config().App.LogoPath))
+	}
 	r.PathPrefix("/").Handler(fs)
 }
 
+// handleStaticFile serves the given path with h, both directly and when
+// dynamic routes hand it to the static file server.
+func (app *App) handleStaticFile(r *mux.Router, path string, h http.Handler) {
+	app.shttp.Handle(path, h)
+	r.Handle(path, h)
+}
+
 // InitRoutes adds dynamic routes for the given mux.Router.
 func InitRoutes(apper Apper, r *mux.Router) *mux.Router {
 	// Create handler
 	handler := NewWFHandler(apper)
 
 	// Set up routes
-	hostSubroute := apper.App().cfg.App.Host[strings.Index(apper.App().cfg.App.Host, "://")+3:]
-	if apper.App().cfg.App.SingleUser {
+	hostSubroute := apper.App().Config().App.Host[strings.Index(apper.App().Config().App.Host, "://")+3:]
+	if apper.App().Config().App.SingleUser {
 		hostSubroute = "{domain}"
 	} else {
 		if strings.HasPrefix(hostSubroute, "localhost") {
@@ -46,17 +72,51 @@ func InitRoutes(apper Apper, r *mux.Router) *mux.Router {
 		}
 	}
 
-	if apper.App().cfg.App.SingleUser {
+	if apper.App().Config().App.SingleUser {
 		log.Info("Adding %s routes (single user)...", hostSubroute)
 	} else {
 		log.Info("Adding %s routes (multi-user)...", hostSubroute)
 	}
 
+	if apper.App().Config().Server.EnforceCanonicalHost {
+		r.Use(canonicalHostMiddleware(apper.App().Config()))
+	}
+
+	if csp := apper.App().Config().App.CSP; csp != "" {
+		r.Use(cspMiddleware(csp))
+	}
+
+	if cfg := apper.App().Config().Server; cfg.MetricsEnabled {
+		m := newAppMetrics(apper.App())
+		r.Use(m.middleware)
+		r.Handle(cfg.MetricsEndpoint(), m.handler(cfg.MetricsToken)).Methods("GET")
+	}
+
+	if cfg := apper.App().Config().Server; !cfg.HealthDisabled {
+		r.HandleFunc(cfg.HealthEndpoint(), handleHealth).Methods("GET", "HEAD")
+		r.HandleFunc(cfg.ReadyEndpoint(), handleReady(apper.App())).Methods("GET", "HEAD")
+	}
+
+	r.HandleFunc("/robots.txt", handleRobots(apper.App().Config().App)).Methods("GET", "HEAD")
+
 	// Primary app routes
 	write := r.PathPrefix("/").Subrouter()
+	if len(apper.App().Config().App.CORSOrigins) > 0 {
+		write.Use(corsMiddleware(apper.App().Config().App))
+		write.PathPrefix("/api/").Methods("OPTIONS").HandlerFunc(handleCORSPreflight)
+	}
+	if max, uploadMax := apper.App().Config().App.MaxAPIBodyBytes, apper.App().Config().Storage.MaxUploadBytes; max > 0 || uploadMax > 0 {
+		write.Use(apiBodyLimit(max, uploadMax))
+	}
+	apper.App().rateLimits = newRateLimits(apper.App().Config())
+	write.Use(apper.App().rateLimits.middleware)
+	write.Use(maintenanceMiddleware(apper.App()))
+	if apper.App().Config().App.NormalizeURLs {
+		write.Use(normalizeURLsMiddleware(apper.App().Config()))
+	}
 
 	// Federation endpoint configurations
-	wf := webfinger.Default(wfResolver{apper.App().db, apper.App().cfg})
+	wf := webfinger.Default(wfResolver{apper.App().db, apper.App().Config()})
 	wf.NoTLSHandler = nil
 
 	// Federation endpoints
@@ -65,20 +125,18 @@ func InitRoutes(apper Apper, r *mux.Router) *mux.Router {
 	// webfinger
 	write.HandleFunc(webfinger.WebFingerPath, handler.LogHandlerFunc(http.HandlerFunc(wf.Webfinger)))
 	// nodeinfo
-	niCfg := nodeInfoConfig(apper.App().db, apper.App().cfg)
-	ni := nodeinfo.NewService(*niCfg, nodeInfoResolver{apper.App().cfg, apper.App().db})
-	write.HandleFunc(nodeinfo.NodeInfoPath, handler.LogHandlerFunc(http.HandlerFunc(ni.NodeInfoDiscover)))
-	write.HandleFunc(niCfg.InfoURL, handler.LogHandlerFunc(http.HandlerFunc(ni.NodeInfo)))
+	nodeInfoRoutes(write, handler, apper.App())
 
 	// Set up dyamic page handlers
 	// Handle auth
 	auth := write.PathPrefix("/api/auth/").Subrouter()
-	if apper.App().cfg.App.OpenRegistration {
+	if apper.App().Config().App.Registration() != config.RegistrationClosed {
 		auth.HandleFunc("/signup", handler.All(apiSignup)).Methods("POST")
 	}
 	auth.HandleFunc("/login", handler.All(login)).Methods("POST")
 	auth.HandleFunc("/read", handler.WebErrors(handleWebCollectionUnlock, UserLevelNone)).Methods("POST")
 	auth.HandleFunc("/me", handler.All(handleAPILogout)).Methods("DELETE")
+	auth.HandleFunc("/me/rotate", handler.All(handleAPIRotateToken)).Methods("POST")
 
 	// Handle logged in user sections
 	me := write.PathPrefix("/me").Subrouter()
@@ -140,6 +198,10 @@ func InitRoutes(apper Apper, r *mux.Router) *mux.Router {
 
 	write.HandleFunc("/auth/signup", handler.Web(handleWebSignup, UserLevelNoneRequired)).Methods("POST")
 	write.HandleFunc("/auth/login", handler.Web(webLogin, UserLevelNoneRequired)).Methods("POST")
+	if apper.App().Config().OAuth.Enabled() {
+		write.HandleFunc("/oauth/login", handler.Web(handleOAuthLogin, UserLevelOptional)).Methods("GET")
+		write.HandleFunc("/oauth/callback", handler.Web(handleOAuthCallback, UserLevelOptional)).Methods("GET")
+	}
 
 	write.HandleFunc("/admin", handler.Admin(handleViewAdminDash)).Methods("GET")
 	write.HandleFunc("/admin/users", handler.Admin(handleViewAdminUsers)).Methods("GET")
@@ -160,7 +222,7 @@ func InitRoutes(apper Apper, r *mux.Router) *mux.Router {
 	RouteRead(handler, UserLevelReader, write.PathPrefix("/read").Subrouter())
 
 	draftEditPrefix := ""
-	if apper.App().cfg.App.SingleUser {
+	if apper.App().Config().App.SingleUser {
 		draftEditPrefix = "/d"
 		write.HandleFunc("/me/new", handler.Web(handleViewPad, UserLevelOptional)).Methods("GET")
 	} else {
@@ -171,7 +233,7 @@ func InitRoutes(apper Apper, r *mux.Router) *mux.Router {
 	write.HandleFunc(draftEditPrefix+"/{action}/edit", handler.Web(handleViewPad, UserLevelOptional)).Methods("GET")
 	write.HandleFunc(draftEditPrefix+"/{action}/meta", handler.Web(handleViewMeta, UserLevelOptional)).Methods("GET")
 	// Collections
-	if apper.App().cfg.App.SingleUser {
+	if apper.App().Config().App.SingleUser {
 		RouteCollections(handler, write.PathPrefix("/").Subrouter())
 	} else {
 		write.HandleFunc("/{prefix:[@~$!\\-+]}{collection}", handler.Web(handleViewCollection, UserLevelReader))
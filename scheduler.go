@@ -0,0 +1,89 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"time"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+// postScheduler wakes up every interval to publish the scheduled posts that
+// have come due and haven't been published yet. Scheduled posts already show up on their
+// blogs once their time comes, so publishing them means sending them to
+// followers, which can't happen when they're created.
+type postScheduler struct {
+	interval time.Duration
+	publish  func(now time.Time)
+}
+
+// newPostScheduler returns a scheduler for the app, or nil when the
+// scheduled_publish_interval is zero or empty.
+func newPostScheduler(app *App) *postScheduler {
+	d := app.Config().App.ScheduledPublishDuration()
+	if d <= 0 {
+		return nil
+	}
+	return &postScheduler{
+		interval: d,
+		publish: func(now time.Time) {
+			publishDuePosts(app, now)
+		},
+	}
+}
+
+// run publishes due posts every interval until stop is closed.
+func (s *postScheduler) run(stop <-chan struct{}) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C:
+			s.publish(now)
+		}
+	}
+}
+
+// publishDuePosts federates the scheduled blog posts that are due by now,
+// and sends the webhook for them. Each post is claimed first, so only one
+// app instance publishes it.
+func publishDuePosts(app *App, now time.Time) {
+	federate := !app.Config().App.Private && app.Config().App.Federation
+	if !federate && !app.Config().Webhook.Sends(config.WebhookPostPublished) {
+		return
+	}
+	posts, err := app.db.GetPostsDue(now)
+	if err != nil {
+		return
+	}
+	for i := range posts {
+		p := &posts[i]
+		if ok, err := app.db.ClaimScheduledPost(p.ID); err != nil || !ok {
+			continue
+		}
+		coll, err := app.db.GetCollectionByID(p.CollectionID.Int64)
+		if err != nil {
+			log.Error("Scheduler: Unable to get collection for post %s: %v", p.ID, err)
+			continue
+		}
+		coll.hostName = app.Config().App.Host
+		p.Collection = &CollectionObj{Collection: *coll}
+		log.Info("Scheduler: Publishing post %s", p.ID)
+		if federate {
+			go federatePost(app, p, coll.ID, false)
+		}
+		go sendPostWebhook(app, config.WebhookPostPublished, p)
+	}
+}
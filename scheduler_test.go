@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"testing"
+	"time"
+
+	"github.com/writeas/writefreely/config"
+)
+
+func TestPostScheduler(t *testing.T) {
+	app := newTestApp(config.New())
+	for _, tc := range []struct {
+		Interval string
+		Expected time.Duration
+	}{
+		{"1m", time.Minute},
+		{"90s", 90 * time.Second},
+		{"0", 0},
+		{"", 0},
+	} {
+		app.Config().App.ScheduledPublishInterval = tc.Interval
+		s := newPostScheduler(app)
+		if tc.Expected == 0 {
+			if s != nil {
+				t.Errorf("Interval %q: scheduler enabled; expected it off", tc.Interval)
+			}
+			continue
+		}
+		if s == nil || s.interval != tc.Expected {
+			t.Errorf("Interval %q: scheduler = %+v; expected interval %s", tc.Interval, s, tc.Expected)
+		}
+	}
+
+	// Each run publishes the posts due by the time it runs
+	interval := 20 * time.Millisecond
+	runs := make(chan time.Time, 3)
+	s := &postScheduler{interval: interval, publish: func(now time.Time) {
+		runs <- now
+	}}
+	stop := make(chan struct{})
+	start := time.Now()
+	go s.run(stop)
+	prev := start
+	for i := 0; i < 3; i++ {
+		now := <-runs
+		if d := now.Sub(prev); d < interval/2 {
+			t.Errorf("Run %d came %s after the one before; expected about %s", i+1, d, interval)
+		}
+		prev = now
+	}
+	close(stop)
+}
@@ -15,39 +15,47 @@ import (
 	"github.com/gorilla/sessions"
 	"github.com/writeas/web-core/log"
 	"net/http"
-	"strings"
 )
 
 const (
 	day           = 86400
 	sessionLength = 180 * day
-	cookieName    = "wfu"
 	cookieUserVal = "u"
 
 	blogPassCookieName = "ub"
 )
 
-// InitSession creates the cookie store. It depends on the keychain already
-// being loaded.
+// InitSession creates the session store: a cookie store by default, or a
+// Redis-backed one when configured. It depends on the keychain already being
+// loaded.
 func (app *App) InitSession() {
 	// Register complex data types we'll be storing in cookies
 	gob.Register(&User{})
 
-	// Create the cookie store
-	store := sessions.NewCookieStore(app.keys.CookieAuthKey, app.keys.CookieKey)
-	store.Options = &sessions.Options{
+	opts := &sessions.Options{
 		Path:     "/",
-		MaxAge:   sessionLength,
+		MaxAge:   app.Config().Session.MaxAge(),
 		HttpOnly: true,
-		Secure:   strings.HasPrefix(app.cfg.App.Host, "https://"),
+		Secure:   app.Config().Session.Secure(app.Config().App.Host),
+		SameSite: app.Config().Session.SameSite(),
+	}
+	if app.Config().Cache.IsRedis() {
+		store := newCacheStore(newRedisCache(app.Config().Cache), app.keys.CookieAuthKey, app.keys.CookieKey)
+		store.Options = opts
+		app.sessionStore = store
+		return
 	}
+
+	// Create the cookie store
+	store := sessions.NewCookieStore(app.keys.CookieAuthKey, app.keys.CookieKey)
+	store.Options = opts
 	app.sessionStore = store
 }
 
 func getSessionFlashes(app *App, w http.ResponseWriter, r *http.Request, session *sessions.Session) ([]string, error) {
 	var err error
 	if session == nil {
-		session, err = app.sessionStore.Get(r, cookieName)
+		session, err = app.sessionStore.Get(r, app.Config().Session.Name())
 		if err != nil {
 			return nil, err
 		}
@@ -69,7 +77,7 @@ func getSessionFlashes(app *App, w http.ResponseWriter, r *http.Request, session
 func addSessionFlash(app *App, w http.ResponseWriter, r *http.Request, m string, session *sessions.Session) error {
 	var err error
 	if session == nil {
-		session, err = app.sessionStore.Get(r, cookieName)
+		session, err = app.sessionStore.Get(r, app.Config().Session.Name())
 	}
 
 	if err != nil {
@@ -83,7 +91,7 @@ func addSessionFlash(app *App, w http.ResponseWriter, r *http.Request, m string,
 }
 
 func getUserAndSession(app *App, r *http.Request) (*User, *sessions.Session) {
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err == nil {
 		// Got the currently logged-in user
 		val := session.Values[cookieUserVal]
@@ -103,13 +111,13 @@ func getUserSession(app *App, r *http.Request) *User {
 }
 
 func saveUserSession(app *App, r *http.Request, w http.ResponseWriter) error {
-	session, err := app.sessionStore.Get(r, cookieName)
+	session, err := app.sessionStore.Get(r, app.Config().Session.Name())
 	if err != nil {
 		return ErrInternalCookieSession
 	}
 
 	// Extend the session
-	session.Options.MaxAge = int(sessionLength)
+	session.Options.MaxAge = app.Config().Session.MaxAge()
 
 	// Remove any information that accidentally got added
 	// FIXME: find where Plan information is getting saved to cookie.
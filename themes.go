@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+// installedThemes returns the names of the themes in the given directory,
+// which are the stylesheets served at /css/{theme}.css.
+func installedThemes(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var themes []string
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".css" {
+			themes = append(themes, strings.TrimSuffix(f.Name(), ".css"))
+		}
+	}
+	return themes, nil
+}
+
+// checkTheme makes sure the configured themes are installed, falling back
+// to the default theme when the site's isn't, and to the site's when the
+// blogs' isn't, instead of rendering unstyled pages.
+func checkTheme(app *App) {
+	dir := filepath.Join(app.Config().Server.StaticParentDir, staticDir, "css")
+	themes, err := installedThemes(dir)
+	if err != nil || len(themes) == 0 {
+		// Stylesheets haven't been built yet, so there's nothing to check
+		// against
+		return
+	}
+	if themesDir, err := app.Config().App.ThemesPath(); err == nil && themesDir != "" {
+		custom, err := installedThemes(themesDir)
+		if err != nil {
+			log.Error("Unable to read themes_dir: %v", err)
+		}
+		themes = append(custom, themes...)
+	}
+	app.fillConfig(func(cfg *config.Config) {
+		if err := cfg.App.ValidateTheme(themes); err != nil {
+			log.Error("[WARNING] %s. Using the %s theme instead.", err, config.DefaultTheme)
+			cfg.App.Theme = config.DefaultTheme
+		}
+		if err := cfg.App.ValidateBlogTheme(themes); err != nil {
+			log.Error("[WARNING] %s. Using the site theme for blogs instead.", err)
+			cfg.App.DefaultBlogTheme = ""
+		}
+	})
+}
+
+// customCSSURL is where the configured custom stylesheet is served. It's
+// outside of /css/ so it can't clash with a theme in themes_dir.
+const customCSSURL = "/brand/custom.css"
+
+// loadCustomCSS reads the configured custom stylesheet, if there is one.
+func loadCustomCSS(app *App) {
+	if app.Config().App.CustomCSSPath == "" {
+		return
+	}
+	fi, err := os.Stat(app.Config().App.CustomCSSPath)
+	if err != nil {
+		log.Error("Unable to load custom CSS: %v", err)
+		return
+	}
+	css, err := ioutil.ReadFile(app.Config().App.CustomCSSPath)
+	if err != nil {
+		log.Error("Unable to load custom CSS: %v", err)
+		return
+	}
+	app.customCSS = css
+	app.customCSSMod = fi.ModTime()
+}
+
+// handleCustomCSS serves the custom stylesheet loaded at startup.
+func (app *App) handleCustomCSS(w http.ResponseWriter, r *http.Request) {
+	if app.customCSS == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	http.ServeContent(w, r, customCSSURL, app.customCSSMod, bytes.NewReader(app.customCSS))
+}
+
+// themeFileServer serves stylesheets under /css/ from the given themes
+// directory when it has them, and from the static handler otherwise.
+func themeFileServer(dir string, static http.Handler) http.Handler {
+	themes := http.StripPrefix("/css", http.FileServer(http.Dir(dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f, err := http.Dir(dir).Open(strings.TrimPrefix(r.URL.Path, "/css")); err == nil {
+			fi, err := f.Stat()
+			f.Close()
+			if err == nil && !fi.IsDir() {
+				themes.ServeHTTP(w, r)
+				return
+			}
+		}
+		static.ServeHTTP(w, r)
+	})
+}
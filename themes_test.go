@@ -0,0 +1,192 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/writeas/writefreely/config"
+	"github.com/writeas/writefreely/page"
+)
+
+func TestCheckTheme(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfthemes")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cssDir := filepath.Join(dir, staticDir, "css")
+	if err = os.MkdirAll(cssDir, 0755); err != nil {
+		t.Fatalf("Unable to create css dir: %v", err)
+	}
+	for _, f := range []string{"write.css", "dark.css", "notes.txt"} {
+		if err = ioutil.WriteFile(filepath.Join(cssDir, f), nil, 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", f, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		Theme, Expected string
+	}{
+		{"dark", "dark"},
+		{"write", "write"},
+		{"drak", "write"},
+		{"notes", "write"},
+	} {
+		app := newTestApp(config.New())
+		app.Config().Server.StaticParentDir = dir
+		app.Config().App.Theme = tc.Theme
+		prev := app.Config()
+		checkTheme(app)
+		if app.Config().App.Theme != tc.Expected {
+			t.Errorf("Theme %s: got %s; expected %s", tc.Theme, app.Config().App.Theme, tc.Expected)
+		}
+		// Requests may still be reading the previous config
+		if prev.App.Theme != tc.Theme {
+			t.Errorf("Theme %s: checkTheme changed the running config in place", tc.Theme)
+		}
+	}
+
+	for _, tc := range []struct {
+		BlogTheme, Expected string
+	}{
+		{"", ""},
+		{"dark", "dark"},
+		{"drak", ""},
+	} {
+		app := newTestApp(config.New())
+		app.Config().Server.StaticParentDir = dir
+		app.Config().App.DefaultBlogTheme = tc.BlogTheme
+		checkTheme(app)
+		if app.Config().App.DefaultBlogTheme != tc.Expected {
+			t.Errorf("Blog theme %s: got %s; expected %s", tc.BlogTheme, app.Config().App.DefaultBlogTheme, tc.Expected)
+		}
+	}
+}
+
+func TestThemeFileServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfthemes")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "custom.css"), []byte("body{}"), 0644); err != nil {
+		t.Fatalf("Unable to write theme: %v", err)
+	}
+	static := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("static"))
+	})
+
+	for _, tc := range []struct {
+		Path, Expected string
+	}{
+		{"/css/custom.css", "body{}"},
+		{"/css/write.css", "static"},
+		{"/css/../../../etc/passwd", "static"},
+		{"/css/", "static"},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.URL.Path = tc.Path
+		themeFileServer(dir, static).ServeHTTP(w, r)
+		if body := w.Body.String(); body != tc.Expected {
+			t.Errorf("%s: body = %q; expected %q", tc.Path, body, tc.Expected)
+		}
+	}
+}
+
+func TestCustomCSS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wfcustomcss")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cssPath := filepath.Join(dir, "brand.css")
+	if err = ioutil.WriteFile(cssPath, []byte("body{color:teal}"), 0644); err != nil {
+		t.Fatalf("Unable to write custom CSS: %v", err)
+	}
+
+	initPage("", filepath.Join(pagesDir, "404.tmpl"), "404.tmpl")
+	for _, tc := range []struct {
+		Path     string
+		Expected bool
+	}{
+		{"", false},
+		{cssPath, true},
+	} {
+		app := newTestApp(config.New())
+		app.Config().App.CustomCSSPath = tc.Path
+		loadCustomCSS(app)
+
+		var buf bytes.Buffer
+		if err = renderPage(&buf, "404.tmpl", page.StaticPage{AppCfg: app.Config().App}); err != nil {
+			t.Fatalf("Unable to render page: %v", err)
+		}
+		if linked := strings.Contains(buf.String(), `href="`+app.Config().App.Host+customCSSURL+`"`); linked != tc.Expected {
+			t.Errorf("Path %q: custom CSS linked = %t; expected %t", tc.Path, linked, tc.Expected)
+		}
+
+		w := httptest.NewRecorder()
+		app.handleCustomCSS(w, httptest.NewRequest("GET", customCSSURL, nil))
+		if tc.Expected && w.Body.String() != "body{color:teal}" {
+			t.Errorf("Path %q: served %q", tc.Path, w.Body.String())
+		} else if !tc.Expected && w.Code != http.StatusNotFound {
+			t.Errorf("Path %q: status = %d; expected %d", tc.Path, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestWebFontSource(t *testing.T) {
+	initPage("", filepath.Join(pagesDir, "404.tmpl"), "404.tmpl")
+	for _, tc := range []struct {
+		Source     string
+		JSDisabled bool
+		Expected   string
+	}{
+		{config.WebFontsLocal, false, "urls: [ '/css/fonts.css' ]"},
+		{config.WebFontsLocal, true, "/css/fonts.css"},
+		{config.WebFontsCDN, false, "google: { families:"},
+		{config.WebFontsCDN, true, "https://fonts.googleapis.com/css"},
+		{config.WebFontsNone, false, ""},
+		{config.WebFontsNone, true, ""},
+	} {
+		cfg := config.New()
+		cfg.App.WebFontSource = tc.Source
+		cfg.App.JSDisabled = tc.JSDisabled
+
+		var buf bytes.Buffer
+		if err := renderPage(&buf, "404.tmpl", page.StaticPage{AppCfg: cfg.App}); err != nil {
+			t.Fatalf("Unable to render page: %v", err)
+		}
+		out := buf.String()
+		if tc.Expected != "" && !strings.Contains(out, tc.Expected) {
+			t.Errorf("Source %s (JS disabled: %t): page doesn't include %q", tc.Source, tc.JSDisabled, tc.Expected)
+		}
+		for _, f := range []string{"fonts.css", "googleapis", "webfont.js"} {
+			if tc.Expected == "" && strings.Contains(out, f) {
+				t.Errorf("Source %s (JS disabled: %t): page loads fonts with %s", tc.Source, tc.JSDisabled, f)
+			}
+		}
+		if tc.Source == config.WebFontsCDN && strings.Contains(out, "fonts.css") {
+			t.Errorf("Source %s (JS disabled: %t): page loads local fonts", tc.Source, tc.JSDisabled)
+		}
+		if tc.Source == config.WebFontsLocal && strings.Contains(out, "googleapis") {
+			t.Errorf("Source %s (JS disabled: %t): page loads fonts from the CDN", tc.Source, tc.JSDisabled)
+		}
+	}
+}
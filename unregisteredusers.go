@@ -48,16 +48,24 @@ func handleWebSignup(app *App, w http.ResponseWriter, r *http.Request) error {
 	ur.Normalize = true
 
 	to := "/"
-	if app.cfg.App.SimpleNav {
+	if app.Config().App.SimpleNav {
 		to = "/new"
 	}
 	if ur.InviteCode != "" {
 		to = "/invite/" + ur.InviteCode
 	}
-	_, err := signupWithRegistration(app, ur, w, r)
+	au, err := signupWithRegistration(app, ur, w, r)
+	if err == nil && au.User.IsPending() {
+		session, _ := app.sessionStore.Get(r, app.Config().Session.Name())
+		if session != nil {
+			session.AddFlash("Your account was created, and is awaiting approval by an admin.")
+			session.Save(r, w)
+		}
+		return impart.HTTPError{http.StatusFound, "/login"}
+	}
 	if err != nil {
 		if err, ok := err.(impart.HTTPError); ok {
-			session, _ := app.sessionStore.Get(r, cookieName)
+			session, _ := app.sessionStore.Get(r, app.Config().Session.Name())
 			if session != nil {
 				session.AddFlash(err.Message)
 				session.Save(r, w)
@@ -0,0 +1,92 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/writeas/impart"
+)
+
+// isBodyTooLarge returns whether err came from reading past the limit of an
+// http.MaxBytesReader. Go 1.16 doesn't give the error its own type, so its
+// message is checked instead.
+func isBodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
+// apiBodyLimit rejects API requests with bodies over max bytes with a 413
+// Request Entity Too Large error. Multipart bodies, like file uploads, are
+// limited to uploadMax instead, or to max when uploadMax is zero. A zero
+// limit is unlimited.
+func apiBodyLimit(max, uploadMax int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") || r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			multipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+			limit := max
+			if multipart && uploadMax > 0 {
+				limit = uploadMax
+			}
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			tooLarge := impart.HTTPError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Request is too large. The maximum size is %s.", friendlyBytes(limit))}
+			if r.ContentLength > limit {
+				impart.WriteError(w, tooLarge)
+				return
+			}
+			if multipart {
+				// Uploads can be too big to hold in memory, so they're left
+				// for the handler to read, up to the limit
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Read the body up front, so handlers that fail to decode a
+			// truncated body can't return a misleading 400 instead
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+			r.Body.Close()
+			if err != nil {
+				if isBodyTooLarge(err) {
+					impart.WriteError(w, tooLarge)
+					return
+				}
+				impart.WriteError(w, impart.HTTPError{http.StatusBadRequest, "Unable to read request body."})
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// friendlyBytes returns the given number of bytes in the largest whole unit.
+func friendlyBytes(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%d GiB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newUploadRequest returns a multipart request with a file of the given size,
+// along with the total size of the request body.
+func newUploadRequest(t *testing.T, fileSize int) (*http.Request, int64) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "photo.jpg")
+	if err != nil {
+		t.Fatalf("Unable to create form file: %v", err)
+	}
+	fw.Write(bytes.Repeat([]byte("a"), fileSize))
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r, int64(body.Len())
+}
+
+func TestFriendlyBytes(t *testing.T) {
+	for n, expected := range map[int64]string{
+		10 << 20: "10 MiB",
+		1 << 30:  "1 GiB",
+		1536:     "1536 bytes",
+		2048:     "2 KiB",
+		500:      "500 bytes",
+	} {
+		if s := friendlyBytes(n); s != expected {
+			t.Errorf("friendlyBytes(%d) = %s; expected %s", n, s, expected)
+		}
+	}
+}
+
+func TestAPIBodyLimit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				if isBodyTooLarge(err) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					return
+				}
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		}
+		var post map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(post["body"]))
+	})
+	_, uploadSize := newUploadRequest(t, 1024)
+	h := apiBodyLimit(64, uploadSize)(handler)
+	for _, tc := range []struct {
+		Name     string
+		Path     string
+		Body     string
+		Chunked  bool
+		Expected int
+	}{
+		{"Under the limit", "/api/posts", `{"body": "Hello"}`, false, http.StatusOK},
+		{"Over the limit", "/api/posts", `{"body": "` + strings.Repeat("a", 64) + `"}`, false, http.StatusRequestEntityTooLarge},
+		{"Over the limit without a length", "/api/posts", `{"body": "` + strings.Repeat("a", 64) + `"}`, true, http.StatusRequestEntityTooLarge},
+		{"Outside the API", "/auth/login", `{"body": "` + strings.Repeat("a", 64) + `"}`, false, http.StatusOK},
+	} {
+		r := httptest.NewRequest("POST", tc.Path, strings.NewReader(tc.Body))
+		r.Header.Set("Content-Type", "application/json")
+		if tc.Chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.Expected {
+			t.Errorf("%s: status = %d; expected %d", tc.Name, w.Code, tc.Expected)
+		}
+	}
+
+	// Multipart bodies get the upload limit, or the API limit without one
+	for _, tc := range []struct {
+		Name      string
+		UploadMax int64
+		FileSize  int
+		Chunked   bool
+		Expected  int
+	}{
+		{"Multipart under the upload limit", uploadSize, 1024, false, http.StatusOK},
+		{"Multipart over the upload limit", uploadSize, 1025, false, http.StatusRequestEntityTooLarge},
+		{"Multipart over the upload limit without a length", uploadSize, 1025, true, http.StatusRequestEntityTooLarge},
+		{"Multipart over the API limit", 0, 1024, false, http.StatusRequestEntityTooLarge},
+	} {
+		r, _ := newUploadRequest(t, tc.FileSize)
+		if tc.Chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		apiBodyLimit(64, tc.UploadMax)(handler).ServeHTTP(w, r)
+		if w.Code != tc.Expected {
+			t.Errorf("%s: status = %d; expected %d", tc.Name, w.Code, tc.Expected)
+		}
+	}
+}
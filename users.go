@@ -24,6 +24,7 @@ type UserStatus int
 const (
 	UserActive = iota
 	UserSilenced
+	UserPending
 )
 
 type (
@@ -130,3 +131,9 @@ func (u *User) IsAdmin() bool {
 func (u *User) IsSilenced() bool {
 	return u.Status&UserSilenced != 0
 }
+
+// IsPending returns whether the user signed up while registration required
+// approval, and hasn't been approved by an admin yet.
+func (u *User) IsPending() bool {
+	return u.Status&UserPending != 0
+}
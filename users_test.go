@@ -0,0 +1,37 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import "testing"
+
+func TestCheckLoginAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		Name    string
+		Status  UserStatus
+		Pending bool
+	}{
+		{"Active", UserActive, false},
+		{"Silenced", UserSilenced, false},
+		{"Pending", UserPending, true},
+		{"Pending and silenced", UserPending | UserSilenced, true},
+	} {
+		u := &User{Username: "matt", Status: tc.Status}
+		if u.IsPending() != tc.Pending {
+			t.Errorf("%s: IsPending = %t; expected %t", tc.Name, u.IsPending(), tc.Pending)
+		}
+		err := checkLoginAllowed(u)
+		if tc.Pending && err != ErrUserPending {
+			t.Errorf("%s: login error = %v; expected %v", tc.Name, err, ErrUserPending)
+		} else if !tc.Pending && err != nil {
+			t.Errorf("%s: login error = %v; expected none", tc.Name, err)
+		}
+	}
+}
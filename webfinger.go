@@ -56,7 +56,7 @@ func (wfr wfResolver) FindUser(username string, host, requestHost string, r []we
 	}
 	// Only return information if site has federation enabled.
 	// TODO: enable two levels of federation? Unlisted or Public on timelines?
-	if !wfr.cfg.App.Federation {
+	if !wfr.cfg.App.Federation || !c.Federates() {
 		return nil, wfUserNotFoundErr
 	}
 
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/writeas/web-core/log"
+	"github.com/writeas/writefreely/config"
+)
+
+const (
+	// webhookSignatureHeader holds the hex-encoded HMAC-SHA256 of the
+	// payload, keyed with the webhook secret, as in sha256=abc123.
+	webhookSignatureHeader = "X-WriteFreely-Signature"
+
+	webhookTimeout = 15 * time.Second
+)
+
+// webhookRetryDelay is how long to wait before retrying a failed delivery,
+// which grows with each attempt.
+var webhookRetryDelay = 5 * time.Second
+
+// webhookPayload is the JSON body sent to the webhook URL for an event.
+type webhookPayload struct {
+	Event   string      `json:"event"`
+	Created time.Time   `json:"created"`
+	Post    *PublicPost `json:"post"`
+}
+
+// sendPostWebhook sends the webhook for the given post event, if it's
+// configured to be, retrying failed deliveries.
+func sendPostWebhook(app *App, event string, p *PublicPost) {
+	wc := app.Config().Webhook
+	if !wc.Sends(event) {
+		return
+	}
+	body, err := json.Marshal(webhookPayload{
+		Event:   event,
+		Created: time.Now().UTC(),
+		Post:    p,
+	})
+	if err != nil {
+		log.Error("Webhook: Unable to encode %s payload: %v", event, err)
+		return
+	}
+
+	c := &http.Client{Timeout: webhookTimeout}
+	for attempt := 0; attempt <= wc.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * webhookRetryDelay)
+		}
+		if err = postWebhook(c, wc, app.Config().App.Host, body); err == nil {
+			return
+		}
+	}
+	log.Error("Webhook: Unable to send %s for post %s after %d attempt(s): %v", event, p.ID, wc.Retries+1, err)
+}
+
+// postWebhook delivers a single webhook payload.
+func postWebhook(c *http.Client, wc config.WebhookCfg, hostName string, body []byte) error {
+	req, err := http.NewRequest("POST", wc.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Go ("+serverSoftware+"/"+softwareVer+"; +"+hostName+")")
+	if wc.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+webhookSignature(wc.Secret, body))
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", wc.URL, resp.Status)
+	}
+	return nil
+}
+
+// webhookSignature returns the hex-encoded HMAC-SHA256 of the body, keyed
+// with the secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright © 2019 A Bunch Tell LLC.
+ *
+ * This file is part of WriteFreely.
+ *
+ * WriteFreely is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License, included
+ * in the LICENSE file in this source code package.
+ */
+
+package writefreely
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/writeas/writefreely/config"
+)
+
+func TestPostWebhook(t *testing.T) {
+	defer func(d time.Duration) { webhookRetryDelay = d }(webhookRetryDelay)
+	webhookRetryDelay = time.Millisecond
+
+	const secret = "0123456789abcdef"
+	var mu sync.Mutex
+	var attempts, failures int
+	var received webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if sig := r.Header.Get(webhookSignatureHeader); !hmac.Equal([]byte(sig), []byte(expected)) {
+			t.Errorf("Signature = %q; expected %q", sig, expected)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q; expected application/json", ct)
+		}
+		if attempts <= failures {
+			http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Unable to decode payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	p := &PublicPost{Post: &Post{ID: "abcdefghij", Slug: null.NewString("hello-world", true)}}
+	for _, tc := range []struct {
+		Name     string
+		Events   []string
+		Retries  int
+		Failures int
+		Attempts int
+	}{
+		{"Delivered", nil, 0, 0, 1},
+		{"Retried", nil, 2, 2, 3},
+		{"Gave up", nil, 1, 5, 2},
+		{"Listed event", []string{config.WebhookPostPublished}, 0, 0, 1},
+		{"Unlisted event", []string{config.WebhookPostDeleted}, 0, 0, 0},
+	} {
+		cfg := config.New()
+		cfg.Webhook = config.WebhookCfg{URL: srv.URL, Secret: secret, Events: tc.Events, Retries: tc.Retries}
+		mu.Lock()
+		attempts, failures, received = 0, tc.Failures, webhookPayload{}
+		mu.Unlock()
+
+		sendPostWebhook(newTestApp(cfg), config.WebhookPostPublished, p)
+
+		mu.Lock()
+		if attempts != tc.Attempts {
+			t.Errorf("%s: %d attempts; expected %d", tc.Name, attempts, tc.Attempts)
+		}
+		delivered := tc.Attempts > tc.Failures
+		if delivered && (received.Event != config.WebhookPostPublished || received.Post == nil || received.Post.ID != p.ID) {
+			t.Errorf("%s: received %+v", tc.Name, received)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	var sig []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header[webhookSignatureHeader]
+	}))
+	defer srv.Close()
+
+	err := postWebhook(srv.Client(), config.WebhookCfg{URL: srv.URL}, "https://blog.example.com", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("postWebhook failed: %v", err)
+	}
+	if len(sig) > 0 {
+		t.Errorf("Payload without a secret was signed: %s", strings.Join(sig, ", "))
+	}
+}